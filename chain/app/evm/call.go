@@ -0,0 +1,219 @@
+// Copyright © 2017 ZhongAn Technology
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evm
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	rtypes "github.com/dappledger/AnnChain/chain/types"
+	"github.com/dappledger/AnnChain/eth/accounts/abi"
+	"github.com/dappledger/AnnChain/eth/common"
+	"github.com/dappledger/AnnChain/eth/common/math"
+	"github.com/dappledger/AnnChain/eth/core"
+	estate "github.com/dappledger/AnnChain/eth/core/state"
+	etypes "github.com/dappledger/AnnChain/eth/core/types"
+	"github.com/dappledger/AnnChain/eth/core/vm"
+	"github.com/dappledger/AnnChain/eth/crypto"
+	"github.com/dappledger/AnnChain/eth/params"
+	"github.com/dappledger/AnnChain/eth/rlp"
+	gtypes "github.com/dappledger/AnnChain/gemmill/types"
+)
+
+var revertSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+
+// callEnv returns a private copy of the state and the header a simulated call runs on,
+// height 0 means the latest committed state
+func (app *EVMApp) callEnv(height uint64) (*estate.StateDB, *etypes.Header, error) {
+	if height == 0 {
+		app.stateMtx.Lock()
+		defer app.stateMtx.Unlock()
+		header := app.currentHeader
+		if header == nil {
+			header = &etypes.Header{
+				Difficulty: big.NewInt(0),
+				GasLimit:   math.MaxBig256.Uint64(),
+				Time:       big.NewInt(0),
+				Number:     big.NewInt(0),
+			}
+		}
+		return app.state.Copy(), header, nil
+	}
+
+	//appHash save in next block AppHash
+	blockMeta, err := app.core.GetBlockMeta(int64(height + 1))
+	if err != nil {
+		return nil, nil, err
+	}
+	trieRoot := EmptyTrieRoot
+	if len(blockMeta.Header.AppHash) > 0 {
+		trieRoot = common.BytesToHash(blockMeta.Header.AppHash)
+	}
+	state, err := estate.New(trieRoot, estate.NewDatabase(app.stateDb))
+	if err != nil {
+		return nil, nil, err
+	}
+	return state, makeETHHeader(blockMeta.Header), nil
+}
+
+func applyOverrides(state *estate.StateDB, overrides []rtypes.StateOverride) {
+	for _, o := range overrides {
+		if o.Balance != nil {
+			state.SetBalance(o.Address, o.Balance)
+		}
+		if len(o.Code) > 0 {
+			state.SetCode(o.Address, o.Code)
+		}
+		for _, s := range o.Storage {
+			state.SetState(o.Address, s.Key, s.Value)
+		}
+	}
+}
+
+func (app *EVMApp) applyCall(state *estate.StateDB, header *etypes.Header, msg etypes.Message) ([]byte, uint64, bool, error) {
+	bc := NewBlockChain(app.stateDb)
+	envCxt := core.NewEVMContext(msg, header, bc, nil)
+	vmEnv := vm.NewEVM(envCxt, state, app.chainConfig, simulateConfig)
+	gp := new(core.GasPool).AddGas(msg.Gas())
+	return core.ApplyMessage(vmEnv, msg, gp)
+}
+
+func (app *EVMApp) call(tx *etypes.Transaction, from *common.Address, overrides []rtypes.StateOverride, height uint64) gtypes.Result {
+	sender, err := app.callSender(tx, from)
+	if err != nil {
+		return gtypes.NewError(gtypes.CodeType_BaseInvalidInput, err.Error())
+	}
+	state, header, err := app.callEnv(height)
+	if err != nil {
+		return gtypes.NewError(gtypes.CodeType_BaseInvalidInput, err.Error())
+	}
+	applyOverrides(state, overrides)
+
+	msg := etypes.NewMessage(sender, tx.To(), 0, tx.Value(), tx.Gas(), tx.GasPrice(), tx.Data(), false)
+	ret, gasUsed, failed, err := app.applyCall(state, header, msg)
+	if err != nil {
+		return gtypes.NewError(gtypes.CodeType_InternalError, err.Error())
+	}
+	if failed {
+		return gtypes.NewResult(gtypes.CodeType_InvalidTx, ret, revertLog(ret))
+	}
+	return gtypes.NewResultOK(ret, fmt.Sprintf("gasUsed:%d", gasUsed))
+}
+
+// estimateGas binary searches the lowest gas limit the tx executes successfully with
+func (app *EVMApp) estimateGas(tx *etypes.Transaction, sender *common.Address, overrides []rtypes.StateOverride) gtypes.Result {
+	from, err := app.callSender(tx, sender)
+	if err != nil {
+		return gtypes.NewError(gtypes.CodeType_BaseInvalidInput, err.Error())
+	}
+	base, header, err := app.callEnv(0)
+	if err != nil {
+		return gtypes.NewError(gtypes.CodeType_InternalError, err.Error())
+	}
+	applyOverrides(base, overrides)
+
+	lo, hi := params.TxGas-1, tx.Gas()
+	if hi < params.TxGas || hi > EVMGasLimit {
+		hi = EVMGasLimit
+	}
+	// the sender has to be able to pay for whatever gas we try
+	if tx.GasPrice().Sign() > 0 {
+		available := new(big.Int).Sub(base.GetBalance(from), tx.Value())
+		allowance := new(big.Int).Div(available, tx.GasPrice())
+		if allowance.IsUint64() && allowance.Uint64() < hi {
+			hi = allowance.Uint64()
+		}
+	}
+	limit := hi
+
+	executable := func(gas uint64) ([]byte, bool, error) {
+		msg := etypes.NewMessage(from, tx.To(), 0, tx.Value(), gas, tx.GasPrice(), tx.Data(), false)
+		ret, _, failed, err := app.applyCall(base.Copy(), header, msg)
+		if err != nil {
+			return nil, false, err
+		}
+		return ret, !failed, nil
+	}
+
+	for lo+1 < hi {
+		mid := (hi + lo) / 2
+		if _, ok, _ := executable(mid); ok {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	if hi == limit {
+		ret, ok, err := executable(hi)
+		if err != nil {
+			return gtypes.NewError(gtypes.CodeType_InternalError, err.Error())
+		}
+		if !ok {
+			return gtypes.NewResult(gtypes.CodeType_InvalidTx, ret, revertLog(ret))
+		}
+	}
+
+	data, err := rlp.EncodeToBytes(hi)
+	if err != nil {
+		return gtypes.NewError(gtypes.CodeType_InternalError, err.Error())
+	}
+	return gtypes.NewResultOK(data, "")
+}
+
+func (app *EVMApp) queryCall(load []byte, estimate bool) gtypes.Result {
+	args := &rtypes.CallArgs{}
+	if err := rlp.DecodeBytes(load, args); err != nil {
+		return gtypes.NewError(gtypes.CodeType_BaseInvalidInput, err.Error())
+	}
+	tx := new(etypes.Transaction)
+	if err := rlp.DecodeBytes(args.Tx, tx); err != nil {
+		return gtypes.NewError(gtypes.CodeType_BaseInvalidInput, err.Error())
+	}
+	if estimate {
+		return app.estimateGas(tx, args.From, args.Overrides)
+	}
+	return app.call(tx, args.From, args.Overrides, 0)
+}
+
+// callSender is from if given, otherwise the signer of tx
+func (app *EVMApp) callSender(tx *etypes.Transaction, from *common.Address) (common.Address, error) {
+	if from != nil {
+		return *from, nil
+	}
+	return app.Signer.Sender(tx)
+}
+
+// revertLog describes a failed call, with the reason if the contract reverted with Error(string)
+func revertLog(ret []byte) string {
+	if reason, ok := unpackRevertReason(ret); ok {
+		return "execution reverted: " + reason
+	}
+	return "execution reverted"
+}
+
+func unpackRevertReason(ret []byte) (string, bool) {
+	if len(ret) < 4 || !bytes.Equal(ret[:4], revertSelector) {
+		return "", false
+	}
+	typ, err := abi.NewType("string", nil)
+	if err != nil {
+		return "", false
+	}
+	var reason string
+	if err := (abi.Arguments{{Type: typ}}).Unpack(&reason, ret[4:]); err != nil {
+		return "", false
+	}
+	return reason, true
+}
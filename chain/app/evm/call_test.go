@@ -0,0 +1,176 @@
+// Copyright © 2017 ZhongAn Technology
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evm
+
+import (
+	"math/big"
+	"testing"
+
+	rtypes "github.com/dappledger/AnnChain/chain/types"
+	"github.com/dappledger/AnnChain/eth/common"
+	estate "github.com/dappledger/AnnChain/eth/core/state"
+	etypes "github.com/dappledger/AnnChain/eth/core/types"
+	"github.com/dappledger/AnnChain/eth/core/vm"
+	"github.com/dappledger/AnnChain/eth/ethdb"
+	"github.com/dappledger/AnnChain/eth/params"
+	"github.com/dappledger/AnnChain/eth/rlp"
+	gtypes "github.com/dappledger/AnnChain/gemmill/types"
+)
+
+func TestUnpackRevertReason(t *testing.T) {
+	// revert("not owner")
+	ret := common.Hex2Bytes("08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"0000000000000000000000000000000000000000000000000000000000000009" +
+		"6e6f74206f776e65720000000000000000000000000000000000000000000000")
+	reason, ok := unpackRevertReason(ret)
+	if !ok || reason != "not owner" {
+		t.Fatalf("expected reason 'not owner', got %q (%v)", reason, ok)
+	}
+	if log := revertLog(ret); log != "execution reverted: not owner" {
+		t.Fatalf("unexpected revert log %q", log)
+	}
+
+	if _, ok := unpackRevertReason(common.Hex2Bytes("deadbeef")); ok {
+		t.Fatal("unexpected reason for a non Error(string) payload")
+	}
+	if log := revertLog(nil); log != "execution reverted" {
+		t.Fatalf("unexpected revert log %q", log)
+	}
+}
+
+func newCallTestApp(t *testing.T, code map[common.Address][]byte, funded common.Address) *EVMApp {
+	db := ethdb.NewMemDatabase()
+	state, err := estate.New(common.Hash{}, estate.NewDatabase(db))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for addr, c := range code {
+		state.SetCode(addr, c)
+	}
+	state.SetBalance(funded, big.NewInt(1000000))
+	return &EVMApp{state: state, stateDb: db, chainConfig: params.MainnetChainConfig, Signer: new(etypes.HomesteadSigner)}
+}
+
+func TestEstimateGas(t *testing.T) {
+	var (
+		from     = common.HexToAddress("0x0a")
+		to       = common.HexToAddress("0x0b")
+		reverter = common.HexToAddress("0x0d")
+		// return(0, 32), creating a contract of 32 bytes
+		initCode = common.Hex2Bytes("60206000f3")
+	)
+	app := newCallTestApp(t, map[common.Address][]byte{
+		reverter: common.Hex2Bytes("60006000fd"), // revert(0, 0)
+	}, from)
+	newTx := func(to *common.Address, gas uint64, gasPrice int64) *etypes.Transaction {
+		if to == nil {
+			return etypes.NewContractCreation(0, new(big.Int), gas, big.NewInt(gasPrice), initCode)
+		}
+		return etypes.NewTransaction(0, *to, new(big.Int), gas, big.NewInt(gasPrice), nil)
+	}
+	estimate := func(to *common.Address, gas uint64, gasPrice int64) gtypes.Result {
+		return app.estimateGas(newTx(to, gas, gasPrice), &from, nil)
+	}
+	gasOf := func(res gtypes.Result) uint64 {
+		if !res.IsOK() {
+			t.Fatalf("expected an estimate, got %v %s", res.Code, res.Log)
+		}
+		var gas uint64
+		if err := rlp.DecodeBytes(res.Data, &gas); err != nil {
+			t.Fatal(err)
+		}
+		return gas
+	}
+
+	if gas := gasOf(estimate(&to, 0, 0)); gas != params.TxGas {
+		t.Fatalf("expected %d gas for a transfer, got %d", params.TxGas, gas)
+	}
+	// the estimate is the lowest gas the tx succeeds with, here the one
+	// storing the code
+	gas := gasOf(estimate(nil, 0, 0))
+	run := func(gas uint64) bool {
+		return app.call(newTx(nil, gas, 0), &from, nil, 0).IsOK()
+	}
+	if gas <= params.TxGas || !run(gas) || run(gas-1) {
+		t.Fatalf("expected %d to be the lowest gas creating", gas)
+	}
+
+	// the tx gas bounds the search, so does the balance of the sender
+	if res := estimate(nil, gas-1, 0); res.Code != gtypes.CodeType_InvalidTx {
+		t.Fatalf("expected the tx gas to be too low, got %v", res.Code)
+	}
+	if res := estimate(nil, 0, 1000000/int64(gas-1000)); res.Code != gtypes.CodeType_InvalidTx {
+		t.Fatalf("expected the balance to be too low, got %v", res.Code)
+	}
+	if res := estimate(&reverter, 0, 0); res.Code != gtypes.CodeType_InvalidTx || res.Log != "execution reverted" {
+		t.Fatalf("expected a revert, got %v %s", res.Code, res.Log)
+	}
+}
+
+func TestCallOverrides(t *testing.T) {
+	var (
+		from     = common.HexToAddress("0x0a")
+		contract = common.HexToAddress("0x0b")
+	)
+	app := newCallTestApp(t, nil, from)
+	call := func(overrides []rtypes.StateOverride) gtypes.Result {
+		tx := etypes.NewTransaction(0, contract, new(big.Int), 100000, new(big.Int), nil)
+		return app.call(tx, &from, overrides, 0)
+	}
+
+	// return(sload(0)) and the balance of the contract
+	overrides := []rtypes.StateOverride{{
+		Address: contract,
+		Balance: big.NewInt(7),
+		Code:    common.Hex2Bytes("600054600052303160205260406000f3"),
+		Storage: []rtypes.StorageOverride{{Key: common.Hash{}, Value: common.HexToHash("0x2a")}},
+	}}
+	res := call(overrides)
+	if !res.IsOK() || len(res.Data) != 64 {
+		t.Fatalf("unexpected result %v %x", res.Code, res.Data)
+	}
+	if common.BytesToHash(res.Data[:32]) != common.HexToHash("0x2a") || new(big.Int).SetBytes(res.Data[32:]).Int64() != 7 {
+		t.Fatalf("expected the overridden storage and balance, got %x", res.Data)
+	}
+	// the overrides don't reach the state
+	if res := call(nil); !res.IsOK() || len(res.Data) != 0 || app.state.GetBalance(contract).Sign() != 0 {
+		t.Fatalf("expected no code without the overrides, got %x", res.Data)
+	}
+}
+
+func TestCallSimulatesAdminOps(t *testing.T) {
+	from := common.HexToAddress("0x0a")
+	app := newCallTestApp(t, nil, from)
+	var simulated []bool
+	vm.DefaultAdminContract.SetCallback(func(app *vm.AdminDBApp, data []byte) error {
+		simulated = append(simulated, app.Simulated())
+		return nil
+	})
+	defer vm.DefaultAdminContract.SetCallback(nil)
+
+	input := append(append(common.LeftPadBytes([]byte{21}, 32), from.Bytes()...), 'x')
+	tx := etypes.NewTransaction(0, common.BytesToAddress([]byte{254}), new(big.Int), 0, new(big.Int), input)
+	if res := app.estimateGas(tx, &from, nil); !res.IsOK() {
+		t.Fatalf("unexpected estimate %v %s", res.Code, res.Log)
+	}
+	if len(simulated) == 0 {
+		t.Fatal("expected the admin contract to run")
+	}
+	for _, s := range simulated {
+		if !s {
+			t.Fatal("expected every estimate run to be simulated")
+		}
+	}
+}
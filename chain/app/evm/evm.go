@@ -17,9 +17,11 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"math/big"
 	"path/filepath"
+	"sort"
 	"sync"
 
 	"go.uber.org/zap"
@@ -78,11 +80,24 @@ func (bc *BlockChainEvm) GetHeader(hash common.Hash, number uint64) *etypes.Head
 
 var (
 	ReceiptsPrefix = []byte("receipts-")
+	// freeze events are kept by sequence, FreezeEventsCountKey says how many
+	// there are and the height of the last one. FreezeEventsAddrPrefix with an
+	// address keeps how many events it has, and with an index after it the
+	// sequence of each.
+	FreezeEventsPrefix     = []byte("freeze-events-")
+	FreezeEventsCountKey   = []byte("freeze-events-count")
+	FreezeEventsAddrPrefix = []byte("freeze-events-addr-")
 
 	EmptyTrieRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
 
 	IsHomestead = true
 	evmConfig   = vm.Config{EVMGasLimit: EVMGasLimit}
+	// simulateConfig is for the calls and replays, whose admin txs mustn't
+	// stage ops in the node
+	simulateConfig = vm.Config{EVMGasLimit: EVMGasLimit, SimulateAdmin: true}
+	// replayConfig is for the replays of committed txs, whose admin ops were
+	// signed by the validators of their block, not of the current one
+	replayConfig = vm.Config{EVMGasLimit: EVMGasLimit, SimulateAdmin: true, ReplayAdmin: true}
 
 	errQuitExecute = fmt.Errorf("quit executing block")
 )
@@ -190,6 +205,11 @@ func (app *EVMApp) Start() (err error) {
 		log.Error("fail to new state", zap.Error(err))
 		return
 	}
+	if err = checkNativeCode(app.state); err != nil {
+		app.Stop()
+		log.Error("invalid precompiles", zap.Error(err))
+		return
+	}
 
 	return nil
 }
@@ -243,6 +263,15 @@ func (app *EVMApp) genExecFun(block *gtypes.Block, res *gtypes.ExecuteResult) Be
 		temReceipt := make([]*etypes.Receipt, 0)
 
 		execFunc := func(txIndex int, raw []byte, tx *etypes.Transaction) error {
+			from, err := etypes.Sender(app.Signer, tx)
+			if err != nil {
+				return err
+			}
+			// an admin tx earlier in the block may have frozen from or changed
+			// the permissions
+			if err := checkSender(state, from, tx); err != nil {
+				return err
+			}
 			gp := new(core.GasPool).AddGas(math.MaxBig256.Uint64())
 
 			txBytes, err := rlp.EncodeToBytes(tx)
@@ -287,6 +316,14 @@ func (app *EVMApp) genExecFun(block *gtypes.Block, res *gtypes.ExecuteResult) Be
 	}
 }
 
+// checkSender returns why from may not send tx in state, nil if it may
+func checkSender(state *estate.StateDB, from common.Address, tx *etypes.Transaction) error {
+	if vm.IsFrozen(state, from) {
+		return fmt.Errorf("%v: %s", vm.ErrAccountFrozen, from.Hex())
+	}
+	return vm.CheckTxPermissions(state, from, tx.To() == nil, tx.Value())
+}
+
 func makeCurrentHeader(block *gtypes.Block, header *gtypes.Header) *etypes.Header {
 	return &etypes.Header{
 		ParentHash: common.BytesToHash(block.Header.LastBlockID.Hash),
@@ -345,6 +382,9 @@ func (app *EVMApp) OnCommit(height, round int64, block *gtypes.Block) (interface
 	if err != nil {
 		log.Error("application save receipts", zap.Error(err), zap.Int64("height", block.Height))
 	}
+	if err := app.saveFreezeEvents(uint64(height)); err != nil {
+		log.Error("application save freeze events", zap.Error(err), zap.Int64("height", block.Height))
+	}
 
 	app.receipts = nil
 	app.pool.updateToState()
@@ -366,6 +406,9 @@ func (app *EVMApp) CheckTx(bs []byte) error {
 
 	app.stateMtx.Lock()
 	defer app.stateMtx.Unlock()
+	if err := checkSender(app.state, from, tx); err != nil {
+		return err
+	}
 	// Last but not least check for nonce errors
 	nonce := tx.Nonce()
 	getNonce := app.state.GetNonce(from)
@@ -405,6 +448,132 @@ func (app *EVMApp) SaveReceipts() ([]byte, error) {
 	return rHash, nil
 }
 
+// saveFreezeEvents keeps the freezes and unfreezes logged in the receipts of
+// the block at height for queryFrozen
+func (app *EVMApp) saveFreezeEvents(height uint64) error {
+	count, last := app.freezeEventsCount()
+	if last >= height && count > 0 {
+		// the block is committed again
+		return nil
+	}
+	var (
+		batch     = app.stateDb.NewBatch()
+		addrCount = make(map[common.Address]uint64)
+	)
+	for _, receipt := range app.receipts {
+		for _, l := range receipt.Logs {
+			if !vm.IsFreezeLog(l) {
+				continue
+			}
+			event := &rtypes.FreezeEvent{
+				Address: common.BytesToAddress(l.Topics[1].Bytes()),
+				Frozen:  l.Topics[0] == vm.FreezeTopic,
+				Reason:  string(l.Data),
+				Height:  height,
+				TxHash:  receipt.TxHash,
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			if err := batch.Put(freezeEventKey(count), data); err != nil {
+				return err
+			}
+			n, ok := addrCount[event.Address]
+			if !ok {
+				n = app.freezeEventsCountOf(event.Address)
+			}
+			if err := batch.Put(freezeAddrEventKey(event.Address, n), uint64Bytes(count)); err != nil {
+				return err
+			}
+			addrCount[event.Address] = n + 1
+			count++
+		}
+	}
+	if batch.ValueSize() == 0 {
+		return nil
+	}
+	for addr, n := range addrCount {
+		if err := batch.Put(freezeAddrKey(addr), uint64Bytes(n)); err != nil {
+			return err
+		}
+	}
+	countBytes := make([]byte, 16)
+	binary.BigEndian.PutUint64(countBytes, count)
+	binary.BigEndian.PutUint64(countBytes[8:], height)
+	if err := batch.Put(FreezeEventsCountKey, countBytes); err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
+func freezeEventKey(seq uint64) []byte {
+	key := make([]byte, len(FreezeEventsPrefix)+8)
+	copy(key, FreezeEventsPrefix)
+	binary.BigEndian.PutUint64(key[len(FreezeEventsPrefix):], seq)
+	return key
+}
+
+func freezeAddrKey(addr common.Address) []byte {
+	return append(append([]byte(nil), FreezeEventsAddrPrefix...), addr.Bytes()...)
+}
+
+func freezeAddrEventKey(addr common.Address, n uint64) []byte {
+	return append(freezeAddrKey(addr), uint64Bytes(n)...)
+}
+
+func uint64Bytes(n uint64) []byte {
+	bz := make([]byte, 8)
+	binary.BigEndian.PutUint64(bz, n)
+	return bz
+}
+
+func (app *EVMApp) freezeEventsCount() (count, height uint64) {
+	data, err := app.stateDb.Get(FreezeEventsCountKey)
+	if err != nil || len(data) != 16 {
+		return 0, 0
+	}
+	return binary.BigEndian.Uint64(data), binary.BigEndian.Uint64(data[8:])
+}
+
+func (app *EVMApp) freezeEventsCountOf(addr common.Address) uint64 {
+	data, err := app.stateDb.Get(freezeAddrKey(addr))
+	if err != nil || len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// freezeEvents gives the number of the freeze events of addr, or of all the
+// accounts if addr is nil, and the way to get the i-th of them
+func (app *EVMApp) freezeEvents(addr *common.Address) (uint64, func(i uint64) (*rtypes.FreezeEvent, error)) {
+	get := func(seq uint64) (*rtypes.FreezeEvent, error) {
+		data, err := app.stateDb.Get(freezeEventKey(seq))
+		if err != nil {
+			return nil, err
+		}
+		event := &rtypes.FreezeEvent{}
+		if err := json.Unmarshal(data, event); err != nil {
+			return nil, err
+		}
+		return event, nil
+	}
+	if addr == nil {
+		count, _ := app.freezeEventsCount()
+		return count, get
+	}
+	return app.freezeEventsCountOf(*addr), func(i uint64) (*rtypes.FreezeEvent, error) {
+		data, err := app.stateDb.Get(freezeAddrEventKey(*addr, i))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) != 8 {
+			return nil, fmt.Errorf("bad freeze event index of %s", addr.Hex())
+		}
+		return get(binary.BigEndian.Uint64(data))
+	}
+}
+
 func (app *EVMApp) Info() (resInfo gtypes.ResultInfo) {
 	lb := &LastBlockInfo{
 		AppHash: make([]byte, 0),
@@ -443,6 +612,22 @@ func (app *EVMApp) Query(query []byte) (res gtypes.Result) {
 		res = app.queryPayLoad(load)
 	case rtypes.QueryType_TxRaw:
 		res = app.queryTransaction(load)
+	case rtypes.QueryType_TxDetail:
+		res = app.queryTxDetail(load)
+	case rtypes.QueryType_EstimateGas:
+		res = app.queryCall(load, true)
+	case rtypes.QueryType_Call:
+		res = app.queryCall(load, false)
+	case rtypes.QueryType_TraceTx:
+		res = app.queryTraceTx(load)
+	case rtypes.QueryType_Code:
+		res = app.queryCode(load)
+	case rtypes.QueryType_Proof:
+		res = app.queryProof(load)
+	case rtypes.QueryType_Permissions:
+		res = app.queryPermissions(load)
+	case rtypes.QueryType_Frozen:
+		res = app.queryFrozen(load)
 	default:
 		res = gtypes.NewError(gtypes.CodeType_BaseInvalidInput, "unimplemented query")
 	}
@@ -475,53 +660,7 @@ func (app *EVMApp) queryContract(load []byte, height uint64) gtypes.Result {
 	if err != nil {
 		return gtypes.NewError(gtypes.CodeType_BaseInvalidInput, err.Error())
 	}
-
-	from, err := app.Signer.Sender(tx)
-	if err != nil {
-		return gtypes.NewError(gtypes.CodeType_BaseInvalidInput, err.Error())
-	}
-	txMsg := etypes.NewMessage(from, tx.To(), 0, tx.Value(), tx.Gas(), tx.GasPrice(), tx.Data(), false)
-
-	bc := NewBlockChain(app.stateDb)
-
-	var vmEnv *vm.EVM
-
-	if height == 0 {
-
-		envCxt := core.NewEVMContext(txMsg, app.currentHeader, bc, nil)
-
-		app.stateMtx.Lock()
-		vmEnv = vm.NewEVM(envCxt, app.state.Copy(), app.chainConfig, evmConfig)
-		app.stateMtx.Unlock()
-	} else {
-		//appHash save in next block AppHash
-		height++
-		blockMeta, err := app.core.GetBlockMeta(int64(height))
-		if err != nil {
-			return gtypes.NewError(gtypes.CodeType_BaseInvalidInput, err.Error())
-		}
-		ethHeader := makeETHHeader(blockMeta.Header)
-		envCxt := core.NewEVMContext(txMsg, ethHeader, bc, nil)
-
-		trieRoot := EmptyTrieRoot
-		if len(blockMeta.Header.AppHash) > 0 {
-			trieRoot = common.BytesToHash(blockMeta.Header.AppHash)
-		}
-
-		state, err := estate.New(trieRoot, estate.NewDatabase(app.stateDb))
-		if err != nil {
-			return gtypes.NewError(gtypes.CodeType_BaseInvalidInput, err.Error())
-		}
-		vmEnv = vm.NewEVM(envCxt, state, app.chainConfig, evmConfig)
-	}
-
-	gpl := new(core.GasPool).AddGas(math.MaxBig256.Uint64())
-	res, _, _, err := core.ApplyMessage(vmEnv, txMsg, gpl) // we don't care about gasUsed
-	if err != nil {
-		log.Warn("query apply msg err", zap.Error(err))
-	}
-
-	return gtypes.NewResultOK(res, "")
+	return app.call(tx, nil, nil, height)
 }
 
 func makeETHHeader(header *gtypes.Header) *etypes.Header {
@@ -551,6 +690,111 @@ func (app *EVMApp) queryNonce(addrBytes []byte) gtypes.Result {
 	return gtypes.NewResultOK(data, "")
 }
 
+func (app *EVMApp) queryCode(addrBytes []byte) gtypes.Result {
+	if len(addrBytes) != 20 {
+		return gtypes.NewError(gtypes.CodeType_BaseInvalidInput, "Invalid address")
+	}
+	addr := common.BytesToAddress(addrBytes)
+
+	app.stateMtx.Lock()
+	code := app.state.GetCode(addr)
+	app.stateMtx.Unlock()
+
+	return gtypes.NewResultOK(code, "")
+}
+
+// queryPermissions returns the permission table in json, only with the
+// permissions of the account if load is an address
+func (app *EVMApp) queryPermissions(load []byte) gtypes.Result {
+	var addr *common.Address
+	if len(load) > 0 {
+		if len(load) != 20 {
+			return gtypes.NewError(gtypes.CodeType_BaseInvalidInput, "Invalid address")
+		}
+		a := common.BytesToAddress(load)
+		addr = &a
+	}
+
+	app.stateMtx.Lock()
+	permissions := vm.GetPermissions(app.state, addr)
+	app.stateMtx.Unlock()
+
+	data, err := json.Marshal(permissions)
+	if err != nil {
+		return gtypes.NewError(gtypes.CodeType_InternalError, err.Error())
+	}
+	return gtypes.NewResultOK(data, "")
+}
+
+// queryFrozen returns the frozen accounts and a page of the freeze events in
+// json, load is the rlp of FrozenArgs. A bare address is taken for the first
+// page of its events.
+func (app *EVMApp) queryFrozen(load []byte) gtypes.Result {
+	args := &rtypes.FrozenArgs{}
+	if len(load) == 20 {
+		addr := common.BytesToAddress(load)
+		args.Address = &addr
+	} else if len(load) > 0 {
+		if err := rlp.DecodeBytes(load, args); err != nil {
+			return gtypes.NewError(gtypes.CodeType_BaseInvalidInput, err.Error())
+		}
+	}
+	limit := args.Limit
+	if limit == 0 || limit > rtypes.MaxFreezeEvents {
+		limit = rtypes.MaxFreezeEvents
+	}
+
+	result := &rtypes.FrozenAccounts{Frozen: make([]common.Address, 0), Events: make([]*rtypes.FreezeEvent, 0)}
+	app.stateMtx.Lock()
+	if args.Address == nil {
+		result.Frozen = vm.FrozenAccounts(app.state)
+	} else if vm.IsFrozen(app.state, *args.Address) {
+		result.Frozen = append(result.Frozen, *args.Address)
+	}
+	app.stateMtx.Unlock()
+
+	total, event := app.freezeEvents(args.Address)
+	result.Total = total
+	start := args.Start
+	if args.FromHeight > 0 {
+		// the events come in the order of their heights
+		var err error
+		from := uint64(sort.Search(int(total), func(i int) bool {
+			if err != nil {
+				return true
+			}
+			var e *rtypes.FreezeEvent
+			if e, err = event(uint64(i)); err != nil {
+				return true
+			}
+			return e.Height >= args.FromHeight
+		}))
+		if err != nil {
+			return gtypes.NewError(gtypes.CodeType_InternalError, err.Error())
+		}
+		if from > start {
+			start = from
+		}
+	}
+	i := start
+	for ; i < total && uint64(len(result.Events)) < limit; i++ {
+		e, err := event(i)
+		if err != nil {
+			return gtypes.NewError(gtypes.CodeType_InternalError, err.Error())
+		}
+		result.Events = append(result.Events, e)
+	}
+	if i < total {
+		result.Next = i
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return gtypes.NewError(gtypes.CodeType_InternalError, err.Error())
+	}
+	return gtypes.NewResultOK(data, "")
+}
+
 func (app *EVMApp) queryReceipt(txHashBytes []byte) gtypes.Result {
 	key := append(ReceiptsPrefix, txHashBytes...)
 	data, err := app.stateDb.Get(key)
@@ -580,6 +824,60 @@ func (app *EVMApp) queryTransaction(txHashBytes []byte) gtypes.Result {
 	return res
 }
 
+func (app *EVMApp) queryTxDetail(txHashBytes []byte) gtypes.Result {
+	if len(txHashBytes) == 0 {
+		return gtypes.NewError(gtypes.CodeType_BaseInvalidInput, "Empty query")
+	}
+
+	data, err := app.core.Query(gtypes.QueryTx, txHashBytes)
+	if err != nil {
+		return gtypes.NewError(gtypes.CodeType_InternalError, err.Error())
+	}
+	rtx, ok := data.(*gtypes.ResultTransaction)
+	if !ok {
+		return gtypes.NewError(gtypes.CodeType_InternalError, "unexpected transaction result")
+	}
+
+	detail := rtypes.TxDetail{
+		Hash:             common.BytesToHash(txHashBytes),
+		BlockHash:        rtx.BlockHash,
+		BlockHeight:      rtx.BlockHeight,
+		TransactionIndex: rtx.TransactionIndex,
+		Timestamp:        rtx.Timestamp,
+		Status:           gtypes.TxStatusString(rtx.Status),
+		Error:            rtx.Error,
+	}
+
+	// an invalid tx may not even decode, its status and error are all we have then
+	tx := new(etypes.Transaction)
+	if err := rlp.DecodeBytes(rtx.RawTransaction, tx); err == nil {
+		detail.From, _ = etypes.Sender(app.Signer, tx)
+		detail.To = tx.To()
+		detail.Nonce = tx.Nonce()
+		detail.Value = tx.Value().String()
+		detail.GasPrice = tx.GasPrice().String()
+		detail.Gas = tx.Gas()
+		detail.Input = tx.Data()
+	}
+
+	if rtx.Status == gtypes.TxStatusValid {
+		key := append(ReceiptsPrefix, txHashBytes...)
+		if rdata, err := app.stateDb.Get(key); err == nil {
+			receipt := new(etypes.ReceiptForStorage)
+			if err := rlp.DecodeBytes(rdata, receipt); err != nil {
+				return gtypes.NewError(gtypes.CodeType_InternalError, err.Error())
+			}
+			detail.Receipt = (*etypes.Receipt)(receipt)
+		}
+	}
+
+	bs, err := json.Marshal(detail)
+	if err != nil {
+		return gtypes.NewError(gtypes.CodeType_InternalError, err.Error())
+	}
+	return gtypes.NewResultOK(bs, "")
+}
+
 func (app *EVMApp) queryPayLoad(txHashBytes []byte) gtypes.Result {
 	if len(txHashBytes) == 0 {
 		return gtypes.NewError(gtypes.CodeType_BaseInvalidInput, "Empty query")
@@ -0,0 +1,102 @@
+// Copyright © 2017 ZhongAn Technology
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evm
+
+import (
+	"encoding/json"
+	"testing"
+
+	rtypes "github.com/dappledger/AnnChain/chain/types"
+	"github.com/dappledger/AnnChain/eth/common"
+	etypes "github.com/dappledger/AnnChain/eth/core/types"
+	"github.com/dappledger/AnnChain/eth/core/vm"
+	"github.com/dappledger/AnnChain/eth/rlp"
+	gtypes "github.com/dappledger/AnnChain/gemmill/types"
+)
+
+func TestQueryFrozenPages(t *testing.T) {
+	var (
+		a   = common.HexToAddress("0x0a")
+		b   = common.HexToAddress("0x0b")
+		app = newCallTestApp(t, nil, a)
+	)
+	freeze := func(addr common.Address, frozen bool) *etypes.Log {
+		topic := vm.UnfreezeTopic
+		if frozen {
+			topic = vm.FreezeTopic
+		}
+		return &etypes.Log{Address: vm.PermissionsAddress, Topics: []common.Hash{topic, addr.Hash()}}
+	}
+	// a is frozen and unfrozen at every height, b frozen once at height 2
+	for height := uint64(1); height <= 4; height++ {
+		logs := []*etypes.Log{freeze(a, true), freeze(a, false)}
+		if height == 2 {
+			logs = append(logs, freeze(b, true))
+		}
+		app.receipts = etypes.Receipts{{Logs: logs}}
+		if err := app.saveFreezeEvents(height); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// committing a block again adds nothing
+	if err := app.saveFreezeEvents(4); err != nil {
+		t.Fatal(err)
+	}
+
+	query := func(args *rtypes.FrozenArgs) *rtypes.FrozenAccounts {
+		load, err := rlp.EncodeToBytes(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := app.queryFrozen(load)
+		if res.Code != gtypes.CodeType_OK {
+			t.Fatal(res.Log)
+		}
+		frozen := &rtypes.FrozenAccounts{}
+		if err := json.Unmarshal(res.Data, frozen); err != nil {
+			t.Fatal(err)
+		}
+		return frozen
+	}
+
+	all := query(&rtypes.FrozenArgs{FromHeight: 2, Limit: 3})
+	if all.Total != 9 || len(all.Events) != 3 || all.Next != 5 ||
+		all.Events[0].Height != 2 || all.Events[2].Address != b {
+		t.Fatalf("unexpected first page %+v", all)
+	}
+	all = query(&rtypes.FrozenArgs{FromHeight: 2, Start: all.Next, Limit: 3})
+	if len(all.Events) != 3 || all.Next != 8 || all.Events[0].Height != 3 {
+		t.Fatalf("unexpected second page %+v", all)
+	}
+
+	ofA := query(&rtypes.FrozenArgs{Address: &a, FromHeight: 4})
+	if ofA.Total != 8 || len(ofA.Events) != 2 || ofA.Next != 0 {
+		t.Fatalf("unexpected events of a %+v", ofA)
+	}
+	for _, e := range ofA.Events {
+		if e.Address != a || e.Height != 4 {
+			t.Fatalf("unexpected event of a %+v", e)
+		}
+	}
+
+	// a bare address still asks for its events
+	res := app.queryFrozen(b.Bytes())
+	ofB := &rtypes.FrozenAccounts{}
+	if err := json.Unmarshal(res.Data, ofB); err != nil {
+		t.Fatal(err)
+	}
+	if ofB.Total != 1 || len(ofB.Events) != 1 || ofB.Events[0].Height != 2 || !ofB.Events[0].Frozen {
+		t.Fatalf("unexpected events of b %+v", ofB)
+	}
+}
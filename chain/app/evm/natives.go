@@ -0,0 +1,177 @@
+// Copyright © 2017 ZhongAn Technology
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/dappledger/AnnChain/eth/common"
+	estate "github.com/dappledger/AnnChain/eth/core/state"
+	"github.com/dappledger/AnnChain/eth/core/vm"
+	"github.com/dappledger/AnnChain/eth/crypto"
+	"github.com/dappledger/AnnChain/eth/params"
+	gcrypto "github.com/dappledger/AnnChain/gemmill/go-crypto"
+	"github.com/dappledger/AnnChain/gemmill/go-crypto/sm3"
+	gtypes "github.com/dappledger/AnnChain/gemmill/types"
+)
+
+// The native contract kinds the genesis can install
+const (
+	NativeEd25519Verify = "ed25519_verify"
+	NativeSM3           = "sm3"
+	NativeRegistry      = "registry"
+)
+
+var (
+	registrySetSelector = crypto.Keccak256([]byte("set(bytes32,bytes32)"))[:4]
+	registryGetSelector = crypto.Keccak256([]byte("get(address,bytes32)"))[:4]
+
+	errRegistryInput    = errors.New("registry: invalid input")
+	errRegistryReadOnly = errors.New("registry: set in a static call")
+	errRegistryDelegate = errors.New("registry: set in a delegated call")
+)
+
+func init() {
+	vm.RegisterNativeContract(NativeEd25519Verify, &ed25519Verify{}, vm.GasSchedule{Base: params.EcrecoverGas, PerWord: params.Sha256PerWordGas})
+	vm.RegisterNativeContract(NativeSM3, &sm3Hash{}, vm.GasSchedule{Base: params.Sha256BaseGas, PerWord: params.Sha256PerWordGas})
+	vm.RegisterNativeContract(NativeRegistry, &registry{}, vm.GasSchedule{Base: params.GasTableEIP158.SLoad})
+}
+
+// InitGenesis installs the precompiles of the genesis
+func (app *EVMApp) InitGenesis(genesis *gtypes.GenesisDoc) error {
+	installs := make([]*vm.NativeInstall, 0, len(genesis.Precompiles))
+	for _, p := range genesis.Precompiles {
+		if !common.IsHexAddress(p.Address) {
+			return fmt.Errorf("precompile %s: invalid address %q", p.Kind, p.Address)
+		}
+		if p.ActivationHeight < 0 {
+			return fmt.Errorf("precompile %s: negative activation height", p.Kind)
+		}
+		installs = append(installs, &vm.NativeInstall{
+			Kind:             p.Kind,
+			Address:          common.HexToAddress(p.Address),
+			ActivationHeight: uint64(p.ActivationHeight),
+			BaseGas:          p.BaseGas,
+			WordGas:          p.WordGas,
+		})
+	}
+	if err := vm.InstallNativeContracts(installs); err != nil {
+		return err
+	}
+	// a node bootstrapping its genesis from peers has started already
+	if app.state != nil {
+		app.stateMtx.Lock()
+		defer app.stateMtx.Unlock()
+		return checkNativeCode(app.state)
+	}
+	return nil
+}
+
+// checkNativeCode refuses native contracts installed at accounts with code,
+// which they would shadow
+func checkNativeCode(state *estate.StateDB) error {
+	for _, addr := range vm.NativeContractAddresses() {
+		if state.GetCodeSize(addr) > 0 {
+			return fmt.Errorf("native contract at %s, which has code", addr.Hex())
+		}
+	}
+	return nil
+}
+
+// ed25519Verify checks the ed25519 signatures of gemmill, the node keys sign
+// with. The input is pubkey(32) || signature(64) || message, the output the
+// word 1 if the signature is valid and 0 otherwise.
+type ed25519Verify struct{}
+
+func (c *ed25519Verify) RequiredGas(input []byte) uint64 {
+	return 0
+}
+
+func (c *ed25519Verify) Run(ctx *vm.NativeContext, input []byte) ([]byte, error) {
+	if len(input) < gcrypto.PubKeyLenEd25519+gcrypto.SignKeyLenEd25519 {
+		return common.LeftPadBytes(nil, 32), nil
+	}
+	var (
+		pubKey gcrypto.PubKeyEd25519
+		sig    gcrypto.SignatureEd25519
+	)
+	copy(pubKey[:], input)
+	copy(sig[:], input[len(pubKey):])
+	if !pubKey.VerifyBytes(input[len(pubKey)+len(sig):], sig) {
+		return common.LeftPadBytes(nil, 32), nil
+	}
+	return common.LeftPadBytes([]byte{1}, 32), nil
+}
+
+// sm3Hash returns the SM3 hash of the input
+type sm3Hash struct{}
+
+func (c *sm3Hash) RequiredGas(input []byte) uint64 {
+	return 0
+}
+
+func (c *sm3Hash) Run(ctx *vm.NativeContext, input []byte) ([]byte, error) {
+	sum := sm3.Sum(input)
+	return sum[:], nil
+}
+
+// registry is a key-value store each caller writes its own keys of and
+// everyone reads:
+//
+//	set(bytes32 key, bytes32 value)
+//	get(address owner, bytes32 key) returns (bytes32)
+type registry struct{}
+
+// the value of key set by owner
+func registrySlot(owner common.Address, key []byte) common.Hash {
+	return crypto.Keccak256Hash(owner.Bytes(), key)
+}
+
+func (c *registry) RequiredGas(input []byte) uint64 {
+	if len(input) >= 4 && bytes.Equal(input[:4], registrySetSelector) {
+		return params.SstoreSetGas
+	}
+	return 0
+}
+
+func (c *registry) Run(ctx *vm.NativeContext, input []byte) ([]byte, error) {
+	if len(input) != 4+64 {
+		return nil, errRegistryInput
+	}
+	switch args := input[4:]; {
+	case bytes.Equal(input[:4], registrySetSelector):
+		if ctx.ReadOnly {
+			return nil, errRegistryReadOnly
+		}
+		// the caller of a delegating contract would own the keys it sets
+		if ctx.Delegated {
+			return nil, errRegistryDelegate
+		}
+		// an account without nonce, balance nor code is deleted as empty
+		// with its storage
+		if ctx.StateDB.GetNonce(ctx.Self) == 0 {
+			ctx.StateDB.SetNonce(ctx.Self, 1)
+		}
+		ctx.StateDB.SetState(ctx.Self, registrySlot(ctx.Caller, args[:32]), common.BytesToHash(args[32:]))
+		return nil, nil
+	case bytes.Equal(input[:4], registryGetSelector):
+		owner := common.BytesToAddress(args[:32])
+		value := ctx.StateDB.GetState(ctx.Self, registrySlot(owner, args[32:]))
+		return value.Bytes(), nil
+	default:
+		return nil, errRegistryInput
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright © 2017 ZhongAn Technology
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/dappledger/AnnChain/eth/common"
+	estate "github.com/dappledger/AnnChain/eth/core/state"
+	"github.com/dappledger/AnnChain/eth/core/vm"
+	"github.com/dappledger/AnnChain/eth/ethdb"
+	"github.com/dappledger/AnnChain/eth/params"
+	gcrypto "github.com/dappledger/AnnChain/gemmill/go-crypto"
+	gtypes "github.com/dappledger/AnnChain/gemmill/types"
+)
+
+func TestNativeContracts(t *testing.T) {
+	var (
+		verifyAddr   = common.HexToAddress("0x0100")
+		sm3Addr      = common.HexToAddress("0x0101")
+		registryAddr = common.HexToAddress("0x0102")
+		alice        = common.HexToAddress("0x0a")
+		bob          = common.HexToAddress("0x0b")
+		zero         = uint64(0)
+	)
+	app := &EVMApp{}
+	if err := app.InitGenesis(&gtypes.GenesisDoc{Precompiles: []*gtypes.GenesisPrecompile{{Kind: NativeSM3, Address: "0x01x"}}}); err == nil {
+		t.Fatal("expected an invalid address to be refused")
+	}
+	err := app.InitGenesis(&gtypes.GenesisDoc{Precompiles: []*gtypes.GenesisPrecompile{
+		{Kind: NativeEd25519Verify, Address: verifyAddr.Hex()},
+		{Kind: NativeSM3, Address: sm3Addr.Hex(), WordGas: &zero},
+		{Kind: NativeRegistry, Address: registryAddr.Hex(), ActivationHeight: 10},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.InstallNativeContracts(nil)
+
+	db, _ := estate.New(common.Hash{}, estate.NewDatabase(ethdb.NewMemDatabase()))
+	newEVM := func() *vm.EVM {
+		ctx := vm.Context{
+			CanTransfer: func(vm.StateDB, common.Address, *big.Int) bool { return true },
+			Transfer:    func(vm.StateDB, common.Address, common.Address, *big.Int) {},
+			BlockNumber: big.NewInt(10),
+		}
+		return vm.NewEVM(ctx, db, params.MainnetChainConfig, evmConfig)
+	}
+	call := func(from, to common.Address, input []byte) []byte {
+		ret, _, err := newEVM().Call(vm.AccountRef(from), to, input, 100000, new(big.Int))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ret
+	}
+
+	// the node keys sign with gemmill
+	priv := gcrypto.GenPrivKeyEd25519()
+	pub := priv.PubKey().(gcrypto.PubKeyEd25519)
+	msg := []byte("vote")
+	sig := priv.Sign(msg).(gcrypto.SignatureEd25519)
+	input := append(append(pub[:], sig[:]...), msg...)
+	if ret := call(alice, verifyAddr, input); new(big.Int).SetBytes(ret).Int64() != 1 {
+		t.Fatalf("expected a valid signature, got %x", ret)
+	}
+	input[len(input)-1] ^= 1
+	if ret := call(alice, verifyAddr, input); len(ret) != 32 || new(big.Int).SetBytes(ret).Sign() != 0 {
+		t.Fatalf("expected an invalid signature, got %x", ret)
+	}
+
+	evm := newEVM()
+	ret, _, err := evm.Call(vm.AccountRef(alice), sm3Addr, []byte("abc"), 100000, new(big.Int))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(ret) != "66c7f0f462eeedd9d1f2d46bdc10e4e24167c4875cf2f7a2297da02b8f4ba8e0" {
+		t.Fatalf("unexpected sm3 hash %x", ret)
+	}
+	if gas := evmConfig.EVMGasLimit - evm.GasLeft(); gas != params.Sha256BaseGas {
+		t.Fatalf("expected %d gas without the word gas, got %d", params.Sha256BaseGas, gas)
+	}
+
+	// each caller sets its own keys
+	key, value := common.HexToHash("0x01"), common.HexToHash("0x2a")
+	call(alice, registryAddr, append(append(common.CopyBytes(registrySetSelector), key[:]...), value[:]...))
+	db.Finalise(true)
+	get := func(owner common.Address) []byte {
+		return call(bob, registryAddr, append(append(common.CopyBytes(registryGetSelector), common.LeftPadBytes(owner[:], 32)...), key[:]...))
+	}
+	if ret := get(alice); !bytes.Equal(ret, value[:]) {
+		t.Fatalf("expected the value of alice, got %x", ret)
+	}
+	if ret := get(bob); !bytes.Equal(ret, common.Hash{}.Bytes()) {
+		t.Fatalf("expected no value of bob, got %x", ret)
+	}
+	if _, _, err := newEVM().StaticCall(vm.AccountRef(bob), registryAddr, append(append(common.CopyBytes(registrySetSelector), key[:]...), value[:]...), 100000); err != errRegistryReadOnly {
+		t.Fatalf("expected %v, got %v", errRegistryReadOnly, err)
+	}
+
+	// a proxy delegating calls to the registry reads the registry, and can't
+	// set keys of the callers of the proxy
+	proxy := common.HexToAddress("0x0c")
+	db.SetCode(proxy, common.Hex2Bytes("366000600037"+"6000600036600061"+"0102"+"5af4"+"601957"+"600080fd"+"5b3d600060003e3d6000f3"))
+	if ret := call(bob, proxy, append(append(common.CopyBytes(registryGetSelector), common.LeftPadBytes(alice[:], 32)...), key[:]...)); !bytes.Equal(ret, value[:]) {
+		t.Fatalf("expected the value of alice through the proxy, got %x", ret)
+	}
+	if _, _, err := newEVM().Call(vm.AccountRef(bob), proxy, append(append(common.CopyBytes(registrySetSelector), key[:]...), value[:]...), 100000, new(big.Int)); err == nil {
+		t.Fatal("expected a delegated set to fail")
+	}
+}
+
+func TestNativeContractOverCode(t *testing.T) {
+	addr := common.HexToAddress("0x0100")
+	app := &EVMApp{}
+	app.state, _ = estate.New(common.Hash{}, estate.NewDatabase(ethdb.NewMemDatabase()))
+	app.state.SetCode(addr, []byte{0x00})
+	defer vm.InstallNativeContracts(nil)
+	if err := app.InitGenesis(&gtypes.GenesisDoc{Precompiles: []*gtypes.GenesisPrecompile{{Kind: NativeSM3, Address: addr.Hex()}}}); err == nil {
+		t.Fatal("expected a native contract over code to be refused")
+	}
+	if err := app.InitGenesis(&gtypes.GenesisDoc{Precompiles: []*gtypes.GenesisPrecompile{{Kind: NativeSM3, Address: common.HexToAddress("0x0101").Hex()}}}); err != nil {
+		t.Fatal(err)
+	}
+}
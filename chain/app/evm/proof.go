@@ -0,0 +1,58 @@
+// Copyright © 2017 ZhongAn Technology
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evm
+
+import (
+	rtypes "github.com/dappledger/AnnChain/chain/types"
+	"github.com/dappledger/AnnChain/eth/rlp"
+	gtypes "github.com/dappledger/AnnChain/gemmill/types"
+)
+
+// queryProof proves an account and its storage slots in the state after a
+// past block, the latest state is left out as no header carries its root yet
+func (app *EVMApp) queryProof(load []byte) gtypes.Result {
+	args := &rtypes.ProofArgs{}
+	if err := rlp.DecodeBytes(load, args); err != nil {
+		return gtypes.NewError(gtypes.CodeType_BaseInvalidInput, err.Error())
+	}
+	if args.Height == 0 {
+		return gtypes.NewError(gtypes.CodeType_BaseInvalidInput, "height must be greater than 0")
+	}
+	state, _, err := app.callEnv(args.Height)
+	if err != nil {
+		return gtypes.NewError(gtypes.CodeType_BaseInvalidInput, err.Error())
+	}
+
+	proof := &rtypes.AccountProof{Address: args.Address, Height: args.Height}
+	if proof.AccountProof, err = state.GetProof(args.Address); err != nil {
+		return gtypes.NewError(gtypes.CodeType_InternalError, err.Error())
+	}
+	exist := state.Exist(args.Address)
+	for _, key := range args.Keys {
+		sp := rtypes.StorageProof{Key: key}
+		// the account proof shows a missing account, its slots need none
+		if exist {
+			if sp.Proof, err = state.GetStorageProof(args.Address, key); err != nil {
+				return gtypes.NewError(gtypes.CodeType_InternalError, err.Error())
+			}
+		}
+		proof.StorageProof = append(proof.StorageProof, sp)
+	}
+
+	data, err := rlp.EncodeToBytes(proof)
+	if err != nil {
+		return gtypes.NewError(gtypes.CodeType_InternalError, err.Error())
+	}
+	return gtypes.NewResultOK(data, "")
+}
@@ -0,0 +1,348 @@
+// Copyright © 2017 ZhongAn Technology
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evm
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	rtypes "github.com/dappledger/AnnChain/chain/types"
+	"github.com/dappledger/AnnChain/eth/common"
+	"github.com/dappledger/AnnChain/eth/common/math"
+	"github.com/dappledger/AnnChain/eth/core"
+	estate "github.com/dappledger/AnnChain/eth/core/state"
+	etypes "github.com/dappledger/AnnChain/eth/core/types"
+	"github.com/dappledger/AnnChain/eth/core/vm"
+	"github.com/dappledger/AnnChain/eth/rlp"
+	gtypes "github.com/dappledger/AnnChain/gemmill/types"
+)
+
+func (app *EVMApp) queryTraceTx(load []byte) gtypes.Result {
+	args := &rtypes.TraceArgs{}
+	if err := rlp.DecodeBytes(load, args); err != nil {
+		return gtypes.NewError(gtypes.CodeType_BaseInvalidInput, err.Error())
+	}
+	trace, err := app.traceTransaction(args)
+	if err != nil {
+		return gtypes.NewError(gtypes.CodeType_InternalError, err.Error())
+	}
+	bs, err := json.Marshal(trace)
+	if err != nil {
+		return gtypes.NewError(gtypes.CodeType_InternalError, err.Error())
+	}
+	return gtypes.NewResultOK(bs, "")
+}
+
+// traceTransaction re-executes a committed tx on top of its parent state and the txs
+// before it in the same block, with the requested tracer attached
+func (app *EVMApp) traceTransaction(args *rtypes.TraceArgs) (*rtypes.TxTrace, error) {
+	data, err := app.core.Query(gtypes.QueryTx, args.TxHash.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	rtx, ok := data.(*gtypes.ResultTransaction)
+	if !ok {
+		return nil, fmt.Errorf("unexpected transaction result")
+	}
+	if rtx.Status != gtypes.TxStatusValid {
+		return nil, fmt.Errorf("tx was not executed: %s", rtx.Error)
+	}
+	block, _, err := app.core.GetBlock(int64(rtx.BlockHeight))
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block %d is pruned", rtx.BlockHeight)
+	}
+
+	// the parent state root is carried by this block's AppHash
+	trieRoot := EmptyTrieRoot
+	if len(block.Header.AppHash) > 0 {
+		trieRoot = common.BytesToHash(block.Header.AppHash)
+	}
+	state, err := estate.New(trieRoot, estate.NewDatabase(app.stateDb))
+	if err != nil {
+		return nil, err
+	}
+	header := makeCurrentHeader(block, block.Header)
+	blockHash := common.BytesToHash(block.Hash())
+	bc := NewBlockChain(app.stateDb)
+
+	for i := 0; i < int(rtx.TransactionIndex); i++ {
+		tx := new(etypes.Transaction)
+		if err := rlp.DecodeBytes(block.Data.Txs[i], tx); err != nil {
+			continue
+		}
+		// skip the txs the block execution refused
+		from, err := etypes.Sender(app.Signer, tx)
+		if err != nil || checkSender(state, from, tx) != nil {
+			continue
+		}
+		snapshot := state.Snapshot()
+		state.Prepare(common.BytesToHash(block.Data.Txs[i].Hash()), blockHash, i)
+		gp := new(core.GasPool).AddGas(math.MaxBig256.Uint64())
+		if _, _, err := core.ApplyTransaction(app.chainConfig, bc, nil, gp, state, header, tx, new(uint64), replayConfig); err != nil {
+			state.RevertToSnapshot(snapshot)
+		}
+	}
+
+	tx := new(etypes.Transaction)
+	if err := rlp.DecodeBytes(rtx.RawTransaction, tx); err != nil {
+		return nil, err
+	}
+	msg, err := tx.AsMessage(app.Signer)
+	if err != nil {
+		return nil, err
+	}
+	state.Prepare(args.TxHash, blockHash, int(rtx.TransactionIndex))
+
+	var (
+		structLogger *vm.StructLogger
+		calls        *callTracer
+		tracer       vm.Tracer
+	)
+	switch args.Tracer {
+	case rtypes.TracerStructLogs:
+		structLogger = vm.NewStructLogger(&vm.LogConfig{
+			DisableMemory:  args.DisableMemory,
+			DisableStack:   args.DisableStack,
+			DisableStorage: args.DisableStorage,
+		})
+		tracer = structLogger
+	case rtypes.TracerCallTree:
+		calls = newCallTracer()
+		tracer = calls
+	default:
+		return nil, fmt.Errorf("unknown tracer %s", args.Tracer)
+	}
+
+	vmEnv := vm.NewEVM(core.NewEVMContext(msg, header, bc, nil), state, app.chainConfig,
+		vm.Config{EVMGasLimit: EVMGasLimit, Debug: true, Tracer: tracer, SimulateAdmin: true, ReplayAdmin: true})
+	ret, gasUsed, failed, err := core.ApplyMessage(vmEnv, msg, new(core.GasPool).AddGas(math.MaxBig256.Uint64()))
+	if err != nil {
+		return nil, err
+	}
+
+	trace := &rtypes.TxTrace{
+		Gas:         gasUsed,
+		Failed:      failed,
+		ReturnValue: ret,
+	}
+	if structLogger != nil {
+		trace.StructLogs = formatStructLogs(structLogger.StructLogs())
+	}
+	if calls != nil {
+		trace.Calls = calls.root
+	}
+	return trace, nil
+}
+
+func formatStructLogs(logs []vm.StructLog) []rtypes.StructLogRes {
+	res := make([]rtypes.StructLogRes, len(logs))
+	for i, l := range logs {
+		res[i] = rtypes.StructLogRes{
+			Pc:      l.Pc,
+			Op:      l.Op.String(),
+			Gas:     l.Gas,
+			GasCost: l.GasCost,
+			Depth:   l.Depth,
+			Error:   l.ErrorString(),
+		}
+		for _, item := range l.Stack {
+			res[i].Stack = append(res[i].Stack, fmt.Sprintf("%x", math.PaddedBigBytes(item, 32)))
+		}
+		for j := 0; j+32 <= len(l.Memory); j += 32 {
+			res[i].Memory = append(res[i].Memory, fmt.Sprintf("%x", l.Memory[j:j+32]))
+		}
+		for k, v := range l.Storage {
+			res[i].Storage = append(res[i].Storage, rtypes.StorageEntry{Key: k, Value: v})
+		}
+	}
+	return res
+}
+
+// callTracer builds the tree of calls, creates and self destructs a tx made,
+// following the frame bookkeeping of go-ethereum's javascript call tracer
+type callTracer struct {
+	root      *rtypes.CallFrame
+	stack     []*tracedFrame
+	descended bool
+}
+
+type tracedFrame struct {
+	*rtypes.CallFrame
+	gasIn   uint64
+	gasCost uint64
+	outOff  int64
+	outLen  int64
+}
+
+func newCallTracer() *callTracer {
+	return &callTracer{}
+}
+
+func (t *callTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	typ := "CALL"
+	if create {
+		typ = "CREATE"
+	}
+	t.root = &rtypes.CallFrame{
+		Type:  typ,
+		From:  from,
+		To:    to,
+		Value: value.String(),
+		Gas:   gas,
+		Input: common.CopyBytes(input),
+	}
+	t.stack = []*tracedFrame{{CallFrame: t.root}}
+	return nil
+}
+
+func (t *callTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if err != nil {
+		return t.CaptureFault(env, pc, op, gas, cost, memory, stack, contract, depth, err)
+	}
+	if len(t.stack) == 0 {
+		return nil
+	}
+	// the gas a sub call received is only known at its first step
+	if t.descended {
+		if depth >= len(t.stack) {
+			t.stack[len(t.stack)-1].Gas = gas
+		}
+		t.descended = false
+	}
+	// stepping at the depth of the caller again means the last call returned
+	if len(t.stack) > 1 && depth == len(t.stack)-1 {
+		t.exit(gas, memory, stack)
+	}
+
+	switch op {
+	case vm.CREATE, vm.CREATE2:
+		off, size := stack.Back(1).Int64(), stack.Back(2).Int64()
+		t.enter(&tracedFrame{
+			CallFrame: &rtypes.CallFrame{
+				Type:  op.String(),
+				From:  contract.Address(),
+				Value: stack.Back(0).String(),
+				Input: memorySlice(memory, off, size),
+			},
+			gasIn:   gas,
+			gasCost: cost,
+		})
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		argOff := 2
+		value := ""
+		if op == vm.CALL || op == vm.CALLCODE {
+			argOff = 3
+			value = stack.Back(2).String()
+		}
+		inOff, inLen := stack.Back(argOff).Int64(), stack.Back(argOff+1).Int64()
+		t.enter(&tracedFrame{
+			CallFrame: &rtypes.CallFrame{
+				Type:  op.String(),
+				From:  contract.Address(),
+				To:    common.BigToAddress(stack.Back(1)),
+				Value: value,
+				Input: memorySlice(memory, inOff, inLen),
+			},
+			gasIn:   gas,
+			gasCost: cost,
+			outOff:  stack.Back(argOff + 2).Int64(),
+			outLen:  stack.Back(argOff + 3).Int64(),
+		})
+	case vm.REVERT:
+		t.stack[len(t.stack)-1].Error = "execution reverted"
+	case vm.SELFDESTRUCT:
+		parent := t.stack[len(t.stack)-1]
+		parent.Calls = append(parent.Calls, &rtypes.CallFrame{
+			Type:  op.String(),
+			From:  contract.Address(),
+			To:    common.BigToAddress(stack.Back(0)),
+			Value: env.StateDB.GetBalance(contract.Address()).String(),
+		})
+	}
+	return nil
+}
+
+func (t *callTracer) enter(f *tracedFrame) {
+	parent := t.stack[len(t.stack)-1]
+	parent.Calls = append(parent.Calls, f.CallFrame)
+	t.stack = append(t.stack, f)
+	t.descended = true
+}
+
+func (t *callTracer) exit(gas uint64, memory *vm.Memory, stack *vm.Stack) {
+	f := t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+
+	if used := int64(f.gasIn) - int64(f.gasCost) + int64(f.Gas) - int64(gas); used > 0 {
+		f.GasUsed = uint64(used)
+	}
+	ret := stack.Back(0)
+	switch {
+	case ret.Sign() == 0:
+		if f.Error == "" {
+			f.Error = "internal failure"
+		}
+	case f.Type == vm.CREATE.String() || f.Type == vm.CREATE2.String():
+		f.To = common.BigToAddress(ret)
+	default:
+		f.Output = memorySlice(memory, f.outOff, f.outLen)
+	}
+}
+
+// memorySlice copies a memory range, clipped to what has actually been allocated
+func memorySlice(memory *vm.Memory, off, size int64) []byte {
+	data := memory.Data()
+	if off < 0 || size <= 0 || off >= int64(len(data)) {
+		return nil
+	}
+	end := off + size
+	if end > int64(len(data)) || end < off {
+		end = int64(len(data))
+	}
+	return common.CopyBytes(data[off:end])
+}
+
+func (t *callTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if len(t.stack) == 0 {
+		return nil
+	}
+	f := t.stack[len(t.stack)-1]
+	if f.Error != "" {
+		return nil
+	}
+	f.Error = err.Error()
+	f.GasUsed = f.Gas
+	if len(t.stack) > 1 {
+		t.stack = t.stack[:len(t.stack)-1]
+	}
+	t.descended = false
+	return nil
+}
+
+func (t *callTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	if t.root == nil {
+		return nil
+	}
+	t.root.GasUsed = gasUsed
+	t.root.Output = common.CopyBytes(output)
+	if err != nil && t.root.Error == "" {
+		t.root.Error = err.Error()
+	}
+	return nil
+}
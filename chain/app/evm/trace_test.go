@@ -0,0 +1,173 @@
+// Copyright © 2017 ZhongAn Technology
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evm
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"testing"
+
+	rtypes "github.com/dappledger/AnnChain/chain/types"
+	"github.com/dappledger/AnnChain/eth/common"
+	"github.com/dappledger/AnnChain/eth/core/state"
+	etypes "github.com/dappledger/AnnChain/eth/core/types"
+	"github.com/dappledger/AnnChain/eth/core/vm"
+	"github.com/dappledger/AnnChain/eth/core/vm/runtime"
+	"github.com/dappledger/AnnChain/eth/crypto"
+	"github.com/dappledger/AnnChain/eth/ethdb"
+	"github.com/dappledger/AnnChain/eth/params"
+	"github.com/dappledger/AnnChain/eth/rlp"
+	gtypes "github.com/dappledger/AnnChain/gemmill/types"
+)
+
+func TestCallTracerRevertedSubCall(t *testing.T) {
+	var (
+		caller = common.HexToAddress("0x0a")
+		callee = common.HexToAddress("0x0b")
+	)
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	// revert(0, 0)
+	statedb.SetCode(callee, common.Hex2Bytes("60006000fd"))
+	// call(gas, callee, 0, 0, 0, 0, 0); stop
+	statedb.SetCode(caller, append(append(common.Hex2Bytes("6000600060006000600073"), callee.Bytes()...), common.Hex2Bytes("5af100")...))
+
+	tracer := newCallTracer()
+	_, _, err := runtime.Call(caller, nil, &runtime.Config{
+		State:     statedb,
+		EVMConfig: vm.Config{EVMGasLimit: EVMGasLimit, Debug: true, Tracer: tracer},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tracer.root == nil || tracer.root.To != caller || tracer.root.Error != "" {
+		t.Fatalf("unexpected root frame %+v", tracer.root)
+	}
+	if len(tracer.root.Calls) != 1 {
+		t.Fatalf("expected 1 sub call, got %d", len(tracer.root.Calls))
+	}
+	sub := tracer.root.Calls[0]
+	if sub.Type != "CALL" || sub.From != caller || sub.To != callee {
+		t.Fatalf("unexpected sub call %+v", sub)
+	}
+	if sub.Error != "execution reverted" {
+		t.Fatalf("expected reverted sub call, got error %q", sub.Error)
+	}
+}
+
+// testCore serves the committed blocks and txs of a test
+type testCore struct {
+	blocks map[int64]*gtypes.Block
+	txs    map[common.Hash]*gtypes.ResultTransaction
+}
+
+func (c *testCore) Query(typ byte, load []byte) (interface{}, error) {
+	if tx, ok := c.txs[common.BytesToHash(load)]; ok && typ == gtypes.QueryTx {
+		return tx, nil
+	}
+	return nil, fmt.Errorf("no tx %x", load)
+}
+
+func (c *testCore) GetBlockMeta(height int64) (*gtypes.BlockMeta, error) {
+	return nil, nil
+}
+
+func (c *testCore) GetBlock(height int64) (*gtypes.Block, *gtypes.BlockMeta, error) {
+	return c.blocks[height], nil, nil
+}
+
+func TestTraceTransactionReplay(t *testing.T) {
+	var (
+		signer   = new(etypes.HomesteadSigner)
+		contract = common.HexToAddress("0x0c")
+	)
+	sender, _ := crypto.GenerateKey()
+	unpermitted, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(sender.PublicKey)
+
+	db := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	statedb.SetBalance(from, big.NewInt(1000))
+	statedb.SetBalance(crypto.PubkeyToAddress(unpermitted.PublicKey), big.NewInt(1000))
+	// returns the balance of its caller: caller balance push1 0 mstore push1 32 push1 0 return
+	statedb.SetCode(contract, common.Hex2Bytes("333160005260206000f3"))
+	// the admin contract keeps the permission table from being deleted
+	statedb.SetCode(vm.PermissionsAddress, []byte{0})
+	if err := vm.SetRestricted(statedb, vm.PermissionTransfer, true); err != nil {
+		t.Fatal(err)
+	}
+	root, err := statedb.Commit(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := statedb.Database().TrieDB().Commit(root, false); err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(key *ecdsa.PrivateKey, nonce uint64, to common.Address, value int64, data []byte) gtypes.Tx {
+		tx, err := etypes.SignTx(etypes.NewTransaction(nonce, to, big.NewInt(value), 100000, new(big.Int), data), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, _ := rlp.EncodeToBytes(tx)
+		return raw
+	}
+	adminInput := append(append(common.LeftPadBytes([]byte{21}, 32), from.Bytes()...), 'x')
+	txs := gtypes.Txs{
+		sign(sender, 0, common.BytesToAddress([]byte{254}), 0, adminInput),
+		// refused by the block as transfers are restricted
+		sign(unpermitted, 0, from, 100, nil),
+		sign(sender, 1, contract, 0, nil),
+	}
+	block := &gtypes.Block{
+		Header: &gtypes.Header{Height: 1, AppHash: root.Bytes()},
+		Data:   &gtypes.Data{Txs: txs},
+	}
+	traced := common.BytesToHash(txs[2].Hash())
+	app := &EVMApp{
+		stateDb:     db,
+		chainConfig: params.MainnetChainConfig,
+		Signer:      signer,
+		core: &testCore{
+			blocks: map[int64]*gtypes.Block{1: block},
+			txs: map[common.Hash]*gtypes.ResultTransaction{
+				traced: {BlockHeight: 1, TransactionIndex: 2, RawTransaction: txs[2], Status: gtypes.TxStatusValid},
+			},
+		},
+	}
+
+	var simulated []bool
+	vm.DefaultAdminContract.SetCallback(func(app *vm.AdminDBApp, data []byte) error {
+		simulated = append(simulated, app.Simulated() && app.Replayed())
+		return nil
+	})
+	defer vm.DefaultAdminContract.SetCallback(nil)
+
+	trace, err := app.traceTransaction(&rtypes.TraceArgs{TxHash: traced, Tracer: rtypes.TracerCallTree})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(simulated) != 1 || !simulated[0] {
+		t.Fatalf("expected the admin tx to be replayed simulated as committed, got %v", simulated)
+	}
+	if balance := new(big.Int).SetBytes(trace.ReturnValue); balance.Int64() != 1000 {
+		t.Fatalf("expected the refused transfer to be skipped, got the balance %v", balance)
+	}
+
+	delete(app.core.(*testCore).blocks, 1)
+	if _, err := app.traceTransaction(&rtypes.TraceArgs{TxHash: traced, Tracer: rtypes.TracerCallTree}); err == nil {
+		t.Fatal("expected a pruned block to fail")
+	}
+}
@@ -56,6 +56,7 @@ type ethTxPool struct {
 	pendingLimit    int           // pending queue size limit
 	height          int64
 	filter          []types.IFilter
+	txsAvailable    chan struct{}
 }
 
 func NewEthTxPool(app *EVMApp, conf *viper.Viper) *ethTxPool {
@@ -70,6 +71,7 @@ func NewEthTxPool(app *EVMApp, conf *viper.Viper) *ethTxPool {
 		pendingLimit:    conf.GetInt("block_size") * 10,
 		waitingLifeTime: waitingLifeTime,
 		app:             app,
+		txsAvailable:    make(chan struct{}, 1),
 	}
 }
 
@@ -247,6 +249,12 @@ func (tp *ethTxPool) CheckAndAdd(tx *etypes.Transaction, rawTx types.Tx) error {
 	}
 
 	from, _ := etypes.Sender(tp.app.Signer, tx)
+	tp.app.stateMtx.Lock()
+	err := checkSender(tp.app.state, from, tx)
+	tp.app.stateMtx.Unlock()
+	if err != nil {
+		return err
+	}
 	currentNonce := tp.safeGetNonce(from)
 	if currentNonce > tx.Nonce() {
 		return fmt.Errorf("nonce(%d) different with getNonce(%d)", tx.Nonce(), currentNonce)
@@ -303,6 +311,19 @@ func (tp *ethTxPool) Size() int {
 	return tp.extTxs.Len() + len(tp.all)
 }
 
+// ExecutableSize counts the txs that can go into the next block. Txs waiting
+// for a nonce gap to close are left out, so they do not keep consensus
+// proposing empty blocks.
+func (tp *ethTxPool) ExecutableSize() int {
+	tp.Lock()
+	defer tp.Unlock()
+	size := tp.extTxs.Len()
+	for _, accountTxs := range tp.pending {
+		size += accountTxs.Len()
+	}
+	return size
+}
+
 // blocking get first element of broadcast queue
 func (tp *ethTxPool) TxsFrontWait() *clist.CElement {
 	return tp.broadcastQueue.FrontWait()
@@ -449,6 +470,19 @@ func (tp *ethTxPool) broadcastNewTx(tx types.Tx) {
 		Tx:     tx,
 	}
 	tp.broadcastQueue.PushBack(memTx)
+	tp.notifyTxsAvailable()
+}
+
+// TxsAvailable returns a channel that fires once new txs entered the pool
+func (tp *ethTxPool) TxsAvailable() <-chan struct{} {
+	return tp.txsAvailable
+}
+
+func (tp *ethTxPool) notifyTxsAvailable() {
+	select {
+	case tp.txsAvailable <- struct{}{}:
+	default:
+	}
 }
 
 // Remove broadcast list transactions that are already in txs
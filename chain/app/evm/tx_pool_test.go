@@ -0,0 +1,62 @@
+// Copyright © 2017 ZhongAn Technology
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/dappledger/AnnChain/eth/common"
+	etypes "github.com/dappledger/AnnChain/eth/core/types"
+	"github.com/dappledger/AnnChain/eth/crypto"
+	"github.com/dappledger/AnnChain/eth/rlp"
+)
+
+func TestTxPoolExecutableSizeSkipsWaitingTxs(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	app := newCallTestApp(t, nil, from)
+	conf := viper.New()
+	conf.Set("block_size", 10)
+	tp := NewEthTxPool(app, conf)
+
+	add := func(nonce uint64) {
+		tx, err := etypes.SignTx(etypes.NewTransaction(nonce, common.HexToAddress("0x0b"), big.NewInt(1), 21000, new(big.Int), nil), app.Signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, _ := rlp.EncodeToBytes(tx)
+		if err := tp.CheckAndAdd(tx, raw); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// nonce 1 waits for nonce 0
+	add(1)
+	if size := tp.ExecutableSize(); size != 0 {
+		t.Fatalf("pool with a nonce gap has %d executable txs", size)
+	}
+	if size := tp.Size(); size != 1 {
+		t.Fatalf("pool size %d, expected the waiting tx counted", size)
+	}
+	add(0)
+	if size := tp.ExecutableSize(); size != 2 {
+		t.Fatalf("pool has %d executable txs, expected 2", size)
+	}
+	if size := tp.Size(); size != 2 {
+		t.Fatalf("pool size %d, expected 2", size)
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright © 2017 ZhongAn Technology
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/dappledger/AnnChain/chain/commands/global"
+	"github.com/dappledger/AnnChain/gemmill/archive"
+	"github.com/dappledger/AnnChain/gemmill/blockchain"
+	dbm "github.com/dappledger/AnnChain/gemmill/modules/go-db"
+	"github.com/dappledger/AnnChain/gemmill/utils/zip"
+)
+
+func NewArchiveCommand() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "archive",
+		Short: "manage archived block segments",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	c.AddCommand(ArchiveVerify())
+
+	return c
+}
+
+func ArchiveVerify() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "verify",
+		Short: "download every archived segment and verify it against its manifest and the live chain, the node must be stopped",
+		Args:  cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			runtime, _ := cmd.Flags().GetString("runtime")
+			if err = global.CheckAndReadRuntimeConfig(runtime); err == nil {
+				setFlags(cmd, global.GConf())
+			}
+			return err
+		},
+		Run: archiveVerify,
+	}
+
+	return c
+}
+
+func archiveVerify(cmd *cobra.Command, args []string) {
+	if err := verifyArchive(global.GConf()); err != nil {
+		fmt.Println("archive verify failed: ", err)
+		os.Exit(1)
+	}
+}
+
+func verifyArchive(conf *viper.Viper) error {
+	threshold := conf.GetInt64("threshold_blocks")
+	if threshold <= 0 {
+		return errors.New("archiving is disabled, threshold_blocks is 0")
+	}
+	client, err := archive.NewArchiveClient(conf)
+	if err != nil {
+		return err
+	}
+	if client == nil {
+		return errors.New("no archive_backend configured")
+	}
+	dbBackend := conf.GetString("db_backend")
+	ar := archive.NewArchive(dbBackend, conf.GetString("db_dir"), threshold, client)
+	defer ar.Close()
+	storeDB, archiveDB := blockchain.BlockStoreDB(conf)
+	defer storeDB.Close()
+	defer archiveDB.Close()
+	live := blockchain.NewBlockStore(storeDB, archiveDB)
+
+	tmpDir, err := ioutil.TempDir("", "archive-verify")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	var last *archive.Manifest
+	for from := int64(1); from <= live.OriginHeight(); from += threshold {
+		m, err := verifySegment(ar, dbBackend, tmpDir, from)
+		if err != nil {
+			return err
+		}
+		if m == nil {
+			last = nil
+			continue
+		}
+		if last != nil {
+			if err := last.VerifyNext(m.ParentHash); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("segment %d_%d ok, file %s, signed by %X\n", m.FromHeight, m.ToHeight, m.FileHash, m.PubKey.Address())
+		last = m
+	}
+	if last == nil {
+		fmt.Println("no archived segments with manifests")
+		return nil
+	}
+	next := live.LoadBlock(last.ToHeight + 1)
+	if next == nil {
+		return fmt.Errorf("block %d not found to link the last segment", last.ToHeight+1)
+	}
+	if err := last.VerifyNext(next.LastBlockID.Hash); err != nil {
+		return err
+	}
+	fmt.Printf("archive verified up to height %d\n", last.ToHeight)
+	return nil
+}
+
+// verifySegment downloads the segment holding height into dir and checks it
+// block by block against its manifest. A segment archived before manifests
+// existed is skipped with a nil manifest.
+func verifySegment(ar *archive.Archive, dbBackend, dir string, height int64) (*archive.Manifest, error) {
+	fileHash := string(ar.QueryFileHash(height))
+	if fileHash == "" {
+		return nil, fmt.Errorf("no archived segment holds height %d", height)
+	}
+	if ar.PreManifest(fileHash) {
+		fmt.Printf("segment of height %d, file %s, was archived before manifests, not verified\n", height, fileHash)
+		return nil, nil
+	}
+	m, err := ar.LoadManifest(fileHash)
+	if err != nil {
+		return nil, fmt.Errorf("segment %s: %v", fileHash, err)
+	}
+	if m.FileHash != fileHash {
+		return nil, fmt.Errorf("segment %s: manifest is for file %s", fileHash, m.FileHash)
+	}
+	if err := m.ValidateBasic(); err != nil {
+		return nil, fmt.Errorf("segment %s: %v", fileHash, err)
+	}
+
+	// the client checks the download against fileHash
+	zipPath := filepath.Join(dir, fileHash+".zip")
+	if err := ar.Client.DownloadFile(fileHash, zipPath); err != nil {
+		return nil, fmt.Errorf("segment %s: %v", fileHash, err)
+	}
+	defer os.Remove(zipPath)
+	dbPath := filepath.Join(dir, fileHash+".db")
+	if err := zip.Decompress(zipPath, dbPath); err != nil {
+		return nil, fmt.Errorf("segment %s: %v", fileHash, err)
+	}
+	defer os.RemoveAll(dbPath)
+	segmentDB := dbm.NewDB(fileHash, dbBackend, dir)
+	defer segmentDB.Close()
+	if err := m.VerifyBlocks(blockchain.NewBlockStore(segmentDB, nil).LoadBlock); err != nil {
+		return nil, fmt.Errorf("segment %s: %v", fileHash, err)
+	}
+	return m, nil
+}
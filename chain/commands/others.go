@@ -15,6 +15,7 @@ package commands
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -34,7 +35,7 @@ func NewShowCommand() *cobra.Command {
 		},
 	}
 
-	c.AddCommand(ShowPubkey())
+	c.AddCommand(ShowPubkey(), ShowGenesisHash())
 
 	return c
 }
@@ -64,6 +65,39 @@ func showPubkey(cmd *cobra.Command, args []string) {
 	fmt.Println(privValidator.PubKey.KeyString())
 }
 
+func ShowGenesisHash() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "genesis_hash",
+		Short: "print the hash of this node's genesis, which nodes joining without genesis file trust",
+		Args:  cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			runtime, _ := cmd.Flags().GetString("runtime")
+			if err = global.CheckAndReadRuntimeConfig(runtime); err == nil {
+				setFlags(cmd, global.GConf())
+			}
+			return err
+		},
+		Run: showGenesisHash,
+	}
+
+	return c
+}
+
+func showGenesisHash(cmd *cobra.Command, args []string) {
+	jsonBlob, err := ioutil.ReadFile(global.GConf().GetString("genesis_file"))
+	if err != nil {
+		fmt.Println("read genesis file error: ", err)
+		os.Exit(1)
+	}
+	genDoc, err := gtypes.GenesisDocFromJSONRet(jsonBlob)
+	if err != nil {
+		fmt.Println("parse genesis file error: ", err)
+		os.Exit(1)
+	}
+	fmt.Printf("%X\n", genDoc.Hash())
+}
+
 func NewVersionCommand() *cobra.Command {
 	c := &cobra.Command{
 		Use:   "version",
@@ -0,0 +1,109 @@
+// Copyright © 2017 ZhongAn Technology
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/dappledger/AnnChain/chain/commands/global"
+	log "github.com/dappledger/AnnChain/gemmill/modules/go-log"
+	"github.com/dappledger/AnnChain/gemmill/refuse_list"
+)
+
+func NewRefuseListCommand() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "refuselist",
+		Short: "inspect the refuse list, the node must be stopped",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	c.AddCommand(RefuseListList(), RefuseListHistory())
+
+	return c
+}
+
+func refuseListCommand(use, short string, run func(*cobra.Command, []string)) *cobra.Command {
+	c := &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			runtime, _ := cmd.Flags().GetString("runtime")
+			if err = global.CheckAndReadRuntimeConfig(runtime); err == nil {
+				setFlags(cmd, global.GConf())
+			}
+			return err
+		},
+		Run: run,
+	}
+	c.Flags().String("pubkey", "", "only the keys starting with this hex prefix")
+	c.Flags().String("reason", "", "only the reasons containing this text")
+	c.Flags().Int64("from_height", 0, "only from this height on")
+	c.Flags().Int64("to_height", 0, "only up to this height")
+
+	return c
+}
+
+func RefuseListList() *cobra.Command {
+	return refuseListCommand("list", "print the refused keys with their reason, source and expiry, expired ones are dropped", refuseListList)
+}
+
+func RefuseListHistory() *cobra.Command {
+	c := refuseListCommand("history", "print the changes made to the refuse list, oldest first", refuseListHistory)
+	c.Flags().Int("limit", 0, "only the last changes, 0 for all")
+
+	return c
+}
+
+func refuseListList(cmd *cobra.Command, args []string) {
+	rl, f := openRefuseList(cmd, global.GConf())
+	defer rl.Stop()
+	printRefuseList(rl.List(f))
+}
+
+func refuseListHistory(cmd *cobra.Command, args []string) {
+	rl, f := openRefuseList(cmd, global.GConf())
+	defer rl.Stop()
+	limit, _ := cmd.Flags().GetInt("limit")
+	printRefuseList(rl.History(f, limit))
+}
+
+func openRefuseList(cmd *cobra.Command, conf *viper.Viper) (*refuse_list.RefuseList, *refuse_list.Filter) {
+	log.SetLog(zap.NewNop())
+	f := &refuse_list.Filter{}
+	f.PubKey, _ = cmd.Flags().GetString("pubkey")
+	f.Reason, _ = cmd.Flags().GetString("reason")
+	f.FromHeight, _ = cmd.Flags().GetInt64("from_height")
+	f.ToHeight, _ = cmd.Flags().GetInt64("to_height")
+	return refuse_list.NewRefuseList(conf.GetString("db_backend"), conf.GetString("db_dir")), f
+}
+
+func printRefuseList(v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Println("refuselist failed: ", err)
+		os.Exit(1)
+	}
+	fmt.Println(string(data))
+}
@@ -47,6 +47,8 @@ func init() {
 		NewShowCommand(),
 		NewVersionCommand(),
 		NewResetCommand(),
+		NewArchiveCommand(),
+		NewRefuseListCommand(),
 	)
 
 	cobra.EnablePrefixMatching = true
@@ -40,6 +40,11 @@ func NewRunCommand() *cobra.Command {
 				return err
 			}
 			setFlags(cmd, global.GConf())
+			// not bound like chain_id, an unset flag must not override config.toml
+			if cmd.Flags().Changed("genesis_hash") {
+				genesisHash, _ := cmd.Flags().GetString("genesis_hash")
+				global.GConf().Set("genesis_hash", genesisHash)
+			}
 
 			logDir, _ := cmd.Flags().GetString("log_path")
 
@@ -57,6 +62,7 @@ func NewRunCommand() *cobra.Command {
 	}
 
 	c.Flags().StringP("chain_id", "", "", "specify the chain id when the node is joining it without genesis file")
+	c.Flags().StringP("genesis_hash", "", "", "the trusted genesis hash when the node is joining without genesis file, see 'show genesis_hash'")
 	c.Flags().BoolP("pprof", "", false, "start golang profile at port :6060")
 	c.Flags().BoolP("statistic", "", false, "start statistic tool on specified code lines")
 	c.Flags().BoolP("test", "", false, "run the node in test mode")
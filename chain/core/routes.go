@@ -14,17 +14,22 @@
 package core
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/dappledger/AnnChain/chain/types"
+	ethcmn "github.com/dappledger/AnnChain/eth/common"
+	"github.com/dappledger/AnnChain/eth/rlp"
+	"github.com/dappledger/AnnChain/gemmill"
 	"github.com/dappledger/AnnChain/gemmill/go-crypto"
+	"github.com/dappledger/AnnChain/gemmill/refuse_list"
 	rpc "github.com/dappledger/AnnChain/gemmill/rpc/server"
 	gtypes "github.com/dappledger/AnnChain/gemmill/types"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 )
 
 // RPCNode define the node's abilities provided for rpc calls
@@ -62,19 +67,25 @@ func (n *Node) rpcRoutes() map[string]*rpc.RPCFunc {
 
 		// info API
 		// "shards":               rpc.NewRPCFunc(h.Shards, ""),
-		"status":               rpc.NewRPCFunc(h.Status, ""),
-		"healthinfo":           rpc.NewRPCFunc(h.HealthInfo, ""),
-		"net_info":             rpc.NewRPCFunc(h.NetInfo, ""),
-		"blockchain":           rpc.NewRPCFunc(h.BlockchainInfo, "minHeight,maxHeight"),
-		"genesis":              rpc.NewRPCFunc(h.Genesis, ""),
-		"block":                rpc.NewRPCFunc(h.Block, "height"),
-		"validators":           rpc.NewRPCFunc(h.Validators, ""),
-		"dump_consensus_state": rpc.NewRPCFunc(h.DumpConsensusState, ""),
-		"unconfirmed_txs":      rpc.NewRPCFunc(h.UnconfirmedTxs, ""),
-		"num_unconfirmed_txs":  rpc.NewRPCFunc(h.NumUnconfirmedTxs, ""),
-		"num_archived_blocks":  rpc.NewRPCFunc(h.NumArchivedBlocks, ""),
-		"za_surveillance":      rpc.NewRPCFunc(h.ZaSurveillance, ""),
-		"core_version":         rpc.NewRPCFunc(h.CoreVersion, ""),
+		"status":                 rpc.NewRPCFunc(h.Status, ""),
+		"healthinfo":             rpc.NewRPCFunc(h.HealthInfo, ""),
+		"net_info":               rpc.NewRPCFunc(h.NetInfo, ""),
+		"sync_info":              rpc.NewRPCFunc(h.SyncInfo, ""),
+		"bans":                   rpc.NewRPCFunc(h.Bans, ""),
+		"plugins":                rpc.NewRPCFunc(h.Plugins, ""),
+		"blockchain":             rpc.NewRPCFunc(h.BlockchainInfo, "minHeight,maxHeight"),
+		"genesis":                rpc.NewRPCFunc(h.Genesis, ""),
+		"block":                  rpc.NewRPCFunc(h.Block, "height"),
+		"validators":             rpc.NewRPCFunc(h.Validators, "height"),
+		"commit":                 rpc.NewRPCFunc(h.Commit, "height"),
+		"validator_uptime":       rpc.NewRPCFunc(h.ValidatorUptime, ""),
+		"validator_signing_info": rpc.NewRPCFunc(h.ValidatorSigningInfo, "address"),
+		"dump_consensus_state":   rpc.NewRPCFunc(h.DumpConsensusState, ""),
+		"unconfirmed_txs":        rpc.NewRPCFunc(h.UnconfirmedTxs, ""),
+		"num_unconfirmed_txs":    rpc.NewRPCFunc(h.NumUnconfirmedTxs, ""),
+		"num_archived_blocks":    rpc.NewRPCFunc(h.NumArchivedBlocks, ""),
+		"za_surveillance":        rpc.NewRPCFunc(h.ZaSurveillance, ""),
+		"core_version":           rpc.NewRPCFunc(h.CoreVersion, ""),
 
 		"last_height": rpc.NewRPCFunc(h.LastHeight, ""),
 
@@ -89,9 +100,13 @@ func (n *Node) rpcRoutes() map[string]*rpc.RPCFunc {
 
 		"transaction": rpc.NewRPCFunc(h.GetTransactionByHash, "tx"),
 
+		// debug API
+		"debug_traceTransaction": rpc.NewRPCFunc(h.TraceTransaction, "hash,tracer,disable_memory,disable_stack,disable_storage"),
+
 		// control API
 		// "dial_seeds":           rpc.NewRPCFunc(h.UnsafeDialSeeds, "seeds"),
 		"unsafe_flush_mempool": rpc.NewRPCFunc(h.UnsafeFlushMempool, ""),
+		"unsafe_clear_bans":    rpc.NewRPCFunc(h.UnsafeClearBans, "id"),
 		// "unsafe_set_config":    rpc.NewRPCFunc(h.UnsafeSetConfig, "type,key,value"),
 
 		// profiler API
@@ -100,7 +115,13 @@ func (n *Node) rpcRoutes() map[string]*rpc.RPCFunc {
 		// "unsafe_write_heap_profile": rpc.NewRPCFunc(UnsafeWriteHeapProfileResult, "filename"),
 
 		// refuse_list API
-		"blacklist": rpc.NewRPCFunc(h.Blacklist, ""),
+		"blacklist":           rpc.NewRPCFunc(h.Blacklist, ""),
+		"refuse_list":         rpc.NewRPCFunc(h.RefuseList, "pubkey,reason,from_height,to_height"),
+		"refuse_list_history": rpc.NewRPCFunc(h.RefuseListHistory, "pubkey,reason,from_height,to_height,limit"),
+
+		// admin governance API
+		"admin_proposals": rpc.NewRPCFunc(h.AdminProposals, "status"),
+		"admin_proposal":  rpc.NewRPCFunc(h.AdminProposal, "id"),
 
 		// sharding API
 		// "shard_join": rpc.NewRPCFunc(h.ShardJoin, "gdata,cdata,sig"),
@@ -133,6 +154,10 @@ func (h *rpcHandler) Status() (*gtypes.ResultStatus, error) {
 		LatestBlockTime:   latestBlockTime}, nil
 }
 
+func (h *rpcHandler) SyncInfo() (*gtypes.ResultSyncInfo, error) {
+	return h.node.Angine.GetSyncInfo()
+}
+
 func (h *rpcHandler) Genesis() (*gtypes.ResultGenesis, error) {
 	return &gtypes.ResultGenesis{Genesis: h.node.GenesisDoc}, nil
 }
@@ -152,9 +177,21 @@ func (h *rpcHandler) Block(height int64) (*gtypes.ResultBlock, error) {
 	res := gtypes.ResultBlock{}
 	var err error
 	res.Block, res.BlockMeta, err = h.node.Angine.GetBlock(height)
+	if err == gemmill.ErrBlockPruned {
+		// the meta is all there is left
+		res.Pruned, err = true, nil
+	}
 	return &res, err
 }
 
+func (h *rpcHandler) Commit(height int64) (*gtypes.ResultCommit, error) {
+	header, commit, canonical, err := h.node.Angine.GetCommit(height)
+	if err != nil {
+		return nil, err
+	}
+	return &gtypes.ResultCommit{Header: header, Commit: commit, Canonical: canonical}, nil
+}
+
 func (h *rpcHandler) BlockchainInfo(minHeight, maxHeight int64) (*gtypes.ResultBlockchainInfo, error) {
 	if minHeight > maxHeight {
 		return nil, fmt.Errorf("maxHeight has to be bigger than minHeight")
@@ -181,7 +218,11 @@ func (h *rpcHandler) BlockchainInfo(minHeight, maxHeight int64) (*gtypes.ResultB
 		}
 		blockMetas = append(blockMetas, blockMeta)
 	}
-	return &gtypes.ResultBlockchainInfo{LastHeight: blockStoreHeight, BlockMetas: blockMetas}, nil
+	return &gtypes.ResultBlockchainInfo{
+		LastHeight:   blockStoreHeight,
+		PrunedHeight: h.node.Angine.PrunedHeight(),
+		BlockMetas:   blockMetas,
+	}, nil
 }
 
 func (h *rpcHandler) DumpConsensusState() (*gtypes.ResultDumpConsensusState, error) {
@@ -251,9 +292,39 @@ func (h *rpcHandler) Query(query []byte) (*gtypes.ResultQuery, error) {
 	return &gtypes.ResultQuery{Result: h.node.Application.Query(query)}, nil
 }
 
-func (h *rpcHandler) GetTransactionByHash(hash []byte) (*gtypes.ResultQuery, error) {
-	query := append([]byte{types.QueryType_TxRaw, gtypes.QueryTx}, hash...)
-	return &gtypes.ResultQuery{Result: h.node.Application.Query(query)}, nil
+func (h *rpcHandler) GetTransactionByHash(hash []byte) (*types.TxDetail, error) {
+	query := append([]byte{types.QueryType_TxDetail}, hash...)
+	res := h.node.Application.Query(query)
+	if res.IsErr() {
+		return nil, errors.New(res.Log)
+	}
+	detail := &types.TxDetail{}
+	if err := json.Unmarshal(res.Data, detail); err != nil {
+		return nil, err
+	}
+	return detail, nil
+}
+
+func (h *rpcHandler) TraceTransaction(hash []byte, tracer string, disableMemory, disableStack, disableStorage bool) (*types.TxTrace, error) {
+	load, err := rlp.EncodeToBytes(&types.TraceArgs{
+		TxHash:         ethcmn.BytesToHash(hash),
+		Tracer:         tracer,
+		DisableMemory:  disableMemory,
+		DisableStack:   disableStack,
+		DisableStorage: disableStorage,
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := h.node.Application.Query(append([]byte{types.QueryType_TraceTx}, load...))
+	if res.IsErr() {
+		return nil, errors.New(res.Log)
+	}
+	trace := &types.TxTrace{}
+	if err := json.Unmarshal(res.Data, trace); err != nil {
+		return nil, err
+	}
+	return trace, nil
 }
 
 func (h *rpcHandler) Info() (*gtypes.ResultInfo, error) {
@@ -261,14 +332,36 @@ func (h *rpcHandler) Info() (*gtypes.ResultInfo, error) {
 	return &res, nil
 }
 
-func (h *rpcHandler) Validators() (*gtypes.ResultValidators, error) {
-	_, vs := h.node.Angine.GetValidators()
+func (h *rpcHandler) Validators(height int64) (*gtypes.ResultValidators, error) {
+	if height > 0 {
+		vs, err := h.node.Angine.GetValidatorsAt(height)
+		if err != nil {
+			return nil, err
+		}
+		return &gtypes.ResultValidators{
+			Validators:   gtypes.MakeResultValidators(vs.Validators),
+			BlockHeight:  height,
+			ValidatorSet: vs,
+		}, nil
+	}
+	height, vs := h.node.Angine.GetValidators()
+	rvs := gtypes.MakeResultValidators(vs.Validators)
+	stats, params := h.node.Angine.GetProposerStats()
+	gtypes.SetProposerStats(rvs, stats, height+1, params)
 	return &gtypes.ResultValidators{
-		Validators:  gtypes.MakeResultValidators(vs.Validators),
+		Validators:  rvs,
 		BlockHeight: h.node.Angine.Height(),
 	}, nil
 }
 
+func (h *rpcHandler) ValidatorUptime() (*gtypes.ResultValidatorUptime, error) {
+	return h.node.Angine.GetValidatorUptime()
+}
+
+func (h *rpcHandler) ValidatorSigningInfo(address []byte) (*gtypes.ResultValidatorSigningInfo, error) {
+	return h.node.Angine.GetValidatorSigningInfo(address)
+}
+
 func (h *rpcHandler) CoreVersion() (*gtypes.ResultCoreVersion, error) {
 	appInfo := h.node.Application.Info()
 	vs := strings.Split(types.GetCommitVersion(), "-")
@@ -301,24 +394,25 @@ func (h *rpcHandler) ZaSurveillance() (*gtypes.ResultSurveillance, error) {
 		if startHeight < 1 {
 			startHeight = 1
 		}
-		eBlock, _, err := h.node.Angine.GetBlock(bcHeight)
+		// the metas are kept for the pruned blocks too
+		eMeta, err := h.node.Angine.GetBlockMeta(bcHeight)
 		if err != nil {
 			return nil, err
 		}
-		endTime := eBlock.Header.Time
-		sBlock, _, err := h.node.Angine.GetBlock(startHeight)
+		endTime := eMeta.Header.Time
+		sMeta, err := h.node.Angine.GetBlockMeta(startHeight)
 		if err != nil {
 			return nil, err
 		}
-		startTime := sBlock.Header.Time
-		totalNumTxs += int64(sBlock.Header.NumTxs)
+		startTime := sMeta.Header.Time
+		totalNumTxs += int64(sMeta.Header.NumTxs)
 		dura := endTime.Sub(startTime)
 		for height := startHeight + 1; height < bcHeight; height++ {
-			block, _, err := h.node.Angine.GetBlock(height)
+			meta, err := h.node.Angine.GetBlockMeta(height)
 			if err != nil {
 				return nil, err
 			}
-			totalNumTxs += int64(block.Header.NumTxs)
+			totalNumTxs += int64(meta.Header.NumTxs)
 		}
 		if totalNumTxs > 0 {
 			txAvg = int64(dura) / totalNumTxs
@@ -354,9 +448,48 @@ func (h *rpcHandler) ZaSurveillance() (*gtypes.ResultSurveillance, error) {
 func (h *rpcHandler) NetInfo() (*gtypes.ResultNetInfo, error) {
 	res := gtypes.ResultNetInfo{}
 	res.Listening, res.Listeners, res.Peers = h.node.Angine.GetP2PNetInfo()
+	res.Rejected = h.node.Angine.GetRejectedPeers()
 	return &res, nil
 }
 
+func (h *rpcHandler) Plugins() (*gtypes.ResultPlugins, error) {
+	return h.node.Angine.GetPlugins(), nil
+}
+
+func (h *rpcHandler) Bans() (*gtypes.ResultBans, error) {
+	return &gtypes.ResultBans{Bans: h.node.Angine.GetBans()}, nil
+}
+
+func (h *rpcHandler) UnsafeClearBans(id string) (*gtypes.ResultClearBans, error) {
+	return &gtypes.ResultClearBans{Cleared: h.node.Angine.ClearBans(id)}, nil
+}
+
 func (h *rpcHandler) Blacklist() (*gtypes.ResultRefuseList, error) {
 	return &gtypes.ResultRefuseList{Result: h.node.Angine.GetBlacklist()}, nil
 }
+
+func (h *rpcHandler) RefuseList(pubkey, reason string, fromHeight, toHeight int64) (*gtypes.ResultRefuseEntries, error) {
+	f := &refuse_list.Filter{PubKey: pubkey, Reason: reason, FromHeight: fromHeight, ToHeight: toHeight}
+	return &gtypes.ResultRefuseEntries{Entries: h.node.Angine.GetRefuseList(f)}, nil
+}
+
+func (h *rpcHandler) RefuseListHistory(pubkey, reason string, fromHeight, toHeight int64, limit int) (*gtypes.ResultRefuseHistory, error) {
+	f := &refuse_list.Filter{PubKey: pubkey, Reason: reason, FromHeight: fromHeight, ToHeight: toHeight}
+	return &gtypes.ResultRefuseHistory{Records: h.node.Angine.GetRefuseHistory(f, limit)}, nil
+}
+
+func (h *rpcHandler) AdminProposals(status string) (*gtypes.ResultAdminProposals, error) {
+	proposals, err := h.node.Angine.GetAdminProposals(status)
+	if err != nil {
+		return nil, err
+	}
+	return &gtypes.ResultAdminProposals{Proposals: proposals}, nil
+}
+
+func (h *rpcHandler) AdminProposal(id string) (*gtypes.ResultAdminProposal, error) {
+	proposal, err := h.node.Angine.GetAdminProposal(id)
+	if err != nil {
+		return nil, err
+	}
+	return &gtypes.ResultAdminProposal{Proposal: proposal}, nil
+}
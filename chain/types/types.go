@@ -13,7 +13,12 @@
 
 package types
 
-import "github.com/dappledger/AnnChain/eth/common"
+import (
+	"math/big"
+
+	"github.com/dappledger/AnnChain/eth/common"
+	etypes "github.com/dappledger/AnnChain/eth/core/types"
+)
 
 type (
 	// LastBlockInfo used for crash recover
@@ -30,9 +35,162 @@ type (
 		Message string
 	}
 
+	// TxDetail is the decoded view of a committed tx, valid or not
+	TxDetail struct {
+		Hash             common.Hash     `json:"hash"`
+		BlockHash        []byte          `json:"block_hash"`
+		BlockHeight      uint64          `json:"block_height"`
+		TransactionIndex uint64          `json:"transaction_index"`
+		Timestamp        uint64          `json:"timestamp"`
+		From             common.Address  `json:"from"`
+		To               *common.Address `json:"to"`
+		Nonce            uint64          `json:"nonce"`
+		Value            string          `json:"value"`
+		GasPrice         string          `json:"gas_price"`
+		Gas              uint64          `json:"gas"`
+		Input            []byte          `json:"input"`
+		Status           string          `json:"status"`
+		Error            string          `json:"error"`
+		Receipt          *etypes.Receipt `json:"receipt"`
+	}
+
+	// CallArgs carries a tx to be simulated against the latest state,
+	// optionally with some accounts' state replaced for that call only.
+	// The tx has to be signed unless From names the sender.
+	CallArgs struct {
+		Tx        []byte
+		Overrides []StateOverride
+		From      *common.Address `rlp:"nil"`
+	}
+
+	// StateOverride replaces balance, code and storage slots of an account,
+	// nil balance and empty code leave the original value in place
+	StateOverride struct {
+		Address common.Address
+		Balance *big.Int `rlp:"nil"`
+		Code    []byte
+		Storage []StorageOverride
+	}
+
+	StorageOverride struct {
+		Key   common.Hash
+		Value common.Hash
+	}
+
+	// TraceArgs selects the tx to trace, the tracer used and what it captures,
+	// an empty Tracer means opcode level struct logs
+	TraceArgs struct {
+		TxHash         common.Hash
+		Tracer         string
+		DisableMemory  bool
+		DisableStack   bool
+		DisableStorage bool
+	}
+
+	// ProofArgs asks for the proof of an account and some of its storage
+	// slots in the state after the block at Height
+	ProofArgs struct {
+		Address common.Address
+		Keys    []common.Hash
+		Height  uint64
+	}
+
+	// AccountProof holds the state trie nodes proving an account against the
+	// AppHash of the block at Height+1, and the storage trie nodes proving
+	// each key against the account's storage root.
+	AccountProof struct {
+		Address      common.Address
+		Height       uint64
+		AccountProof [][]byte
+		StorageProof []StorageProof
+	}
+
+	StorageProof struct {
+		Key   common.Hash
+		Proof [][]byte
+	}
+
+	// FreezeEvent is a freeze or an unfreeze of an account by an admin tx
+	FreezeEvent struct {
+		Address common.Address `json:"address"`
+		Frozen  bool           `json:"frozen"`
+		Reason  string         `json:"reason,omitempty"`
+		Height  uint64         `json:"height"`
+		TxHash  common.Hash    `json:"tx_hash"`
+	}
+
+	// FrozenArgs selects the freeze events returned with the frozen
+	// accounts, the ones of Address if it is not nil. The events start at
+	// the first one from FromHeight on, or at the Start-th one if further.
+	FrozenArgs struct {
+		Address    *common.Address `rlp:"nil"`
+		FromHeight uint64
+		Start      uint64 // Next of the previous page
+		Limit      uint64 // 0 or above MaxFreezeEvents for MaxFreezeEvents
+	}
+
+	// FrozenAccounts are the accounts frozen in the latest state and a page
+	// of the events which froze or unfroze them. Next is the Start of the
+	// next page, 0 when there is none, Total counts all the events.
+	FrozenAccounts struct {
+		Frozen []common.Address `json:"frozen"`
+		Events []*FreezeEvent   `json:"events"`
+		Next   uint64           `json:"next,omitempty"`
+		Total  uint64           `json:"total"`
+	}
+
+	// TxTrace is the result of re-executing a committed tx under a tracer
+	TxTrace struct {
+		Gas         uint64         `json:"gas"`
+		Failed      bool           `json:"failed"`
+		ReturnValue []byte         `json:"return_value"`
+		StructLogs  []StructLogRes `json:"struct_logs,omitempty"`
+		Calls       *CallFrame     `json:"calls,omitempty"`
+	}
+
+	// StructLogRes is one executed opcode, stack and memory are hex encoded 32 byte words
+	StructLogRes struct {
+		Pc      uint64         `json:"pc"`
+		Op      string         `json:"op"`
+		Gas     uint64         `json:"gas"`
+		GasCost uint64         `json:"gas_cost"`
+		Depth   int            `json:"depth"`
+		Error   string         `json:"error,omitempty"`
+		Stack   []string       `json:"stack,omitempty"`
+		Memory  []string       `json:"memory,omitempty"`
+		Storage []StorageEntry `json:"storage,omitempty"`
+	}
+
+	StorageEntry struct {
+		Key   common.Hash `json:"key"`
+		Value common.Hash `json:"value"`
+	}
+
+	// CallFrame is a node of the call tree built by the call tracer
+	CallFrame struct {
+		Type    string         `json:"type"`
+		From    common.Address `json:"from"`
+		To      common.Address `json:"to"`
+		Value   string         `json:"value,omitempty"`
+		Gas     uint64         `json:"gas"`
+		GasUsed uint64         `json:"gas_used"`
+		Input   []byte         `json:"input"`
+		Output  []byte         `json:"output,omitempty"`
+		Error   string         `json:"error,omitempty"`
+		Calls   []*CallFrame   `json:"calls,omitempty"`
+	}
+
 	QueryType = byte
 )
 
+const (
+	TracerStructLogs = ""
+	TracerCallTree   = "callTracer"
+
+	// MaxFreezeEvents bounds the freeze events of a frozen query
+	MaxFreezeEvents = 100
+)
+
 const (
 	APIQueryTx                          = iota
 	QueryType_Contract        QueryType = 0
@@ -44,4 +202,12 @@ const (
 	QueryType_TxRaw           QueryType = 6
 	QueryTxLimit              QueryType = 9
 	QueryTypeContractByHeight QueryType = 10
+	QueryType_TxDetail        QueryType = 11
+	QueryType_EstimateGas     QueryType = 12
+	QueryType_Call            QueryType = 13
+	QueryType_TraceTx         QueryType = 14
+	QueryType_Code            QueryType = 15
+	QueryType_Proof           QueryType = 16
+	QueryType_Permissions     QueryType = 17
+	QueryType_Frozen          QueryType = 18
 )
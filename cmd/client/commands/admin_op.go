@@ -27,6 +27,7 @@ import (
 	"github.com/dappledger/AnnChain/chain/types"
 	"github.com/dappledger/AnnChain/cmd/client/commons"
 	"github.com/dappledger/AnnChain/eth/accounts/abi"
+	"github.com/dappledger/AnnChain/eth/common"
 	"github.com/dappledger/AnnChain/eth/core"
 	etypes "github.com/dappledger/AnnChain/eth/core/types"
 	"github.com/dappledger/AnnChain/eth/rlp"
@@ -53,6 +54,9 @@ var (
 					anntoolFlags.cType,
 					anntoolFlags.verbose,
 					anntoolFlags.nPrivs,
+					anntoolFlags.propose,
+					anntoolFlags.votingPeriod,
+					anntoolFlags.description,
 				},
 			},
 			{
@@ -61,8 +65,13 @@ var (
 				Action: RemoveNode,
 				Flags: []cli.Flag{
 					anntoolFlags.validatorPubkey,
+					anntoolFlags.reason,
+					anntoolFlags.refuseFor,
 					anntoolFlags.verbose,
 					anntoolFlags.nPrivs,
+					anntoolFlags.propose,
+					anntoolFlags.votingPeriod,
+					anntoolFlags.description,
 				},
 			},
 			{
@@ -75,6 +84,96 @@ var (
 					anntoolFlags.cType,
 					anntoolFlags.verbose,
 					anntoolFlags.nPrivs,
+					anntoolFlags.propose,
+					anntoolFlags.votingPeriod,
+					anntoolFlags.description,
+				},
+			},
+			{
+				Name:   "change_consensus_params",
+				Usage:  "change consensus params of all validators from next height on",
+				Action: ChangeConsensusParams,
+				Flags: []cli.Flag{
+					anntoolFlags.consensusParams,
+					anntoolFlags.cType,
+					anntoolFlags.verbose,
+					anntoolFlags.nPrivs,
+					anntoolFlags.propose,
+					anntoolFlags.votingPeriod,
+					anntoolFlags.description,
+				},
+			},
+			{
+				Name:   "change_permissions",
+				Usage:  "change who may deploy contracts or move value, needs +2/3 signatures and can't be proposed",
+				Action: ChangePermissions,
+				Flags: []cli.Flag{
+					anntoolFlags.restrict,
+					anntoolFlags.unrestrict,
+					anntoolFlags.grant,
+					anntoolFlags.revoke,
+					anntoolFlags.accounts,
+					anntoolFlags.cType,
+					anntoolFlags.verbose,
+					anntoolFlags.nPrivs,
+				},
+			},
+			{
+				Name:   "freeze",
+				Usage:  "freeze accounts, they can't send txs anymore; needs +2/3 signatures and can't be proposed",
+				Action: FreezeAccounts,
+				Flags: []cli.Flag{
+					anntoolFlags.accounts,
+					anntoolFlags.reason,
+					anntoolFlags.cType,
+					anntoolFlags.verbose,
+					anntoolFlags.nPrivs,
+				},
+			},
+			{
+				Name:   "unfreeze",
+				Usage:  "unfreeze accounts, needs +2/3 signatures and can't be proposed",
+				Action: UnfreezeAccounts,
+				Flags: []cli.Flag{
+					anntoolFlags.accounts,
+					anntoolFlags.reason,
+					anntoolFlags.cType,
+					anntoolFlags.verbose,
+					anntoolFlags.nPrivs,
+				},
+			},
+			{
+				Name:  "proposal",
+				Usage: "admin operations put to the vote of the validators",
+				Subcommands: []cli.Command{
+					{
+						Name:   "list",
+						Usage:  "list the proposals",
+						Action: ListProposals,
+						Flags: []cli.Flag{
+							anntoolFlags.status,
+						},
+					},
+					{
+						Name:   "show",
+						Usage:  "show a proposal with its votes",
+						Action: ShowProposal,
+						Flags: []cli.Flag{
+							anntoolFlags.proposalID,
+						},
+					},
+					{
+						Name:   "vote",
+						Usage:  "vote for a proposal, or against it with --reject, with the node privkeys",
+						Action: VoteProposal,
+						Flags: []cli.Flag{
+							anntoolFlags.proposalID,
+							anntoolFlags.reject,
+							anntoolFlags.cType,
+							anntoolFlags.verbose,
+							anntoolFlags.nPrivs,
+						},
+					},
 				},
 			},
 		},
@@ -105,6 +204,62 @@ func NPrivs() string {
 	return anntoolFlags.nPrivs.GetName()
 }
 
+func ConsensusParams() string {
+	return anntoolFlags.consensusParams.GetName()
+}
+
+func Reason() string {
+	return anntoolFlags.reason.GetName()
+}
+
+func RefuseFor() string {
+	return anntoolFlags.refuseFor.GetName()
+}
+
+func Propose() string {
+	return anntoolFlags.propose.GetName()
+}
+
+func VotingPeriod() string {
+	return anntoolFlags.votingPeriod.GetName()
+}
+
+func Description() string {
+	return anntoolFlags.description.GetName()
+}
+
+func ProposalID() string {
+	return anntoolFlags.proposalID.GetName()
+}
+
+func Reject() string {
+	return anntoolFlags.reject.GetName()
+}
+
+func Status() string {
+	return anntoolFlags.status.GetName()
+}
+
+func Restrict() string {
+	return anntoolFlags.restrict.GetName()
+}
+
+func Unrestrict() string {
+	return anntoolFlags.unrestrict.GetName()
+}
+
+func Grant() string {
+	return anntoolFlags.grant.GetName()
+}
+
+func Revoke() string {
+	return anntoolFlags.revoke.GetName()
+}
+
+func Accounts() string {
+	return anntoolFlags.accounts.GetName()
+}
+
 //-------------------------------------------------------------------
 func AddPeer(ctx *cli.Context) error {
 	crypto.NodeInit(ctx.String("crypto_type"))
@@ -125,6 +280,9 @@ func AddPeer(ctx *cli.Context) error {
 	if err != nil {
 		return cli.NewExitError("MakeAddPeerMsg :"+err.Error(), 127)
 	}
+	if err = proposeIfSet(ctx, au); err != nil {
+		return cli.NewExitError("MakeProposalMsg :"+err.Error(), 127)
+	}
 	hash, err := adminContractCall(au)
 	if err != nil {
 		return cli.NewExitError("adminContractCall :"+err.Error(), 127)
@@ -142,10 +300,13 @@ func RemoveNode(ctx *cli.Context) error {
 		return cli.NewExitError("NewAdminOPUser :"+err.Error(), 127)
 	}
 	validatorPub := gcommon.SanitizeHex(ctx.String(PubKey()))
-	err = au.MakeRemoveNodeMsg(validatorPub)
+	err = au.MakeRemoveNodeMsg(validatorPub, ctx.String(Reason()), ctx.Duration(RefuseFor()))
 	if err != nil {
 		return cli.NewExitError("MakeAddPeerMsg :"+err.Error(), 127)
 	}
+	if err = proposeIfSet(ctx, au); err != nil {
+		return cli.NewExitError("MakeProposalMsg :"+err.Error(), 127)
+	}
 	hash, err := adminContractCall(au)
 	if err != nil {
 		return cli.NewExitError("adminContractCall :"+err.Error(), 127)
@@ -175,6 +336,9 @@ func UpdateNode(ctx *cli.Context) error {
 	if err != nil {
 		return cli.NewExitError("MakeAddPeerMsg :"+err.Error(), 127)
 	}
+	if err = proposeIfSet(ctx, au); err != nil {
+		return cli.NewExitError("MakeProposalMsg :"+err.Error(), 127)
+	}
 	hash, err := adminContractCall(au)
 	if err != nil {
 		return cli.NewExitError("MakeAddPeerMsg :"+err.Error(), 127)
@@ -183,6 +347,181 @@ func UpdateNode(ctx *cli.Context) error {
 	return nil
 }
 
+func ChangeConsensusParams(ctx *cli.Context) error {
+	if !ctx.IsSet(ConsensusParams()) {
+		return cli.NewExitError("missing consensus params", 127)
+	}
+	params := &gtypes.ConsensusParams{}
+	if err := json.Unmarshal([]byte(ctx.String(ConsensusParams())), params); err != nil {
+		return cli.NewExitError("parse consensus params :"+err.Error(), 127)
+	}
+	if err := params.ValidateBasic(); err != nil {
+		return cli.NewExitError("invalid consensus params :"+err.Error(), 127)
+	}
+	au, err := NewAdminOPUser(ctx)
+	if err != nil {
+		return cli.NewExitError("NewAdminOPUser :"+err.Error(), 127)
+	}
+	err = au.MakeConsensusParamsMsg(params)
+	if err != nil {
+		return cli.NewExitError("MakeConsensusParamsMsg :"+err.Error(), 127)
+	}
+	if err = proposeIfSet(ctx, au); err != nil {
+		return cli.NewExitError("MakeProposalMsg :"+err.Error(), 127)
+	}
+	hash, err := adminContractCall(au)
+	if err != nil {
+		return cli.NewExitError("adminContractCall :"+err.Error(), 127)
+	}
+	fmt.Println("hash=", hash)
+	return nil
+}
+
+func ChangePermissions(ctx *cli.Context) error {
+	attr := &gtypes.PermissionsAttr{
+		Restrict:   splitList(ctx.String(Restrict())),
+		Unrestrict: splitList(ctx.String(Unrestrict())),
+		Grant:      splitList(ctx.String(Grant())),
+		Revoke:     splitList(ctx.String(Revoke())),
+	}
+	var err error
+	if attr.Accounts, err = parseAccounts(ctx); err != nil {
+		return cli.NewExitError(err.Error(), 127)
+	}
+	if len(attr.Accounts) == 0 && (len(attr.Grant) > 0 || len(attr.Revoke) > 0) {
+		return cli.NewExitError("missing accounts", 127)
+	}
+	au, err := NewAdminOPUser(ctx)
+	if err != nil {
+		return cli.NewExitError("NewAdminOPUser :"+err.Error(), 127)
+	}
+	err = au.MakePermissionsMsg(attr)
+	if err != nil {
+		return cli.NewExitError("MakePermissionsMsg :"+err.Error(), 127)
+	}
+	hash, err := adminContractCall(au)
+	if err != nil {
+		return cli.NewExitError("adminContractCall :"+err.Error(), 127)
+	}
+	fmt.Println("hash=", hash)
+	return nil
+}
+
+func FreezeAccounts(ctx *cli.Context) error {
+	return freezeAccounts(ctx, true)
+}
+
+func UnfreezeAccounts(ctx *cli.Context) error {
+	return freezeAccounts(ctx, false)
+}
+
+func freezeAccounts(ctx *cli.Context, freeze bool) error {
+	accounts, err := parseAccounts(ctx)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 127)
+	}
+	if len(accounts) == 0 {
+		return cli.NewExitError("missing accounts", 127)
+	}
+	au, err := NewAdminOPUser(ctx)
+	if err != nil {
+		return cli.NewExitError("NewAdminOPUser :"+err.Error(), 127)
+	}
+	err = au.MakeFreezeMsg(accounts, freeze, ctx.String(Reason()))
+	if err != nil {
+		return cli.NewExitError("MakeFreezeMsg :"+err.Error(), 127)
+	}
+	hash, err := adminContractCall(au)
+	if err != nil {
+		return cli.NewExitError("adminContractCall :"+err.Error(), 127)
+	}
+	fmt.Println("hash=", hash)
+	return nil
+}
+
+// parseAccounts returns the addresses of the accounts flag
+func parseAccounts(ctx *cli.Context) ([][]byte, error) {
+	var accounts [][]byte
+	for _, a := range splitList(ctx.String(Accounts())) {
+		addr, err := hex.DecodeString(gcommon.SanitizeHex(a))
+		if err != nil || len(addr) != common.AddressLength {
+			return nil, fmt.Errorf("invalid account %s", a)
+		}
+		accounts = append(accounts, addr)
+	}
+	return accounts, nil
+}
+
+// splitList splits a comma separated flag value, dropping the empty items
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// proposeIfSet turns the op of au into a proposal if --propose is set, the
+// node privkeys signing it approve it
+func proposeIfSet(ctx *cli.Context, au *AdminOPUser) error {
+	if !ctx.Bool(Propose()) {
+		return nil
+	}
+	return au.MakeProposalMsg(ctx.Int64(VotingPeriod()), ctx.String(Description()))
+}
+
+func ListProposals(ctx *cli.Context) error {
+	clt := rpcclient.NewClientJSONRPC(commons.QueryServer)
+	rpcResult := new(gtypes.ResultAdminProposals)
+	if _, err := clt.Call("admin_proposals", []interface{}{ctx.String(Status())}, rpcResult); err != nil {
+		return cli.NewExitError("admin_proposals :"+err.Error(), 127)
+	}
+	for _, p := range rpcResult.Proposals {
+		fmt.Printf("id=%s op=%s status=%s height=%d deadline=%d votes=%d %s\n",
+			p.ID, p.Op.CmdType, p.Status, p.Height, p.Deadline, len(p.Votes), p.Description)
+	}
+	return nil
+}
+
+func ShowProposal(ctx *cli.Context) error {
+	if !ctx.IsSet(ProposalID()) {
+		return cli.NewExitError("missing proposal id", 127)
+	}
+	clt := rpcclient.NewClientJSONRPC(commons.QueryServer)
+	rpcResult := new(gtypes.ResultAdminProposal)
+	if _, err := clt.Call("admin_proposal", []interface{}{ctx.String(ProposalID())}, rpcResult); err != nil {
+		return cli.NewExitError("admin_proposal :"+err.Error(), 127)
+	}
+	data, err := json.MarshalIndent(rpcResult.Proposal, "", "  ")
+	if err != nil {
+		return cli.NewExitError(err.Error(), 127)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func VoteProposal(ctx *cli.Context) error {
+	if !ctx.IsSet(ProposalID()) {
+		return cli.NewExitError("missing proposal id", 127)
+	}
+	au, err := NewAdminOPUser(ctx)
+	if err != nil {
+		return cli.NewExitError("NewAdminOPUser :"+err.Error(), 127)
+	}
+	err = au.MakeProposalVoteMsg(ctx.String(ProposalID()), !ctx.Bool(Reject()))
+	if err != nil {
+		return cli.NewExitError("MakeProposalVoteMsg :"+err.Error(), 127)
+	}
+	hash, err := adminContractCall(au)
+	if err != nil {
+		return cli.NewExitError("adminContractCall :"+err.Error(), 127)
+	}
+	fmt.Println("hash=", hash)
+	return nil
+}
+
 func adminContractCall(au *AdminOPUser) (string, error) {
 	var abiJson abi.ABI
 	abiJson, err := abi.JSON(strings.NewReader(core.AdminABI))
@@ -302,16 +641,99 @@ func (au *AdminOPUser) MakeUpdateNodeMsg(pub string, power int64) error {
 	return err
 }
 
-func (au *AdminOPUser) MakeRemoveNodeMsg(pub string) error {
+// MakeRemoveNodeMsg removes the node pub, which stays in the refuse list for
+// refuseFor, or for ever if 0
+func (au *AdminOPUser) MakeRemoveNodeMsg(pub, reason string, refuseFor time.Duration) error {
 	vAttr, err := au.makeValidatorAttr(pub, 0, gtypes.ValidatorCmdRemoveNode)
 	if err != nil {
 		return err
 	}
+	vAttr.Reason = reason
+	vAttr.RefuseFor = int64(refuseFor / time.Second)
 	scmd, err := au.ContractsAdminCmd(vAttr)
 	au.adminOPData, err = json.Marshal(scmd)
 	return err
 }
 
+func (au *AdminOPUser) MakeConsensusParamsMsg(params *gtypes.ConsensusParams) error {
+	pAttr := &gtypes.ConsensusParamsAttr{
+		Params: params,
+		Addr:   au.Addr,
+		Nonce:  au.Nonce,
+	}
+	pdata, err := json.Marshal(pAttr)
+	if err != nil {
+		return err
+	}
+	au.adminOPData, err = json.Marshal(au.signAdminCmd(gtypes.AdminOpChangeConsensusParams, pdata))
+	return err
+}
+
+func (au *AdminOPUser) MakePermissionsMsg(attr *gtypes.PermissionsAttr) error {
+	attr.Addr = au.Addr
+	attr.Nonce = au.Nonce
+	pdata, err := json.Marshal(attr)
+	if err != nil {
+		return err
+	}
+	au.adminOPData, err = json.Marshal(au.signAdminCmd(gtypes.AdminOpChangePermissions, pdata))
+	return err
+}
+
+func (au *AdminOPUser) MakeFreezeMsg(accounts [][]byte, freeze bool, reason string) error {
+	fAttr := &gtypes.FreezeAttr{
+		Accounts: accounts,
+		Freeze:   freeze,
+		Reason:   reason,
+		Addr:     au.Addr,
+		Nonce:    au.Nonce,
+	}
+	fdata, err := json.Marshal(fAttr)
+	if err != nil {
+		return err
+	}
+	au.adminOPData, err = json.Marshal(au.signAdminCmd(gtypes.AdminOpFreezeAccounts, fdata))
+	return err
+}
+
+// MakeProposalMsg wraps the op made before into a proposal, which the node
+// privkeys approve by signing it
+func (au *AdminOPUser) MakeProposalMsg(votingPeriod int64, description string) error {
+	op := &gtypes.AdminOPCmd{}
+	if err := json.Unmarshal(au.adminOPData, op); err != nil {
+		return err
+	}
+	op.SInfos = nil
+	pAttr := &gtypes.AdminProposalAttr{
+		Op:           op,
+		VotingPeriod: votingPeriod,
+		Description:  description,
+		Addr:         au.Addr,
+		Nonce:        au.Nonce,
+	}
+	pdata, err := json.Marshal(pAttr)
+	if err != nil {
+		return err
+	}
+	au.adminOPData, err = json.Marshal(au.signAdminCmd(gtypes.AdminOpPropose, pdata))
+	return err
+}
+
+func (au *AdminOPUser) MakeProposalVoteMsg(id string, approve bool) error {
+	vAttr := &gtypes.AdminProposalVoteAttr{
+		ProposalID: strings.ToUpper(gcommon.SanitizeHex(id)),
+		Approve:    approve,
+		Addr:       au.Addr,
+		Nonce:      au.Nonce,
+	}
+	vdata, err := json.Marshal(vAttr)
+	if err != nil {
+		return err
+	}
+	au.adminOPData, err = json.Marshal(au.signAdminCmd(gtypes.AdminOpProposalVote, vdata))
+	return err
+}
+
 func (au *AdminOPUser) makeValidatorAttr(nodepub string, power int64, cmd gtypes.ValidatorCmd) (*gtypes.ValidatorAttr, error) {
 	if strings.HasPrefix(nodepub, "0x") || strings.HasPrefix(nodepub, "0X") {
 		nodepub = nodepub[2:]
@@ -334,14 +756,19 @@ func (au *AdminOPUser) ContractsAdminCmd(vAttr *gtypes.ValidatorAttr) (*gtypes.A
 	if err != nil {
 		return nil, err
 	}
+	return au.signAdminCmd(gtypes.AdminOpChangeValidator, vdata), nil
+}
+
+// signAdminCmd wraps msg into an admin cmd signed by all node privkeys
+func (au *AdminOPUser) signAdminCmd(cmdType string, msg []byte) *gtypes.AdminOPCmd {
 	scmd := &gtypes.AdminOPCmd{}
-	scmd.CmdType = gtypes.AdminOpChangeValidator
+	scmd.CmdType = cmdType
 	scmd.Time = time.Now()
-	scmd.Msg = vdata
+	scmd.Msg = msg
 	for _, pk := range au.privs {
 		pubk := crypto.GetNodePubkeyBytes(pk.PubKey())
-		sigbytes := crypto.GetNodeSigBytes(pk.Sign(vdata))
-		scmd.SInfos = append(scmd.SInfos, gtypes.SigInfo{pubk, sigbytes})
+		sigbytes := crypto.GetNodeSigBytes(pk.Sign(msg))
+		scmd.SInfos = append(scmd.SInfos, gtypes.SigInfo{PubKey: pubk, Signature: sigbytes})
 	}
-	return scmd, nil
+	return scmd
 }
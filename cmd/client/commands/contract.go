@@ -0,0 +1,114 @@
+// Copyright © 2017 ZhongAn Technology
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"fmt"
+
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/dappledger/AnnChain/cmd/client/commons"
+	"github.com/dappledger/AnnChain/eth/common"
+	gcmn "github.com/dappledger/AnnChain/gemmill/modules/go-common"
+)
+
+var (
+	//ContractCommands manages the local contract abi registry
+	ContractCommands = cli.Command{
+		Name:     "contract",
+		Usage:    "manage the local contract abi registry",
+		Category: "Contract",
+		Subcommands: []cli.Command{
+			{
+				Name:      "register",
+				Usage:     "register the abi of a deployed contract",
+				ArgsUsage: "<address> <abi json or file>",
+				Action:    registerContract,
+			},
+			{
+				Name:      "remove",
+				Usage:     "remove a contract from the registry",
+				ArgsUsage: "<address>",
+				Action:    removeContract,
+			},
+			{
+				Name:   "list",
+				Usage:  "list the registered contracts",
+				Action: listContracts,
+			},
+		},
+	}
+)
+
+func registerContract(ctx *cli.Context) error {
+	if ctx.NArg() != 2 {
+		return cli.NewExitError("usage: contract register <address> <abi json or file>", 127)
+	}
+	addrHex := gcmn.SanitizeHex(ctx.Args().Get(0))
+	if addrHex == "" {
+		return cli.NewExitError(commons.ErrEmptyContractAddress.Error(), 127)
+	}
+	abiJSON, err := fileData(ctx.Args().Get(1))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 127)
+	}
+	reg, err := commons.LoadABIRegistry()
+	if err != nil {
+		return cli.NewExitError(err.Error(), 127)
+	}
+	addr := common.HexToAddress(addrHex)
+	if err := reg.Register(addr, string(abiJSON)); err != nil {
+		return cli.NewExitError(err.Error(), 127)
+	}
+	if err := reg.Save(); err != nil {
+		return cli.NewExitError(err.Error(), 127)
+	}
+	fmt.Println("registered:", addr.Hex())
+	return nil
+}
+
+func removeContract(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return cli.NewExitError("usage: contract remove <address>", 127)
+	}
+	reg, err := commons.LoadABIRegistry()
+	if err != nil {
+		return cli.NewExitError(err.Error(), 127)
+	}
+	addr := common.HexToAddress(gcmn.SanitizeHex(ctx.Args().Get(0)))
+	if !reg.Remove(addr) {
+		return cli.NewExitError("contract not registered: "+addr.Hex(), 127)
+	}
+	if err := reg.Save(); err != nil {
+		return cli.NewExitError(err.Error(), 127)
+	}
+	fmt.Println("removed:", addr.Hex())
+	return nil
+}
+
+func listContracts(ctx *cli.Context) error {
+	reg, err := commons.LoadABIRegistry()
+	if err != nil {
+		return cli.NewExitError(err.Error(), 127)
+	}
+	for _, addr := range reg.Addresses() {
+		def, err := reg.Lookup(common.HexToAddress(addr))
+		if err != nil {
+			fmt.Printf("%s: invalid abi: %v\n", addr, err)
+			continue
+		}
+		fmt.Printf("%s: %d methods, %d events\n", addr, len(def.Methods), len(def.Events))
+	}
+	return nil
+}
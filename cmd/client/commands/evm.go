@@ -20,7 +20,6 @@ import (
 	"io/ioutil"
 	"math/big"
 	"os"
-	"reflect"
 	"strings"
 
 	"github.com/bitly/go-simplejson"
@@ -29,6 +28,7 @@ import (
 	"github.com/dappledger/AnnChain/cmd/client/commons"
 	"github.com/dappledger/AnnChain/eth/accounts/abi"
 	"github.com/dappledger/AnnChain/eth/common"
+	"github.com/dappledger/AnnChain/eth/common/hexutil"
 	etypes "github.com/dappledger/AnnChain/eth/core/types"
 	"github.com/dappledger/AnnChain/eth/crypto"
 	"github.com/dappledger/AnnChain/eth/rlp"
@@ -58,9 +58,10 @@ var (
 					anntoolFlags.callf,
 				},
 			}, {
-				Name:   "call",
-				Usage:  "execute a new contract",
-				Action: callContract,
+				Name:      "call",
+				Usage:     "execute a new contract",
+				ArgsUsage: "[args of --sig ...]",
+				Action:    callContract,
 				Flags: []cli.Flag{
 					anntoolFlags.payload,
 					anntoolFlags.nonce,
@@ -69,11 +70,13 @@ var (
 					anntoolFlags.to,
 					anntoolFlags.abif,
 					anntoolFlags.callf,
+					anntoolFlags.sig,
 				},
 			}, {
-				Name:   "read",
-				Usage:  "read a contract",
-				Action: readContract,
+				Name:      "read",
+				Usage:     "read a contract",
+				ArgsUsage: "[args of --sig ...]",
+				Action:    readContract,
 				Flags: []cli.Flag{
 					anntoolFlags.payload,
 					anntoolFlags.nonce,
@@ -82,6 +85,7 @@ var (
 					anntoolFlags.to,
 					anntoolFlags.abif,
 					anntoolFlags.callf,
+					anntoolFlags.sig,
 				},
 			}, {
 				Name:   "exist",
@@ -167,29 +171,12 @@ func createContract(ctx *cli.Context) error {
 }
 
 func callContract(ctx *cli.Context) error {
-	json, err := getCallParamsJSON(ctx)
-	if err != nil {
-		return err
-	}
-	aabbii, err := getAbiJSON(ctx)
-	if err != nil {
-		return err
-	}
-	function := json.Get("function").MustString()
-	params := json.Get("params").MustArray()
-	contractAddress := gcmn.SanitizeHex(json.Get("contract").MustString())
-	args, err := commons.ParseArgs(function, *aabbii, params)
+	to, _, aabbii, data, err := contractInput(ctx)
 	if err != nil {
-		panic(err)
-	}
-
-	data, err := aabbii.Pack(function, args...)
-	if err != nil {
-		return err
+		return cli.NewExitError(err.Error(), 127)
 	}
 
 	nonce := ctx.Uint64("nonce")
-	to := common.HexToAddress(contractAddress)
 
 	tx := etypes.NewTransaction(nonce, to, big.NewInt(0), gasLimit, big.NewInt(0), data)
 
@@ -216,42 +203,37 @@ func callContract(ctx *cli.Context) error {
 	clientJSON := client.NewClientJSONRPC(commons.QueryServer)
 	_, err = clientJSON.Call("broadcast_tx_commit", []interface{}{b}, rpcResult)
 	if err != nil {
-		return err
+		return cli.NewExitError(err.Error(), 127)
 	}
 
 	hash := rpcResult.TxHash
 	fmt.Println("tx result:", hash)
 
-	return nil
-}
-
-func readContract(ctx *cli.Context) error {
-	json, err := getCallParamsJSON(ctx)
-	if err != nil {
-		return cli.NewExitError(err.Error(), 127)
+	if aabbii == nil || len(aabbii.Events) == 0 {
+		return nil
 	}
-	aabbii, err := getAbiJSON(ctx)
+	// events are best effort, the tx may not have been executed yet in sync mode
+	receipt, err := fetchReceipt(hash)
 	if err != nil {
-		return cli.NewExitError(err.Error(), 127)
-	}
-	function := json.Get("function").MustString()
-	if !aabbii.Methods[function].Const {
-		fmt.Printf("we can only read constant method, %s is not! Any consequence is on you.\n", function)
+		return nil
 	}
-	params := json.Get("params").MustArray()
-	contractAddress := gcmn.SanitizeHex(json.Get("contract").MustString())
-	args, err := commons.ParseArgs(function, *aabbii, params)
-	if err != nil {
-		return cli.NewExitError(err.Error(), 127)
+	events := decodeLogs(receipt.Logs, map[common.Address]*abi.ABI{to: aabbii})
+	if len(events) > 0 {
+		printJSON("events:", events)
 	}
+	return nil
+}
 
-	data, err := aabbii.Pack(function, args...)
+func readContract(ctx *cli.Context) error {
+	to, method, _, data, err := contractInput(ctx)
 	if err != nil {
 		return cli.NewExitError(err.Error(), 127)
 	}
+	if !method.Const {
+		fmt.Printf("we can only read constant method, %s is not! Any consequence is on you.\n", method.Name)
+	}
 
 	nonce := ctx.Uint64("nonce")
-	to := common.HexToAddress(contractAddress)
 
 	tx := etypes.NewTransaction(nonce, to, big.NewInt(0), gasLimit, big.NewInt(0), data)
 
@@ -281,13 +263,94 @@ func readContract(ctx *cli.Context) error {
 	if err != nil {
 		return cli.NewExitError(err.Error(), 127)
 	}
+	if rpcResult.Result.Code != types.CodeType_OK {
+		return cli.NewExitError(fmt.Sprintf("call failed: %s", rpcResult.Result.Log), 127)
+	}
 
-	parseResult, _ := UnpackResult(function, *aabbii, string(rpcResult.Result.Data))
-	fmt.Println("parse result:", reflect.TypeOf(parseResult), parseResult)
+	if len(method.Outputs) == 0 {
+		fmt.Println("parse result:", hexutil.Encode(rpcResult.Result.Data))
+		return nil
+	}
+	outputs, err := commons.DecodeOutputs(method, rpcResult.Result.Data)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 127)
+	}
+	printJSON("parse result:", outputs)
 
 	return nil
 }
 
+// contractInput resolves the target contract, the method and its packed input,
+// either from --sig with typed positional args or from the calljson/callf params.
+func contractInput(ctx *cli.Context) (to common.Address, method abi.Method, aabbii *abi.ABI, data []byte, err error) {
+	if sig := ctx.String("sig"); sig != "" {
+		contractAddress := gcmn.SanitizeHex(ctx.String("to"))
+		if contractAddress == "" {
+			err = commons.ErrEmptyContractAddress
+			return
+		}
+		to = common.HexToAddress(contractAddress)
+		aabbii, err = getContractABI(ctx, to)
+		switch err {
+		case nil:
+			method, err = commons.FindMethod(aabbii, sig)
+		case commons.ErrNoABI:
+			method, err = commons.ParseMethodSignature(sig)
+		}
+		if err != nil {
+			return
+		}
+		var args []interface{}
+		if args, err = commons.ParseCLIArgs(method, ctx.Args()); err != nil {
+			return
+		}
+		data, err = commons.PackMethod(method, args)
+		return
+	}
+
+	json, err := getCallParamsJSON(ctx)
+	if err != nil {
+		return
+	}
+	function := json.Get("function").MustString()
+	params := json.Get("params").MustArray()
+	contractAddress := gcmn.SanitizeHex(json.Get("contract").MustString())
+	to = common.HexToAddress(contractAddress)
+	if aabbii, err = getContractABI(ctx, to); err != nil {
+		return
+	}
+	method, ok := aabbii.Methods[function]
+	if !ok {
+		err = commons.ErrNoSuchMethod
+		return
+	}
+	args, err := commons.ParseArgs(function, *aabbii, params)
+	if err != nil {
+		return
+	}
+	data, err = aabbii.Pack(function, args...)
+	return
+}
+
+// getContractABI prefers the abi given by flags and falls back to the registry.
+func getContractABI(ctx *cli.Context, addr common.Address) (*abi.ABI, error) {
+	if ctx.String("abif") != "" || ctx.String("abi") != "" {
+		return getAbiJSON(ctx)
+	}
+	reg, err := commons.LoadABIRegistry()
+	if err != nil {
+		return nil, err
+	}
+	aabbii, err := reg.Lookup(addr)
+	if err != nil {
+		return nil, err
+	}
+	if aabbii == nil {
+		return nil, commons.ErrNoABI
+	}
+	return aabbii, nil
+}
+
 func UnpackResult(method string, abiDef abi.ABI, output string) (interface{}, error) {
 	m, ok := abiDef.Methods[method]
 	if !ok {
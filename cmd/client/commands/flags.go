@@ -38,6 +38,24 @@ type AnntoolFlags struct {
 	cType,
 	verbose,
 	nPrivs,
+	sig,
+	consensusParams,
+	reason,
+	refuseFor,
+	propose,
+	votingPeriod,
+	description,
+	proposalID,
+	reject,
+	status,
+	restrict,
+	unrestrict,
+	grant,
+	revoke,
+	accounts,
+	fromHeight,
+	start,
+	limit,
 	codeHash cli.Flag
 }
 
@@ -113,8 +131,80 @@ var anntoolFlags = AnntoolFlags{
 	verbose: cli.BoolFlag{
 		Name: "verbose",
 	},
+	sig: cli.StringFlag{
+		Name:  "sig",
+		Usage: "method signature, e.g. 'transfer(address,uint256)' or 'balanceOf(address)(uint256)', args follow on the command line",
+	},
 	nPrivs: cli.IntFlag{
 		Name:  "nPrivs",
 		Usage: "number of ca privateKey!",
 	},
+	consensusParams: cli.StringFlag{
+		Name:  "consensus_params",
+		Usage: "all consensus params in json, e.g. '{\"block_size\":5000,\"block_part_size\":65536,\"timeout_propose\":3000,...}', timeouts in milliseconds",
+	},
+	reason: cli.StringFlag{
+		Name:  "reason",
+		Usage: "why the node is removed, kept with its refuse list entry, or why the accounts are frozen",
+	},
+	refuseFor: cli.DurationFlag{
+		Name:  "refuse_for",
+		Usage: "how long the removed node stays in the refuse list, e.g. 72h, 0 for ever",
+	},
+	propose: cli.BoolFlag{
+		Name:  "propose",
+		Usage: "put the operation to the vote of the validators instead of signing it with 2/3 of them",
+	},
+	votingPeriod: cli.Int64Flag{
+		Name:  "voting_period",
+		Usage: "number of blocks the proposal is open for votes, 0 for the default 1000",
+	},
+	description: cli.StringFlag{
+		Name:  "description",
+		Usage: "description of the proposal",
+	},
+	proposalID: cli.StringFlag{
+		Name:  "proposal_id",
+		Usage: "id of the proposal, the hash of the tx proposing it",
+	},
+	reject: cli.BoolFlag{
+		Name:  "reject",
+		Usage: "vote against the proposal",
+	},
+	status: cli.StringFlag{
+		Name:  "status",
+		Usage: "only the proposals of status: pending, executed, failed, rejected or expired",
+	},
+	restrict: cli.StringFlag{
+		Name:  "restrict",
+		Usage: "permissions all accounts need from now on, comma separated: deploy,transfer",
+	},
+	unrestrict: cli.StringFlag{
+		Name:  "unrestrict",
+		Usage: "permissions the accounts don't need anymore, comma separated",
+	},
+	grant: cli.StringFlag{
+		Name:  "grant",
+		Usage: "permissions given to the accounts, comma separated",
+	},
+	revoke: cli.StringFlag{
+		Name:  "revoke",
+		Usage: "permissions taken from the accounts, comma separated",
+	},
+	accounts: cli.StringFlag{
+		Name:  "accounts",
+		Usage: "addresses of the accounts, comma separated",
+	},
+	fromHeight: cli.Uint64Flag{
+		Name:  "from_height",
+		Usage: "only the events from this height on",
+	},
+	start: cli.Uint64Flag{
+		Name:  "start",
+		Usage: "index of the first event, the next of the previous page",
+	},
+	limit: cli.Uint64Flag{
+		Name:  "limit",
+		Usage: "number of events at most, 0 for the max of 100",
+	},
 }
@@ -20,9 +20,12 @@ import (
 
 	"gopkg.in/urfave/cli.v1"
 
+	rtypes "github.com/dappledger/AnnChain/chain/types"
 	"github.com/dappledger/AnnChain/cmd/client/commons"
+	"github.com/dappledger/AnnChain/eth/accounts/abi"
 	"github.com/dappledger/AnnChain/eth/common"
 	"github.com/dappledger/AnnChain/eth/core/types"
+	"github.com/dappledger/AnnChain/eth/core/vm"
 	"github.com/dappledger/AnnChain/eth/rlp"
 	gcmn "github.com/dappledger/AnnChain/gemmill/modules/go-common"
 	cl "github.com/dappledger/AnnChain/gemmill/rpc/client"
@@ -51,6 +54,25 @@ var (
 					anntoolFlags.hash,
 				},
 			},
+			{
+				Name:   "permissions",
+				Usage:  "query the permission table, or the permissions of --address",
+				Action: queryPermissions,
+				Flags: []cli.Flag{
+					anntoolFlags.addr,
+				},
+			},
+			{
+				Name:   "frozen",
+				Usage:  "query the frozen accounts and a page of the freeze events, or the ones of --address",
+				Action: queryFrozen,
+				Flags: []cli.Flag{
+					anntoolFlags.addr,
+					anntoolFlags.fromHeight,
+					anntoolFlags.start,
+					anntoolFlags.limit,
+				},
+			},
 		},
 	}
 )
@@ -76,10 +98,82 @@ func queryNonce(ctx *cli.Context) error {
 	return nil
 }
 
+func queryPermissions(ctx *cli.Context) error {
+	clientJSON := cl.NewClientJSONRPC(commons.QueryServer)
+	rpcResult := new(gtypes.ResultQuery)
+
+	query := []byte{rtypes.QueryType_Permissions}
+	if ctx.IsSet("address") {
+		query = append(query, common.Hex2Bytes(gcmn.SanitizeHex(ctx.String("address")))...)
+	}
+	_, err := clientJSON.Call("query", []interface{}{query}, rpcResult)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 127)
+	}
+	if rpcResult.Result.Code != gtypes.CodeType_OK {
+		return cli.NewExitError(rpcResult.Result.Log, 127)
+	}
+
+	permissions := new(vm.Permissions)
+	if err := json.Unmarshal(rpcResult.Result.Data, permissions); err != nil {
+		return cli.NewExitError(err.Error(), 127)
+	}
+	printJSON("query result:", permissions)
+	return nil
+}
+
+func queryFrozen(ctx *cli.Context) error {
+	clientJSON := cl.NewClientJSONRPC(commons.QueryServer)
+	rpcResult := new(gtypes.ResultQuery)
+
+	args := &rtypes.FrozenArgs{
+		FromHeight: ctx.Uint64("from_height"),
+		Start:      ctx.Uint64("start"),
+		Limit:      ctx.Uint64("limit"),
+	}
+	if ctx.IsSet("address") {
+		addr := common.HexToAddress(ctx.String("address"))
+		args.Address = &addr
+	}
+	load, err := rlp.EncodeToBytes(args)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 127)
+	}
+	query := append([]byte{rtypes.QueryType_Frozen}, load...)
+	_, err = clientJSON.Call("query", []interface{}{query}, rpcResult)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 127)
+	}
+	if rpcResult.Result.Code != gtypes.CodeType_OK {
+		return cli.NewExitError(rpcResult.Result.Log, 127)
+	}
+
+	frozen := new(rtypes.FrozenAccounts)
+	if err := json.Unmarshal(rpcResult.Result.Data, frozen); err != nil {
+		return cli.NewExitError(err.Error(), 127)
+	}
+	printJSON("query result:", frozen)
+	return nil
+}
+
 func queryReceipt(ctx *cli.Context) error {
+	receiptForStorage, err := fetchReceipt(ctx.String("hash"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 127)
+	}
+	receiptJSON, _ := json.Marshal(receiptForStorage)
+	fmt.Println("query result:", string(receiptJSON))
+
+	if events := decodeLogs(receiptForStorage.Logs, nil); len(events) > 0 {
+		printJSON("events:", events)
+	}
+
+	return nil
+}
+
+func fetchReceipt(hash string) (*types.ReceiptForStorage, error) {
 	clientJSON := cl.NewClientJSONRPC(commons.QueryServer)
 	rpcResult := new(gtypes.ResultQuery)
-	hash := ctx.String("hash")
 	if strings.Index(hash, "0x") == 0 {
 		hash = hash[2:]
 	}
@@ -88,17 +182,49 @@ func queryReceipt(ctx *cli.Context) error {
 	query := append([]byte{3}, hashBytes...)
 	_, err := clientJSON.Call("query", []interface{}{query}, rpcResult)
 	if err != nil {
-		return cli.NewExitError(err.Error(), 127)
+		return nil, err
 	}
 
 	receiptForStorage := new(types.ReceiptForStorage)
+	if err = rlp.DecodeBytes(rpcResult.Result.Data, receiptForStorage); err != nil {
+		return nil, err
+	}
+	return receiptForStorage, nil
+}
 
-	err = rlp.DecodeBytes(rpcResult.Result.Data, receiptForStorage)
-	if err != nil {
-		return cli.NewExitError(err.Error(), 127)
+// decodeLogs decodes the logs whose contract abi is known, either given in
+// abis or registered locally; logs that cannot be decoded are skipped.
+func decodeLogs(logs []*types.Log, abis map[common.Address]*abi.ABI) []*commons.DecodedEvent {
+	var reg *commons.ABIRegistry
+	events := make([]*commons.DecodedEvent, 0, len(logs))
+	for _, log := range logs {
+		def, ok := abis[log.Address]
+		if !ok {
+			if reg == nil {
+				var err error
+				if reg, err = commons.LoadABIRegistry(); err != nil {
+					return events
+				}
+			}
+			def, _ = reg.Lookup(log.Address)
+		}
+		if def == nil {
+			continue
+		}
+		event, err := commons.DecodeLog(def, log)
+		if err != nil {
+			continue
+		}
+		events = append(events, event)
 	}
-	receiptJSON, _ := json.Marshal(receiptForStorage)
-	fmt.Println("query result:", string(receiptJSON))
+	return events
+}
 
-	return nil
+func printJSON(title string, v interface{}) {
+	js, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Println(title, v)
+		return
+	}
+	fmt.Println(title, string(js))
 }
@@ -0,0 +1,294 @@
+// Copyright © 2017 ZhongAn Technology
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commons
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/dappledger/AnnChain/eth/accounts/abi"
+	"github.com/dappledger/AnnChain/eth/common"
+	etypes "github.com/dappledger/AnnChain/eth/core/types"
+)
+
+// DecodedArg is a named, human readable abi value.
+type DecodedArg struct {
+	Name    string      `json:"name"`
+	Type    string      `json:"type"`
+	Indexed bool        `json:"indexed,omitempty"`
+	Value   interface{} `json:"value"`
+}
+
+// DecodedEvent is an event log decoded by its abi definition.
+type DecodedEvent struct {
+	Address string       `json:"address"`
+	Event   string       `json:"event"`
+	Args    []DecodedArg `json:"args"`
+}
+
+// ParseMethodSignature builds a method from a signature like
+// "transfer(address,uint256)" or "balanceOf(address)(uint256)";
+// "name(...) returns (...)" is accepted as well.
+func ParseMethodSignature(sig string) (abi.Method, error) {
+	var method abi.Method
+	sig = strings.TrimSpace(sig)
+	open := strings.Index(sig, "(")
+	if open <= 0 {
+		return method, ErrInvalidMethodSignature
+	}
+	method.Name = strings.TrimSpace(sig[:open])
+	inputs, rest, err := splitParenthesized(sig[open:])
+	if err != nil {
+		return method, err
+	}
+	if method.Inputs, err = parseSignatureArgs(inputs); err != nil {
+		return method, err
+	}
+	rest = strings.TrimSpace(rest)
+	rest = strings.TrimSpace(strings.TrimPrefix(rest, "returns"))
+	if rest == "" {
+		return method, nil
+	}
+	outputs, tail, err := splitParenthesized(rest)
+	if err != nil {
+		return method, err
+	}
+	if strings.TrimSpace(tail) != "" {
+		return method, ErrInvalidMethodSignature
+	}
+	if method.Outputs, err = parseSignatureArgs(outputs); err != nil {
+		return method, err
+	}
+	return method, nil
+}
+
+// splitParenthesized returns the content of the leading "(...)" group and what follows it.
+func splitParenthesized(s string) (string, string, error) {
+	if !strings.HasPrefix(s, "(") {
+		return "", "", ErrInvalidMethodSignature
+	}
+	depth := 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return s[1:i], s[i+1:], nil
+			}
+		}
+	}
+	return "", "", ErrInvalidMethodSignature
+}
+
+func parseSignatureArgs(s string) (abi.Arguments, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var args abi.Arguments
+	for _, field := range strings.Split(s, ",") {
+		parts := strings.Fields(field)
+		if len(parts) == 0 || len(parts) > 2 {
+			return nil, ErrInvalidMethodSignature
+		}
+		typ, err := abi.NewType(parts[0], nil)
+		if err != nil {
+			return nil, err
+		}
+		arg := abi.Argument{Type: typ}
+		if len(parts) == 2 {
+			arg.Name = parts[1]
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// FindMethod looks sig up in abiDef, sig is either a plain method name or a full signature.
+func FindMethod(abiDef *abi.ABI, sig string) (abi.Method, error) {
+	if !strings.Contains(sig, "(") {
+		method, ok := abiDef.Methods[strings.TrimSpace(sig)]
+		if !ok {
+			return method, ErrNoSuchMethod
+		}
+		return method, nil
+	}
+	parsed, err := ParseMethodSignature(sig)
+	if err != nil {
+		return parsed, err
+	}
+	for _, method := range abiDef.Methods {
+		if method.Sig() == parsed.Sig() {
+			return method, nil
+		}
+	}
+	return parsed, ErrNoSuchMethod
+}
+
+// ParseCLIArgs converts command line strings into typed method arguments,
+// array arguments are given in JSON, e.g. '["0x01","0x02"]'.
+func ParseCLIArgs(method abi.Method, args []string) ([]interface{}, error) {
+	if len(args) != len(method.Inputs) {
+		return nil, ErrUnmatchedParams
+	}
+	values := make([]interface{}, 0, len(args))
+	for i, arg := range args {
+		var value interface{} = arg
+		if strings.HasPrefix(strings.TrimSpace(arg), "[") {
+			dec := json.NewDecoder(strings.NewReader(arg))
+			dec.UseNumber()
+			var list []interface{}
+			if err := dec.Decode(&list); err != nil {
+				return nil, fmt.Errorf("fail to parse %s into %s: %v", arg, method.Inputs[i].Type, err)
+			}
+			value = list
+		}
+		v, err := ParseArg(method.Inputs[i], value)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// PackMethod encodes a call of method with args, selector included.
+func PackMethod(method abi.Method, args []interface{}) ([]byte, error) {
+	input, err := method.Inputs.Pack(args...)
+	if err != nil {
+		return nil, err
+	}
+	return append(method.Id(), input...), nil
+}
+
+// DecodeOutputs unpacks the return data of method.
+func DecodeOutputs(method abi.Method, data []byte) ([]DecodedArg, error) {
+	if len(method.Outputs) == 0 {
+		return nil, nil
+	}
+	if len(data) == 0 {
+		return nil, ErrEmptyResult
+	}
+	values, err := method.Outputs.UnpackValues(data)
+	if err != nil {
+		return nil, err
+	}
+	decoded := make([]DecodedArg, len(values))
+	for i, value := range values {
+		decoded[i] = DecodedArg{
+			Name:  method.Outputs[i].Name,
+			Type:  method.Outputs[i].Type.String(),
+			Value: FormatABIValue(value),
+		}
+	}
+	return decoded, nil
+}
+
+// DecodeLog decodes log with the matching event of abiDef, anonymous events are not supported.
+func DecodeLog(abiDef *abi.ABI, log *etypes.Log) (*DecodedEvent, error) {
+	if len(log.Topics) == 0 {
+		return nil, ErrNoSuchEvent
+	}
+	var (
+		event abi.Event
+		found bool
+	)
+	for _, e := range abiDef.Events {
+		if !e.Anonymous && e.Id() == log.Topics[0] {
+			event, found = e, true
+			break
+		}
+	}
+	if !found {
+		return nil, ErrNoSuchEvent
+	}
+
+	nonIndexed, err := event.Inputs.UnpackValues(log.Data)
+	if err != nil {
+		return nil, err
+	}
+	decoded := &DecodedEvent{
+		Address: log.Address.Hex(),
+		Event:   event.Name,
+		Args:    make([]DecodedArg, 0, len(event.Inputs)),
+	}
+	topic := 1
+	for _, input := range event.Inputs {
+		arg := DecodedArg{
+			Name:    input.Name,
+			Type:    input.Type.String(),
+			Indexed: input.Indexed,
+		}
+		if !input.Indexed {
+			arg.Value = FormatABIValue(nonIndexed[0])
+			nonIndexed = nonIndexed[1:]
+			decoded.Args = append(decoded.Args, arg)
+			continue
+		}
+		if topic >= len(log.Topics) {
+			return nil, fmt.Errorf("event %s expects more topics than the %d given", event.Name, len(log.Topics))
+		}
+		switch input.Type.T {
+		case abi.StringTy, abi.BytesTy, abi.SliceTy, abi.ArrayTy, abi.TupleTy:
+			// dynamic values are stored as the hash of their encoding
+			arg.Value = log.Topics[topic].Hex()
+		default:
+			values, err := abi.Arguments{{Type: input.Type}}.UnpackValues(log.Topics[topic].Bytes())
+			if err != nil {
+				return nil, err
+			}
+			arg.Value = FormatABIValue(values[0])
+		}
+		topic++
+		decoded.Args = append(decoded.Args, arg)
+	}
+	return decoded, nil
+}
+
+// FormatABIValue turns unpacked abi values into JSON friendly ones:
+// bytes in hex, addresses in checksum hex and big integers in decimal strings.
+func FormatABIValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case *big.Int:
+		return v.String()
+	case common.Address:
+		return v.Hex()
+	case common.Hash:
+		return v.Hex()
+	case []byte:
+		return "0x" + hex.EncodeToString(v)
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Array, reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return "0x" + hex.EncodeToString(b)
+		}
+		list := make([]interface{}, rv.Len())
+		for i := range list {
+			list[i] = FormatABIValue(rv.Index(i).Interface())
+		}
+		return list
+	}
+	return value
+}
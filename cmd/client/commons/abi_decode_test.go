@@ -0,0 +1,177 @@
+// Copyright © 2017 ZhongAn Technology
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commons
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dappledger/AnnChain/eth/accounts/abi"
+	"github.com/dappledger/AnnChain/eth/common"
+	etypes "github.com/dappledger/AnnChain/eth/core/types"
+	"github.com/dappledger/AnnChain/eth/crypto"
+)
+
+const testABI = `[
+	{"constant":true,"inputs":[{"name":"owner","type":"address"}],"name":"balanceOf","outputs":[{"name":"balance","type":"uint256"}],"type":"function"},
+	{"constant":false,"inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}],"name":"transfer","outputs":[{"name":"","type":"bool"}],"type":"function"},
+	{"anonymous":false,"inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"value","type":"uint256"}],"name":"Transfer","type":"event"}
+]`
+
+func TestParseMethodSignature(t *testing.T) {
+	for _, sig := range []string{
+		"balanceOf(address)(uint256)",
+		"balanceOf(address owner) returns (uint256 balance)",
+	} {
+		method, err := ParseMethodSignature(sig)
+		if err != nil {
+			t.Fatalf("%s: %v", sig, err)
+		}
+		if method.Sig() != "balanceOf(address)" {
+			t.Fatalf("%s: unexpected sig %s", sig, method.Sig())
+		}
+		if len(method.Outputs) != 1 || method.Outputs[0].Type.String() != "uint256" {
+			t.Fatalf("%s: unexpected outputs %v", sig, method.Outputs)
+		}
+	}
+	for _, sig := range []string{"", "balanceOf", "(address)", "balanceOf(address", "balanceOf(address)uint256"} {
+		if _, err := ParseMethodSignature(sig); err == nil {
+			t.Fatalf("%q: expected error", sig)
+		}
+	}
+}
+
+func TestPackAndDecodeBySignature(t *testing.T) {
+	def, err := abi.JSON(strings.NewReader(testABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	method, err := FindMethod(&def, "transfer(address,uint256)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	args, err := ParseCLIArgs(method, []string{"0x00000000000000000000000000000000000000aa", "1000000000000000000000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := PackMethod(method, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect, err := def.Pack("transfer", common.HexToAddress("0xaa"), new(big.Int).Exp(big.NewInt(10), big.NewInt(21), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if common.Bytes2Hex(data) != common.Bytes2Hex(expect) {
+		t.Fatalf("packed %x, expect %x", data, expect)
+	}
+
+	balanceOf, err := FindMethod(&def, "balanceOf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs, err := DecodeOutputs(balanceOf, common.LeftPadBytes(big.NewInt(42).Bytes(), 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) != 1 || outputs[0].Name != "balance" || outputs[0].Value != "42" {
+		t.Fatalf("unexpected outputs %+v", outputs)
+	}
+}
+
+func TestFormatABIValue(t *testing.T) {
+	if v := FormatABIValue([4]byte{1, 2, 3, 4}); v != "0x01020304" {
+		t.Fatalf("unexpected bytes4 %v", v)
+	}
+	list, ok := FormatABIValue([]*big.Int{big.NewInt(1), big.NewInt(2)}).([]interface{})
+	if !ok || len(list) != 2 || list[1] != "2" {
+		t.Fatalf("unexpected list %v", list)
+	}
+}
+
+func TestDecodeLog(t *testing.T) {
+	def, err := abi.JSON(strings.NewReader(testABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, to := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	log := &etypes.Log{
+		Address: common.HexToAddress("0x03"),
+		Topics: []common.Hash{
+			crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)")),
+			common.BytesToHash(from.Bytes()),
+			common.BytesToHash(to.Bytes()),
+		},
+		Data: common.LeftPadBytes(big.NewInt(7).Bytes(), 32),
+	}
+	event, err := DecodeLog(&def, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Event != "Transfer" || len(event.Args) != 3 {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	if event.Args[0].Value != from.Hex() || event.Args[1].Value != to.Hex() || event.Args[2].Value != "7" {
+		t.Fatalf("unexpected args %+v", event.Args)
+	}
+
+	log.Topics[0] = common.Hash{}
+	if _, err := DecodeLog(&def, log); err != ErrNoSuchEvent {
+		t.Fatalf("expected ErrNoSuchEvent, got %v", err)
+	}
+}
+
+func TestABIRegistry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "abi_registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ABIRegistryPath = filepath.Join(dir, "sub", "abi_registry.json")
+	defer func() { ABIRegistryPath = "" }()
+
+	addr := common.HexToAddress("0xAbC0000000000000000000000000000000000001")
+	reg, err := LoadABIRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Register(addr, "not json"); err == nil {
+		t.Fatal("expected invalid abi to be rejected")
+	}
+	if err := reg.Register(addr, testABI); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reg, err = LoadABIRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	def, err := reg.Lookup(addr)
+	if err != nil || def == nil {
+		t.Fatalf("lookup failed: %v", err)
+	}
+	if _, ok := def.Methods["transfer"]; !ok {
+		t.Fatal("registered abi lost its methods")
+	}
+	if !reg.Remove(addr) || reg.Remove(addr) {
+		t.Fatal("unexpected remove result")
+	}
+}
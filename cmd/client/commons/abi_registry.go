@@ -0,0 +1,133 @@
+// Copyright © 2017 ZhongAn Technology
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commons
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dappledger/AnnChain/eth/accounts/abi"
+	"github.com/dappledger/AnnChain/eth/common"
+)
+
+// ABIRegistry maps contract addresses to their ABI definitions so that
+// calls, return values and event logs can be encoded and decoded without
+// passing the ABI on every invocation.
+type ABIRegistry struct {
+	path      string
+	Contracts map[string]json.RawMessage `json:"contracts"`
+}
+
+func abiRegistryPath() (string, error) {
+	if ABIRegistryPath != "" {
+		return ABIRegistryPath, nil
+	}
+	home := os.Getenv("HOME")
+	if home == "" {
+		return "", ErrEmptyABIRegistryPath
+	}
+	return filepath.Join(home, ".anntool", "abi_registry.json"), nil
+}
+
+// LoadABIRegistry reads the registry file, a missing file yields an empty registry.
+func LoadABIRegistry() (*ABIRegistry, error) {
+	path, err := abiRegistryPath()
+	if err != nil {
+		return nil, err
+	}
+	reg := &ABIRegistry{
+		path:      path,
+		Contracts: make(map[string]json.RawMessage),
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return reg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, reg); err != nil {
+		return nil, fmt.Errorf("fail to parse abi registry %s: %v", path, err)
+	}
+	if reg.Contracts == nil {
+		reg.Contracts = make(map[string]json.RawMessage)
+	}
+	return reg, nil
+}
+
+// Save writes the registry back to disk.
+func (r *ABIRegistry) Save() error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.path, data, 0600)
+}
+
+// Register validates abiJSON and binds it to addr, replacing any previous entry.
+func (r *ABIRegistry) Register(addr common.Address, abiJSON string) error {
+	if strings.TrimSpace(abiJSON) == "" {
+		return ErrEmptyContractABI
+	}
+	if _, err := abi.JSON(strings.NewReader(abiJSON)); err != nil {
+		return err
+	}
+	r.Contracts[registryKey(addr)] = json.RawMessage(abiJSON)
+	return nil
+}
+
+// Remove drops the entry of addr, it reports whether the entry existed.
+func (r *ABIRegistry) Remove(addr common.Address) bool {
+	key := registryKey(addr)
+	if _, ok := r.Contracts[key]; !ok {
+		return false
+	}
+	delete(r.Contracts, key)
+	return true
+}
+
+// Lookup returns the ABI registered for addr, or nil if there is none.
+func (r *ABIRegistry) Lookup(addr common.Address) (*abi.ABI, error) {
+	raw, ok := r.Contracts[registryKey(addr)]
+	if !ok {
+		return nil, nil
+	}
+	def, err := abi.JSON(strings.NewReader(string(raw)))
+	if err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
+
+// Addresses returns the registered addresses in a stable order.
+func (r *ABIRegistry) Addresses() []string {
+	addrs := make([]string, 0, len(r.Contracts))
+	for addr := range r.Contracts {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+func registryKey(addr common.Address) string {
+	return strings.ToLower(addr.Hex())
+}
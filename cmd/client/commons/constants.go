@@ -90,6 +90,15 @@ var (
 
 	ErrNoSuchAuditor = errors.New("no such auditor")
 
+	// ErrEmptyABIRegistryPath errors
+	ErrEmptyABIRegistryPath = errors.New("abi registry path is empty and $HOME is not set")
+	// ErrNoABI errors
+	ErrNoABI = errors.New("no abi given and none registered for the contract")
+	// ErrInvalidMethodSignature errors
+	ErrInvalidMethodSignature = errors.New("invalid method signature, expect name(type,...) or name(type,...)(type,...)")
+	// ErrNoSuchEvent errors
+	ErrNoSuchEvent = errors.New("no such event")
+
 	// EmptyAddressHex represents a empty address in hex
 	EmptyAddressHex = "0x0000000000000000000000000000000000000000"
 )
@@ -16,4 +16,7 @@ package commons
 var (
 	QueryServer = "tcp://localhost:46657"
 	CallMode    = "sync"
+	// ABIRegistryPath is the file holding registered contract ABIs,
+	// defaults to $HOME/.anntool/abi_registry.json when empty.
+	ABIRegistryPath = ""
 )
@@ -33,6 +33,7 @@ func main() {
 		commands.SignCommand,
 
 		commands.EVMCommands,
+		commands.ContractCommands,
 		commands.AccountCommands,
 		commands.QueryCommands,
 		commands.TxCommands,
@@ -55,6 +56,11 @@ func main() {
 			Destination: &commons.QueryServer,
 			Usage:       "rpc address of the node",
 		},
+		cli.StringFlag{
+			Name:        "abi_registry",
+			Destination: &commons.ABIRegistryPath,
+			Usage:       "contract abi registry file, defaults to $HOME/.anntool/abi_registry.json",
+		},
 	}
 
 	app.Before = func(ctx *cli.Context) error {
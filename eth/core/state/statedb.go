@@ -611,6 +611,11 @@ func (self *StateDB) Prepare(thash, bhash common.Hash, ti int) {
 	self.txIndex = ti
 }
 
+// TxHash returns the hash of the transaction set by Prepare.
+func (self *StateDB) TxHash() common.Hash {
+	return self.thash
+}
+
 func (s *StateDB) clearJournalAndRefund() {
 	s.journal = newJournal()
 	s.validRevisions = s.validRevisions[:0]
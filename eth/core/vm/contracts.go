@@ -81,33 +81,86 @@ type AdminCallback func(app *AdminDBApp,data []byte) error
 
 type AdminOP struct {
 	callback AdminCallback
-	state StateDB
 }
 
 type AdminDBApp struct {
 	StateDB
 	Addr []byte
+	// Simulate is set for the calls and replays, which mustn't change the node
+	Simulate bool
+	// Replay is set for the replays of committed txs
+	Replay bool
 }
 
 func (app *AdminDBApp)From()[]byte{
 	return app.Addr
 }
 
+// Simulated tells the admin plugin to change the state only
+func (app *AdminDBApp) Simulated() bool {
+	return app.Simulate
+}
+
+// Replayed tells the admin plugin the op was committed already
+func (app *AdminDBApp) Replayed() bool {
+	return app.Replay
+}
+
 func (app *AdminDBApp)GetNonce()uint64{
 	var addr common.Address
 	addr.SetBytes(app.Addr)
 	return app.StateDB.GetNonce(addr)
 }
 
+// TxHash returns the hash of the transaction calling the admin contract, nil
+// if the state doesn't know it.
+func (app *AdminDBApp) TxHash() []byte {
+	if s, ok := app.StateDB.(interface{ TxHash() common.Hash }); ok {
+		return s.TxHash().Bytes()
+	}
+	return nil
+}
+
+// SetRestricted and SetPermission change the permission table in the state of
+// the transaction calling the admin contract
+func (app *AdminDBApp) SetRestricted(permission string, restricted bool) error {
+	return SetRestricted(app.StateDB, permission, restricted)
+}
+
+func (app *AdminDBApp) SetPermission(addr []byte, permission string, granted bool) error {
+	if len(addr) != common.AddressLength {
+		return fmt.Errorf("invalid address %x", addr)
+	}
+	return SetPermission(app.StateDB, common.BytesToAddress(addr), permission, granted)
+}
+
+// SetFrozen freezes addr or unfreezes it in the state of the transaction
+// calling the admin contract
+func (app *AdminDBApp) SetFrozen(addr []byte, frozen bool, reason string) error {
+	if len(addr) != common.AddressLength {
+		return fmt.Errorf("invalid address %x", addr)
+	}
+	SetFrozen(app.StateDB, common.BytesToAddress(addr), frozen, reason)
+	return nil
+}
+
 func (c *AdminOP) RequiredGas(input []byte) uint64 {
 	return 0
 }
 
-func (c *AdminOP)SetState(s StateDB){
-	c.state = s
+// Run fails, the admin contract changes the state of its call, see RunState
+func (c *AdminOP) Run(input []byte) ([]byte, error) {
+	return nil, errors.New("admin contract called without a state")
 }
 
-func (c *AdminOP) Run(input []byte) ([]byte, error) {
+// RunState runs the admin op of input against the state of the call, the
+// state is passed per call as the contract is shared by all the EVMs. A
+// simulated op doesn't stage anything in the node, a replayed one was
+// committed already.
+func (c *AdminOP) RunState(state StateDB, input []byte, simulate, replay bool) ([]byte, error) {
+	if len(input) < 32+20 {
+		return nil, errors.New("admin input too short")
+	}
 	//[$len + $arg]
 	dlen := new(big.Int).SetBytes(input[:32]).Uint64()
 	offset := dlen + 32
@@ -117,8 +170,10 @@ func (c *AdminOP) Run(input []byte) ([]byte, error) {
 	from := input[32:32+20]
 	data := input[32+20:offset]
 	app := &AdminDBApp{
-		c.state,
-		from,
+		StateDB:  state,
+		Addr:     from,
+		Simulate: simulate,
+		Replay:   replay,
 	}
 	if c.callback != nil {
 		return nil, c.callback(app,data)
@@ -22,6 +22,9 @@ import (
 	"testing"
 
 	"github.com/dappledger/AnnChain/eth/common"
+	"github.com/dappledger/AnnChain/eth/core/state"
+	"github.com/dappledger/AnnChain/eth/ethdb"
+	"github.com/dappledger/AnnChain/eth/params"
 )
 
 // precompiledTest defines the input/output pairs for precompiled contract tests.
@@ -481,3 +484,46 @@ func BenchmarkPrecompiledBn256Pairing(bench *testing.B) {
 		benchmarkPrecompiled("08", test, bench)
 	}
 }
+
+// Tests that the admin contract changes the state of the EVM calling it,
+// whatever the other EVMs run at the same time.
+func TestAdminOPState(t *testing.T) {
+	var (
+		from  = common.HexToAddress("0x0a")
+		admin = common.BytesToAddress([]byte{254})
+		slot  = common.HexToHash("0x01")
+	)
+	DefaultAdminContract.SetCallback(func(app *AdminDBApp, data []byte) error {
+		app.SetState(PermissionsAddress, slot, common.BytesToHash(data))
+		return nil
+	})
+	defer DefaultAdminContract.SetCallback(nil)
+
+	ctx := Context{
+		CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+		BlockNumber: big.NewInt(1),
+	}
+	dbs := make([]*state.StateDB, 2)
+	evms := make([]*EVM, 2)
+	for i := range evms {
+		dbs[i], _ = state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+		evms[i] = NewEVM(ctx, dbs[i], params.TestChainConfig, Config{EVMGasLimit: 100000})
+	}
+	for i := range evms {
+		input := append(common.LeftPadBytes([]byte{21}, 32), from.Bytes()...)
+		input = append(input, byte(i+1))
+		if _, _, err := evms[i].Call(AccountRef(from), admin, input, 100000, new(big.Int)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, db := range dbs {
+		if v := db.GetState(PermissionsAddress, slot); v != common.BytesToHash([]byte{byte(i + 1)}) {
+			t.Fatalf("expected the op of EVM %d in its state, got %x", i, v)
+		}
+	}
+
+	if _, err := DefaultAdminContract.RunState(dbs[0], []byte{1}, false, false); err == nil {
+		t.Fatal("expected a short input to fail")
+	}
+}
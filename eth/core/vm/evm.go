@@ -55,13 +55,26 @@ func run(evm *EVM, contract *Contract, input []byte, readOnly bool) ([]byte, err
 		if p := precompiles[*contract.CodeAddr]; p != nil {
 			gas := p.RequiredGas(input)
 			if useGas(&evm.gasLeft, gas) {
-				ap, ok := p.(*AdminOP)
-				if ok {
-					ap.SetState(evm.StateDB)
+				if ap, ok := p.(*AdminOP); ok {
+					return ap.RunState(evm.StateDB, input, evm.vmConfig.SimulateAdmin, evm.vmConfig.ReplayAdmin)
 				}
 				return p.Run(input)
 			}
 
+			return nil, ErrOutOfGas
+		}
+		if n := activeNative(*contract.CodeAddr, evm.BlockNumber); n != nil {
+			if useGas(&evm.gasLeft, n.requiredGas(input)) {
+				return n.contract.Run(&NativeContext{
+					StateDB:     evm.StateDB,
+					Caller:      contract.Caller(),
+					Self:        *contract.CodeAddr,
+					BlockNumber: evm.BlockNumber,
+					ReadOnly:    readOnly || evm.staticCall(),
+					Delegated:   contract.Address() != *contract.CodeAddr,
+				}, input)
+			}
+
 			return nil, ErrOutOfGas
 		}
 	}
@@ -209,6 +222,10 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 	if evm.depth > int(params.CallCreateDepth) {
 		return nil, gas, ErrDepth
 	}
+	// Fail if the caller is frozen
+	if IsFrozen(evm.StateDB, caller.Address()) {
+		return nil, gas, ErrAccountFrozen
+	}
 	// Fail if we're trying to transfer more than the available balance
 	if !evm.Context.CanTransfer(evm.StateDB, caller.Address(), value) {
 		return nil, gas, ErrInsufficientBalance
@@ -228,7 +245,7 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 		*/
 		precompiles := PrecompiledContractsByzantium
 
-		if precompiles[addr] == nil && evm.ChainConfig().IsEIP158(evm.BlockNumber) && value.Sign() == 0 {
+		if precompiles[addr] == nil && activeNative(addr, evm.BlockNumber) == nil && evm.ChainConfig().IsEIP158(evm.BlockNumber) && value.Sign() == 0 {
 			// Calling a non existing account, don't do anything, but ping the tracer
 			if evm.vmConfig.Debug && evm.depth == 0 {
 				evm.vmConfig.Tracer.CaptureStart(caller.Address(), addr, false, input, gas, value)
@@ -285,6 +302,10 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 	if evm.depth > int(params.CallCreateDepth) {
 		return nil, gas, ErrDepth
 	}
+	// Fail if the caller is frozen
+	if IsFrozen(evm.StateDB, caller.Address()) {
+		return nil, gas, ErrAccountFrozen
+	}
 	// Fail if we're trying to transfer more than the available balance
 	if !evm.CanTransfer(evm.StateDB, caller.Address(), value) {
 		return nil, gas, ErrInsufficientBalance
@@ -323,6 +344,10 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 	if evm.depth > int(params.CallCreateDepth) {
 		return nil, gas, ErrDepth
 	}
+	// Fail if the caller is frozen
+	if IsFrozen(evm.StateDB, caller.Address()) {
+		return nil, gas, ErrAccountFrozen
+	}
 
 	var (
 		snapshot = evm.StateDB.Snapshot()
@@ -355,6 +380,10 @@ func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte
 	if evm.depth > int(params.CallCreateDepth) {
 		return nil, gas, ErrDepth
 	}
+	// Fail if the caller is frozen
+	if IsFrozen(evm.StateDB, caller.Address()) {
+		return nil, gas, ErrAccountFrozen
+	}
 
 	var (
 		to       = AccountRef(addr)
@@ -404,6 +433,14 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 	if evm.depth > int(params.CallCreateDepth) {
 		return nil, common.Address{}, gas, ErrDepth
 	}
+	if IsFrozen(evm.StateDB, caller.Address()) {
+		return nil, common.Address{}, gas, ErrAccountFrozen
+	}
+	// contracts created by contracts need the deploy permission of the
+	// sender of the tx too
+	if err := CheckTxPermissions(evm.StateDB, evm.Origin, true, nil); err != nil {
+		return nil, common.Address{}, gas, err
+	}
 	if !evm.CanTransfer(evm.StateDB, caller.Address(), value) {
 		return nil, common.Address{}, gas, ErrInsufficientBalance
 	}
@@ -0,0 +1,71 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vm
+
+import (
+	"errors"
+
+	"github.com/dappledger/AnnChain/eth/common"
+	"github.com/dappledger/AnnChain/eth/core/types"
+	"github.com/dappledger/AnnChain/eth/crypto"
+)
+
+// Frozen accounts can't send txs nor call or create contracts. They are kept
+// next to the permission table, each freeze and unfreeze logs an event from
+// PermissionsAddress with the address as topic and the reason as data.
+
+const frozenList = "frozen"
+
+var (
+	FreezeTopic   = crypto.Keccak256Hash([]byte("Freeze(address,string)"))
+	UnfreezeTopic = crypto.Keccak256Hash([]byte("Unfreeze(address,string)"))
+
+	ErrAccountFrozen = errors.New("account frozen")
+)
+
+// IsFrozen reports whether addr is frozen
+func IsFrozen(db StateDB, addr common.Address) bool {
+	return getUint(db, indexSlot(frozenList, addr)) != 0
+}
+
+// SetFrozen freezes addr or unfreezes it and logs the event, it does nothing
+// if addr already is
+func SetFrozen(db StateDB, addr common.Address, frozen bool, reason string) {
+	if IsFrozen(db, addr) == frozen {
+		return
+	}
+	topic := UnfreezeTopic
+	if frozen {
+		listAdd(db, frozenList, addr)
+		topic = FreezeTopic
+	} else {
+		listRemove(db, frozenList, addr)
+	}
+	db.AddLog(&types.Log{
+		Address: PermissionsAddress,
+		Topics:  []common.Hash{topic, addr.Hash()},
+		Data:    []byte(reason),
+	})
+}
+
+// FrozenAccounts returns the frozen addresses
+func FrozenAccounts(db StateDB) []common.Address {
+	return listMembers(db, frozenList)
+}
+
+// IsFreezeLog reports whether l is the event of a freeze or an unfreeze
+func IsFreezeLog(l *types.Log) bool {
+	return l.Address == PermissionsAddress && len(l.Topics) == 2 && (l.Topics[0] == FreezeTopic || l.Topics[0] == UnfreezeTopic)
+}
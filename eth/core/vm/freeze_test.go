@@ -0,0 +1,66 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/dappledger/AnnChain/eth/common"
+	"github.com/dappledger/AnnChain/eth/core/state"
+	"github.com/dappledger/AnnChain/eth/ethdb"
+	"github.com/dappledger/AnnChain/eth/params"
+)
+
+func TestFreeze(t *testing.T) {
+	db, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	var (
+		a      = common.HexToAddress("0x0a")
+		b      = common.HexToAddress("0x0b")
+		target = common.HexToAddress("0x0c")
+	)
+	db.Prepare(common.HexToHash("0x01"), common.Hash{}, 0)
+	SetFrozen(db, a, true, "court order 1")
+	SetFrozen(db, b, true, "court order 2")
+	SetFrozen(db, a, true, "again")
+	SetFrozen(db, a, false, "lifted")
+
+	if IsFrozen(db, a) || !IsFrozen(db, b) {
+		t.Fatal("expected only b frozen")
+	}
+	if frozen := FrozenAccounts(db); len(frozen) != 1 || frozen[0] != b {
+		t.Fatalf("unexpected frozen accounts %v", frozen)
+	}
+	logs := db.GetLogs(common.HexToHash("0x01"))
+	if len(logs) != 3 || !IsFreezeLog(logs[2]) || logs[2].Topics[0] != UnfreezeTopic || string(logs[2].Data) != "lifted" {
+		t.Fatalf("expected 3 freeze events ending with the unfreeze, got %d", len(logs))
+	}
+
+	// frozen accounts can't call nor create, whatever the contract
+	ctx := Context{
+		CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+	}
+	evm := NewEVM(ctx, db, params.TestChainConfig, Config{})
+	if _, _, err := evm.Call(AccountRef(b), target, nil, 100000, new(big.Int)); err != ErrAccountFrozen {
+		t.Fatalf("expected %v, got %v", ErrAccountFrozen, err)
+	}
+	if _, _, _, err := evm.Create(AccountRef(b), []byte{0x00}, 100000, new(big.Int)); err != ErrAccountFrozen {
+		t.Fatalf("expected %v, got %v", ErrAccountFrozen, err)
+	}
+	if _, _, err := evm.Call(AccountRef(a), target, nil, 100000, new(big.Int)); err != nil {
+		t.Fatal(err)
+	}
+}
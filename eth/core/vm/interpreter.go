@@ -49,6 +49,13 @@ type Config struct {
 
 	// gasLimit for interpreter run
 	EVMGasLimit uint64
+
+	// SimulateAdmin runs the admin contract for the calls and replays which
+	// aren't committed, its ops change the state only
+	SimulateAdmin bool
+	// ReplayAdmin marks the replays of committed txs, the signatures of their
+	// admin ops were checked against the validators of their block
+	ReplayAdmin bool
 }
 
 // Interpreter is used to run Ethereum based contracts and will utilise the
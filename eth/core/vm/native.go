@@ -0,0 +1,190 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vm
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/dappledger/AnnChain/eth/common"
+	"github.com/dappledger/AnnChain/eth/common/math"
+)
+
+// Native contracts are precompiles the application brings. A kind is
+// registered once with its code and default gas schedule, then the genesis
+// installs kinds at addresses from an activation height on.
+
+// NativeContract is the Go code of a kind of native contract
+type NativeContract interface {
+	// RequiredGas is the gas the input costs on top of the gas schedule
+	RequiredGas(input []byte) uint64
+	Run(ctx *NativeContext, input []byte) ([]byte, error)
+}
+
+// NativeContext is what a native contract is called with
+type NativeContext struct {
+	StateDB     StateDB
+	Caller      common.Address
+	Self        common.Address // the address installed at, whose storage the call changes
+	BlockNumber *big.Int
+	ReadOnly    bool // the call mustn't change the state
+	Delegated   bool // called by DELEGATECALL or CALLCODE, Caller may not be the direct caller
+}
+
+// GasSchedule is the gas a native contract costs, Base plus PerWord for each
+// 32 bytes word of input
+type GasSchedule struct {
+	Base    uint64 `json:"base"`
+	PerWord uint64 `json:"per_word"`
+}
+
+// Gas returns the gas of the input, MaxUint64 when it overflows
+func (s GasSchedule) Gas(input []byte) uint64 {
+	gas, overflow := math.SafeMul(toWordSize(uint64(len(input))), s.PerWord)
+	if overflow {
+		return math.MaxUint64
+	}
+	if gas, overflow = math.SafeAdd(gas, s.Base); overflow {
+		return math.MaxUint64
+	}
+	return gas
+}
+
+// NativeInstall installs a kind at Address, the nil gas fields keep the
+// defaults of the kind
+type NativeInstall struct {
+	Kind             string
+	Address          common.Address
+	ActivationHeight uint64
+	BaseGas          *uint64
+	WordGas          *uint64
+}
+
+type nativeKind struct {
+	contract NativeContract
+	schedule GasSchedule
+}
+
+type installedNative struct {
+	contract NativeContract
+	schedule GasSchedule
+	height   uint64
+}
+
+var (
+	nativeMtx       sync.RWMutex
+	nativeKinds     = make(map[string]*nativeKind)
+	nativeContracts = make(map[common.Address]*installedNative)
+)
+
+// RegisterNativeContract makes the kind available to the genesis, it panics
+// if the kind is registered twice
+func RegisterNativeContract(kind string, contract NativeContract, schedule GasSchedule) {
+	nativeMtx.Lock()
+	defer nativeMtx.Unlock()
+	if _, ok := nativeKinds[kind]; ok {
+		panic(fmt.Sprintf("native contract %s registered twice", kind))
+	}
+	nativeKinds[kind] = &nativeKind{contract: contract, schedule: schedule}
+}
+
+// NativeContractKinds returns the registered kinds in order
+func NativeContractKinds() []string {
+	nativeMtx.RLock()
+	defer nativeMtx.RUnlock()
+	kinds := make([]string, 0, len(nativeKinds))
+	for kind := range nativeKinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// InstallNativeContracts replaces the installed native contracts with
+// installs, nothing changes if one of them is invalid
+func InstallNativeContracts(installs []*NativeInstall) error {
+	nativeMtx.Lock()
+	defer nativeMtx.Unlock()
+	contracts := make(map[common.Address]*installedNative, len(installs))
+	for _, in := range installs {
+		kind, ok := nativeKinds[in.Kind]
+		if !ok {
+			return fmt.Errorf("unknown native contract %s", in.Kind)
+		}
+		if PrecompiledContractsByzantium[in.Address] != nil || PrecompiledContractsHomestead[in.Address] != nil {
+			return fmt.Errorf("native contract %s at the precompiled contract %x", in.Kind, in.Address)
+		}
+		if in.Address == PermissionsAddress {
+			return fmt.Errorf("native contract %s at the admin contract %x", in.Kind, in.Address)
+		}
+		if _, ok := contracts[in.Address]; ok {
+			return fmt.Errorf("two native contracts at %x", in.Address)
+		}
+		n := &installedNative{contract: kind.contract, schedule: kind.schedule, height: in.ActivationHeight}
+		if in.BaseGas != nil {
+			n.schedule.Base = *in.BaseGas
+		}
+		if in.WordGas != nil {
+			n.schedule.PerWord = *in.WordGas
+		}
+		contracts[in.Address] = n
+	}
+	nativeContracts = contracts
+	return nil
+}
+
+// NativeContractAddresses returns the addresses of the installed native
+// contracts in order
+func NativeContractAddresses() []common.Address {
+	nativeMtx.RLock()
+	defer nativeMtx.RUnlock()
+	addrs := make([]common.Address, 0, len(nativeContracts))
+	for addr := range nativeContracts {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i].Big().Cmp(addrs[j].Big()) < 0 })
+	return addrs
+}
+
+// activeNative returns the native contract at addr if it is active at number
+func activeNative(addr common.Address, number *big.Int) *installedNative {
+	nativeMtx.RLock()
+	n := nativeContracts[addr]
+	nativeMtx.RUnlock()
+	if n == nil {
+		return nil
+	}
+	if n.height > 0 && (number == nil || number.Cmp(new(big.Int).SetUint64(n.height)) < 0) {
+		return nil
+	}
+	return n
+}
+
+// staticCall tells if the interpreter runs a static call, the calls it makes
+// mustn't change the state either
+func (evm *EVM) staticCall() bool {
+	in, ok := evm.interpreter.(*EVMInterpreter)
+	return ok && in.readOnly
+}
+
+func (n *installedNative) requiredGas(input []byte) uint64 {
+	gas, overflow := math.SafeAdd(n.schedule.Gas(input), n.contract.RequiredGas(input))
+	if overflow {
+		return math.MaxUint64
+	}
+	return gas
+}
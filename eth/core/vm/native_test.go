@@ -0,0 +1,89 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vm
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/dappledger/AnnChain/eth/common"
+	"github.com/dappledger/AnnChain/eth/core/state"
+	"github.com/dappledger/AnnChain/eth/ethdb"
+	"github.com/dappledger/AnnChain/eth/params"
+)
+
+// echoNative returns the caller followed by the input
+type echoNative struct{}
+
+func (echoNative) RequiredGas(input []byte) uint64 { return 7 }
+
+func (echoNative) Run(ctx *NativeContext, input []byte) ([]byte, error) {
+	return append(ctx.Caller.Bytes(), input...), nil
+}
+
+func TestNativeContracts(t *testing.T) {
+	RegisterNativeContract("test.echo", echoNative{}, GasSchedule{Base: 100, PerWord: 10})
+	defer InstallNativeContracts(nil)
+
+	var (
+		caller = common.HexToAddress("0x0a")
+		echo   = common.HexToAddress("0x0100")
+		input  = make([]byte, 33)
+		base   = uint64(200)
+	)
+	if err := InstallNativeContracts([]*NativeInstall{{Kind: "test.echo", Address: echo, ActivationHeight: 5, BaseGas: &base}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the invalid installs are refused and keep the installed ones
+	for _, installs := range [][]*NativeInstall{
+		{{Kind: "test.unknown", Address: echo}},
+		{{Kind: "test.echo", Address: common.BytesToAddress([]byte{1})}},
+		{{Kind: "test.echo", Address: PermissionsAddress}},
+		{{Kind: "test.echo", Address: echo}, {Kind: "test.echo", Address: echo}},
+	} {
+		if err := InstallNativeContracts(installs); err == nil {
+			t.Fatalf("expected the install of %v to fail", installs[0])
+		}
+	}
+
+	call := func(number int64) ([]byte, uint64) {
+		db, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+		ctx := Context{
+			CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+			Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+			BlockNumber: big.NewInt(number),
+		}
+		evm := NewEVM(ctx, db, params.TestChainConfig, Config{EVMGasLimit: 100000})
+		ret, _, err := evm.Call(AccountRef(caller), echo, input, 100000, new(big.Int))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ret, 100000 - evm.GasLeft()
+	}
+
+	// not active before its activation height
+	if ret, _ := call(4); ret != nil {
+		t.Fatalf("expected no native contract at 4, got %x", ret)
+	}
+	ret, gas := call(5)
+	if !bytes.Equal(ret, append(caller.Bytes(), input...)) {
+		t.Fatalf("unexpected output %x", ret)
+	}
+	if gas != 200+2*10+7 {
+		t.Fatalf("expected 227 gas, got %d", gas)
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/dappledger/AnnChain/eth/common"
+	"github.com/dappledger/AnnChain/eth/crypto"
+)
+
+// The permission table says which accounts may send the txs creating
+// contracts or moving value once they are restricted. It lives in the storage
+// of PermissionsAddress so that it is part of the state root, the admin
+// contract changes it.
+
+const (
+	PermissionDeploy   = "deploy"   // send txs creating contracts
+	PermissionTransfer = "transfer" // send txs moving value
+)
+
+const (
+	deployBit uint64 = 1 << iota
+	transferBit
+)
+
+var (
+	// PermissionsAddress is the admin contract of the default genesis, its code
+	// keeps the account from being deleted as empty
+	PermissionsAddress = common.HexToAddress("0x02000000")
+
+	ErrPermissionDenied = errors.New("permission denied")
+
+	permissionNames = []string{PermissionDeploy, PermissionTransfer} // in the order of their bits
+
+	restrictedSlot = crypto.Keccak256Hash([]byte("permissions.restricted"))
+)
+
+const permissionsList = "permissions" // of the accounts having permissions
+
+// AccountPermissions are the permissions granted to an account
+type AccountPermissions struct {
+	Address     common.Address `json:"address"`
+	Permissions []string       `json:"permissions"`
+}
+
+// Permissions is the permission table
+type Permissions struct {
+	Restricted []string              `json:"restricted"` // the permissions accounts need
+	Accounts   []*AccountPermissions `json:"accounts"`
+}
+
+// the bits of an account are kept at accountSlot
+func accountSlot(addr common.Address) common.Hash {
+	return crypto.Keccak256Hash([]byte("permissions.account"), addr.Bytes())
+}
+
+// the addresses of a list are at its memberSlot 0 to count-1, its indexSlot
+// keeps the position+1 of each
+func countSlot(list string) common.Hash {
+	return crypto.Keccak256Hash([]byte(list + ".count"))
+}
+
+func indexSlot(list string, addr common.Address) common.Hash {
+	return crypto.Keccak256Hash([]byte(list+".index"), addr.Bytes())
+}
+
+func memberSlot(list string, i uint64) common.Hash {
+	return crypto.Keccak256Hash([]byte(list+".member"), uintHash(i).Bytes())
+}
+
+// listAdd adds addr to list if it is not in it yet
+func listAdd(db StateDB, list string, addr common.Address) {
+	if getUint(db, indexSlot(list, addr)) != 0 {
+		return
+	}
+	count := getUint(db, countSlot(list))
+	db.SetState(PermissionsAddress, memberSlot(list, count), addr.Hash())
+	setUint(db, indexSlot(list, addr), count+1)
+	setUint(db, countSlot(list), count+1)
+}
+
+// listRemove removes addr from list, the last member takes its place
+func listRemove(db StateDB, list string, addr common.Address) {
+	index := getUint(db, indexSlot(list, addr))
+	if index == 0 {
+		return
+	}
+	count := getUint(db, countSlot(list))
+	last := common.BytesToAddress(db.GetState(PermissionsAddress, memberSlot(list, count-1)).Bytes())
+	db.SetState(PermissionsAddress, memberSlot(list, index-1), last.Hash())
+	setUint(db, indexSlot(list, last), index)
+	db.SetState(PermissionsAddress, memberSlot(list, count-1), common.Hash{})
+	db.SetState(PermissionsAddress, indexSlot(list, addr), common.Hash{})
+	setUint(db, countSlot(list), count-1)
+}
+
+func listMembers(db StateDB, list string) []common.Address {
+	count := getUint(db, countSlot(list))
+	members := make([]common.Address, 0, count)
+	for i := uint64(0); i < count; i++ {
+		members = append(members, common.BytesToAddress(db.GetState(PermissionsAddress, memberSlot(list, i)).Bytes()))
+	}
+	return members
+}
+
+func uintHash(v uint64) common.Hash {
+	return common.BigToHash(new(big.Int).SetUint64(v))
+}
+
+func getUint(db StateDB, slot common.Hash) uint64 {
+	return db.GetState(PermissionsAddress, slot).Big().Uint64()
+}
+
+func setUint(db StateDB, slot common.Hash, v uint64) {
+	db.SetState(PermissionsAddress, slot, uintHash(v))
+}
+
+func permissionBit(permission string) (uint64, error) {
+	for i, name := range permissionNames {
+		if name == permission {
+			return 1 << uint(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown permission %q", permission)
+}
+
+func permissionsOf(bits uint64) []string {
+	permissions := make([]string, 0, len(permissionNames))
+	for i, name := range permissionNames {
+		if bits&(1<<uint(i)) != 0 {
+			permissions = append(permissions, name)
+		}
+	}
+	return permissions
+}
+
+// SetRestricted makes the accounts need permission to send the txs it is
+// about, or not anymore
+func SetRestricted(db StateDB, permission string, restricted bool) error {
+	bit, err := permissionBit(permission)
+	if err != nil {
+		return err
+	}
+	bits := getUint(db, restrictedSlot)
+	if restricted {
+		bits |= bit
+	} else {
+		bits &^= bit
+	}
+	setUint(db, restrictedSlot, bits)
+	return nil
+}
+
+// SetPermission grants permission to addr or revokes it, the accounts left
+// without any leave the table
+func SetPermission(db StateDB, addr common.Address, permission string, granted bool) error {
+	bit, err := permissionBit(permission)
+	if err != nil {
+		return err
+	}
+	bits := getUint(db, accountSlot(addr))
+	if granted {
+		bits |= bit
+	} else {
+		bits &^= bit
+	}
+	setUint(db, accountSlot(addr), bits)
+	if bits != 0 {
+		listAdd(db, permissionsList, addr)
+	} else {
+		listRemove(db, permissionsList, addr)
+	}
+	return nil
+}
+
+// GetPermissions returns the permission table, only with the permissions of
+// addr if it is not nil
+func GetPermissions(db StateDB, addr *common.Address) *Permissions {
+	p := &Permissions{
+		Restricted: permissionsOf(getUint(db, restrictedSlot)),
+		Accounts:   make([]*AccountPermissions, 0),
+	}
+	if addr != nil {
+		p.Accounts = append(p.Accounts, &AccountPermissions{Address: *addr, Permissions: permissionsOf(getUint(db, accountSlot(*addr)))})
+		return p
+	}
+	for _, member := range listMembers(db, permissionsList) {
+		p.Accounts = append(p.Accounts, &AccountPermissions{Address: member, Permissions: permissionsOf(getUint(db, accountSlot(member)))})
+	}
+	return p
+}
+
+// CheckTxPermissions returns ErrPermissionDenied if from needs a permission it
+// lacks to send a tx creating a contract if create is set, or moving value
+func CheckTxPermissions(db StateDB, from common.Address, create bool, value *big.Int) error {
+	restricted := getUint(db, restrictedSlot)
+	if restricted == 0 {
+		return nil
+	}
+	var need uint64
+	if create {
+		need |= deployBit
+	}
+	if value != nil && value.Sign() > 0 {
+		need |= transferBit
+	}
+	if missing := need & restricted &^ getUint(db, accountSlot(from)); missing != 0 {
+		return fmt.Errorf("%v: %s needs %s", ErrPermissionDenied, from.Hex(), strings.Join(permissionsOf(missing), ","))
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/dappledger/AnnChain/eth/common"
+	"github.com/dappledger/AnnChain/eth/core/state"
+	"github.com/dappledger/AnnChain/eth/ethdb"
+	"github.com/dappledger/AnnChain/eth/params"
+)
+
+func TestPermissions(t *testing.T) {
+	db, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	var (
+		a   = common.HexToAddress("0x0a")
+		b   = common.HexToAddress("0x0b")
+		c   = common.HexToAddress("0x0c")
+		one = big.NewInt(1)
+	)
+
+	// nothing is restricted until the table says so
+	if err := CheckTxPermissions(db, a, true, one); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetRestricted(db, PermissionDeploy, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetRestricted(db, "mint", true); err == nil {
+		t.Fatal("expected an unknown permission to fail")
+	}
+	for _, addr := range []common.Address{a, b, c} {
+		if err := SetPermission(db, addr, PermissionDeploy, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	SetPermission(db, b, PermissionTransfer, true)
+
+	if err := CheckTxPermissions(db, a, true, one); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckTxPermissions(db, common.HexToAddress("0x0d"), false, one); err != nil {
+		t.Fatalf("expected transfers unrestricted, got %v", err)
+	}
+	if err := CheckTxPermissions(db, common.HexToAddress("0x0d"), true, nil); err == nil {
+		t.Fatal("expected a deployer out of the table to be denied")
+	}
+
+	// revoking the only permission of a moves c in its place
+	SetPermission(db, a, PermissionDeploy, false)
+	p := GetPermissions(db, nil)
+	if len(p.Restricted) != 1 || p.Restricted[0] != PermissionDeploy {
+		t.Fatalf("unexpected restricted permissions %v", p.Restricted)
+	}
+	if len(p.Accounts) != 2 || p.Accounts[0].Address != c || p.Accounts[1].Address != b || len(p.Accounts[1].Permissions) != 2 {
+		t.Fatalf("unexpected accounts %+v %+v", p.Accounts[0], p.Accounts[1])
+	}
+	if err := CheckTxPermissions(db, a, true, nil); err == nil {
+		t.Fatal("expected a to be denied once revoked")
+	}
+
+	SetRestricted(db, PermissionTransfer, true)
+	if err := CheckTxPermissions(db, c, false, one); err == nil {
+		t.Fatal("expected c to be denied moving value")
+	}
+	if err := CheckTxPermissions(db, c, false, new(big.Int)); err != nil {
+		t.Fatalf("expected calls without value allowed, got %v", err)
+	}
+	if p := GetPermissions(db, &a); len(p.Accounts) != 1 || len(p.Accounts[0].Permissions) != 0 {
+		t.Fatalf("expected a without permissions, got %+v", p.Accounts)
+	}
+}
+
+func TestDeployPermissionInContracts(t *testing.T) {
+	db, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	var (
+		deployer = common.HexToAddress("0x0a")
+		other    = common.HexToAddress("0x0b")
+		factory  = common.HexToAddress("0x0c")
+	)
+	// PUSH1 0 PUSH1 0 PUSH1 0 CREATE PUSH1 0 SSTORE: keeps the created address in slot 0
+	db.SetCode(factory, common.Hex2Bytes("600060006000f060005500"))
+	SetRestricted(db, PermissionDeploy, true)
+	SetPermission(db, deployer, PermissionDeploy, true)
+
+	created := func(origin common.Address) bool {
+		db.SetState(factory, common.Hash{}, common.Hash{})
+		ctx := Context{
+			CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+			Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+			Origin:      origin,
+			BlockNumber: new(big.Int),
+		}
+		evm := NewEVM(ctx, db, params.TestChainConfig, Config{EVMGasLimit: 1000000})
+		if _, _, err := evm.Call(AccountRef(origin), factory, nil, 1000000, new(big.Int)); err != nil {
+			t.Fatal(err)
+		}
+		return db.GetState(factory, common.Hash{}) != common.Hash{}
+	}
+	if !created(deployer) {
+		t.Fatal("expected the deployer to create through the factory")
+	}
+	if created(other) {
+		t.Fatal("expected a sender without the deploy permission to be denied through the factory")
+	}
+}
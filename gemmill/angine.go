@@ -49,7 +49,6 @@ import (
 	"github.com/dappledger/AnnChain/gemmill/state"
 	"github.com/dappledger/AnnChain/gemmill/trace"
 	"github.com/dappledger/AnnChain/gemmill/types"
-	"github.com/dappledger/AnnChain/gemmill/utils/zip"
 )
 
 const version = "0.9.0"
@@ -80,8 +79,10 @@ type Angine struct {
 	p2pHost       string
 	p2pPort       uint16
 	genesis       *types.GenesisDoc
+	bootstrap     *genesisBootstrap // of a node joining without genesis file
 	addrBook      *p2p.AddrBook
-	plugins       []plugin.IPlugin
+	plugins       []plugin.IPlugin // the running ones, in dependency order
+	loadedPlugins []*plugin.Loaded
 
 	getAdminVote func([]byte, *types.Validator) ([]byte, error)
 
@@ -133,12 +134,25 @@ func NewAngine(app types.Application, tune *Tunes) (angine *Angine, err error) {
 	dbBackend := conf.GetString("db_backend")
 	dbDir := conf.GetString("db_dir")
 
+	archiveClient, err := newArchiveClient(conf)
+	if err != nil {
+		fmt.Println("archive backend error: ", err)
+		return nil, err
+	}
+	if err = checkRetainBlocks(conf); err != nil {
+		fmt.Println("retain_blocks error: ", err)
+		return nil, err
+	}
+
 	genesis, err := getGenesisFile(conf)
 	if err != nil {
 		if err != GENESIS_NOT_FOUND {
 			return nil, err
 		}
-		genesis = nil
+		// a node which joined from the genesis of its peers stored it
+		if genesis = state.LoadGenesisDoc(dbs["state"]); genesis != nil {
+			conf.Set("chain_id", genesis.ChainID)
+		}
 	}
 
 	logger, err := getLogger(conf)
@@ -161,7 +175,8 @@ func NewAngine(app types.Application, tune *Tunes) (angine *Angine, err error) {
 	}
 
 	p2pListener := p2psw.Listeners()[0]
-	dataArchive := archive.NewArchive(dbBackend, dbDir, conf.GetInt64("threshold_blocks"))
+	dataArchive := archive.NewArchive(dbBackend, dbDir, conf.GetInt64("threshold_blocks"), archiveClient)
+	dataArchive.Signer = privValidator.GetPrivKey()
 	eventSwitch := types.NewEventSwitch()
 	angine = &Angine{
 		Tune: tune,
@@ -196,31 +211,47 @@ func (a *Angine) SetQueryPayLoadTxParser(fn func([]byte) ([]byte, error)) {
 	a.queryPayLoadTxParser = fn
 }
 
-func (a *Angine) OnRecvExchangeData(data *p2p.ExchangeData) error {
-	if data == nil {
+// OnRecvExchangeData bootstraps a node joining without a genesis file from the
+// genesis its peers offer, see genesisBootstrap. The accepted genesis is stored
+// under GenDocKey with the genesis state, so a restart doesn't need peers.
+func (a *Angine) OnRecvExchangeData(peerNodeInfo *p2p.NodeInfo, data *p2p.ExchangeData) error {
+	if data == nil || a.genesis != nil {
 		return nil
 	}
-	if a.genesis == nil {
-		if len(data.GenesisJSON) == 0 {
-			// TODO wait ...
-			return errors.New("no genesis file found in other node")
-		}
-		othGenesis, err := types.GenesisDocFromJSONRet(data.GenesisJSON)
-		if err != nil {
-			// TODO log err
-			fmt.Println("oth genesis err:", err)
-			return err
-		}
-		a.p2pSwitch.GetExchangeData().GenesisJSON = data.GenesisJSON
-		if err = a.buildState(othGenesis); err != nil {
-			// TODO log err
-			fmt.Println("build state err:", err)
-			return err
-		}
-		if a.stateMachine == nil {
-			return errors.New("state generaterr")
-		}
-		a.p2pSwitch.Start()
+	peer := peerNodeInfo.PubKey.KeyString()
+	genesis, err := a.bootstrap.offer(peer, data.GenesisJSON)
+	if err != nil {
+		log.Warn("Refused the genesis of peer", zap.String("peer", peer), zap.String("addr", peerNodeInfo.RemoteAddr), zap.Error(err))
+		return err
+	}
+	if genesis == nil {
+		return nil
+	}
+
+	log.Info("Accepted the genesis of peers", zap.String("chain_id", genesis.ChainID), zap.String("hash", fmt.Sprintf("%X", genesis.Hash())))
+	// the genesis state fills a zero genesis time in, keep the trusted one
+	// for the peers joining from this node
+	trusted := *genesis
+	if err = a.initAppGenesis(genesis); err != nil {
+		a.bootstrap.reset()
+		log.Error("Failed to init the app from the accepted genesis", zap.Error(err))
+		return err
+	}
+	if err = a.buildState(genesis); err != nil {
+		a.bootstrap.reset()
+		log.Error("Failed to build the state from the accepted genesis", zap.Error(err))
+		return err
+	}
+	if a.stateMachine == nil {
+		a.bootstrap.reset()
+		log.Error("No state built from the accepted genesis")
+		return errors.New("no state built from the accepted genesis")
+	}
+	state.SaveGenesisDoc(a.dbs["state"], &trusted)
+	a.p2pSwitch.GetExchangeData().GenesisJSON = trusted.JSONBytes()
+	if _, err = a.p2pSwitch.Start(); err != nil {
+		log.Error("Failed to start the switch", zap.Error(err))
+		return err
 	}
 	return nil
 }
@@ -228,12 +259,18 @@ func (a *Angine) OnRecvExchangeData(data *p2p.ExchangeData) error {
 func (a *Angine) buildState(genesis *types.GenesisDoc) error {
 	stateM, err := getOrMakeState(a.conf, a.dbs["state"], genesis)
 	if err != nil {
-		fmt.Println("getOrMakeState error: ", err)
+		log.Error("getOrMakeState error", zap.Error(err))
 		return err
 	}
 
 	if stateM == nil {
 		// delay assemble
+		if a.bootstrap, err = newGenesisBootstrap(a.conf); err != nil {
+			return err
+		}
+		if len(a.bootstrap.trusted) == 0 {
+			log.Warn("No genesis file nor genesis_hash, no peer genesis will be accepted")
+		}
 		a.p2pSwitch.SetDealExchangeDataFunc(a.OnRecvExchangeData)
 		return nil
 	}
@@ -267,6 +304,10 @@ func closeDBs(a *Angine) {
 func (ang *Angine) assembleStateMachine(stateM *state.State) {
 	conf := ang.tune.Conf
 	conf.Set("chain_id", stateM.ChainID)
+	// a node bootstrapping its genesis from peers only learns its network now
+	if ni := ang.p2pSwitch.NodeInfo(); ni != nil && ni.Network == "" {
+		ni.Network = stateM.ChainID
+	}
 
 	fastSync := fastSyncable(conf, ang.privValidator.GetAddress(), stateM.Validators)
 
@@ -291,6 +332,12 @@ func (ang *Angine) assembleStateMachine(stateM *state.State) {
 	bcReactor.SetBlockVerifier(func(bID types.BlockID, h int64, lc *types.Commit) error {
 		return stateM.Validators.VerifyCommit(stateM.ChainID, bID, h, lc)
 	})
+	bcReactor.SetValidators(stateM.ChainID, func() *types.ValidatorSet {
+		return stateM.Validators
+	})
+	bcReactor.SetBlockPartSize(func() int {
+		return int(stateM.GetConsensusParams(conf).BlockPartSize)
+	})
 	bcReactor.SetBlockExecuter(func(blk *types.Block, pst *types.PartSet, c *types.Commit) error {
 		blockStore.SaveBlock(blk, pst, c)
 		if err := stateM.ApplyBlock(*ang.eventSwitch, blk, pst.Header(), txPool, -1); err != nil {
@@ -404,6 +451,9 @@ func (e *Angine) ConnectApp(app types.Application) {
 	if e.genesis == nil {
 		return
 	}
+	if err := e.initAppGenesis(e.genesis); err != nil {
+		gcmn.PanicSanity(fmt.Sprintf("init app from the genesis failed,err:%v", err))
+	}
 
 	info := app.Info()
 	if err := e.RecoverFromCrash(info.LastBlockAppHash, int64(info.LastBlockHeight)); err != nil {
@@ -447,6 +497,17 @@ func (e *Angine) OriginHeight() int64 {
 	return e.blockstore.OriginHeight()
 }
 
+// PrunedHeight returns the height at and below which only block metas are
+// kept, 0 when nothing was pruned.
+func (e *Angine) PrunedHeight() int64 {
+	if !e.blockstore.Pruned() {
+		return 0
+	}
+	return e.blockstore.OriginHeight()
+}
+
+// GetBlock returns the block at height and its meta, only the meta with
+// ErrBlockPruned if the block was pruned
 func (e *Angine) GetBlock(height int64) (block *types.Block, meta *types.BlockMeta, err error) {
 
 	meta, err = e.GetBlockMeta(height)
@@ -455,7 +516,7 @@ func (e *Angine) GetBlock(height int64) (block *types.Block, meta *types.BlockMe
 	}
 	if height > e.blockstore.OriginHeight() {
 		block = e.blockstore.LoadBlock(height)
-	} else {
+	} else if !e.blockstore.Pruned() {
 		archiveDB, errN := e.newArchiveDB(height)
 		if errN != nil {
 			err = errN
@@ -464,6 +525,12 @@ func (e *Angine) GetBlock(height int64) (block *types.Block, meta *types.BlockMe
 		defer archiveDB.Close()
 		newStore := blockchain.NewBlockStore(archiveDB, nil)
 		block = newStore.LoadBlock(height)
+	} else {
+		err = ErrBlockPruned
+		return
+	}
+	if block == nil {
+		err = fmt.Errorf("block %d not found", height)
 	}
 	return
 }
@@ -478,7 +545,7 @@ func (e *Angine) GetBlockMeta(height int64) (meta *types.BlockMeta, err error) {
 		err = fmt.Errorf("height(%d) must be less than the current blockchain height(%d)", height, e.Height())
 		return
 	}
-	if height > e.blockstore.OriginHeight() {
+	if height > e.blockstore.OriginHeight() || e.blockstore.Pruned() {
 		meta = e.blockstore.LoadBlockMeta(height)
 	} else {
 		archiveDB, errN := e.newArchiveDB(height)
@@ -490,28 +557,52 @@ func (e *Angine) GetBlockMeta(height int64) (meta *types.BlockMeta, err error) {
 		newStore := blockchain.NewBlockStore(archiveDB, nil)
 		meta = newStore.LoadBlockMeta(height)
 	}
+	if meta == nil {
+		err = fmt.Errorf("block meta %d not found", height)
+	}
 	return
 }
 
-func (e *Angine) newArchiveDB(height int64) (archiveDB dbm.DB, err error) {
-
-	fileHash := string(e.dataArchive.QueryFileHash(height))
-	archiveDir := e.conf.GetString("db_archive_dir")
-	_, err = os.Stat(filepath.Join(archiveDir, fileHash+".zip"))
+// newArchiveClient returns the configured archive backend, archiving blocks
+// without one would lose them, so that is refused at startup
+func newArchiveClient(conf *viper.Viper) (archive.ArchiveClient, error) {
+	client, err := archive.NewArchiveClient(conf)
 	if err != nil {
-		// download archive data zip
-		err = e.dataArchive.Client.DownloadFile(fileHash, filepath.Join(archiveDir, fileHash+".zip"))
-		if err != nil {
-			return
-		}
-		err = zip.Decompress(filepath.Join(archiveDir, fileHash+".zip"), filepath.Join(archiveDir, fileHash+".db"))
-		if err != nil {
-			return
-		}
+		return nil, err
 	}
+	if threshold := conf.GetInt64("threshold_blocks"); threshold > 0 && client == nil {
+		return nil, fmt.Errorf("threshold_blocks is %d but archive_backend is not set, set it to %q or %q or disable archiving",
+			threshold, archive.BackendLocal, archive.BackendS3)
+	}
+	return client, nil
+}
 
-	archiveDB = dbm.NewDB(fileHash, e.conf.GetString("db_backend"), archiveDir)
-	return
+// checkRetainBlocks validates the pruning mode, a node either prunes or
+// archives its old blocks
+func checkRetainBlocks(conf *viper.Viper) error {
+	retain := conf.GetInt64("retain_blocks")
+	if retain <= 0 {
+		return nil
+	}
+	if conf.GetInt64("threshold_blocks") > 0 {
+		return errors.New("retain_blocks and threshold_blocks cannot both be set")
+	}
+	if retain < blockchain.MinRetainBlocks {
+		return fmt.Errorf("retain_blocks must be at least %d", blockchain.MinRetainBlocks)
+	}
+	return nil
+}
+
+func (e *Angine) newArchiveDB(height int64) (archiveDB dbm.DB, err error) {
+	return blockchain.OpenArchiveSegment(e.conf, e.dataArchive, e.blockstore, height)
+}
+
+// initAppGenesis passes the genesis to the app configured by it
+func (e *Angine) initAppGenesis(genesis *types.GenesisDoc) error {
+	if app, ok := e.app.(types.GenesisApplication); ok {
+		return app.InitGenesis(genesis)
+	}
+	return nil
 }
 
 func (e *Angine) NoneGenesis() bool {
@@ -544,6 +635,9 @@ func (e *Angine) Start() error {
 	if seeds != "" {
 		e.DialSeeds(strings.Split(seeds, ","))
 	}
+	if peers := e.tune.Conf.GetString("persistent_peers"); peers != "" {
+		e.p2pSwitch.DialPersistentPeers(strings.Split(peers, ","))
+	}
 
 	return nil
 }
@@ -557,9 +651,7 @@ func (ang *Angine) Stop() bool {
 
 // Destroy is called after something go south while before angine.Start has been called
 func (ang *Angine) Destroy() {
-	for _, p := range ang.plugins {
-		p.Stop()
-	}
+	plugin.Stop(ang.loadedPlugins)
 
 	if ang.refuseList != nil {
 		ang.refuseList.Stop()
@@ -610,6 +702,60 @@ func (e *Angine) GetValidators() (int64, *types.ValidatorSet) {
 	return e.stateMachine.LastBlockHeight, e.stateMachine.Validators
 }
 
+// GetValidatorsAt returns the validator set that signed the block at height,
+// one past the last block being the set signing the next one
+func (e *Angine) GetValidatorsAt(height int64) (*types.ValidatorSet, error) {
+	if height <= 0 || height > e.Height()+1 {
+		return nil, fmt.Errorf("height(%d) must be between 1 and %d", height, e.Height()+1)
+	}
+	return state.LoadValidators(e.dbs["state"], height)
+}
+
+// GetCommit returns the header of the block at height, 0 for the latest,
+// and the precommits committing it. They are canonical when taken from the
+// next block's LastCommit, the latest block only has the ones this node saw.
+func (e *Angine) GetCommit(height int64) (header *types.Header, commit *types.Commit, canonical bool, err error) {
+	last := e.Height()
+	if height == 0 {
+		height = last
+	}
+	meta, err := e.GetBlockMeta(height)
+	if err != nil {
+		return
+	}
+	header = meta.Header
+	if height < last {
+		commit = e.blockstore.LoadBlockCommit(height)
+		canonical = true
+	} else {
+		commit = e.blockstore.LoadSeenCommit(height)
+	}
+	if commit == nil {
+		err = fmt.Errorf("commit of height(%d) not found", height)
+	}
+	return
+}
+
+// GetProposerStats returns the proposer stats and the consensus params deciding
+// which validators are skipped as proposer
+func (e *Angine) GetProposerStats() (types.ProposerStats, *types.ConsensusParams) {
+	return e.stateMachine.ProposerStats, e.stateMachine.ConsensusParams
+}
+
+// GetSyncInfo returns the fast sync progress
+// GetSyncInfo returns the fast sync progress, an error until the blockchain
+// reactor is added with the state machine
+func (e *Angine) GetSyncInfo() (*types.ResultSyncInfo, error) {
+	if e.p2pSwitch == nil {
+		return nil, errors.New("p2p switch is not started")
+	}
+	bcReactor, ok := e.p2pSwitch.Reactor("BLOCKCHAIN").(*blockchain.BlockchainReactor)
+	if !ok {
+		return nil, errors.New("blockchain reactor is not started")
+	}
+	return bcReactor.SyncInfo(), nil
+}
+
 func (e *Angine) GetP2PNetInfo() (bool, []string, []*types.Peer) {
 	listening := e.p2pSwitch.IsListening()
 	listeners := []string{}
@@ -621,12 +767,43 @@ func (e *Angine) GetP2PNetInfo() (bool, []string, []*types.Peer) {
 		peers = append(peers, &types.Peer{
 			NodeInfo:         *p.NodeInfo,
 			IsOutbound:       p.IsOutbound(),
+			IsPersistent:     e.p2pSwitch.IsPersistent(p),
+			IsUnconditional:  e.p2pSwitch.IsUnconditional(p.Key),
+			IsPrivate:        e.p2pSwitch.IsPrivate(p.Key),
+			Score:            e.p2pSwitch.PeerScore(p.Key),
 			ConnectionStatus: p.Connection().Status(),
 		})
 	}
 	return listening, listeners, peers
 }
 
+// GetRejectedPeers returns the latest peers whose handshake was refused
+func (e *Angine) GetRejectedPeers() []*p2p.RejectedPeer {
+	return e.p2pSwitch.RejectedPeers()
+}
+
+// GetBans returns the peers banned for misbehaving
+func (e *Angine) GetBans() []*p2p.Ban {
+	return e.p2pSwitch.Bans()
+}
+
+// ClearBans lifts the bans on the pubkey or IP id, all of them if id is
+// empty, the addresses of the lifted bans are let back into the addrbook
+func (e *Angine) ClearBans(id string) []*p2p.Ban {
+	cleared := e.p2pSwitch.ClearBans(id)
+	if e.addrBook != nil {
+		for _, ban := range cleared {
+			if ban.IP != "" {
+				e.addrBook.ClearBans(ban.IP)
+			}
+		}
+		if id == "" {
+			e.addrBook.ClearBans("")
+		}
+	}
+	return cleared
+}
+
 func (e *Angine) GetNumPeers() int {
 	o, i, d := e.p2pSwitch.NumPeers()
 	return o + i + d
@@ -666,6 +843,16 @@ func (e *Angine) GetBlacklist() []string {
 	return e.refuseList.ListAllKey()
 }
 
+// GetRefuseList returns the refuse list entries matching f
+func (e *Angine) GetRefuseList(f *refuse_list.Filter) []*refuse_list.Entry {
+	return e.refuseList.List(f)
+}
+
+// GetRefuseHistory returns the last limit changes of the refuse list matching f
+func (e *Angine) GetRefuseHistory(f *refuse_list.Filter, limit int) []*refuse_list.Record {
+	return e.refuseList.History(f, limit)
+}
+
 func (ang *Angine) Query(queryType byte, load []byte) (interface{}, error) {
 	switch queryType {
 	case types.QueryTxExecution:
@@ -681,6 +868,48 @@ func (ang *Angine) Query(queryType byte, load []byte) (interface{}, error) {
 	return nil, errors.Errorf("[Angine Query] no such query type: %v", queryType)
 }
 
+func (ang *Angine) uptimePlugin() (*plugin.UptimePlugin, error) {
+	for _, p := range ang.plugins {
+		if up, ok := p.(*plugin.UptimePlugin); ok {
+			return up, nil
+		}
+	}
+	return nil, errors.New("uptime plugin is not enabled")
+}
+
+// GetValidatorUptime returns the uptime of the current validators over the last uptime_window heights
+func (ang *Angine) GetValidatorUptime() (*types.ResultValidatorUptime, error) {
+	up, err := ang.uptimePlugin()
+	if err != nil {
+		return nil, err
+	}
+	height, uptimes, err := up.Uptime()
+	if err != nil {
+		return nil, err
+	}
+	return &types.ResultValidatorUptime{
+		BlockHeight: height,
+		Window:      up.Window,
+		Validators:  uptimes,
+	}, nil
+}
+
+// GetValidatorSigningInfo returns the signing info of address, or of all the current validators
+func (ang *Angine) GetValidatorSigningInfo(address []byte) (*types.ResultValidatorSigningInfo, error) {
+	up, err := ang.uptimePlugin()
+	if err != nil {
+		return nil, err
+	}
+	height, infos, err := up.SigningInfos(address)
+	if err != nil {
+		return nil, err
+	}
+	return &types.ResultValidatorSigningInfo{
+		BlockHeight: height,
+		Infos:       infos,
+	}, nil
+}
+
 func (ang *Angine) QueryTransaction(load []byte) (interface{}, error) {
 	for _, p := range ang.plugins {
 
@@ -708,6 +937,8 @@ func (ang *Angine) QueryTransaction(load []byte) (interface{}, error) {
 				TransactionIndex: info.Index,
 				RawTransaction:   []byte(tx),
 				Timestamp:        uint64(timestamp),
+				Status:           info.Status,
+				Error:            info.Error,
 			}
 			return &t, err
 		}
@@ -835,20 +1066,44 @@ func (ang *Angine) BeginBlock(block *types.Block, eventFireable events.Fireable,
 	return nil
 }
 
-func (ang *Angine) ExecAdminTx(app plugin.AdminApp, tx []byte) error {
-	var ip *plugin.AdminOp
-	ok := false
+func (ang *Angine) adminOp() *plugin.AdminOp {
 	for _, p := range ang.plugins {
-		if ip, ok = p.(*plugin.AdminOp); ok {
-			break
+		if ip, ok := p.(*plugin.AdminOp); ok {
+			return ip
 		}
 	}
-	if ip != nil {
+	return nil
+}
+
+func (ang *Angine) ExecAdminTx(app plugin.AdminApp, tx []byte) error {
+	if ip := ang.adminOp(); ip != nil {
 		return ip.ExecTX(app, tx)
 	}
 	return fmt.Errorf("there is no plugin.AdminOp")
 }
 
+// GetAdminProposals returns the admin proposals of status, all of them if it
+// is empty
+func (ang *Angine) GetAdminProposals(status string) ([]*types.AdminProposal, error) {
+	ip := ang.adminOp()
+	if ip == nil {
+		return nil, fmt.Errorf("there is no plugin.AdminOp")
+	}
+	return ip.Proposals(status), nil
+}
+
+func (ang *Angine) GetAdminProposal(id string) (*types.AdminProposal, error) {
+	ip := ang.adminOp()
+	if ip == nil {
+		return nil, fmt.Errorf("there is no plugin.AdminOp")
+	}
+	p := ip.Proposal(strings.ToUpper(gcmn.SanitizeHex(id)))
+	if p == nil {
+		return nil, fmt.Errorf("no proposal %s", id)
+	}
+	return p, nil
+}
+
 func (ang *Angine) ExecBlock(block *types.Block, eventFireable events.Fireable, executeResult *types.ExecuteResult) error {
 	params := &plugin.ExecBlockParams{
 		Block:      block,
@@ -869,11 +1124,12 @@ func (ang *Angine) ExecBlock(block *types.Block, eventFireable events.Fireable,
 }
 
 // plugins modify changedValidators inplace
-func (ang *Angine) EndBlock(block *types.Block, eventFireable events.Fireable, blockPartsHeader *types.PartSetHeader, changedValAttrs []*types.ValidatorAttr, nextVS *types.ValidatorSet) error {
+func (ang *Angine) EndBlock(block *types.Block, eventFireable events.Fireable, blockPartsHeader *types.PartSetHeader, changedValAttrs []*types.ValidatorAttr, nextVS *types.ValidatorSet, nextParams *types.ConsensusParams) error {
 	params := &plugin.EndBlockParams{
-		Block:             block,
-		ChangedValidators: changedValAttrs,
-		NextValidatorSet:  nextVS,
+		Block:               block,
+		ChangedValidators:   changedValAttrs,
+		NextValidatorSet:    nextVS,
+		NextConsensusParams: nextParams,
 	}
 	for _, p := range ang.plugins {
 		_, err := p.EndBlock(params)
@@ -893,7 +1149,7 @@ func setEventSwitch(evsw types.EventSwitch, eventables ...types.Eventable) {
 
 func addToRefuselist(refuseList *refuse_list.RefuseList) func([]byte) error {
 	return func(pk []byte) error {
-		refuseList.AddRefuseKey(pk)
+		refuseList.AddEntry(pk, &refuse_list.Entry{Reason: "p2p"})
 		return nil
 	}
 }
@@ -940,51 +1196,85 @@ func authByCA(conf *viper.Viper, ppValidators **types.ValidatorSet) func(*p2p.No
 	}
 }
 
+// InitPlugins loads the core plugins named in the genesis, and the uptime
+// plugin when uptime_enabled, from the plugin registry and starts them in
+// dependency order.
 func (ang *Angine) InitPlugins() {
-	ps := strings.Split(ang.genesis.Plugins, ",")
-	pk := ang.privValidator.GetPrivKey()
+	var names []string
+	plugins := strings.Split(ang.genesis.Plugins, ",")
+	if ang.tune.Conf.GetBool("uptime_enabled") {
+		// uptime only watches the commits, every node may run it or not
+		plugins = append(plugins, "uptime")
+	}
+	for _, name := range plugins {
+		if name == "" {
+			// no core_plugins is allowed, so just ignore it
+			continue
+		}
+		if !plugin.IsRegistered(name) {
+			log.Warn("Ignoring unknown core plugin", zap.String("plugin", name))
+			continue
+		}
+		names = append(names, name)
+	}
+	loaded, err := plugin.Load(names, ang.tune.Conf)
+	if err != nil {
+		gcmn.PanicCrisis(err)
+	}
+
 	params := &plugin.InitParams{
 		StateDB:    ang.dbs["state"],
 		Switch:     ang.p2pSwitch,
-		PrivKey:    pk,
+		PrivKey:    ang.privValidator.GetPrivKey(),
 		RefuseList: ang.refuseList,
 		Validators: &ang.stateMachine.Validators,
+
+		ConsensusParams: &ang.stateMachine.ConsensusParams,
+	}
+	dbDir := ang.tune.Conf.GetString("db_dir")
+	openDB := func(spec *plugin.DBSpec) (dbm.DB, error) {
+		return openPluginDB(dbDir, spec)
+	}
+	if err := plugin.Start(loaded, params, openDB); err != nil {
+		// the plugins left stopped are reported by the plugins rpc
+		log.Error("[InitPlugins]", zap.Error(err))
 	}
 
-	for _, pn := range ps {
-		switch pn {
-		case "adminOp":
-			p := &plugin.AdminOp{}
-			p.Init(params)
-			ang.plugins = append(ang.plugins, p)
-		case "querycache":
-			p := &plugin.QueryCachePlugin{}
-			querydb, err := ensureQueryDB(ang.tune.Conf.GetString("db_dir"))
-			if err != nil {
-				// querydb failure is something that we can bear with
-				log.Error("[QueryCachePlugin Init]", zap.Error(err))
-				fmt.Println(err)
-			}
-			params.StateDB = querydb
-			p.Init(params)
-			ang.plugins = append(ang.plugins, p)
-		case "":
-			// no core_plugins is allowed, so just ignore it
-		default:
+	ang.loadedPlugins = loaded
+	for _, l := range loaded {
+		if l.Running {
+			l.Plugin.SetEventSwitch(*ang.eventSwitch)
+			ang.plugins = append(ang.plugins, l.Plugin)
+		}
+	}
+}
 
+// GetPlugins returns the core plugins loaded from the genesis and their health
+func (ang *Angine) GetPlugins() *types.ResultPlugins {
+	res := &types.ResultPlugins{
+		Plugins:    make([]*types.PluginInfo, 0, len(ang.loadedPlugins)),
+		Registered: plugin.Registered(),
+	}
+	for _, l := range ang.loadedPlugins {
+		info := &types.PluginInfo{Name: l.Name, Requires: l.Requires, Running: l.Running, Healthy: true}
+		if err := l.Health(); err != nil {
+			info.Healthy, info.Error = false, err.Error()
 		}
+		res.Plugins = append(res.Plugins, info)
 	}
+	return res
 }
 
-func ensureQueryDB(dbDir string) (*dbm.GoLevelDB, error) {
-	if err := gcmn.EnsureDir(path.Join(dbDir, "query_cache"), 0775); err != nil {
-		return nil, fmt.Errorf("fail to ensure tx_execution_result")
+func openPluginDB(dbDir string, spec *plugin.DBSpec) (dbm.DB, error) {
+	dir := path.Join(dbDir, spec.Dir)
+	if err := gcmn.EnsureDir(dir, 0775); err != nil {
+		return nil, fmt.Errorf("fail to ensure %s: %v", dir, err)
 	}
-	querydb, err := dbm.NewGoLevelDB("tx_execution_result", path.Join(dbDir, "query_cache"))
+	db, err := dbm.NewGoLevelDB(spec.Name, dir)
 	if err != nil {
-		return nil, fmt.Errorf("fail to open tx_execution_result")
+		return nil, fmt.Errorf("fail to open %s: %v", spec.Name, err)
 	}
-	return querydb, nil
+	return db, nil
 }
 
 func fastSyncable(conf *viper.Viper, selfAddress []byte, validators *types.ValidatorSet) bool {
@@ -1019,6 +1309,9 @@ func fastSyncable(conf *viper.Viper, selfAddress []byte, validators *types.Valid
 
 var (
 	GENESIS_NOT_FOUND = errors.New("missing genesis_file")
+	// ErrBlockPruned is returned for the blocks retain_blocks pruned, their
+	// metas are kept
+	ErrBlockPruned = errors.New("block pruned")
 )
 
 func getGenesisFile(conf *viper.Viper) (*types.GenesisDoc, error) {
@@ -1056,15 +1349,38 @@ func getOrMakeState(conf *viper.Viper, stateDB dbm.DB, genesis *types.GenesisDoc
 				return nil, fmt.Errorf("fail to get genesis state")
 			}
 		}
+	} else if genesis != nil && state.LoadGenesisDoc(stateDB) == nil {
+		if err := storeGenesisDoc(stateDB, stateM, genesis); err != nil {
+			return nil, err
+		}
 	}
 	return stateM, nil
 }
 
+// storeGenesisDoc stores the genesis file of a chain made before the JSON
+// genesis was kept under GenDocKey. The genesis of its state is decoded from
+// binary and lacks the JSON only fields, e.g. precompiles.
+func storeGenesisDoc(stateDB dbm.DB, stateM *state.State, genesis *types.GenesisDoc) error {
+	genDoc := *genesis
+	if genDoc.GenesisTime.IsZero() && stateM.GenesisDoc != nil {
+		// filled in by the genesis state
+		genDoc.GenesisTime = stateM.GenesisDoc.GenesisTime
+	}
+	if stateM.GenesisDoc == nil || !bytes.Equal(wire.BinaryBytes(genDoc), wire.BinaryBytes(*stateM.GenesisDoc)) {
+		return fmt.Errorf("genesis file differs from the genesis of chain %s", stateM.ChainID)
+	}
+	state.SaveGenesisDoc(stateDB, &genDoc)
+	stateM.GenesisDoc = &genDoc
+	return nil
+}
+
 func prepareP2P(conf *viper.Viper, genesis *types.GenesisDoc, privValidator *types.PrivValidator, refuseList *refuse_list.RefuseList) (*p2p.Switch, error) {
 	var genesisJSON []byte
+	var network string
 	var err error
 	if genesis != nil {
 		genesisJSON = genesis.JSONBytes()
+		network = genesis.ChainID
 	}
 	p2psw := p2p.NewSwitch(conf)
 	protocol, address := ProtocolAndAddress(conf.GetString("p2p_laddr"))
@@ -1076,6 +1392,7 @@ func prepareP2P(conf *viper.Viper, genesis *types.GenesisDoc, privValidator *typ
 		PubKey:      privValidator.GetPubKey(),
 		SigndPubKey: conf.GetString("signbyCA"),
 		Moniker:     conf.GetString("moniker"),
+		Network:     network,
 		ListenAddr:  defaultListener.ExternalAddress().String(),
 		Version:     version,
 	}
@@ -0,0 +1,106 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gemmill
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/dappledger/AnnChain/gemmill/blockchain"
+	"github.com/dappledger/AnnChain/gemmill/go-crypto"
+	dbm "github.com/dappledger/AnnChain/gemmill/modules/go-db"
+	"github.com/dappledger/AnnChain/gemmill/p2p"
+	"github.com/dappledger/AnnChain/gemmill/state"
+	"github.com/dappledger/AnnChain/gemmill/types"
+)
+
+func TestGetBlockPruned(t *testing.T) {
+	bs := blockchain.NewBlockStore(dbm.NewMemDB(), dbm.NewMemDB())
+	var lastID types.BlockID
+	for h := int64(1); h <= 5; h++ {
+		block := &types.Block{
+			Header:     &types.Header{ChainID: "angine-test", Height: h, LastBlockID: lastID, ValidatorsHash: []byte{0x01}},
+			Data:       &types.Data{},
+			LastCommit: &types.Commit{},
+		}
+		parts := block.MakePartSet(1024)
+		lastID = types.BlockID{Hash: block.Hash(), PartsHeader: parts.Header()}
+		bs.SaveBlock(block, parts, &types.Commit{BlockID: lastID})
+	}
+	if err := bs.PruneBlocks(3); err != nil {
+		t.Fatal(err)
+	}
+	e := &Angine{blockstore: bs}
+
+	block, meta, err := e.GetBlock(2)
+	if err != ErrBlockPruned || block != nil || meta == nil || meta.Header.Height != 2 {
+		t.Fatalf("expected the meta of the pruned block, got %v %v", meta, err)
+	}
+	if block, _, err := e.GetBlock(4); err != nil || block.Height != 4 {
+		t.Fatalf("expected block 4, got %v", err)
+	}
+	if _, _, err := e.GetBlock(6); err == nil {
+		t.Fatal("expected a block above the height to fail")
+	}
+}
+
+func TestGetSyncInfoBeforeStateMachine(t *testing.T) {
+	e := &Angine{}
+	if _, err := e.GetSyncInfo(); err == nil {
+		t.Fatal("expected an error without a p2p switch")
+	}
+	e.p2pSwitch = p2p.NewSwitch(viper.New())
+	if _, err := e.GetSyncInfo(); err == nil {
+		t.Fatal("expected an error without the blockchain reactor")
+	}
+}
+
+func TestGetOrMakeStateStoresGenesis(t *testing.T) {
+	pubKey := crypto.GenPrivKeyEd25519().PubKey()
+	genesisFile := func(chainID string) *types.GenesisDoc {
+		return &types.GenesisDoc{
+			ChainID:     chainID,
+			Validators:  []types.GenesisValidator{{PubKey: pubKey, Amount: 10}},
+			Precompiles: []*types.GenesisPrecompile{{Kind: "sm3", Address: "0x0000000000000000000000000000000000000100"}},
+		}
+	}
+	// a chain made before the JSON genesis was stored
+	oldChain := func() dbm.DB {
+		db := dbm.NewMemDB()
+		state.MakeGenesisState(db, genesisFile("angine-test")).Save()
+		db.DeleteSync(types.GenDocKey)
+		if s := state.LoadState(db); len(s.GenesisDoc.Precompiles) != 0 {
+			t.Fatal("expected the binary genesis without precompiles")
+		}
+		return db
+	}
+
+	db := oldChain()
+	stateM, err := getOrMakeState(viper.New(), db, genesisFile("angine-test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stateM.GenesisDoc.Precompiles) != 1 || stateM.GenesisDoc.GenesisTime.IsZero() {
+		t.Fatalf("expected the genesis file with the chain genesis time, got %+v", stateM.GenesisDoc)
+	}
+	if stored := state.LoadGenesisDoc(db); stored == nil || len(stored.Precompiles) != 1 {
+		t.Fatal("expected the genesis file stored")
+	}
+
+	if _, err := getOrMakeState(viper.New(), oldChain(), genesisFile("other-chain")); err == nil {
+		t.Fatal("expected the genesis file of another chain to be refused")
+	}
+}
@@ -16,6 +16,8 @@ package archive
 import (
 	"fmt"
 
+	"github.com/dappledger/AnnChain/gemmill/go-crypto"
+	"github.com/dappledger/AnnChain/gemmill/go-wire"
 	dbm "github.com/dappledger/AnnChain/gemmill/modules/go-db"
 )
 
@@ -23,6 +25,7 @@ type Archive struct {
 	db        dbm.DB
 	Threshold int64 // height int64
 	Client    ArchiveClient
+	Signer    crypto.PrivKey // signs the manifest of every new segment
 }
 
 type ArchiveClient interface {
@@ -30,16 +33,25 @@ type ArchiveClient interface {
 	DownloadFile(fileHash, filepath string) (err error)
 }
 
-var dbName = "archive"
+var (
+	dbName = "archive"
 
-func NewArchive(dbBackend, dbDir string, threshold int64) *Archive {
+	manifestPrefix    = "manifest_"
+	preManifestPrefix = "premanifest_"
+	// preManifestMarked is set once the segments archived before manifests
+	// existed are marked
+	preManifestMarked = []byte("premanifest")
+)
+
+func NewArchive(dbBackend, dbDir string, threshold int64, client ArchiveClient) *Archive {
 	archiveDB := dbm.NewDB(dbName, dbBackend, dbDir)
-	return &Archive{
+	ar := &Archive{
 		db:        archiveDB,
 		Threshold: threshold,
-		// client implement ArchiveClient
-		//	Client:    client,
+		Client:    client,
 	}
+	ar.markPreManifest()
+	return ar
 }
 
 func (ar *Archive) QueryFileHash(height int64) (ret []byte) {
@@ -54,6 +66,53 @@ func (ar *Archive) AddItem(originHeight int64, value string) {
 	ar.db.SetSync([]byte(key), []byte(value))
 }
 
+// SaveManifest keeps m by the hash of its segment file
+func (ar *Archive) SaveManifest(m *Manifest) {
+	ar.db.SetSync([]byte(manifestPrefix+m.FileHash), wire.BinaryBytes(m))
+}
+
+// LoadManifest returns the manifest of the segment file fileHash
+func (ar *Archive) LoadManifest(fileHash string) (*Manifest, error) {
+	bytez := ar.db.Get([]byte(manifestPrefix + fileHash))
+	if len(bytez) == 0 {
+		return nil, ErrManifestNotFound
+	}
+	var m *Manifest
+	if err := wire.ReadBinaryBytes(bytez, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// PreManifest tells if the segment file fileHash was archived before
+// manifests existed, it has none to be verified against
+func (ar *Archive) PreManifest(fileHash string) bool {
+	return len(ar.db.Get([]byte(preManifestPrefix+fileHash))) > 0
+}
+
+// markPreManifest marks the segments archived without a manifest, once. The
+// segments archived later always have one.
+func (ar *Archive) markPreManifest() {
+	if len(ar.db.Get(preManifestMarked)) > 0 {
+		return
+	}
+	var fileHashes []string
+	iter := ar.db.Iterator()
+	for iter != nil && iter.Next() {
+		var from, to int64
+		if n, err := fmt.Sscanf(string(iter.Key()), "%d_%d", &from, &to); err != nil || n != 2 {
+			continue
+		}
+		fileHashes = append(fileHashes, string(iter.Value()))
+	}
+	for _, fileHash := range fileHashes {
+		if len(ar.db.Get([]byte(manifestPrefix+fileHash))) == 0 {
+			ar.db.SetSync([]byte(preManifestPrefix+fileHash), []byte{1})
+		}
+	}
+	ar.db.SetSync(preManifestMarked, []byte{1})
+}
+
 func (ar *Archive) Close() {
 	ar.db.Close()
 }
@@ -0,0 +1,103 @@
+// Copyright © 2017 ZhongAn Technology
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	BackendNone  = ""
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+// NewArchiveClient builds the ArchiveClient selected by archive_backend,
+// it returns nil without error when no backend is configured.
+func NewArchiveClient(conf *viper.Viper) (ArchiveClient, error) {
+	switch backend := strings.ToLower(conf.GetString("archive_backend")); backend {
+	case BackendNone:
+		return nil, nil
+	case BackendLocal:
+		return NewLocalClient(conf.GetString("archive_local_dir"))
+	case BackendS3:
+		return NewS3Client(S3Config{
+			Endpoint:  conf.GetString("archive_s3_endpoint"),
+			Region:    conf.GetString("archive_s3_region"),
+			Bucket:    conf.GetString("archive_s3_bucket"),
+			Prefix:    conf.GetString("archive_s3_prefix"),
+			AccessKey: conf.GetString("archive_s3_access_key"),
+			SecretKey: conf.GetString("archive_s3_secret_key"),
+		})
+	default:
+		return nil, fmt.Errorf("unknown archive_backend %q, expect %q or %q", backend, BackendLocal, BackendS3)
+	}
+}
+
+// fileSHA256 returns the hex sha256 of the file's content, which archive
+// clients use as the content address of the uploaded file.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func validFileHash(fileHash string) error {
+	if b, err := hex.DecodeString(fileHash); err != nil || len(b) != sha256.Size {
+		return fmt.Errorf("invalid archive file hash %q", fileHash)
+	}
+	return nil
+}
+
+// saveVerified writes r to path, the file only appears at path if its
+// content hashes to fileHash.
+func saveVerified(r io.Reader, fileHash, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != fileHash {
+		return fmt.Errorf("archive file %s is corrupted, got checksum %s", fileHash, sum)
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,68 @@
+// Copyright © 2017 ZhongAn Technology
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// LocalClient keeps archive files in a directory, typically a filesystem shared by the nodes.
+type LocalClient struct {
+	dir string
+}
+
+func NewLocalClient(dir string) (*LocalClient, error) {
+	if dir == "" {
+		return nil, errors.New("archive_local_dir is required by the local archive backend")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &LocalClient{dir: dir}, nil
+}
+
+func (c *LocalClient) UploadFile(path string) (string, error) {
+	fileHash, err := fileSHA256(path)
+	if err != nil {
+		return "", err
+	}
+	dst := filepath.Join(c.dir, fileHash)
+	if _, err := os.Stat(dst); err == nil {
+		// content addressed, the same content is there already
+		return fileHash, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if err := saveVerified(f, fileHash, dst); err != nil {
+		return "", err
+	}
+	return fileHash, nil
+}
+
+func (c *LocalClient) DownloadFile(fileHash, path string) error {
+	if err := validFileHash(fileHash); err != nil {
+		return err
+	}
+	f, err := os.Open(filepath.Join(c.dir, fileHash))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return saveVerified(f, fileHash, path)
+}
@@ -0,0 +1,221 @@
+// Copyright © 2017 ZhongAn Technology
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/dappledger/AnnChain/gemmill/go-crypto"
+	"github.com/dappledger/AnnChain/gemmill/go-wire"
+	"github.com/dappledger/AnnChain/gemmill/modules/go-merkle"
+	"github.com/dappledger/AnnChain/gemmill/types"
+)
+
+var (
+	ErrManifestNotFound  = errors.New("archive manifest not found")
+	ErrManifestSignature = errors.New("archive manifest signature is invalid")
+	ErrManifestUnsigned  = errors.New("archive manifest is not signed")
+)
+
+// Manifest describes one archived segment, blocks FromHeight..ToHeight.
+// It is signed by the node that produced the segment and lets a downloaded
+// segment be checked against the hashes recorded at archive time.
+type Manifest struct {
+	ChainID     string   `json:"chain_id"`
+	FromHeight  int64    `json:"from_height"`
+	ToHeight    int64    `json:"to_height"`
+	ParentHash  []byte   `json:"parent_hash"`  // LastBlockID.Hash of the block at FromHeight
+	BlockHashes [][]byte `json:"block_hashes"` // BlockHashes[i] is the hash of block FromHeight+i
+	BlocksRoot  []byte   `json:"blocks_root"`  // simple merkle root of BlockHashes
+	FileHash    string   `json:"file_hash"`    // sha256 of the segment zip
+
+	PubKey    crypto.PubKey    `json:"pub_key"`
+	Signature crypto.Signature `json:"signature"`
+
+	// LastCommit is the seen commit of the block at ToHeight. It stays last
+	// since a commit may end with optional vote timestamps.
+	LastCommit *types.Commit `json:"last_commit"`
+}
+
+type canonicalManifest struct {
+	FromHeight     int64  `json:"from_height"`
+	ToHeight       int64  `json:"to_height"`
+	ParentHash     []byte `json:"parent_hash"`
+	BlocksRoot     []byte `json:"blocks_root"`
+	LastCommitHash []byte `json:"last_commit_hash"`
+	FileHash       string `json:"file_hash"`
+}
+
+type canonicalJSONOnceManifest struct {
+	ChainID  string            `json:"chain_id"`
+	Manifest canonicalManifest `json:"manifest"`
+}
+
+// NewManifest builds the unsigned manifest of a segment from its blocks,
+// which must be contiguous and in ascending height order.
+func NewManifest(blocks []*types.Block, lastCommit *types.Commit, fileHash string) (*Manifest, error) {
+	if len(blocks) == 0 {
+		return nil, errors.New("archive segment has no blocks")
+	}
+	first, last := blocks[0], blocks[len(blocks)-1]
+	m := &Manifest{
+		ChainID:     first.ChainID,
+		FromHeight:  first.Height,
+		ToHeight:    last.Height,
+		ParentHash:  first.LastBlockID.Hash,
+		BlockHashes: make([][]byte, len(blocks)),
+		LastCommit:  lastCommit,
+		FileHash:    fileHash,
+	}
+	for i, block := range blocks {
+		if block.Height != m.FromHeight+int64(i) {
+			return nil, fmt.Errorf("archive segment is not contiguous at height %d", block.Height)
+		}
+		m.BlockHashes[i] = block.Hash()
+	}
+	m.BlocksRoot = merkle.SimpleHashFromHashes(m.BlockHashes)
+	return m, nil
+}
+
+func (m *Manifest) WriteSignBytes(chainID string, w io.Writer, n *int, err *error) {
+	var commitHash []byte
+	if m.LastCommit != nil {
+		commitHash = m.LastCommit.Hash()
+	}
+	wire.WriteJSON(canonicalJSONOnceManifest{
+		chainID,
+		canonicalManifest{
+			FromHeight:     m.FromHeight,
+			ToHeight:       m.ToHeight,
+			ParentHash:     m.ParentHash,
+			BlocksRoot:     m.BlocksRoot,
+			LastCommitHash: commitHash,
+			FileHash:       m.FileHash,
+		},
+	}, w, n, err)
+}
+
+func (m *Manifest) Sign(privKey crypto.PrivKey) {
+	m.PubKey = privKey.PubKey()
+	m.Signature = privKey.Sign(types.SignBytes(m.ChainID, m))
+}
+
+// ValidateBasic checks the manifest is self consistent and correctly signed.
+func (m *Manifest) ValidateBasic() error {
+	if m.FromHeight <= 0 || m.ToHeight < m.FromHeight {
+		return fmt.Errorf("invalid archive manifest range %d_%d", m.FromHeight, m.ToHeight)
+	}
+	if int64(len(m.BlockHashes)) != m.ToHeight-m.FromHeight+1 {
+		return fmt.Errorf("archive manifest %d_%d has %d block hashes", m.FromHeight, m.ToHeight, len(m.BlockHashes))
+	}
+	if !bytes.Equal(merkle.SimpleHashFromHashes(m.BlockHashes), m.BlocksRoot) {
+		return fmt.Errorf("archive manifest %d_%d blocks root mismatch", m.FromHeight, m.ToHeight)
+	}
+	if m.LastCommit == nil {
+		return fmt.Errorf("archive manifest %d_%d has no last commit", m.FromHeight, m.ToHeight)
+	}
+	if m.LastCommit.Height() != m.ToHeight {
+		return fmt.Errorf("archive manifest last commit is for height %d, expected %d", m.LastCommit.Height(), m.ToHeight)
+	}
+	if !bytes.Equal(m.LastCommit.BlockID.Hash, m.LastHash()) {
+		return fmt.Errorf("archive manifest last commit is for block %X, expected %X", m.LastCommit.BlockID.Hash, m.LastHash())
+	}
+	if m.PubKey == nil || m.Signature == nil {
+		return ErrManifestUnsigned
+	}
+	if !m.PubKey.VerifyBytes(types.SignBytes(m.ChainID, m), m.Signature) {
+		return ErrManifestSignature
+	}
+	return nil
+}
+
+// LastHash returns the hash of the block at ToHeight.
+func (m *Manifest) LastHash() []byte {
+	if len(m.BlockHashes) == 0 {
+		return nil
+	}
+	return m.BlockHashes[len(m.BlockHashes)-1]
+}
+
+// BlockHash returns the recorded hash of the block at height, or nil when the
+// height is outside the segment.
+func (m *Manifest) BlockHash(height int64) []byte {
+	if height < m.FromHeight || height > m.ToHeight || int64(len(m.BlockHashes)) <= height-m.FromHeight {
+		return nil
+	}
+	return m.BlockHashes[height-m.FromHeight]
+}
+
+// Proof returns the merkle proof of the block at height against BlocksRoot.
+func (m *Manifest) Proof(height int64) (*merkle.SimpleProof, error) {
+	if m.BlockHash(height) == nil {
+		return nil, fmt.Errorf("height %d is outside archive segment %d_%d", height, m.FromHeight, m.ToHeight)
+	}
+	items := make([]merkle.Hashable, len(m.BlockHashes))
+	for i, hash := range m.BlockHashes {
+		items[i] = blockHash(hash)
+	}
+	_, proofs := merkle.SimpleProofsFromHashables(items)
+	return proofs[height-m.FromHeight], nil
+}
+
+// VerifyBlock checks that hash is the block at height using proof against
+// BlocksRoot, so a single block can be checked without the full hash list.
+func (m *Manifest) VerifyBlock(height int64, hash []byte, proof *merkle.SimpleProof) bool {
+	if proof == nil || height < m.FromHeight || height > m.ToHeight {
+		return false
+	}
+	total := int(m.ToHeight - m.FromHeight + 1)
+	return proof.Verify(int(height-m.FromHeight), total, hash, m.BlocksRoot)
+}
+
+// VerifyBlocks loads every block of the segment and checks each against the
+// manifest and the LastBlockID of its successor.
+func (m *Manifest) VerifyBlocks(load func(height int64) *types.Block) error {
+	prev := m.ParentHash
+	for h := m.FromHeight; h <= m.ToHeight; h++ {
+		block := load(h)
+		if block == nil {
+			return fmt.Errorf("archive segment %d_%d is missing block %d", m.FromHeight, m.ToHeight, h)
+		}
+		hash := block.Hash()
+		if !bytes.Equal(hash, m.BlockHash(h)) {
+			return fmt.Errorf("archived block %d hash %X does not match manifest %X", h, hash, m.BlockHash(h))
+		}
+		if !bytes.Equal(block.LastBlockID.Hash, prev) {
+			return fmt.Errorf("archived block %d links to %X, expected %X", h, block.LastBlockID.Hash, prev)
+		}
+		prev = hash
+	}
+	return nil
+}
+
+// VerifyNext checks the segment against lastBlockHash, the LastBlockID.Hash
+// of the block right after ToHeight, tying it to the chain that follows.
+func (m *Manifest) VerifyNext(lastBlockHash []byte) error {
+	if !bytes.Equal(lastBlockHash, m.LastHash()) {
+		return fmt.Errorf("block %d links to %X, archive segment %d_%d ends with %X",
+			m.ToHeight+1, lastBlockHash, m.FromHeight, m.ToHeight, m.LastHash())
+	}
+	return nil
+}
+
+type blockHash []byte
+
+func (h blockHash) Hash() []byte {
+	return h
+}
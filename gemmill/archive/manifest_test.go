@@ -0,0 +1,171 @@
+// Copyright © 2017 ZhongAn Technology
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/dappledger/AnnChain/gemmill/go-crypto"
+	dbm "github.com/dappledger/AnnChain/gemmill/modules/go-db"
+	"github.com/dappledger/AnnChain/gemmill/types"
+)
+
+func testSegment(from, to int64, parent []byte) ([]*types.Block, *types.Commit) {
+	blocks := make([]*types.Block, 0, to-from+1)
+	for h := from; h <= to; h++ {
+		block := &types.Block{
+			Header: &types.Header{
+				ChainID:        "archive-test",
+				Height:         h,
+				LastBlockID:    types.BlockID{Hash: parent},
+				ValidatorsHash: []byte{0x01},
+			},
+			Data:       &types.Data{},
+			LastCommit: &types.Commit{},
+		}
+		parent = block.Hash()
+		blocks = append(blocks, block)
+	}
+	commit := &types.Commit{
+		BlockID: types.BlockID{Hash: parent},
+		Precommits: []*types.Vote{{
+			Height:    to,
+			Type:      types.VoteTypePrecommit,
+			BlockID:   types.BlockID{Hash: parent},
+			Signature: crypto.SignatureEd25519{},
+		}},
+	}
+	return blocks, commit
+}
+
+func TestManifest(t *testing.T) {
+	blocks, commit := testSegment(11, 20, []byte("parent"))
+	m, err := NewManifest(blocks, commit, "filehash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ValidateBasic(); err != ErrManifestUnsigned {
+		t.Fatalf("unsigned manifest validated with %v", err)
+	}
+	m.Sign(crypto.GenPrivKeyEd25519())
+	if err := m.ValidateBasic(); err != nil {
+		t.Fatal(err)
+	}
+
+	ar := NewArchive("memdb", "", 10, nil)
+	ar.SaveManifest(m)
+	loaded, err := ar.LoadManifest("filehash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.ValidateBasic(); err != nil {
+		t.Fatalf("loaded manifest: %v", err)
+	}
+	if _, err := ar.LoadManifest("other"); err != ErrManifestNotFound {
+		t.Fatalf("expected ErrManifestNotFound, got %v", err)
+	}
+
+	loaded.FileHash = "other"
+	if err := loaded.ValidateBasic(); err != ErrManifestSignature {
+		t.Fatalf("tampered manifest validated with %v", err)
+	}
+
+	for h := m.FromHeight; h <= m.ToHeight; h++ {
+		proof, err := m.Proof(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !m.VerifyBlock(h, blocks[h-m.FromHeight].Hash(), proof) {
+			t.Fatalf("proof of block %d does not verify", h)
+		}
+		if m.VerifyBlock(h, []byte("bogus"), proof) {
+			t.Fatalf("proof of block %d verifies a bogus hash", h)
+		}
+	}
+	if _, err := m.Proof(21); err == nil {
+		t.Fatal("expected an error for a height outside the segment")
+	}
+}
+
+func TestPreManifestSegments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a segment archived before manifests existed, one with a manifest
+	db := dbm.NewDB(dbName, dbm.GoLevelDBBackendStr, dir)
+	ar := &Archive{db: db, Threshold: 10}
+	ar.AddItem(0, "legacy")
+	blocks, commit := testSegment(11, 20, []byte("parent"))
+	m, err := NewManifest(blocks, commit, "signed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ar.SaveManifest(m)
+	ar.AddItem(10, "signed")
+	db.Close()
+
+	ar = NewArchive(dbm.GoLevelDBBackendStr, dir, 5, nil)
+	if !ar.PreManifest("legacy") || ar.PreManifest("signed") {
+		t.Fatal("expected only the segment without a manifest marked")
+	}
+	// the manifest is found whatever the threshold is now
+	if _, err := ar.LoadManifest("signed"); err != nil {
+		t.Fatal(err)
+	}
+	// a segment losing its manifest later is not taken as a legacy one
+	ar.AddItem(20, "later")
+	ar.Close()
+	ar = NewArchive(dbm.GoLevelDBBackendStr, dir, 10, nil)
+	defer ar.Close()
+	if ar.PreManifest("later") {
+		t.Fatal("expected segments archived after the marking left unmarked")
+	}
+}
+
+func TestManifestVerifyBlocks(t *testing.T) {
+	blocks, commit := testSegment(1, 5, nil)
+	m, err := NewManifest(blocks, commit, "filehash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	load := func(height int64) *types.Block {
+		return blocks[height-1]
+	}
+	if err := m.VerifyBlocks(load); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.VerifyNext(blocks[4].Hash()); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.VerifyNext(blocks[3].Hash()); err == nil {
+		t.Fatal("expected a linkage error")
+	}
+
+	forged, _ := testSegment(1, 5, []byte("fork"))
+	if err := m.VerifyBlocks(func(height int64) *types.Block { return forged[height-1] }); err == nil {
+		t.Fatal("expected forged blocks to fail verification")
+	}
+	if err := m.VerifyBlocks(func(height int64) *types.Block { return nil }); err == nil {
+		t.Fatal("expected missing blocks to fail verification")
+	}
+	if bytes.Equal(m.BlockHash(3), blocks[3].Hash()) {
+		t.Fatal("block hash index is off by one")
+	}
+}
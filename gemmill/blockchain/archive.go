@@ -0,0 +1,105 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blockchain
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dappledger/AnnChain/gemmill/archive"
+	dbm "github.com/dappledger/AnnChain/gemmill/modules/go-db"
+	log "github.com/dappledger/AnnChain/gemmill/modules/go-log"
+	"github.com/dappledger/AnnChain/gemmill/utils/zip"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// OpenArchiveSegment returns the db of the archived segment holding height.
+// A segment that is not cached in db_archive_dir yet is downloaded and
+// verified against its manifest first; one that fails verification is removed.
+// Only the segments marked as archived before manifests existed go unverified.
+func OpenArchiveSegment(config *viper.Viper, ar *archive.Archive, live *BlockStore, height int64) (dbm.DB, error) {
+	fileHash := string(ar.QueryFileHash(height))
+	if fileHash == "" {
+		return nil, fmt.Errorf("height %d is not archived", height)
+	}
+	var (
+		archiveDir = config.GetString("db_archive_dir")
+		dbBackend  = config.GetString("db_backend")
+		zipPath    = filepath.Join(archiveDir, fileHash+".zip")
+		dbPath     = filepath.Join(archiveDir, fileHash+".db")
+	)
+	if _, err := os.Stat(zipPath); err == nil {
+		return dbm.NewDB(fileHash, dbBackend, archiveDir), nil
+	}
+
+	if err := ar.Client.DownloadFile(fileHash, zipPath); err != nil {
+		return nil, err
+	}
+	if err := zip.Decompress(zipPath, dbPath); err != nil {
+		os.Remove(zipPath)
+		return nil, err
+	}
+	segmentDB := dbm.NewDB(fileHash, dbBackend, archiveDir)
+	err := VerifyArchiveSegment(ar, fileHash, NewBlockStore(segmentDB, nil), live)
+	if err == archive.ErrManifestNotFound && ar.PreManifest(fileHash) {
+		log.Warn("archive segment was archived before manifests, skip verification", zap.String("file", fileHash))
+		err = nil
+	}
+	if err != nil {
+		segmentDB.Close()
+		os.Remove(zipPath)
+		os.RemoveAll(dbPath)
+		return nil, err
+	}
+	return segmentDB, nil
+}
+
+// VerifyArchiveSegment checks the segment of file fileHash against its signed
+// manifest, then links its last block to the chain that follows: the live
+// block right after it, or the next archived segment.
+func VerifyArchiveSegment(ar *archive.Archive, fileHash string, segment, live *BlockStore) error {
+	m, err := ar.LoadManifest(fileHash)
+	if err != nil {
+		return err
+	}
+	if m.FileHash != fileHash {
+		return fmt.Errorf("archive segment %d_%d is file %s, manifest says %s", m.FromHeight, m.ToHeight, fileHash, m.FileHash)
+	}
+	if err := m.ValidateBasic(); err != nil {
+		return err
+	}
+	if err := m.VerifyBlocks(segment.LoadBlock); err != nil {
+		return err
+	}
+	if m.ToHeight >= live.OriginHeight() {
+		next := live.LoadBlock(m.ToHeight + 1)
+		if next == nil {
+			return fmt.Errorf("block %d not found to link archive segment %d_%d", m.ToHeight+1, m.FromHeight, m.ToHeight)
+		}
+		return m.VerifyNext(next.LastBlockID.Hash)
+	}
+	nextHash := string(ar.QueryFileHash(m.ToHeight + 1))
+	if nextHash == "" {
+		return fmt.Errorf("no archived segment holds height %d to link archive segment %d_%d", m.ToHeight+1, m.FromHeight, m.ToHeight)
+	}
+	nm, err := ar.LoadManifest(nextHash)
+	if err != nil {
+		return err
+	}
+	return m.VerifyNext(nm.ParentHash)
+}
@@ -32,6 +32,9 @@ const (
 	maxPendingRequests        = maxTotalRequesters
 	maxPendingRequestsPerPeer = 75
 	minRecvRate               = 10240 // 10Kb/s
+
+	// peers serving blocks that fail verification are ignored for this long
+	badBlockBanMinutes = 10
 )
 
 var peerTimeoutSeconds = time.Duration(15) // not const so we can override with tests
@@ -53,11 +56,14 @@ type BlockPool struct {
 
 	mtx sync.Mutex
 	// block requests
-	requesters map[int64]*bpRequester
-	height     int64 // the lowest key in requesters.
-	numPending int32 // number of requests pending assignment or block response
+	requesters  map[int64]*bpRequester
+	startHeight int64
+	height      int64 // the lowest key in requesters.
+	numPending  int32 // number of requests pending assignment or block response
 	// peers
-	peers map[string]*bpPeer
+	peers        map[string]*bpPeer
+	banned       map[string]time.Time // peer id to the time its ban ends
+	numBadBlocks int64
 
 	requestsCh chan<- BlockRequest
 	timeoutsCh chan<- string
@@ -65,11 +71,13 @@ type BlockPool struct {
 
 func NewBlockPool(start int64, requestsCh chan<- BlockRequest, timeoutsCh chan<- string) *BlockPool {
 	bp := &BlockPool{
-		peers: make(map[string]*bpPeer),
+		peers:  make(map[string]*bpPeer),
+		banned: make(map[string]time.Time),
 
-		requesters: make(map[int64]*bpRequester),
-		height:     start,
-		numPending: 0,
+		requesters:  make(map[int64]*bpRequester),
+		startHeight: start,
+		height:      start,
+		numPending:  0,
 
 		requestsCh: requestsCh,
 		timeoutsCh: timeoutsCh,
@@ -179,6 +187,27 @@ func (pool *BlockPool) PeekTwoBlocks() (first *types.Block, second *types.Block)
 	return
 }
 
+// PeekBlocks returns up to max consecutive blocks from pool.height, so the
+// commits of several blocks can be verified at once.
+func (pool *BlockPool) PeekBlocks(max int) []*types.Block {
+	pool.mtx.Lock()
+	defer pool.mtx.Unlock()
+
+	blocks := make([]*types.Block, 0, max)
+	for h := pool.height; len(blocks) < max; h++ {
+		r := pool.requesters[h]
+		if r == nil {
+			break
+		}
+		block := r.getBlock()
+		if block == nil {
+			break
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
 // Pop the first block at pool.height
 // It must have been validated by 'second'.Commit from PeekTwoBlocks().
 func (pool *BlockPool) PopRequest() {
@@ -191,6 +220,10 @@ func (pool *BlockPool) PopRequest() {
 			gcmn.PanicSanity("PopRequest() requires a valid block")
 		}
 		*/
+		// the block got verified, credit the peer serving it
+		if peer := pool.peers[r.getPeerID()]; peer != nil {
+			peer.score++
+		}
 		r.Stop()
 		delete(pool.requesters, pool.height)
 		pool.height++
@@ -199,19 +232,45 @@ func (pool *BlockPool) PopRequest() {
 	}
 }
 
-// Invalidates the block at pool.height,
-// Remove the peer and redo request from others.
-func (pool *BlockPool) RedoRequest(height int64) {
+// Invalidates the block at height, which failed verification against the
+// commit of the block above it. Either block may be the bad one, so both
+// peers serving them get banned and removed, their requests are redone with
+// others. Returns the banned peers.
+func (pool *BlockPool) RedoRequest(height int64) []string {
 	pool.mtx.Lock()
-	request := pool.requesters[height]
-	pool.mtx.Unlock()
+	defer pool.mtx.Unlock()
 
-	if request.block == nil {
+	request := pool.requesters[height]
+	if request == nil || request.getBlock() == nil {
 		gcmn.PanicSanity("Expected block to be non-nil")
 	}
-	// RemovePeer will redo all requesters associated with this peer.
-	// TODO: record this malfeasance
-	pool.RemovePeer(request.peerID)
+	pool.numBadBlocks++
+
+	var bad []string
+	for _, h := range []int64{height, height + 1} {
+		r := pool.requesters[h]
+		if r == nil {
+			continue
+		}
+		peerID := r.getPeerID()
+		if peerID == "" || pool.isBanned(peerID) {
+			continue
+		}
+		pool.banned[peerID] = time.Now().Add(badBlockBanMinutes * time.Minute)
+		// removePeer will redo all requesters associated with this peer.
+		pool.removePeer(peerID)
+		bad = append(bad, peerID)
+	}
+	return bad
+}
+
+func (pool *BlockPool) isBanned(peerID string) bool {
+	until, ok := pool.banned[peerID]
+	if ok && time.Now().After(until) {
+		delete(pool.banned, peerID)
+		return false
+	}
+	return ok
 }
 
 // TODO: ensure that blocks come in order for each peer.
@@ -238,6 +297,9 @@ func (pool *BlockPool) SetPeerHeight(peerID string, height int64) {
 	pool.mtx.Lock()
 	defer pool.mtx.Unlock()
 
+	if pool.isBanned(peerID) {
+		return
+	}
 	peer := pool.peers[peerID]
 	if peer != nil {
 		peer.height = height
@@ -264,17 +326,19 @@ func (pool *BlockPool) removePeer(peerID string) {
 	delete(pool.peers, peerID)
 }
 
-// Pick an available peer with at least the given minHeight.
+// Pick an available peer with at least the given minHeight, the least busy
+// one and among those the one which served the most good blocks, so requests
+// spread over all peers.
 // If no peers are available, returns nil.
 func (pool *BlockPool) pickIncrAvailablePeer(minHeight int64) *bpPeer {
 	pool.mtx.Lock()
 	defer pool.mtx.Unlock()
 
+	var picked *bpPeer
 	for _, peer := range pool.peers {
 		if peer.didTimeout {
 			pool.removePeer(peer.id)
 			continue
-		} else {
 		}
 		if peer.numPending >= maxPendingRequestsPerPeer {
 			continue
@@ -282,10 +346,48 @@ func (pool *BlockPool) pickIncrAvailablePeer(minHeight int64) *bpPeer {
 		if peer.height < minHeight {
 			continue
 		}
-		peer.incrPending()
-		return peer
+		if picked == nil || peer.numPending < picked.numPending ||
+			(peer.numPending == picked.numPending && peer.score > picked.score) {
+			picked = peer
+		}
 	}
-	return nil
+	if picked != nil {
+		picked.incrPending()
+	}
+	return picked
+}
+
+// SyncInfo reports the progress of the pool and the peers it downloads from.
+func (pool *BlockPool) SyncInfo() *types.ResultSyncInfo {
+	pool.mtx.Lock()
+	defer pool.mtx.Unlock()
+
+	info := &types.ResultSyncInfo{
+		StartHeight:   pool.startHeight,
+		NumPending:    pool.numPending,
+		NumRequesters: len(pool.requesters),
+		NumBadBlocks:  pool.numBadBlocks,
+		Peers:         make([]*types.SyncPeer, 0, len(pool.peers)),
+		BannedPeers:   make([]string, 0, len(pool.banned)),
+	}
+	for _, peer := range pool.peers {
+		info.MaxPeerHeight = gcmn.MaxInt64(info.MaxPeerHeight, peer.height)
+		info.Peers = append(info.Peers, &types.SyncPeer{
+			ID:         peer.id,
+			Height:     peer.height,
+			NumPending: peer.numPending,
+			Score:      peer.score,
+		})
+	}
+	for id := range pool.banned {
+		if pool.isBanned(id) {
+			info.BannedPeers = append(info.BannedPeers, id)
+		}
+	}
+	if elapsed := int64(time.Since(pool.startTime) / time.Second); elapsed > 0 {
+		info.BlocksPerSecond = (pool.height - pool.startHeight) / elapsed
+	}
+	return info
 }
 
 func (pool *BlockPool) makeNextRequester() {
@@ -341,6 +443,7 @@ type bpPeer struct {
 	mtx        sync.Mutex
 	height     int64
 	numPending int32
+	score      int64 // blocks served and verified
 	timeout    *time.Timer
 	didTimeout bool
 }
@@ -150,3 +150,47 @@ func TestTimeout(t *testing.T) {
 
 	pool.Stop()
 }
+
+func TestRedoRequestBansPeers(t *testing.T) {
+	start := int64(42)
+	timeoutsCh := make(chan string, 100)
+	requestsCh := make(chan BlockRequest, 100)
+	pool := NewBlockPool(start, requestsCh, timeoutsCh)
+	pool.Start()
+	defer pool.Stop()
+
+	pool.SetPeerHeight("good", 100)
+	pool.SetPeerHeight("bad", 100)
+
+	// serve the first two heights from different peers
+	served := map[int64]string{}
+	for len(served) < 2 {
+		request := <-requestsCh
+		if request.Height > start+1 {
+			continue
+		}
+		if _, ok := served[request.Height]; ok {
+			continue
+		}
+		served[request.Height] = request.PeerID
+		pool.AddBlock(request.PeerID, &types.Block{Header: &types.Header{Height: request.Height}}, 123)
+	}
+
+	banned := pool.RedoRequest(start)
+	if len(banned) == 0 {
+		t.Fatal("expected the peers serving the bad blocks to be banned")
+	}
+	pool.SetPeerHeight(banned[0], 200)
+
+	info := pool.SyncInfo()
+	if info.NumBadBlocks != 1 || len(info.BannedPeers) != len(banned) {
+		t.Fatalf("unexpected sync info %+v", info)
+	}
+	for _, peer := range info.Peers {
+		for _, id := range banned {
+			if peer.ID == id {
+				t.Fatalf("banned peer %v is still in the pool", id)
+			}
+		}
+	}
+}
@@ -22,13 +22,14 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/dappledger/AnnChain/gemmill/archive"
 	"github.com/dappledger/AnnChain/gemmill/go-wire"
 	gcmn "github.com/dappledger/AnnChain/gemmill/modules/go-common"
-	"github.com/dappledger/AnnChain/gemmill/modules/go-db"
 	log "github.com/dappledger/AnnChain/gemmill/modules/go-log"
 	"github.com/dappledger/AnnChain/gemmill/p2p"
 	"github.com/dappledger/AnnChain/gemmill/types"
@@ -40,10 +41,14 @@ import (
 
 const (
 	BlockchainChannel      = byte(0x40)
+	ProtocolVersion        = "1.0.0"
 	defaultChannelCapacity = 100
 	defaultSleepIntervalMS = 500
 	trySyncIntervalMS      = 100
 
+	// verify and execute at most this many blocks every trySyncIntervalMS
+	maxSyncBatch = 128
+
 	// stop syncing when last block's time is within this much of the system time.
 	stopSyncingDurationMinutes = 10
 
@@ -54,6 +59,14 @@ const (
 	switchToConsensusIntervalSeconds = 1
 
 	maxBlockchainResponseSize = types.MaxBlockSize + 2
+
+	// prune at most pruneBatchSize blocks every pruneIntervalSeconds
+	pruneIntervalSeconds = 10
+	pruneBatchSize       = 1000
+
+	// MinRetainBlocks is the smallest retain_blocks accepted, recent blocks are
+	// still needed to replay state and serve peers catching up
+	MinRetainBlocks = 100
 )
 
 var ErrNotFound = errors.New("leveldb not found")
@@ -73,6 +86,12 @@ type BlockchainReactor struct {
 
 	blockVerifier func(types.BlockID, int64, *types.Commit) error
 	blockExecuter func(*types.Block, *types.PartSet, *types.Commit) error
+	blockPartSize func() int
+
+	chainID       string
+	validators    func() *types.ValidatorSet
+	verifyWorkers int
+	syncing       int32 // atomic, 1 while fast syncing
 
 	evsw types.EventSwitch
 }
@@ -96,6 +115,11 @@ func NewBlockchainReactor(config *viper.Viper, lastBlockHeight int64, store *Blo
 		requestsCh: requestsCh,
 		timeoutsCh: timeoutsCh,
 	}
+	bcR.blockPartSize = func() int { return config.GetInt("block_part_size") }
+	bcR.verifyWorkers = config.GetInt("fast_sync_verify_workers")
+	if bcR.verifyWorkers <= 0 {
+		bcR.verifyWorkers = runtime.NumCPU()
+	}
 	bcR.BaseReactor = *p2p.NewBaseReactor("BlockchainReactor", bcR)
 	return bcR
 }
@@ -108,6 +132,19 @@ func (bcR *BlockchainReactor) SetBlockExecuter(x func(*types.Block, *types.PartS
 	bcR.blockExecuter = x
 }
 
+// SetValidators lets the reactor verify the commits of several blocks in
+// parallel, vals returns the validator set of the next block to execute.
+// Without it blocks are verified one by one with the block verifier.
+func (bcR *BlockchainReactor) SetValidators(chainID string, vals func() *types.ValidatorSet) {
+	bcR.chainID = chainID
+	bcR.validators = vals
+}
+
+// SetBlockPartSize overrides the block_part_size config, e.g. by consensus params
+func (bcR *BlockchainReactor) SetBlockPartSize(f func() int) {
+	bcR.blockPartSize = f
+}
+
 func (bcR *BlockchainReactor) OnStart() error {
 	bcR.BaseReactor.OnStart()
 	if bcR.archive.Threshold > 0 {
@@ -115,11 +152,15 @@ func (bcR *BlockchainReactor) OnStart() error {
 	} else {
 		log.Warn("invalid archive.Threshold", zap.Int64("archive_threshold", bcR.archive.Threshold))
 	}
+	if retain := bcR.config.GetInt64("retain_blocks"); retain > 0 {
+		go bcR.BlockPrune(retain)
+	}
 	if bcR.fastSync {
 		_, err := bcR.pool.Start()
 		if err != nil {
 			return err
 		}
+		atomic.StoreInt32(&bcR.syncing, 1)
 		go bcR.poolRoutine()
 	}
 	return nil
@@ -130,6 +171,11 @@ func (bcR *BlockchainReactor) OnStop() {
 	bcR.pool.Stop()
 }
 
+// Implements p2p.VersionedReactor
+func (bcR *BlockchainReactor) ProtocolVersion() string {
+	return ProtocolVersion
+}
+
 // Implements Reactor
 func (bcR *BlockchainReactor) GetChannels() []*p2p.ChannelDescriptor {
 	return []*p2p.ChannelDescriptor{
@@ -158,6 +204,7 @@ func (bcR *BlockchainReactor) Receive(chID byte, src *p2p.Peer, msgBytes []byte)
 	_, msg, err := DecodeMessage(msgBytes)
 	if err != nil {
 		log.Warn("Error decoding message", zap.String("error", err.Error()))
+		bcR.Switch.PenalizePeer(src, p2p.PenaltyMalformedMsg, err)
 		return
 	}
 
@@ -170,7 +217,7 @@ func (bcR *BlockchainReactor) Receive(chID byte, src *p2p.Peer, msgBytes []byte)
 		height := msg.Height
 		if height > bcR.store.OriginHeight() {
 			block = bcR.store.LoadBlock(height)
-		} else {
+		} else if !bcR.store.Pruned() {
 			block, err = bcR.loadArchiveBlock(height)
 			if err != nil {
 				log.Error(" bcR.loadArchiveBlock failed", zap.String("error", err.Error()))
@@ -204,23 +251,10 @@ func (bcR *BlockchainReactor) Receive(chID byte, src *p2p.Peer, msgBytes []byte)
 }
 
 func (bcR *BlockchainReactor) loadArchiveBlock(height int64) (block *types.Block, err error) {
-
-	fileHash := string(bcR.archive.QueryFileHash(height))
-	archiveDir := bcR.config.GetString("db_archive_dir")
-	_, err = os.Stat(filepath.Join(archiveDir, fileHash+".zip"))
+	archiveDB, err := OpenArchiveSegment(bcR.config, bcR.archive, bcR.store, height)
 	if err != nil {
-		//download archive file zip
-		err = bcR.archive.Client.DownloadFile(fileHash, filepath.Join(archiveDir, fileHash+".zip"))
-		if err != nil {
-			return
-		}
-		err = zip.Decompress(filepath.Join(archiveDir, fileHash+".zip"), filepath.Join(archiveDir, fileHash+".db"))
-		if err != nil {
-			return
-		}
+		return
 	}
-
-	archiveDB := db.NewDB(fileHash, bcR.config.GetString("db_backend"), archiveDir)
 	defer archiveDB.Close()
 	newStore := NewBlockStore(archiveDB, nil)
 	block = newStore.LoadBlock(height)
@@ -251,9 +285,11 @@ func (bcR *BlockchainReactor) BlockArchive() {
 		}
 		originHeight := bcR.store.OriginHeight()
 		if bcR.store.Height()-originHeight > bcR.archive.Threshold {
+			blocks := make([]*types.Block, 0, bcR.archive.Threshold)
 			for i := originHeight + 1; i <= originHeight+bcR.archive.Threshold; i++ {
 				block := bcR.store.LoadBlock(i)
-				partSet := block.MakePartSet(bcR.config.GetInt("block_part_size"))
+				blocks = append(blocks, block)
+				partSet := block.MakePartSet(bcR.blockPartSize())
 				seenCommit := bcR.store.LoadSeenCommit(i)
 				bcR.store.SaveBlockToArchive(i, block, partSet, seenCommit)
 			}
@@ -274,6 +310,19 @@ func (bcR *BlockchainReactor) BlockArchive() {
 			} else {
 				log.Info("archiveClient.UploadFile success")
 			}
+			manifest, err := archive.NewManifest(blocks, bcR.store.LoadSeenCommit(originHeight+bcR.archive.Threshold), fileHash)
+			if err != nil {
+				log.Error("archive.NewManifest failed", zap.String("error", err.Error()))
+				os.Remove(storeDir + ".zip")
+				continue
+			}
+			if bcR.archive.Signer == nil {
+				log.Error("archive has no signer to sign the segment manifest")
+				os.Remove(storeDir + ".zip")
+				continue
+			}
+			manifest.Sign(bcR.archive.Signer)
+			bcR.archive.SaveManifest(manifest)
 			bcR.archive.AddItem(originHeight, fileHash)
 			bcR.store.SetOriginHeight(originHeight + bcR.archive.Threshold)
 			for i := originHeight + 1; i <= bcR.store.OriginHeight(); i++ {
@@ -287,6 +336,33 @@ func (bcR *BlockchainReactor) BlockArchive() {
 	}
 }
 
+// BlockPrune keeps the latest retain blocks in full and prunes the rest in
+// batches, leaving only their metas and commits.
+func (bcR *BlockchainReactor) BlockPrune(retain int64) {
+	pruneTicker := time.NewTicker(pruneIntervalSeconds * time.Second)
+	defer pruneTicker.Stop()
+	for {
+		select {
+		case <-bcR.Quit:
+			return
+		case <-pruneTicker.C:
+		}
+		originHeight := bcR.store.OriginHeight()
+		target := bcR.store.Height() - retain
+		if target > originHeight+pruneBatchSize {
+			target = originHeight + pruneBatchSize
+		}
+		if target <= originHeight {
+			continue
+		}
+		if err := bcR.store.PruneBlocks(target); err != nil {
+			log.Error("bcR.store.PruneBlocks failed", zap.String("error", err.Error()))
+			continue
+		}
+		log.Info("pruned blocks", zap.Int64("from", originHeight+1), zap.Int64("to", target))
+	}
+}
+
 // Handle messages from the poolReactor telling the reactor what to do.
 // NOTE: Don't sleep in the FOR_LOOP or otherwise slow it down!
 // (Except for the SYNC_LOOP, which is the primary purpose and must be synchronous.)
@@ -328,37 +404,18 @@ FOR_LOOP:
 				zap.Int("outbound", outbound), zap.Int("inbound", inbound))
 			if bcR.pool.IsCaughtUp() {
 				log.Info("Time to switch to consensus reactor!", zap.Int64("height", height))
+				atomic.StoreInt32(&bcR.syncing, 0)
 				bcR.pool.Stop()
 				types.FireEventSwitchToConsensus(bcR.evsw)
 				break FOR_LOOP
 			}
 		case _ = <-trySyncTicker.C: // chan time
 			// This loop can be slow as long as it's doing syncing work.
-		SYNC_LOOP:
-			for i := 0; i < 10; i++ {
-				// See if there are any blocks to sync.
-				first, second := bcR.pool.PeekTwoBlocks()
-				if first == nil || second == nil {
-					// We need both to sync the first block.
-					break SYNC_LOOP
-				}
-				firstParts := first.MakePartSet(bcR.config.GetInt("block_part_size")) // TODO: put part size in parts header?
-				firstPartsHeader := firstParts.Header()
-				// Finally, verify the first block using the second's commit
-				// NOTE: we can probably make this more efficient, but note that calling
-				// first.Hash() doesn't verify the tx contents, so MakePartSet() is
-				// currently necessary.
-
-				if err := bcR.blockVerifier(types.BlockID{Hash: first.Hash(), PartsHeader: firstPartsHeader}, first.Height, second.LastCommit); err != nil {
-					log.Error("error in validation", zap.String("error", err.Error()))
-					bcR.pool.RedoRequest(first.Height)
-					break SYNC_LOOP
-				} else {
-					bcR.pool.PopRequest()
-					if err := bcR.blockExecuter(first, firstParts, second.LastCommit); err != nil {
-						// TODO This is bad, are we zombie?
-						gcmn.PanicQ(gcmn.Fmt("Failed to process committed block (%d:%X): %v", first.Height, first.Hash(), err))
-					}
+			for i := 0; i < maxSyncBatch; {
+				n, ok := bcR.syncBlocks(maxSyncBatch - i)
+				i += n
+				if !ok || n == 0 {
+					break
 				}
 			}
 			continue FOR_LOOP
@@ -368,6 +425,70 @@ FOR_LOOP:
 	}
 }
 
+// syncBlocks verifies up to max blocks from the pool in parallel and executes
+// them in order. Verifying a block needs the commit of the block above it and
+// the validator set of its height, so a batch only reaches as far as the
+// block headers keep the validator set of the first block, where only the
+// proposer accums move on. Returns the number of blocks executed and false if
+// one of them failed verification.
+func (bcR *BlockchainReactor) syncBlocks(max int) (int, bool) {
+	blocks := bcR.pool.PeekBlocks(max + 1)
+	if len(blocks) < 2 {
+		// We need both to sync the first block.
+		return 0, true
+	}
+
+	verify := bcR.blockVerifier
+	if bcR.validators != nil {
+		vals := bcR.validators()
+		vals.TotalVotingPower() // memoized, fill it before the workers share vals
+		next := vals
+		for i := 1; i < len(blocks)-1; i++ {
+			next = next.Copy()
+			next.IncrementAccum(1)
+			if !bytes.Equal(blocks[i].ValidatorsHash, next.Hash()) {
+				blocks = blocks[:i+1]
+				break
+			}
+		}
+		verify = func(blockID types.BlockID, height int64, commit *types.Commit) error {
+			return vals.VerifyCommit(bcR.chainID, blockID, height, commit)
+		}
+	} else {
+		blocks = blocks[:2]
+	}
+
+	vbs := verifyBlocks(blocks, bcR.blockPartSize(), bcR.verifyWorkers, verify)
+	for i, vb := range vbs {
+		if vb.err != nil {
+			log.Error("error in validation", zap.Int64("height", vb.block.Height), zap.String("error", vb.err.Error()))
+			for _, peerID := range bcR.pool.RedoRequest(vb.block.Height) {
+				if peer := bcR.Switch.Peers().Get(peerID); peer != nil {
+					reason := fmt.Errorf("bad block at height %d: %v", vb.block.Height, vb.err)
+					if !bcR.Switch.PenalizePeer(peer, p2p.PenaltyBadBlock, reason) {
+						bcR.Switch.StopPeerForError(peer, reason)
+					}
+				}
+			}
+			return i, false
+		}
+		bcR.pool.PopRequest()
+		if err := bcR.blockExecuter(vb.block, vb.parts, vb.commit); err != nil {
+			// TODO This is bad, are we zombie?
+			gcmn.PanicQ(gcmn.Fmt("Failed to process committed block (%d:%X): %v", vb.block.Height, vb.hash, err))
+		}
+	}
+	return len(vbs), true
+}
+
+// SyncInfo reports the fast sync progress
+func (bcR *BlockchainReactor) SyncInfo() *types.ResultSyncInfo {
+	info := bcR.pool.SyncInfo()
+	info.Syncing = atomic.LoadInt32(&bcR.syncing) == 1
+	info.Height = bcR.store.Height()
+	return info
+}
+
 func (bcR *BlockchainReactor) BroadcastStatusResponse() error {
 	bcR.Switch.Broadcast(BlockchainChannel, struct{ BlockchainMessage }{&bcStatusResponseMessage{bcR.store.Height()}})
 	return nil
@@ -49,6 +49,7 @@ type BlockStore struct {
 	mtx          sync.RWMutex
 	height       int64
 	originHeight int64
+	pruned       bool // blocks at or below originHeight were pruned, not archived
 }
 
 func NewBlockStore(db, archiveDB dbm.DB) *BlockStore {
@@ -56,6 +57,7 @@ func NewBlockStore(db, archiveDB dbm.DB) *BlockStore {
 	return &BlockStore{
 		height:       bsjson.Height,
 		originHeight: bsjson.OriginHeight,
+		pruned:       bsjson.Pruned,
 		db:           db,
 		archiveDB:    archiveDB,
 	}
@@ -69,11 +71,23 @@ func (bs *BlockStore) Height() int64 {
 }
 
 func (bs *BlockStore) OriginHeight() int64 {
+	bs.mtx.RLock()
+	defer bs.mtx.RUnlock()
 	return bs.originHeight
 }
 
 func (bs *BlockStore) SetOriginHeight(height int64) {
+	bs.mtx.Lock()
 	bs.originHeight = height
+	bs.mtx.Unlock()
+}
+
+// Pruned reports whether the blocks at or below OriginHeight were removed by
+// PruneBlocks rather than moved to the archive.
+func (bs *BlockStore) Pruned() bool {
+	bs.mtx.RLock()
+	defer bs.mtx.RUnlock()
+	return bs.pruned
 }
 
 func (bs *BlockStore) GetReader(key []byte) io.Reader {
@@ -106,6 +120,10 @@ func (bs *BlockStore) LoadBlock(height int64) *types.Block {
 	bytez := []byte{}
 	for i := 0; i < meta.PartsHeader.Total; i++ {
 		part := bs.LoadBlockPart(height, i)
+		if part == nil {
+			// pruned
+			return nil
+		}
 		bytez = append(bytez, part.Bytes...)
 	}
 	block := wire.ReadBinary(&types.Block{}, bytes.NewReader(bytez), 0, &n, &err).(*types.Block)
@@ -208,10 +226,8 @@ func (bs *BlockStore) SaveBlock(block *types.Block, blockParts *types.PartSet, s
 	bs.db.Set(calcSeenCommitKey(height), seenCommitBytes)
 
 	// Save new BlockStoreStateJSON descriptor
-	BlockStoreStateJSON{Height: height, OriginHeight: bs.originHeight}.Save(bs.db)
-
-	// Done!
 	bs.mtx.Lock()
+	BlockStoreStateJSON{Height: height, OriginHeight: bs.originHeight, Pruned: bs.pruned}.Save(bs.db)
 	bs.height = height
 	bs.mtx.Unlock()
 
@@ -285,6 +301,34 @@ func (bs *BlockStore) DeleteBlock(height int64) (err error) {
 	return
 }
 
+// PruneBlocks deletes the block parts and seen commits from OriginHeight+1
+// through height and moves OriginHeight up to it. Metas and block commits
+// are kept so headers stay queryable.
+func (bs *BlockStore) PruneBlocks(height int64) error {
+	if height >= bs.Height() {
+		return fmt.Errorf("cannot prune up to height %d, the store is at %d", height, bs.Height())
+	}
+	batch := bs.db.NewBatch()
+	for h := bs.OriginHeight() + 1; h <= height; h++ {
+		meta := bs.LoadBlockMeta(h)
+		if meta == nil {
+			continue
+		}
+		for i := 0; i < meta.PartsHeader.Total; i++ {
+			batch.Delete(calcBlockPartKey(h, i))
+		}
+		batch.Delete(calcSeenCommitKey(h))
+	}
+	batch.Write()
+
+	bs.mtx.Lock()
+	bs.originHeight = height
+	bs.pruned = true
+	BlockStoreStateJSON{Height: bs.height, OriginHeight: height, Pruned: true}.Save(bs.db)
+	bs.mtx.Unlock()
+	return nil
+}
+
 func (bs *BlockStore) saveBlockPart(height int64, index int, part *types.Part) {
 	if height != bs.Height()+1 {
 		gcmn.PanicSanity(gcmn.Fmt("BlockStore can only save contiguous blocks. Wanted %v, got %v", bs.Height()+1, height))
@@ -299,7 +343,7 @@ func (bs *BlockStore) savePartToArchive(height int64, index int, part *types.Par
 }
 
 func (bs *BlockStore) RevertToHeight(height int64) {
-	BlockStoreStateJSON{Height: height, OriginHeight: bs.originHeight}.Save(bs.db)
+	BlockStoreStateJSON{Height: height, OriginHeight: bs.originHeight, Pruned: bs.pruned}.Save(bs.db)
 }
 
 //-----------------------------------------------------------------------------
@@ -327,6 +371,7 @@ var blockStoreKey = []byte("blockStore")
 type BlockStoreStateJSON struct {
 	Height       int64
 	OriginHeight int64
+	Pruned       bool `json:",omitempty"`
 }
 
 func (bsj BlockStoreStateJSON) Save(db dbm.DB) {
@@ -0,0 +1,74 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blockchain
+
+import (
+	"testing"
+
+	dbm "github.com/dappledger/AnnChain/gemmill/modules/go-db"
+	"github.com/dappledger/AnnChain/gemmill/types"
+)
+
+func saveTestBlocks(bs *BlockStore, n int64) {
+	var lastID types.BlockID
+	for h := int64(1); h <= n; h++ {
+		block := &types.Block{
+			Header: &types.Header{
+				ChainID:        "store-test",
+				Height:         h,
+				LastBlockID:    lastID,
+				ValidatorsHash: []byte{0x01},
+			},
+			Data:       &types.Data{},
+			LastCommit: &types.Commit{},
+		}
+		parts := block.MakePartSet(1024)
+		lastID = types.BlockID{Hash: block.Hash(), PartsHeader: parts.Header()}
+		bs.SaveBlock(block, parts, &types.Commit{BlockID: lastID})
+	}
+}
+
+func TestPruneBlocks(t *testing.T) {
+	db := dbm.NewMemDB()
+	bs := NewBlockStore(db, dbm.NewMemDB())
+	saveTestBlocks(bs, 10)
+
+	if err := bs.PruneBlocks(10); err == nil {
+		t.Fatal("pruning the latest block should fail")
+	}
+	if err := bs.PruneBlocks(6); err != nil {
+		t.Fatal(err)
+	}
+	if !bs.Pruned() || bs.OriginHeight() != 6 {
+		t.Fatalf("pruned %v origin %d, want true 6", bs.Pruned(), bs.OriginHeight())
+	}
+	for h := int64(1); h <= 10; h++ {
+		if bs.LoadBlockMeta(h) == nil {
+			t.Fatalf("meta %d should be kept", h)
+		}
+		block, seen := bs.LoadBlock(h), bs.LoadSeenCommit(h)
+		if h <= 6 && (block != nil || seen != nil) {
+			t.Fatalf("block %d should be pruned", h)
+		}
+		if h > 6 && (block == nil || seen == nil) {
+			t.Fatalf("block %d should be kept", h)
+		}
+	}
+
+	reopened := NewBlockStore(db, nil)
+	if !reopened.Pruned() || reopened.OriginHeight() != 6 || reopened.Height() != 10 {
+		t.Fatalf("state not persisted: pruned %v origin %d height %d", reopened.Pruned(), reopened.OriginHeight(), reopened.Height())
+	}
+}
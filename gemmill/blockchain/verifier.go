@@ -0,0 +1,78 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blockchain
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/dappledger/AnnChain/gemmill/types"
+)
+
+// verifiedBlock is a block checked against the commit of the block above it
+type verifiedBlock struct {
+	block  *types.Block
+	commit *types.Commit
+	hash   []byte
+	parts  *types.PartSet
+	err    error
+}
+
+// verifyBlocks checks blocks[i] against blocks[i+1].LastCommit for every i,
+// spread over workers goroutines. The memoized hashes of the blocks are
+// filled beforehand so the workers only read them.
+func verifyBlocks(blocks []*types.Block, partSize, workers int, verify func(types.BlockID, int64, *types.Commit) error) []*verifiedBlock {
+	if len(blocks) < 2 {
+		return nil
+	}
+	vbs := make([]*verifiedBlock, len(blocks)-1)
+	for i := range vbs {
+		vb := &verifiedBlock{
+			block:  blocks[i],
+			commit: blocks[i+1].LastCommit,
+			hash:   blocks[i].Hash(),
+		}
+		if vb.commit == nil || len(vb.commit.Precommits) == 0 {
+			vb.err = errors.New("missing commit")
+		} else {
+			vb.commit.FirstPrecommit()
+		}
+		vbs[i] = vb
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+	jobs := make(chan *verifiedBlock)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(vbs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for vb := range jobs {
+				vb.parts = vb.block.MakePartSet(partSize)
+				vb.err = verify(types.BlockID{Hash: vb.hash, PartsHeader: vb.parts.Header()}, vb.block.Height, vb.commit)
+			}
+		}()
+	}
+	for _, vb := range vbs {
+		if vb.err == nil {
+			jobs <- vb
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return vbs
+}
@@ -0,0 +1,91 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dappledger/AnnChain/gemmill/types"
+)
+
+const testChainID = "test_chain_id"
+
+// makeChain makes n blocks, each committed by all of vals in the next one
+func makeChain(t *testing.T, n int, vals *types.ValidatorSet, privVals []*types.PrivValidator) []*types.Block {
+	privByAddr := make(map[string]*types.PrivValidator, len(privVals))
+	for _, pv := range privVals {
+		privByAddr[string(pv.GetAddress())] = pv
+	}
+
+	blocks := make([]*types.Block, 0, n)
+	lastCommit := &types.Commit{}
+	lastBlockID := types.BlockID{}
+	for h := int64(1); h <= int64(n); h++ {
+		block, parts := types.MakeBlock(h, testChainID, nil, nil, lastCommit, time.Now(), vals.Validators[0].Address,
+			lastBlockID, vals.Hash(), nil, nil, 65536)
+		blocks = append(blocks, block)
+		lastBlockID = types.BlockID{Hash: block.Hash(), PartsHeader: parts.Header()}
+
+		voteSet := types.NewVoteSet(testChainID, h, 0, types.VoteTypePrecommit, vals)
+		for i, val := range vals.Validators {
+			vote := &types.Vote{
+				ValidatorAddress: val.Address,
+				ValidatorIndex:   i,
+				Height:           h,
+				Type:             types.VoteTypePrecommit,
+				BlockID:          lastBlockID,
+			}
+			vote.Signature = privByAddr[string(val.Address)].GetPrivKey().Sign(types.SignBytes(testChainID, vote))
+			if _, err := voteSet.AddVote(vote); err != nil {
+				t.Fatal(err)
+			}
+		}
+		lastCommit = voteSet.MakeCommit()
+	}
+	return blocks
+}
+
+func TestVerifyBlocks(t *testing.T) {
+	vals, privVals := types.RandValidatorSet(4, 1)
+	blocks := makeChain(t, 20, vals, privVals)
+	verify := func(blockID types.BlockID, height int64, commit *types.Commit) error {
+		return vals.VerifyCommit(testChainID, blockID, height, commit)
+	}
+
+	vbs := verifyBlocks(blocks, 65536, 4, verify)
+	if len(vbs) != len(blocks)-1 {
+		t.Fatalf("expected %d verified blocks, got %d", len(blocks)-1, len(vbs))
+	}
+	for i, vb := range vbs {
+		if vb.err != nil {
+			t.Fatalf("block %d: %v", vb.block.Height, vb.err)
+		}
+		if vb.block != blocks[i] || vb.commit != blocks[i+1].LastCommit || vb.parts == nil {
+			t.Fatalf("block %d: unexpected result %+v", vb.block.Height, vb)
+		}
+	}
+
+	// a forged signature fails the block its commit is for, and the block
+	// carrying the commit whose parts no longer match what got signed
+	blocks = makeChain(t, 10, vals, privVals)
+	forged := blocks[6].LastCommit.Precommits[0]
+	forged.Signature = privVals[0].GetPrivKey().Sign([]byte("forged"))
+	for i, vb := range verifyBlocks(blocks, 65536, 3, verify) {
+		if failed := vb.err != nil; failed != (i == 5 || i == 6) {
+			t.Fatalf("block %d: unexpected verification result %v", vb.block.Height, vb.err)
+		}
+	}
+}
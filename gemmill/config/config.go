@@ -194,6 +194,23 @@ func SetDefaults(runtime string, conf *viper.Viper) *viper.Viper {
 	conf.SetDefault("db_archive_dir", path.Join(runtime, ARCHIVEDIR))
 	conf.SetDefault("revision_file", path.Join(runtime, "revision"))
 	conf.SetDefault("filter_peers", false)
+	conf.SetDefault("retain_blocks", 0)
+	conf.SetDefault("fast_sync_verify_workers", 0) // 0 for the number of CPUs
+	conf.SetDefault("archive_backend", "")
+	conf.SetDefault("archive_local_dir", "")
+	conf.SetDefault("archive_s3_region", "us-east-1")
+	conf.SetDefault("uptime_enabled", false)
+	conf.SetDefault("uptime_window", 1000)
+	conf.SetDefault("uptime_threshold", 90) // percent
+	conf.SetDefault("p2p_rolling_upgrade", false)
+	conf.SetDefault("persistent_peers", "")
+	conf.SetDefault("unconditional_peers", "")
+	conf.SetDefault("private_peer_ids", "")
+	conf.SetDefault("peer_ban_threshold", 0)
+	conf.SetDefault("peer_ban_minutes", 60)
+	conf.SetDefault("peer_flood_rate", 0) // msgs per second, 0 for no limit
+	conf.SetDefault("genesis_hash", "")
+	conf.SetDefault("genesis_quorum", 2)
 
 	setMempoolDefaults(conf)
 	setConsensusDefaults(conf)
@@ -223,6 +240,8 @@ func setConsensusDefaults(conf *viper.Viper) {
 	conf.SetDefault("timeout_precommit_delta", 500)
 	conf.SetDefault("timeout_commit", 1000)
 	conf.SetDefault("skip_timeout_commit", false)
+	conf.SetDefault("create_empty_blocks", true)
+	conf.SetDefault("create_empty_blocks_interval", 0) // milliseconds
 
 	conf.SetDefault("tracerouter_msg_ttl", 5) // seconds
 }
@@ -257,6 +276,7 @@ func DefaultConfig() (conf *viper.Viper) {
 	conf.Set("non_validator_node_auth", false)
 	conf.SetDefault("tracerouter_msg_ttl", 5)
 	conf.Set("threshold_blocks", 0)
+	conf.Set("archive_backend", "")
 	conf.SetDefault("block_size", 5000)
 
 	return conf
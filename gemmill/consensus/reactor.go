@@ -61,6 +61,15 @@ func NewConsensusReactor(consensusState *ConsensusState, fastSync bool) *Consens
 	return conR
 }
 
+func (conR *ConsensusReactor) penalizePeer(peerKey string, penalty int, reason error) {
+	if conR.Switch == nil {
+		return
+	}
+	if peer := conR.Switch.Peers().Get(peerKey); peer != nil {
+		conR.Switch.PenalizePeer(peer, penalty, reason)
+	}
+}
+
 func (conR *ConsensusReactor) OnStart() error {
 	log.Info("ConsensusReactor ", zap.Bool("fastSync", conR.fastSync))
 	conR.BaseReactor.OnStart()
@@ -116,6 +125,11 @@ func (conR *ConsensusReactor) SwitchToConsensus(state *sm.State) {
 	cs.Start()
 }
 
+// Implements p2p.VersionedReactor
+func (conR *ConsensusReactor) ProtocolVersion() string {
+	return ProtocolVersion
+}
+
 // Implements Reactor
 func (conR *ConsensusReactor) GetChannels() []*p2p.ChannelDescriptor {
 	// TODO optimize
@@ -192,7 +206,7 @@ func (conR *ConsensusReactor) Receive(chID byte, src *p2p.Peer, msgBytes []byte)
 	_, msg, err := DecodeMessage(msgBytes)
 	if err != nil {
 		log.Warnw("Error decoding message", "src", src, "chId", chID, "msg", msg, "error", err, "bytes", msgBytes)
-		// TODO punish peer?
+		conR.Switch.PenalizePeer(src, p2p.PenaltyMalformedMsg, err)
 		return
 	}
 	//log.Debugw("Receive", "src", src, "chId", chID, "msg", msg)
@@ -838,7 +852,7 @@ func (ps *PeerState) SetHasProposalBlockPart(height int64, round int64, index in
 // Returns true if vote was sent.
 func (ps *PeerState) PickSendVote(votes types.VoteSetReader) (ok bool) {
 	if vote, ok := ps.PickVoteToSend(votes); ok {
-		msg := &VoteMessage{vote}
+		msg := NewVoteMessage(vote)
 		ps.Peer.Send(VoteChannel, struct{ ConsensusMessage }{msg})
 		return true
 	}
@@ -1217,6 +1231,12 @@ func (m *BlockPartMessage) String() string {
 
 type VoteMessage struct {
 	Vote *types.Vote
+	// Timestamp carries Vote.Timestamp, which the vote encoding leaves out
+	Timestamp time.Time `json:",omitempty" wire:"optional"`
+}
+
+func NewVoteMessage(vote *types.Vote) *VoteMessage {
+	return &VoteMessage{Vote: vote, Timestamp: vote.Timestamp}
 }
 
 func (m *VoteMessage) String() string {
@@ -21,6 +21,7 @@ import (
 	"io"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
@@ -28,6 +29,7 @@ import (
 	"github.com/dappledger/AnnChain/gemmill/go-wire"
 	gcmn "github.com/dappledger/AnnChain/gemmill/modules/go-common"
 	"github.com/dappledger/AnnChain/gemmill/modules/go-log"
+	"github.com/dappledger/AnnChain/gemmill/p2p"
 	sm "github.com/dappledger/AnnChain/gemmill/state"
 	"github.com/dappledger/AnnChain/gemmill/types"
 
@@ -49,6 +51,9 @@ type TimeoutParams struct {
 	PrecommitDelta    int64
 	Commit0           int64
 	SkipTimeoutCommit bool
+
+	CreateEmptyBlocks         bool
+	CreateEmptyBlocksInterval int64
 }
 
 // Wait this long for a proposal
@@ -71,6 +76,16 @@ func (tp *TimeoutParams) Commit(t time.Time) time.Time {
 	return t.Add(time.Duration(tp.Commit0) * time.Millisecond)
 }
 
+// WaitForTxs reports whether round 0 should wait for txs before proposing
+func (tp *TimeoutParams) WaitForTxs() bool {
+	return !tp.CreateEmptyBlocks || tp.CreateEmptyBlocksInterval > 0
+}
+
+// Without txs, propose an empty block after this long anyway (0 waits forever)
+func (tp *TimeoutParams) EmptyBlocksInterval() time.Duration {
+	return time.Duration(tp.CreateEmptyBlocksInterval) * time.Millisecond
+}
+
 // InitTimeoutParamsFromConfig initializes parameters from config
 func InitTimeoutParamsFromConfig(conf *viper.Viper) *TimeoutParams {
 	return &TimeoutParams{
@@ -82,9 +97,26 @@ func InitTimeoutParamsFromConfig(conf *viper.Viper) *TimeoutParams {
 		PrecommitDelta:    int64(conf.GetInt("timeout_precommit_delta")),
 		Commit0:           int64(conf.GetInt("timeout_commit")),
 		SkipTimeoutCommit: conf.GetBool("skip_timeout_commit"),
+
+		CreateEmptyBlocks:         conf.GetBool("create_empty_blocks"),
+		CreateEmptyBlocksInterval: int64(conf.GetInt("create_empty_blocks_interval")),
 	}
 }
 
+// InitTimeoutParams initializes timeouts from the consensus params in effect,
+// the rest comes from config
+func InitTimeoutParams(params *types.ConsensusParams, conf *viper.Viper) *TimeoutParams {
+	tp := InitTimeoutParamsFromConfig(conf)
+	tp.Propose0 = params.TimeoutPropose
+	tp.ProposeDelta = params.TimeoutProposeDelta
+	tp.Prevote0 = params.TimeoutPrevote
+	tp.PrevoteDelta = params.TimeoutPrevoteDelta
+	tp.Precommit0 = params.TimeoutPrecommit
+	tp.PrecommitDelta = params.TimeoutPrecommitDelta
+	tp.Commit0 = params.TimeoutCommit
+	return tp
+}
+
 //-----------------------------------------------------------------------------
 // Errors
 
@@ -254,10 +286,13 @@ type ConsensusState struct {
 	RoundState
 	state *sm.State // State until height-1.
 
-	peerMsgQueue     chan msgInfo   // serializes msgs affecting state (proposals, block parts, votes)
-	internalMsgQueue chan msgInfo   // like peerMsgQueue but for our own proposals, parts, votes
-	timeoutTicker    TimeoutTicker  // ticker for timeouts
-	timeoutParams    *TimeoutParams // parameters and functions for timeout intervals
+	peerMsgQueue     chan msgInfo    // serializes msgs affecting state (proposals, block parts, votes)
+	internalMsgQueue chan msgInfo    // like peerMsgQueue but for our own proposals, parts, votes
+	timeoutTicker    TimeoutTicker   // ticker for timeouts
+	timeoutParams    *TimeoutParams  // parameters and functions for timeout intervals
+	txsAvailable     <-chan struct{} // fires when the mempool gets txs, see waitForTxs
+	txsFrontNotify   chan struct{}   // fed by the TxsFrontWait goroutine if the pool is no TxsNotifier
+	txsWaiting       int32           // set while the TxsFrontWait goroutine is running
 
 	evsw types.EventSwitch
 
@@ -288,6 +323,13 @@ func NewConsensusState(conf *viper.Viper, state *sm.State, blockStore *bc.BlockS
 		done:             make(chan struct{}),
 	}
 
+	if notifier, ok := pool.(types.TxsNotifier); ok {
+		cs.txsAvailable = notifier.TxsAvailable()
+	} else {
+		cs.txsFrontNotify = make(chan struct{}, 1)
+		cs.txsAvailable = cs.txsFrontNotify
+	}
+
 	// set function defaults (may be overwritten before calling Start)
 	cs.decideProposal = cs.defaultDecideProposal
 	cs.doPrevote = cs.defaultDoPrevote
@@ -470,9 +512,9 @@ func (cs *ConsensusState) OpenWAL(walDir string) (err error) {
 // May block on send if queue is full.
 func (cs *ConsensusState) AddVote(vote *types.Vote, peerKey string) (added bool, err error) {
 	if peerKey == "" {
-		cs.internalMsgQueue <- msgInfo{&VoteMessage{vote}, ""}
+		cs.internalMsgQueue <- msgInfo{NewVoteMessage(vote), ""}
 	} else {
-		cs.peerMsgQueue <- msgInfo{&VoteMessage{vote}, peerKey}
+		cs.peerMsgQueue <- msgInfo{NewVoteMessage(vote), peerKey}
 	}
 
 	// TODO: wait for event?!
@@ -560,7 +602,8 @@ func (cs *ConsensusState) reconstructLastCommit(state *sm.State) {
 	}
 	seenCommit := cs.blockStore.LoadSeenCommit(state.LastBlockHeight)
 	lastPrecommits := types.NewVoteSet(cs.config.GetString("chain_id"), state.LastBlockHeight, seenCommit.Round(), types.VoteTypePrecommit, state.LastValidators)
-	for _, precommit := range seenCommit.Precommits {
+	for i := range seenCommit.Precommits {
+		precommit := seenCommit.GetByIndex(i)
 		if precommit == nil {
 			continue
 		}
@@ -607,6 +650,9 @@ func (cs *ConsensusState) updateToState(state *sm.State) {
 		lastPrecommits = cs.Votes.Precommits(cs.CommitRound)
 	}
 
+	// consensus params may have changed with the last block
+	cs.timeoutParams = InitTimeoutParams(state.GetConsensusParams(cs.config), cs.config)
+
 	// RoundState fields
 	cs.updateHeight(height)
 	cs.updateRoundStep(0, RoundStepNewHeight)
@@ -688,6 +734,8 @@ func (cs *ConsensusState) receiveRoutine(maxSteps int) {
 			// if the timeout is relevant to the rs
 			// go to the next step
 			cs.handleTimeout(ti, rs)
+		case <-cs.txsAvailable:
+			cs.handleTxsAvailable()
 		case <-cs.Quit:
 
 			// NOTE: the internalMsgQueue may have signed messages from our
@@ -720,15 +768,19 @@ func (cs *ConsensusState) handleMsg(mi msgInfo, rs RoundState) {
 	case *BlockPartMessage:
 		// if the proposal is complete, we'll enterPrevote or tryFinalizeCommit
 		_, err = cs.addProposalBlockPart(msg.Height, msg.Part, peerKey != "")
+		if err == types.ErrPartSetInvalidProof {
+			cs.penalizePeer(peerKey, p2p.PenaltyBadBlockPart, err)
+		}
 		if err != nil && msg.Round != cs.Round {
 			err = nil
 		}
 	case *VoteMessage:
 		// attempt to add the vote and dupeout the validator if its a duplicate signature
 		// if the vote gives us a 2/3-any or 2/3-one, we transition
+		msg.Vote.Timestamp = msg.Timestamp
 		_, err := cs.tryAddVote(msg.Vote, peerKey)
 		if err == ErrAddingVote {
-			// TODO: punish peer
+			cs.penalizePeer(peerKey, p2p.PenaltyBadVote, err)
 		}
 
 		// NOTE: the vote is broadcast to peers by the reactor listening
@@ -745,6 +797,15 @@ func (cs *ConsensusState) handleMsg(mi msgInfo, rs RoundState) {
 	}
 }
 
+// penalizePeer lowers the score of the peer a bad msg came from, off the
+// state lock as a banned peer gets removed from the reactors
+func (cs *ConsensusState) penalizePeer(peerKey string, penalty int, reason error) {
+	if cs.conR == nil || peerKey == "" {
+		return
+	}
+	go cs.conR.penalizePeer(peerKey, penalty, reason)
+}
+
 func (cs *ConsensusState) handleTimeout(ti timeoutInfo, rs RoundState) {
 	log.Debugw("Received tock", "timeout", ti.Duration, "height", ti.Height, "round", ti.Round, "step", ti.Step)
 
@@ -763,6 +824,9 @@ func (cs *ConsensusState) handleTimeout(ti timeoutInfo, rs RoundState) {
 		// NewRound event fired from enterNewRound.
 		// XXX: should we fire timeout here (for timeout commit)?
 		cs.enterNewRound(ti.Height, 0)
+	case RoundStepNewRound:
+		// no txs showed up within create_empty_blocks_interval
+		cs.enterPropose(ti.Height, 0)
 	case RoundStepPropose:
 		types.FireEventTimeoutPropose(cs.evsw, cs.RoundStateEvent())
 		cs.enterPrevote(ti.Height, ti.Round)
@@ -778,6 +842,52 @@ func (cs *ConsensusState) handleTimeout(ti timeoutInfo, rs RoundState) {
 
 }
 
+func (cs *ConsensusState) handleTxsAvailable() {
+	cs.mtx.Lock()
+	defer cs.mtx.Unlock()
+
+	// only round 0 waits for txs, and the signal may be stale
+	if cs.Round != 0 || cs.Step != RoundStepNewRound || !cs.hasTxs() {
+		return
+	}
+	cs.enterPropose(cs.Height, 0)
+}
+
+// hasTxs reports whether the mempool has txs that can go into the next block
+func (cs *ConsensusState) hasTxs() bool {
+	if counter, ok := cs.mempool.(types.ExecutableCounter); ok {
+		return counter.ExecutableSize() > 0
+	}
+	return cs.mempool.Size() > 0
+}
+
+// waitForTxs makes sure handleTxsAvailable gets called once the mempool has txs.
+// Pools implementing types.TxsNotifier signal on their own.
+func (cs *ConsensusState) waitForTxs() {
+	if cs.txsFrontNotify == nil || !atomic.CompareAndSwapInt32(&cs.txsWaiting, 0, 1) {
+		return
+	}
+	notify := cs.txsFrontNotify
+	go func() {
+		cs.mempool.TxsFrontWait()
+		atomic.StoreInt32(&cs.txsWaiting, 0)
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	}()
+}
+
+// needProofBlock reports whether the block at height is needed to commit
+// the app hash of the previous one, so it must be proposed even without txs.
+func (cs *ConsensusState) needProofBlock(height int64) bool {
+	if height == 1 {
+		return true
+	}
+	lastBlockMeta := cs.blockStore.LoadBlockMeta(height - 1)
+	return lastBlockMeta == nil || !bytes.Equal(cs.state.AppHash, lastBlockMeta.Header.AppHash)
+}
+
 //-----------------------------------------------------------------------------
 // State functions
 // Used internally by handleTimeout and handleMsg to make state transitions
@@ -829,6 +939,16 @@ func (cs *ConsensusState) enterNewRound(height int64, round int64) {
 	types.FireEventHookNewRound(cs.evsw, ed)
 	<-ed.ResCh
 
+	// Wait for txs before proposing at round 0 unless create_empty_blocks is on,
+	// the previous app hash still needs a block, or the mempool is non-empty.
+	if round == 0 && cs.timeoutParams.WaitForTxs() && !cs.needProofBlock(height) && !cs.hasTxs() {
+		if interval := cs.timeoutParams.EmptyBlocksInterval(); interval > 0 {
+			cs.scheduleTimeout(interval, height, round, RoundStepNewRound)
+		}
+		cs.waitForTxs()
+		return
+	}
+
 	// Immediately go to enterPropose.
 	cs.enterPropose(height, round)
 }
@@ -862,14 +982,20 @@ func (cs *ConsensusState) enterPropose(height int64, round int64) {
 		return
 	}
 
-	if !bytes.Equal(cs.Validators.Proposer().Address, cs.privValidator.GetAddress()) {
-		log.Infow("enterPropose: Not our turn to propose", "proposer", fmt.Sprintf("%X", cs.Validators.Proposer().Address), "privValidator", cs.privValidator)
+	if proposer := cs.proposer(); !bytes.Equal(proposer.Address, cs.privValidator.GetAddress()) {
+		log.Infow("enterPropose: Not our turn to propose", "proposer", fmt.Sprintf("%X", proposer.Address), "privValidator", cs.privValidator)
 	} else {
-		log.Infow("enterPropose: Our turn to propose", "proposer", fmt.Sprintf("%X", cs.Validators.Proposer().Address), "privValidator", cs.privValidator)
+		log.Infow("enterPropose: Our turn to propose", "proposer", fmt.Sprintf("%X", proposer.Address), "privValidator", cs.privValidator)
 		cs.decideProposal(height, round)
 	}
 }
 
+// proposer of the current round, validators that kept missing their
+// proposer slots are skipped
+func (cs *ConsensusState) proposer() *types.Validator {
+	return cs.state.ProposerStats.Proposer(cs.Validators, cs.Height, cs.state.ConsensusParams)
+}
+
 func (cs *ConsensusState) defaultDecideProposal(height, round int64) {
 	var block *types.Block
 	var blockParts *types.PartSet
@@ -948,7 +1074,8 @@ func (cs *ConsensusState) createProposalBlock() (block *types.Block, blockParts
 	}
 
 	// Mempool validated transactions
-	alltxs := cs.mempool.Reap(cs.config.GetInt("block_size"))
+	params := cs.state.GetConsensusParams(cs.config)
+	alltxs := cs.mempool.Reap(int(params.BlockSize))
 	extxs := []types.Tx{}
 	txs := []types.Tx{}
 	for _, tx := range alltxs {
@@ -958,8 +1085,12 @@ func (cs *ConsensusState) createProposalBlock() (block *types.Block, blockParts
 			txs = append(txs, tx)
 		}
 	}
-	return types.MakeBlock(cs.Height, cs.state.ChainID, txs, extxs, commit, cs.privValidator.GetAddress(),
-		cs.state.LastBlockID, cs.state.Validators.Hash(), cs.state.AppHash, cs.state.ReceiptsHash, cs.config.GetInt("block_part_size"))
+	blockTime := time.Now()
+	if cs.Height > 1 && cs.state.ConsensusParams.BFTTime(cs.Height) {
+		blockTime = commit.MedianTime(cs.state.LastValidators)
+	}
+	return types.MakeBlock(cs.Height, cs.state.ChainID, txs, extxs, commit, blockTime, cs.privValidator.GetAddress(),
+		cs.state.LastBlockID, cs.state.Validators.Hash(), cs.state.AppHash, cs.state.ReceiptsHash, int(params.BlockPartSize))
 }
 
 // Enter: `timeoutPropose` after entering Propose.
@@ -1361,7 +1492,7 @@ func (cs *ConsensusState) defaultSetProposal(proposal *types.Proposal) error {
 	}
 
 	// Verify signature
-	if !cs.Validators.Proposer().PubKey.VerifyBytes(types.SignBytes(cs.state.ChainID, proposal), proposal.Signature) {
+	if !cs.proposer().PubKey.VerifyBytes(types.SignBytes(cs.state.ChainID, proposal), proposal.Signature) {
 		return ErrInvalidProposalSignature
 	}
 
@@ -1394,7 +1525,7 @@ func (cs *ConsensusState) addProposalBlockPart(height int64, part *types.Part, v
 		cs.ProposalBlock = wire.ReadBinary(&types.Block{}, cs.ProposalBlockParts.GetReader(), types.MaxBlockSize, &n, &err).(*types.Block)
 		// NOTE: it's possible to receive complete proposal blocks for future rounds without having the proposal
 		//log.Debug("Received complete proposal block", zap.Int64("height", cs.ProposalBlock.Height), zap.String("hash", gcmn.Fmt("%X", cs.ProposalBlock.Hash())))
-		if cs.Step == RoundStepPropose && cs.isProposalComplete() {
+		if (cs.Step == RoundStepNewRound || cs.Step == RoundStepPropose) && cs.isProposalComplete() {
 			// Move onto the next step
 			cs.enterPrevote(height, cs.Round)
 		} else if cs.Step == RoundStepCommit {
@@ -1552,10 +1683,31 @@ func (cs *ConsensusState) signVote(type_ byte, hash []byte, header types.PartSet
 		Type:             type_,
 		BlockID:          types.BlockID{hash, header},
 	}
+	// the median time of the next block is taken from these
+	if cs.state.ConsensusParams.BFTTime(cs.Height + 1) {
+		vote.Timestamp = cs.voteTime()
+	}
 	err := cs.privValidator.SignVote(cs.state.ChainID, vote)
 	return vote, err
 }
 
+// voteTime is the local clock, but always after the time of the block voted
+// on so that the median time of the next block moves forward.
+func (cs *ConsensusState) voteTime() time.Time {
+	now := time.Now().Round(0).Truncate(time.Millisecond)
+	minTime := cs.state.LastBlockTime
+	if cs.LockedBlock != nil {
+		minTime = cs.LockedBlock.Time
+	} else if cs.ProposalBlock != nil {
+		minTime = cs.ProposalBlock.Time
+	}
+	minTime = minTime.Add(time.Millisecond)
+	if now.After(minTime) {
+		return now
+	}
+	return minTime
+}
+
 // sign the vote and publish on internalMsgQueue
 func (cs *ConsensusState) signAddVote(type_ byte, hash []byte, header types.PartSetHeader) *types.Vote {
 	// if we don't have a key or we're not in the validator set, do nothing
@@ -1564,7 +1716,7 @@ func (cs *ConsensusState) signAddVote(type_ byte, hash []byte, header types.Part
 	}
 	vote, err := cs.signVote(type_, hash, header)
 	if err == nil {
-		cs.sendInternalMessage(msgInfo{&VoteMessage{vote}, ""})
+		cs.sendInternalMessage(msgInfo{NewVoteMessage(vote), ""})
 		//log.Debugf("Signed and pushed vote height %d, round %d, vote %v, error %v", cs.Height, cs.Round, vote, err)
 		return vote
 	} else {
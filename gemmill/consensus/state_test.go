@@ -0,0 +1,239 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consensus
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+
+	bc "github.com/dappledger/AnnChain/gemmill/blockchain"
+	"github.com/dappledger/AnnChain/gemmill/modules/go-clist"
+	dbm "github.com/dappledger/AnnChain/gemmill/modules/go-db"
+	sm "github.com/dappledger/AnnChain/gemmill/state"
+	"github.com/dappledger/AnnChain/gemmill/types"
+)
+
+// testTxPool holds txs of which only executable can go into a block
+type testTxPool struct {
+	txs        []types.Tx
+	executable int
+	available  chan struct{}
+}
+
+func (tp *testTxPool) Lock()                               {}
+func (tp *testTxPool) Unlock()                             {}
+func (tp *testTxPool) Reap(count int) []types.Tx           { return tp.txs[:tp.executable] }
+func (tp *testTxPool) Update(height int64, txs []types.Tx) {}
+func (tp *testTxPool) Size() int                           { return len(tp.txs) }
+func (tp *testTxPool) ExecutableSize() int                 { return tp.executable }
+func (tp *testTxPool) TxsFrontWait() *clist.CElement       { return nil }
+func (tp *testTxPool) TxsAvailable() <-chan struct{}       { return tp.available }
+func (tp *testTxPool) Flush()                              {}
+func (tp *testTxPool) RegisterFilter(types.IFilter)        {}
+
+func (tp *testTxPool) ReceiveTx(tx types.Tx) error {
+	tp.txs = append(tp.txs, tx)
+	return nil
+}
+
+// testTicker records the timeouts instead of firing them
+type testTicker struct {
+	scheduled []timeoutInfo
+}
+
+func (tt *testTicker) Start() (bool, error)           { return true, nil }
+func (tt *testTicker) Stop() bool                     { return true }
+func (tt *testTicker) Chan() <-chan timeoutInfo       { return nil }
+func (tt *testTicker) ScheduleTimeout(ti timeoutInfo) { tt.scheduled = append(tt.scheduled, ti) }
+
+func (tt *testTicker) last() timeoutInfo {
+	if len(tt.scheduled) == 0 {
+		return timeoutInfo{}
+	}
+	return tt.scheduled[len(tt.scheduled)-1]
+}
+
+type testConsensus struct {
+	*ConsensusState
+	pool   *testTxPool
+	ticker *testTicker
+	privs  []*types.PrivValidator
+}
+
+// newTestConsensus returns a consensus state of a node out of the validator
+// set at height 2, block 1 committed by the validators left no app hash to
+// prove. The steps are driven by the test, nothing runs on its own.
+func newTestConsensus(t *testing.T, conf *viper.Viper) *testConsensus {
+	valSet, privs := types.RandValidatorSet(4, 10)
+	genDoc := &types.GenesisDoc{ChainID: "test-chain"}
+	for _, val := range valSet.Validators {
+		genDoc.Validators = append(genDoc.Validators, types.GenesisValidator{PubKey: val.PubKey, Amount: val.VotingPower})
+	}
+	state := sm.MakeGenesisState(dbm.NewMemDB(), genDoc)
+
+	block, parts := types.MakeBlock(1, genDoc.ChainID, nil, nil, &types.Commit{}, time.Now(), valSet.Proposer().Address,
+		types.BlockID{}, valSet.Hash(), nil, nil, 65536)
+	blockID := types.BlockID{Hash: block.Hash(), PartsHeader: parts.Header()}
+	voteSet := types.NewVoteSet(genDoc.ChainID, 1, 0, types.VoteTypePrecommit, valSet)
+	for _, priv := range privs {
+		idx, _ := valSet.GetByAddress(priv.Address)
+		vote := &types.Vote{ValidatorAddress: priv.Address, ValidatorIndex: idx, Height: 1, Round: 0, Type: types.VoteTypePrecommit, BlockID: blockID}
+		vote.Signature = priv.Sign(types.SignBytes(genDoc.ChainID, vote))
+		if _, err := voteSet.AddVote(vote); err != nil {
+			t.Fatal(err)
+		}
+	}
+	blockStore := bc.NewBlockStore(dbm.NewMemDB(), dbm.NewMemDB())
+	blockStore.SaveBlock(block, parts, voteSet.MakeCommit())
+	state.LastBlockHeight, state.LastBlockID, state.LastBlockTime = 1, blockID, block.Time
+	state.LastValidators = state.Validators.Copy()
+
+	walDir, err := ioutil.TempDir("", "cs_wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Set("chain_id", genDoc.ChainID)
+	conf.Set("cs_wal_dir", walDir)
+	conf.SetDefault("block_size", 5000)
+	conf.SetDefault("block_part_size", 65536)
+	conf.SetDefault("timeout_propose", 3000)
+	conf.SetDefault("timeout_prevote", 1000)
+	conf.SetDefault("timeout_precommit", 1000)
+	conf.SetDefault("timeout_commit", 1000)
+
+	pool := &testTxPool{available: make(chan struct{}, 1)}
+	cs := NewConsensusState(conf, state, blockStore, pool)
+	if cs == nil {
+		t.Fatal("consensus state not created")
+	}
+	ticker := &testTicker{}
+	cs.SetTimeoutTicker(ticker)
+
+	evsw := types.NewEventSwitch()
+	if _, err := evsw.Start(); err != nil {
+		t.Fatal(err)
+	}
+	types.AddListenerForEvent(evsw, "test", types.EventStringHookNewRound(), func(data types.TMEventData) {
+		data.(types.EventDataHookNewRound).ResCh <- types.NewRoundResult{}
+	})
+	cs.SetEventSwitch(evsw)
+	t.Cleanup(func() {
+		evsw.Stop()
+		cs.wal.Stop()
+		os.RemoveAll(walDir)
+	})
+	return &testConsensus{ConsensusState: cs, pool: pool, ticker: ticker, privs: privs}
+}
+
+func TestEmptyBlocksInterval(t *testing.T) {
+	conf := viper.New()
+	conf.Set("create_empty_blocks", true)
+	conf.Set("create_empty_blocks_interval", 500)
+	cs := newTestConsensus(t, conf)
+
+	cs.enterNewRound(2, 0)
+	if cs.Step != RoundStepNewRound {
+		t.Fatalf("expected to wait for txs in NewRound, got %v", cs.Step)
+	}
+	heartbeat := cs.ticker.last()
+	if heartbeat.Step != RoundStepNewRound || heartbeat.Height != 2 || heartbeat.Duration != 500*time.Millisecond {
+		t.Fatalf("expected the empty block timeout scheduled, got %v", heartbeat)
+	}
+
+	// no txs showed up, an empty block is proposed anyway
+	cs.handleTimeout(heartbeat, cs.RoundState)
+	if cs.Step != RoundStepPropose {
+		t.Fatalf("expected Propose after the empty blocks interval, got %v", cs.Step)
+	}
+}
+
+func TestWaitForTxs(t *testing.T) {
+	conf := viper.New()
+	conf.Set("create_empty_blocks", false)
+	cs := newTestConsensus(t, conf)
+
+	cs.enterNewRound(2, 0)
+	if cs.Step != RoundStepNewRound {
+		t.Fatalf("expected to wait for txs in NewRound, got %v", cs.Step)
+	}
+	for _, ti := range cs.ticker.scheduled {
+		if ti.Step == RoundStepNewRound {
+			t.Fatalf("expected no empty block timeout without an interval, got %v", ti)
+		}
+	}
+
+	// a tx waiting for a nonce gap to close doesn't make a block
+	cs.pool.ReceiveTx(types.Tx("waiting"))
+	cs.handleTxsAvailable()
+	if cs.Step != RoundStepNewRound {
+		t.Fatalf("expected to keep waiting with no executable tx, got %v", cs.Step)
+	}
+
+	cs.pool.executable = 1
+	cs.handleTxsAvailable()
+	if cs.Step != RoundStepPropose {
+		t.Fatalf("expected Propose once txs arrived, got %v", cs.Step)
+	}
+	// a stale signal is ignored
+	cs.handleTxsAvailable()
+	if cs.Step != RoundStepPropose {
+		t.Fatalf("expected to stay in Propose, got %v", cs.Step)
+	}
+}
+
+func TestProposalWhileWaitingForTxs(t *testing.T) {
+	conf := viper.New()
+	conf.Set("create_empty_blocks", false)
+	cs := newTestConsensus(t, conf)
+
+	cs.enterNewRound(2, 0)
+	if cs.Step != RoundStepNewRound {
+		t.Fatalf("expected to wait for txs in NewRound, got %v", cs.Step)
+	}
+
+	// the proposer had txs, its proposal is taken before any tx reaches us
+	proposer := cs.proposer()
+	var priv *types.PrivValidator
+	for _, p := range cs.privs {
+		if bytes.Equal(p.Address, proposer.Address) {
+			priv = p
+		}
+	}
+	state := cs.state
+	block, parts := types.MakeBlock(2, state.ChainID, []types.Tx{types.Tx("tx")}, nil, cs.LastCommit.MakeCommit(), time.Now(), proposer.Address,
+		state.LastBlockID, state.Validators.Hash(), state.AppHash, state.ReceiptsHash, 65536)
+	proposal := types.NewProposal(2, 0, parts.Header(), -1, types.BlockID{})
+	proposal.Signature = priv.Sign(types.SignBytes(state.ChainID, proposal))
+
+	if err := cs.setProposal(proposal); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < parts.Total(); i++ {
+		if _, err := cs.addProposalBlockPart(2, parts.GetPart(i), true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cs.ProposalBlock == nil || !bytes.Equal(cs.ProposalBlock.Hash(), block.Hash()) {
+		t.Fatal("expected the proposal block received")
+	}
+	if cs.Step != RoundStepPrevote {
+		t.Fatalf("expected Prevote on a complete proposal in NewRound, got %v", cs.Step)
+	}
+}
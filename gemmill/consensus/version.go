@@ -25,3 +25,6 @@ var Minor = "2"    // replay refactor
 var Revision = "2" // validation -> commit
 
 var Version = gcmn.Fmt("v%s/%s.%s.%s", Spec, Major, Minor, Revision)
+
+// ProtocolVersion is the version of the reactor messages peers have to agree on
+var ProtocolVersion = gcmn.Fmt("%s.%s.%s", Major, Minor, Revision)
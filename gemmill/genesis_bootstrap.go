@@ -0,0 +1,112 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gemmill
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/dappledger/AnnChain/gemmill/modules/go-log"
+	"github.com/dappledger/AnnChain/gemmill/types"
+)
+
+var ErrNoTrustedGenesis = errors.New("joining without genesis file needs the trusted genesis_hash")
+
+// genesisBootstrap collects the genesis peers offer to a node joining without
+// a genesis file. The one hashing to the trusted hash is accepted once quorum
+// distinct peers offered it.
+type genesisBootstrap struct {
+	mtx      sync.Mutex
+	chainID  string
+	trusted  []byte
+	quorum   int
+	peers    map[string]bool // keys of the peers which offered the trusted genesis
+	accepted bool
+}
+
+func newGenesisBootstrap(conf *viper.Viper) (*genesisBootstrap, error) {
+	trusted, err := hex.DecodeString(conf.GetString("genesis_hash"))
+	if err != nil {
+		return nil, errors.Wrap(err, "genesis_hash")
+	}
+	quorum := conf.GetInt("genesis_quorum")
+	if quorum < 1 {
+		quorum = 1
+	}
+	return &genesisBootstrap{
+		chainID: conf.GetString("chain_id"),
+		trusted: trusted,
+		quorum:  quorum,
+		peers:   make(map[string]bool),
+	}, nil
+}
+
+// offer checks the genesis in genesisJSON offered by peer. It returns the
+// genesis the one time the quorum is reached, nil while waiting for more peers
+// and an error for a genesis that can't be trusted.
+func (b *genesisBootstrap) offer(peer string, genesisJSON []byte) (*types.GenesisDoc, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if len(b.trusted) == 0 {
+		return nil, ErrNoTrustedGenesis
+	}
+	if len(genesisJSON) == 0 {
+		return nil, errors.New("peer has no genesis either")
+	}
+	genDoc, err := types.GenesisDocFromJSONRet(genesisJSON)
+	if err != nil {
+		return nil, errors.Wrap(err, "peer genesis")
+	}
+	if hash := genDoc.Hash(); !bytes.Equal(hash, b.trusted) {
+		return nil, fmt.Errorf("peer genesis hash %X is not the trusted %X", hash, b.trusted)
+	}
+	if b.chainID != "" && genDoc.ChainID != b.chainID {
+		return nil, fmt.Errorf("peer genesis is for chain %s, not %s", genDoc.ChainID, b.chainID)
+	}
+	if len(genDoc.Validators) == 0 {
+		return nil, errors.New("peer genesis has no validators")
+	}
+	if genDoc.ConsensusParams != nil {
+		if err := genDoc.ConsensusParams.ValidateBasic(); err != nil {
+			return nil, errors.Wrap(err, "peer genesis consensus_params")
+		}
+	}
+
+	if b.accepted {
+		return nil, nil
+	}
+	b.peers[peer] = true
+	log.Info("Peer offered the trusted genesis", zap.String("peer", peer), zap.Int("peers", len(b.peers)), zap.Int("quorum", b.quorum))
+	if len(b.peers) < b.quorum {
+		return nil, nil
+	}
+	b.accepted = true
+	return genDoc, nil
+}
+
+// reset lets the bootstrap accept a genesis again after building the state
+// from the accepted one failed
+func (b *genesisBootstrap) reset() {
+	b.mtx.Lock()
+	b.accepted = false
+	b.peers = make(map[string]bool)
+	b.mtx.Unlock()
+}
@@ -0,0 +1,92 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gemmill
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/dappledger/AnnChain/gemmill/go-crypto"
+	"github.com/dappledger/AnnChain/gemmill/types"
+)
+
+func testBootstrap(t *testing.T, genDoc *types.GenesisDoc, quorum int) *genesisBootstrap {
+	conf := viper.New()
+	conf.Set("chain_id", genDoc.ChainID)
+	conf.Set("genesis_quorum", quorum)
+	conf.Set("genesis_hash", hex.EncodeToString(genDoc.Hash()))
+	b, err := newGenesisBootstrap(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestGenesisBootstrapQuorum(t *testing.T) {
+	genDoc := &types.GenesisDoc{
+		ChainID:    "test-chain",
+		Validators: []types.GenesisValidator{{PubKey: crypto.GenPrivKeyEd25519().PubKey(), Amount: 10}},
+	}
+	fake := &types.GenesisDoc{
+		ChainID:    "test-chain",
+		Validators: []types.GenesisValidator{{PubKey: crypto.GenPrivKeyEd25519().PubKey(), Amount: 10}},
+	}
+	b := testBootstrap(t, genDoc, 2)
+
+	if _, err := b.offer("evil", fake.JSONBytes()); err == nil {
+		t.Fatal("expected a genesis of another hash to be refused")
+	}
+	if _, err := b.offer("empty", nil); err == nil {
+		t.Fatal("expected a peer without genesis to be refused")
+	}
+	for i := 0; i < 2; i++ {
+		// the same peer offering twice doesn't make a quorum
+		if got, err := b.offer("peer1", genDoc.JSONBytes()); err != nil || got != nil {
+			t.Fatalf("expected to wait for another peer, got %v, %v", got, err)
+		}
+	}
+	got, err := b.offer("peer2", genDoc.JSONBytes())
+	if err != nil || got == nil {
+		t.Fatalf("expected the genesis to be accepted, got %v", err)
+	}
+	if string(got.Hash()) != string(genDoc.Hash()) {
+		t.Fatal("accepted another genesis")
+	}
+	if got, _ := b.offer("peer3", genDoc.JSONBytes()); got != nil {
+		t.Fatal("expected the genesis to be accepted once")
+	}
+}
+
+func TestGenesisBootstrapNeedsTrustedHash(t *testing.T) {
+	genDoc := &types.GenesisDoc{
+		ChainID:    "test-chain",
+		Validators: []types.GenesisValidator{{PubKey: crypto.GenPrivKeyEd25519().PubKey(), Amount: 10}},
+	}
+	b, err := newGenesisBootstrap(viper.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.offer("peer", genDoc.JSONBytes()); err != ErrNoTrustedGenesis {
+		t.Fatalf("expected %v, got %v", ErrNoTrustedGenesis, err)
+	}
+
+	noValidators := &types.GenesisDoc{ChainID: "test-chain"}
+	b = testBootstrap(t, noValidators, 1)
+	if _, err := b.offer("peer", noValidators.JSONBytes()); err == nil {
+		t.Fatal("expected a genesis without validators to be refused")
+	}
+}
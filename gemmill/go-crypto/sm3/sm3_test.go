@@ -0,0 +1,55 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sm3
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// the examples of GB/T 32905-2016
+var golden = []struct {
+	in, out string
+}{
+	{"abc", "66c7f0f462eeedd9d1f2d46bdc10e4e24167c4875cf2f7a2297da02b8f4ba8e0"},
+	{strings.Repeat("abcd", 16), "debe9ff92275b8a138604889c18e5a4d6fdb70e5387e5765293dcba39c0c5732"},
+}
+
+func TestSum(t *testing.T) {
+	for _, g := range golden {
+		sum := Sum([]byte(g.in))
+		if out := hex.EncodeToString(sum[:]); out != g.out {
+			t.Fatalf("Sum(%q) = %s, want %s", g.in, out, g.out)
+		}
+
+		// written in pieces
+		h := New()
+		for i := 0; i < len(g.in); i += 3 {
+			end := i + 3
+			if end > len(g.in) {
+				end = len(g.in)
+			}
+			h.Write([]byte(g.in[i:end]))
+		}
+		if out := hex.EncodeToString(h.Sum(nil)); out != g.out {
+			t.Fatalf("New().Sum(%q) = %s, want %s", g.in, out, g.out)
+		}
+		// summing doesn't change the state
+		if out := hex.EncodeToString(h.Sum(nil)); out != g.out {
+			t.Fatalf("second Sum(%q) = %s, want %s", g.in, out, g.out)
+		}
+	}
+}
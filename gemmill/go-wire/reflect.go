@@ -45,6 +45,8 @@ type Options struct {
 	JSONOmitEmpty bool        // (JSON) Omit field if value is empty
 	Varint        bool        // (Binary) Use length-prefixed encoding for (u)int64
 	Unsafe        bool        // (JSON/Binary) Explicitly enable support for floats or maps
+	Optional      bool        // (Binary) Omit empty trailing field, read as empty at end of input
+	BinarySkip    bool        // (Binary) Field is JSON only, never binary encoded
 	ZeroValue     interface{} // Prototype zero object
 }
 
@@ -70,9 +72,18 @@ func getOptionsFromField(field reflect.StructField) (skip bool, opts Options) {
 	if binTag == "varint" { // TODO: extend
 		opts.Varint = true
 	}
+	if binTag == "-" {
+		opts.BinarySkip = true
+	}
 	if wireTag == "unsafe" {
 		opts.Unsafe = true
 	}
+	// NOTE: optional fields must be the last fields of a type that is always
+	// encoded last, otherwise reading them would consume whatever follows.
+	// They let fields be appended without changing the encoding of old values.
+	if wireTag == "optional" {
+		opts.Optional = true
+	}
 	opts.ZeroValue = reflect.Zero(field.Type).Interface()
 	return
 }
@@ -328,6 +339,17 @@ func readReflectBinary(rv reflect.Value, rt reflect.Type, opts Options, r io.Rea
 			for _, fieldInfo := range typeInfo.Fields {
 				fieldIdx, fieldType, opts := fieldInfo.unpack()
 				fieldRv := rv.Field(fieldIdx)
+				if opts.BinarySkip {
+					continue
+				}
+				if opts.Optional && *err == nil {
+					n_ := *n
+					readReflectBinary(fieldRv, fieldType, opts, r, lmt, n, err)
+					if *err == io.EOF && *n == n_ {
+						*err = nil
+					}
+					continue
+				}
 				readReflectBinary(fieldRv, fieldType, opts, r, lmt, n, err)
 			}
 		}
@@ -535,9 +557,21 @@ func writeReflectBinary(rv reflect.Value, rt reflect.Type, opts Options, w io.Wr
 			// Special case: time.Time
 			WriteTime(rv.Interface().(time.Time), w, n, err)
 		} else {
-			for _, fieldInfo := range typeInfo.Fields {
+			// empty optional fields are only omitted if no optional field
+			// after them is written
+			lastOptional := -1
+			for i, fieldInfo := range typeInfo.Fields {
+				fieldIdx, fieldType, opts := fieldInfo.unpack()
+				if opts.Optional && !isEmpty(fieldType, rv.Field(fieldIdx), opts) {
+					lastOptional = i
+				}
+			}
+			for i, fieldInfo := range typeInfo.Fields {
 				fieldIdx, fieldType, opts := fieldInfo.unpack()
 				fieldRv := rv.Field(fieldIdx)
+				if opts.BinarySkip || (opts.Optional && i > lastOptional) {
+					continue
+				}
 				writeReflectBinary(fieldRv, fieldType, opts, w, n, err)
 			}
 		}
@@ -30,7 +30,8 @@ import (
 )
 
 const (
-	MempoolChannel = byte(0x30)
+	MempoolChannel  = byte(0x30)
+	ProtocolVersion = "1.0.0"
 
 	maxMempoolMessageSize      = 1048576 // 1MB TODO make it configurable
 	peerCatchupSleepIntervalMS = 100     // If peer is behind, sleep this amount
@@ -53,6 +54,11 @@ func NewTxReactor(conf *viper.Viper, mempool types.TxPool) *MempoolReactor {
 	return memR
 }
 
+// Implements p2p.VersionedReactor
+func (memR *MempoolReactor) ProtocolVersion() string {
+	return ProtocolVersion
+}
+
 // Implements Reactor
 func (memR *MempoolReactor) GetChannels() []*p2p.ChannelDescriptor {
 	return []*p2p.ChannelDescriptor{
@@ -78,6 +84,7 @@ func (memR *MempoolReactor) Receive(chID byte, src *p2p.Peer, msgBytes []byte) {
 	_, msg, err := DecodeMessage(msgBytes)
 	if err != nil {
 		log.Warn("Error decoding message", zap.String("error", err.Error()))
+		memR.Switch.PenalizePeer(src, p2p.PenaltyMalformedMsg, err)
 		return
 	}
 	//log.Debugw("Receive", "src", src, "chId", chID, "msg", msg)
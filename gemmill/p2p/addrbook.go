@@ -103,6 +103,7 @@ type AddrBook struct {
 	addrLookup        map[string]*knownAddress // new & old
 	addrNew           []map[string]*knownAddress
 	addrOld           []map[string]*knownAddress
+	banned            map[string]*knownAddress // kept out of the buckets until BannedUntil
 	wg                sync.WaitGroup
 	nOld              int
 	nNew              int
@@ -119,6 +120,7 @@ func NewAddrBook(filePath string, routabilityStrict bool) *AddrBook {
 		rand:              rand.New(rand.NewSource(time.Now().UnixNano())),
 		ourAddrs:          make(map[string]*NetAddress),
 		addrLookup:        make(map[string]*knownAddress),
+		banned:            make(map[string]*knownAddress),
 		filePath:          filePath,
 		routabilityStrict: routabilityStrict,
 	}
@@ -267,16 +269,50 @@ func (a *AddrBook) MarkAttempt(addr *NetAddress) {
 	ka.markAttempt()
 }
 
-func (a *AddrBook) MarkBad(addr *NetAddress) {
+// MarkBad ejects addr and refuses it for banTime, the ban is saved with
+// the book.
+func (a *AddrBook) MarkBad(addr *NetAddress, banTime time.Duration) {
 	a.mtx.Lock()
 	defer a.mtx.Unlock()
 	ka := a.addrLookup[addr.String()]
-	if ka == nil {
-		return
+	if ka != nil {
+		a.removeFromAllBuckets(ka)
+	} else if ka = a.banned[addr.String()]; ka == nil {
+		ka = newKnownAddress(addr, addr)
 	}
-	// We currently just eject the address.
-	// In the future, consider blacklisting.
-	a.removeFromAllBuckets(ka)
+	ka.BannedUntil = time.Now().Add(banTime)
+	a.banned[addr.String()] = ka
+}
+
+// IsBanned tells if addr was marked bad and its ban isn't over
+func (a *AddrBook) IsBanned(addr *NetAddress) bool {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	return a.isBanned(addr.String())
+}
+
+func (a *AddrBook) isBanned(addr string) bool {
+	ka, ok := a.banned[addr]
+	if ok && time.Now().After(ka.BannedUntil) {
+		delete(a.banned, addr)
+		return false
+	}
+	return ok
+}
+
+// ClearBans lifts the bans on the addresses of ip, all of them if ip is
+// empty, they can be added again. Returns the number of lifted bans.
+func (a *AddrBook) ClearBans(ip string) int {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	n := 0
+	for addr, ka := range a.banned {
+		if ip == "" || ka.Addr.IP.String() == ip {
+			delete(a.banned, addr)
+			n++
+		}
+	}
+	return n
 }
 
 /* Peer exchange */
@@ -317,8 +353,9 @@ func (a *AddrBook) GetSelection() []*NetAddress {
 /* Loading & Saving */
 
 type addrBookJSON struct {
-	Key   string
-	Addrs []*knownAddress
+	Key    string
+	Addrs  []*knownAddress
+	Banned []*knownAddress `json:",omitempty"`
 }
 
 func (a *AddrBook) saveToFile(filePath string) {
@@ -328,9 +365,15 @@ func (a *AddrBook) saveToFile(filePath string) {
 		addrs = append(addrs, ka)
 	}
 
+	banned := []*knownAddress{}
+	for _, ka := range a.banned {
+		banned = append(banned, ka)
+	}
+
 	aJSON := &addrBookJSON{
-		Key:   a.key,
-		Addrs: addrs,
+		Key:    a.key,
+		Addrs:  addrs,
+		Banned: banned,
 	}
 
 	jsonBytes, err := json.MarshalIndent(aJSON, "", "\t")
@@ -382,6 +425,12 @@ func (a *AddrBook) loadFromFile(filePath string) bool {
 			a.nOld++
 		}
 	}
+	// Restore the bans not over yet
+	for _, ka := range aJSON.Banned {
+		if time.Now().Before(ka.BannedUntil) {
+			a.banned[ka.Addr.String()] = ka
+		}
+	}
 	return true
 }
 
@@ -540,6 +589,9 @@ func (a *AddrBook) addAddress(addr, src *NetAddress) {
 		// Ignore our own listener address.
 		return
 	}
+	if a.isBanned(addr.String()) {
+		return
+	}
 
 	ka := a.addrLookup[addr.String()]
 
@@ -736,6 +788,7 @@ type knownAddress struct {
 	LastSuccess time.Time
 	BucketType  byte
 	Buckets     []int
+	BannedUntil time.Time
 }
 
 func newKnownAddress(addr *NetAddress, src *NetAddress) *knownAddress {
@@ -18,7 +18,9 @@ import (
 	"fmt"
 	"io/ioutil"
 	"math/rand"
+	"os"
 	"testing"
+	"time"
 )
 
 const addrBookStrict = true
@@ -178,3 +180,41 @@ func TestPromoteToOld(t *testing.T) {
 	selection := book.GetSelection()
 	t.Logf("selection: %v", selection)
 }
+
+func TestMarkBadPersists(t *testing.T) {
+	fname := createTempFileName("addrbook_test")
+	defer os.Remove(fname)
+
+	book := NewAddrBook(fname, addrBookStrict)
+	bad, good := randIPv4Address(), randIPv4Address()
+	book.AddAddress(bad, bad)
+	book.AddAddress(good, good)
+	book.MarkBad(bad, time.Hour)
+	if book.Size() != 1 || !book.IsBanned(bad) {
+		t.Fatal("expected the bad address ejected and banned")
+	}
+	book.AddAddress(bad, good)
+	if book.Size() != 1 {
+		t.Fatal("expected a banned address not to be added")
+	}
+
+	book.saveToFile(fname)
+	book = NewAddrBook(fname, addrBookStrict)
+	book.loadFromFile(fname)
+	if !book.IsBanned(bad) || book.IsBanned(good) {
+		t.Fatal("expected the ban to be loaded")
+	}
+
+	if n := book.ClearBans(bad.IP.String()); n != 1 {
+		t.Fatalf("expected 1 ban cleared, got %d", n)
+	}
+	book.AddAddress(bad, bad)
+	if book.Size() != 2 {
+		t.Fatal("expected the address to be added once its ban is cleared")
+	}
+
+	book.MarkBad(good, -time.Second)
+	if book.IsBanned(good) {
+		t.Fatal("expected an expired ban to be over")
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package p2p
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/dappledger/AnnChain/gemmill/modules/go-log"
+	"go.uber.org/zap"
+)
+
+// Penalties lowering the score of a misbehaving peer
+const (
+	PenaltyMalformedMsg = 10
+	PenaltyFlood        = 10
+	PenaltyBadVote      = 20
+	PenaltyBadBlockPart = 20
+	PenaltyBadBlock     = 50
+)
+
+const (
+	// initialPeerScore is the score of a well behaved peer
+	initialPeerScore = 100
+	// peerScoreRecoveryPerMinute is given back to a penalized peer every
+	// minute until it's at the initial score again
+	peerScoreRecoveryPerMinute = 1
+)
+
+// Ban keeps a misbehaving peer out by its pubkey and IP until it expires
+type Ban struct {
+	PubKey string    `json:"pub_key"`
+	IP     string    `json:"ip"`
+	Reason string    `json:"reason"`
+	Since  time.Time `json:"since"`
+	Until  time.Time `json:"until"`
+}
+
+func (b *Ban) Error() string {
+	return fmt.Sprintf("banned until %s: %s", b.Until.Format(time.RFC3339), b.Reason)
+}
+
+type peerScore struct {
+	value   int
+	updated time.Time
+}
+
+// recover gives back the points earned since the last update
+func (s *peerScore) recover(now time.Time) {
+	minutes := int(now.Sub(s.updated) / time.Minute)
+	if minutes <= 0 {
+		return
+	}
+	s.updated = s.updated.Add(time.Duration(minutes) * time.Minute)
+	if s.value += minutes * peerScoreRecoveryPerMinute; s.value > initialPeerScore {
+		s.value = initialPeerScore
+	}
+}
+
+// PenalizePeer lowers the score of peer for misbehaving, a peer dropping to
+// the ban threshold is stopped and banned for a while. Returns true if peer
+// got banned.
+func (sw *Switch) PenalizePeer(peer *Peer, penalty int, reason interface{}) bool {
+	now := time.Now()
+	sw.scoreMtx.Lock()
+	s, ok := sw.scores[peer.Key]
+	if !ok {
+		s = &peerScore{value: initialPeerScore, updated: now}
+		sw.scores[peer.Key] = s
+	}
+	s.recover(now)
+	s.value -= penalty
+	score := s.value
+
+	var ban *Ban
+	if score <= sw.config.GetInt(configKeyPeerBanThreshold) {
+		ban = &Ban{
+			PubKey: peer.Key,
+			IP:     hostOf(peer.RemoteAddr),
+			Reason: fmt.Sprint(reason),
+			Since:  now,
+			Until:  now.Add(time.Duration(sw.config.GetInt(configKeyPeerBanMinutes)) * time.Minute),
+		}
+		sw.bans[peer.Key] = ban
+		// the peer starts afresh once the ban is over
+		delete(sw.scores, peer.Key)
+	}
+	sw.scoreMtx.Unlock()
+
+	log.Info("Penalized peer", zap.Stringer("peer", peer), zap.Int("penalty", penalty),
+		zap.Int("score", score), zap.String("reason", fmt.Sprint(reason)))
+	if ban == nil {
+		return false
+	}
+	log.Warn("Banned peer", zap.Stringer("peer", peer), zap.String("ip", ban.IP), zap.Time("until", ban.Until))
+	sw.StopPeerForError(peer, ban)
+	return true
+}
+
+// PeerScore returns the current score of the peer of key
+func (sw *Switch) PeerScore(key string) int {
+	sw.scoreMtx.Lock()
+	defer sw.scoreMtx.Unlock()
+	s, ok := sw.scores[key]
+	if !ok {
+		return initialPeerScore
+	}
+	s.recover(time.Now())
+	return s.value
+}
+
+// banned returns the ban on the pubkey key or the IP, either may be empty
+func (sw *Switch) banned(key, ip string) *Ban {
+	now := time.Now()
+	sw.scoreMtx.Lock()
+	defer sw.scoreMtx.Unlock()
+	for k, ban := range sw.bans {
+		if now.After(ban.Until) {
+			delete(sw.bans, k)
+			continue
+		}
+		if (key != "" && ban.PubKey == key) || (ip != "" && ban.IP == ip) {
+			return ban
+		}
+	}
+	return nil
+}
+
+// Bans returns the bans in force, the latest last
+func (sw *Switch) Bans() []*Ban {
+	now := time.Now()
+	sw.scoreMtx.Lock()
+	defer sw.scoreMtx.Unlock()
+	bans := make([]*Ban, 0, len(sw.bans))
+	for k, ban := range sw.bans {
+		if now.After(ban.Until) {
+			delete(sw.bans, k)
+			continue
+		}
+		bans = append(bans, ban)
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Since.Before(bans[j].Since) })
+	return bans
+}
+
+// ClearBans lifts the bans on the pubkey or IP id, all of them if id is
+// empty, and returns the lifted bans
+func (sw *Switch) ClearBans(id string) []*Ban {
+	sw.scoreMtx.Lock()
+	defer sw.scoreMtx.Unlock()
+	var cleared []*Ban
+	for k, ban := range sw.bans {
+		if id == "" || strings.EqualFold(ban.PubKey, id) || ban.IP == id {
+			cleared = append(cleared, ban)
+			delete(sw.bans, k)
+		}
+	}
+	return cleared
+}
+
+// hostOf returns the host of addr, empty for addresses without one
+func hostOf(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return ""
+	}
+	return host
+}
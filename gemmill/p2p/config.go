@@ -24,6 +24,12 @@ const (
 	configKeyHandshakeTimeoutSeconds = "handshake_timeout_seconds"
 	configKeyMaxNumPeers             = "max_num_peers"
 	configKeyAuthEnc                 = "authenticated_encryption"
+	configKeyRollingUpgrade          = "p2p_rolling_upgrade" // let in peers differing in minor versions
+	configKeyUnconditionalPeers      = "unconditional_peers" // comma separated peer ids let in beyond max_num_peers
+	configKeyPrivatePeerIDs          = "private_peer_ids"    // comma separated peer ids pex never gossips
+	configKeyPeerBanThreshold        = "peer_ban_threshold"  // peers scoring this or lower get banned
+	configKeyPeerBanMinutes          = "peer_ban_minutes"
+	configKeyPeerFloodRate           = "peer_flood_rate" // msgs per second a peer is penalized above, 0 for no limit
 
 	// MConnection config keys
 	configKeySendRate = "send_rate"
@@ -45,6 +51,12 @@ func setConfigDefaults(config *viper.Viper) {
 	config.SetDefault(configKeyHandshakeTimeoutSeconds, 20)
 	config.SetDefault(configKeyMaxNumPeers, 50)
 	config.SetDefault(configKeyAuthEnc, true)
+	config.SetDefault(configKeyRollingUpgrade, false)
+	config.SetDefault(configKeyUnconditionalPeers, "")
+	config.SetDefault(configKeyPrivatePeerIDs, "")
+	config.SetDefault(configKeyPeerBanThreshold, 0)
+	config.SetDefault(configKeyPeerBanMinutes, 60)
+	config.SetDefault(configKeyPeerFloodRate, 0)
 
 	// MConnection default config
 	config.SetDefault(configKeySendRate, 5120000) // 5000KB/s
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"time"
 
 	"github.com/dappledger/AnnChain/gemmill/go-wire"
 	gcmn "github.com/dappledger/AnnChain/gemmill/modules/go-common"
@@ -34,10 +35,14 @@ type Peer struct {
 	*NodeInfo
 	Key  string
 	Data *gcmn.CMap // User data.
+
+	// msgs received in the second from recvSince, only touched by the recv routine
+	recvSince time.Time
+	recvCount int
 }
 
 type AuthorizationFunc func(nodeinfo *NodeInfo) error
-type DealExchangeDataFunc func(data *ExchangeData) error
+type DealExchangeDataFunc func(peerNodeInfo *NodeInfo, data *ExchangeData) error
 
 // NOTE: blocking
 // Before creating a peer with newPeer(), perform a handshake on connection.
@@ -69,7 +74,7 @@ func peerHandshake(conn net.Conn, sw *Switch) (*NodeInfo, error) {
 	return peerNodeInfo, nil
 }
 
-func exchangeData(conn net.Conn, sw *Switch) error {
+func exchangeData(conn net.Conn, sw *Switch, peerNodeInfo *NodeInfo) error {
 	var (
 		exchangeData = new(ExchangeData)
 		err1         error
@@ -90,13 +95,17 @@ func exchangeData(conn net.Conn, sw *Switch) error {
 	if err2 != nil {
 		return err2
 	}
-	return sw.DealExchangeData(exchangeData)
+	return sw.DealExchangeData(peerNodeInfo, exchangeData)
 }
 
 // NOTE: call peerHandshake on conn before calling newPeer().
-func newPeer(config *viper.Viper, conn net.Conn, peerNodeInfo *NodeInfo, outbound bool, reactorsByCh map[byte]Reactor, chDescs []*ChannelDescriptor, onPeerError func(*Peer, interface{})) *Peer {
+func newPeer(config *viper.Viper, conn net.Conn, peerNodeInfo *NodeInfo, outbound bool, reactorsByCh map[byte]Reactor, chDescs []*ChannelDescriptor, onPeerError func(*Peer, interface{}), onPeerFlood func(*Peer)) *Peer {
 	var p *Peer
+	floodRate := config.GetInt(configKeyPeerFloodRate)
 	onReceive := func(chID byte, msgBytes []byte) {
+		if floodRate > 0 && p.countRecv(floodRate) {
+			onPeerFlood(p)
+		}
 		reactor := reactorsByCh[chID]
 		if reactor == nil {
 			gcmn.PanicSanity(gcmn.Fmt("Unknown channel %X", chID))
@@ -119,6 +128,16 @@ func newPeer(config *viper.Viper, conn net.Conn, peerNodeInfo *NodeInfo, outboun
 	return p
 }
 
+// countRecv counts a received msg, true for the first msg over rate in a second
+func (p *Peer) countRecv(rate int) bool {
+	now := time.Now()
+	if now.Sub(p.recvSince) >= time.Second {
+		p.recvSince, p.recvCount = now, 0
+	}
+	p.recvCount++
+	return p.recvCount == rate+1
+}
+
 func (p *Peer) OnStart() error {
 	p.BaseService.OnStart()
 	_, err := p.mconn.Start()
@@ -44,12 +44,14 @@ adequate number of peers are connected to the switch.
 type PEXReactor struct {
 	BaseReactor
 
-	book *AddrBook
+	book    *AddrBook
+	private *gcmn.CMap // addresses of private peers
 }
 
 func NewPEXReactor(book *AddrBook) *PEXReactor {
 	pexR := &PEXReactor{
-		book: book,
+		book:    book,
+		private: gcmn.NewCMap(),
 	}
 	pexR.BaseReactor = *NewBaseReactor("PEXReactor", pexR)
 	return pexR
@@ -78,6 +80,19 @@ func (pexR *PEXReactor) GetChannels() []*ChannelDescriptor {
 
 // Implements Reactor
 func (pexR *PEXReactor) AddPeer(peer *Peer) {
+	if pexR.Switch.IsPrivate(peer.Key) {
+		// Keep private peers out of the book and never hand out their
+		// addresses, even the ones learned from others
+		pexR.private.Set(peer.ListenAddr, struct{}{})
+		if peer.IsOutbound() {
+			pexR.private.Set(peer.Connection().RemoteAddress.String(), struct{}{})
+			if pexR.book.NeedMoreAddrs() {
+				pexR.RequestPEX(peer)
+			}
+		}
+		return
+	}
+
 	// Add the peer to the address book
 	netAddr, _ := NewNetAddressString(peer.ListenAddr)
 	if peer.IsOutbound() {
@@ -93,7 +108,18 @@ func (pexR *PEXReactor) AddPeer(peer *Peer) {
 
 // Implements Reactor
 func (pexR *PEXReactor) RemovePeer(peer *Peer, reason interface{}) {
-	// TODO
+	ban, ok := reason.(*Ban)
+	if !ok {
+		return
+	}
+	// Keep the addresses of a banned peer out of the book for the ban
+	banTime := time.Until(ban.Until)
+	if netAddr, err := NewNetAddressString(peer.ListenAddr); err == nil {
+		pexR.book.MarkBad(netAddr, banTime)
+	}
+	if peer.IsOutbound() {
+		pexR.book.MarkBad(peer.Connection().RemoteAddress, banTime)
+	}
 }
 
 // Implements Reactor
@@ -119,6 +145,9 @@ func (pexR *PEXReactor) Receive(chID byte, src *Peer, msgBytes []byte) {
 		// (We don't want to get spammed with bad peers)
 		srcAddr := src.Connection().RemoteAddress
 		for _, addr := range msg.Addrs {
+			if pexR.private.Has(addr.String()) {
+				continue
+			}
 			pexR.book.AddAddress(addr, srcAddr)
 		}
 	default:
@@ -132,7 +161,15 @@ func (pexR *PEXReactor) RequestPEX(peer *Peer) {
 	peer.Send(PexChannel, struct{ PexMessage }{&pexRequestMessage{}})
 }
 
+// SendAddrs sends addrs but the ones of private peers to peer
 func (pexR *PEXReactor) SendAddrs(peer *Peer, addrs []*NetAddress) {
+	public := addrs[:0:0]
+	for _, addr := range addrs {
+		if !pexR.private.Has(addr.String()) {
+			public = append(public, addr)
+		}
+	}
+	addrs = public
 	peer.Send(PexChannel, struct{ PexMessage }{&pexAddrsMessage{Addrs: addrs}})
 }
 
@@ -19,6 +19,9 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/dappledger/AnnChain/gemmill/go-crypto"
@@ -96,11 +99,33 @@ type Switch struct {
 	addToRefuselist   func([]byte) error
 	peerErrorReporter IPeerErrorReporter
 	dealExchangeData  DealExchangeDataFunc
+
+	rejectedMtx sync.Mutex
+	rejected    []*RejectedPeer // most recent last
+
+	persistentMtx  sync.Mutex
+	persistent     map[string]bool   // addresses from DialPersistentPeers
+	persistentKeys map[string]string // peer key -> persistent address it was dialed at
+	redialing      map[string]bool   // persistent addresses with a dial loop running
+
+	scoreMtx sync.Mutex
+	scores   map[string]*peerScore // by peer key, only the penalized ones
+	bans     map[string]*Ban       // by peer key
 }
 
+const (
+	// maxRejectedPeers caps the refused handshakes remembered for net_info
+	maxRejectedPeers = 100
+
+	// backoff between redials of a persistent peer, doubled up to the max
+	persistentRedialBaseBackoff = time.Second
+	persistentRedialMaxBackoff  = 5 * time.Minute
+)
+
 var (
 	ErrSwitchDuplicatePeer      = errors.New("Duplicate peer")
 	ErrSwitchMaxPeersPerIPRange = errors.New("IP range has too many peers")
+	ErrSwitchMaxPeers           = errors.New("Already have enough peers")
 )
 
 func NewSwitch(config *viper.Viper) *Switch {
@@ -114,6 +139,13 @@ func NewSwitch(config *viper.Viper) *Switch {
 		peers:        NewPeerSet(),
 		dialing:      gcmn.NewCMap(),
 		nodeInfo:     nil,
+
+		persistent:     make(map[string]bool),
+		persistentKeys: make(map[string]string),
+		redialing:      make(map[string]bool),
+
+		scores: make(map[string]*peerScore),
+		bans:   make(map[string]*Ban),
 	}
 	sw.BaseService = *gcmn.NewBaseService("P2P Switch", sw)
 	return sw
@@ -134,9 +166,33 @@ func (sw *Switch) AddReactor(name string, reactor Reactor) Reactor {
 	}
 	sw.reactors[name] = reactor
 	reactor.SetSwitch(sw)
+	sw.advertiseProtocols()
 	return reactor
 }
 
+// protocols returns the names of the versioned reactors
+func (sw *Switch) protocols() []string {
+	names := make([]string, 0, len(sw.reactors))
+	for name, reactor := range sw.reactors {
+		if _, ok := reactor.(VersionedReactor); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (sw *Switch) advertiseProtocols() {
+	if sw.nodeInfo == nil {
+		return
+	}
+	for name, reactor := range sw.reactors {
+		if vr, ok := reactor.(VersionedReactor); ok {
+			sw.nodeInfo.SetProtocolVersion(name, vr.ProtocolVersion())
+		}
+	}
+}
+
 // Not goroutine safe.
 func (sw *Switch) Reactors() map[string]Reactor {
 	return sw.reactors
@@ -165,6 +221,7 @@ func (sw *Switch) IsListening() bool {
 // Not goroutine safe.
 func (sw *Switch) SetNodeInfo(nodeInfo *NodeInfo) {
 	sw.nodeInfo = nodeInfo
+	sw.advertiseProtocols()
 }
 
 func (sw *Switch) SetExchangeData(data *ExchangeData) {
@@ -248,6 +305,11 @@ func (sw *Switch) AddPeerWithConnection(conn net.Conn, outbound bool) (*Peer, er
 		conn.Close()
 		return nil, err
 	}
+	if ban := sw.banned("", hostOf(conn.RemoteAddr().String())); ban != nil {
+		sw.reject(conn.RemoteAddr().String(), nil, ban)
+		conn.Close()
+		return nil, ban
+	}
 
 	// Set deadline for handshake so we don't block forever on conn.ReadFull
 	conn.SetDeadline(time.Now().Add(
@@ -274,10 +336,16 @@ func (sw *Switch) AddPeerWithConnection(conn net.Conn, outbound bool) (*Peer, er
 		sconn.Close()
 		return nil, err
 	}
+	if ban := sw.banned(sconn.(*SecretConnection).RemotePubKey().KeyString(), ""); ban != nil {
+		sw.reject(conn.RemoteAddr().String(), nil, ban)
+		sconn.Close()
+		return nil, ban
+	}
 
 	// Then, perform node handshake
 	peerNodeInfo, err := peerHandshake(sconn, sw)
 	if err != nil {
+		sw.reject(conn.RemoteAddr().String(), nil, err)
 		sconn.Close()
 		return nil, err
 	}
@@ -295,19 +363,31 @@ func (sw *Switch) AddPeerWithConnection(conn net.Conn, outbound bool) (*Peer, er
 		return nil, fmt.Errorf("Ignoring connection from self")
 	}
 	// Check version, chain id
-	/*
-		if err := sw.nodeInfo.CompatibleWith(peerNodeInfo); err != nil {
-			sconn.Close()
-			return nil, err
-		}
-	*/
+	mismatches, err := sw.nodeInfo.CompatibleWith(peerNodeInfo, sw.protocols(), sw.config.GetBool(configKeyRollingUpgrade))
+	if err != nil {
+		sw.reject(conn.RemoteAddr().String(), peerNodeInfo, err)
+		sconn.Close()
+		return nil, err
+	}
+	if len(mismatches) > 0 {
+		log.Warn("Peer runs other versions, let in for a rolling upgrade",
+			zap.String("peer", conn.RemoteAddr().String()), zap.Strings("mismatches", mismatches))
+	}
+	// The listener only lets inbound peers beyond max_num_peers through the
+	// handshake when some are unconditional
+	if !outbound && sw.peers.Size() >= sw.config.GetInt(configKeyMaxNumPeers) &&
+		!sw.IsUnconditional(peerNodeInfo.PubKey.KeyString()) {
+		sconn.Close()
+		return nil, ErrSwitchMaxPeers
+	}
 
-	if err := exchangeData(sconn, sw); err != nil {
+	if err := exchangeData(sconn, sw, peerNodeInfo); err != nil {
+		sw.reject(conn.RemoteAddr().String(), peerNodeInfo, err)
 		sconn.Close()
 		return nil, err
 	}
 
-	peer := newPeer(sw.config, sconn, peerNodeInfo, outbound, sw.reactorsByCh, sw.chDescs, sw.StopPeerForError)
+	peer := newPeer(sw.config, sconn, peerNodeInfo, outbound, sw.reactorsByCh, sw.chDescs, sw.StopPeerForError, sw.onPeerFlood)
 
 	// Add the peer to .peers
 	// ignore if duplicate or if we already have too many for that IP range
@@ -327,6 +407,58 @@ func (sw *Switch) AddPeerWithConnection(conn net.Conn, outbound bool) (*Peer, er
 	return peer, nil
 }
 
+// reject remembers a refused handshake, info is nil when it failed before
+// the peer sent its node info
+func (sw *Switch) reject(addr string, info *NodeInfo, reason error) {
+	rp := &RejectedPeer{Addr: addr, Reason: reason.Error(), Count: 1, Time: time.Now()}
+	if info != nil {
+		rp.Moniker, rp.Network, rp.Version = info.Moniker, info.Network, info.Version
+		if info.PubKey != nil {
+			rp.PubKey = info.PubKey.KeyString()
+		}
+	}
+	log.Info("Rejected peer", zap.String("addr", addr), zap.String("reason", rp.Reason))
+
+	sw.rejectedMtx.Lock()
+	defer sw.rejectedMtx.Unlock()
+	for i, r := range sw.rejected {
+		if rejectedKey(r) == rejectedKey(rp) {
+			rp.Count = r.Count + 1
+			sw.rejected = append(sw.rejected[:i], sw.rejected[i+1:]...)
+			break
+		}
+	}
+	if len(sw.rejected) >= maxRejectedPeers {
+		sw.rejected = sw.rejected[1:]
+	}
+	sw.rejected = append(sw.rejected, rp)
+}
+
+// rejectedKey tells peers apart by key, or by host before they sent one as
+// inbound connections come from another port every time
+func rejectedKey(rp *RejectedPeer) string {
+	if rp.PubKey != "" {
+		return rp.PubKey
+	}
+	host, _, err := net.SplitHostPort(rp.Addr)
+	if err != nil {
+		return rp.Addr
+	}
+	return host
+}
+
+// RejectedPeers returns the latest refused handshakes, most recent last
+func (sw *Switch) RejectedPeers() []*RejectedPeer {
+	sw.rejectedMtx.Lock()
+	defer sw.rejectedMtx.Unlock()
+	rejected := make([]*RejectedPeer, len(sw.rejected))
+	for i, r := range sw.rejected {
+		rc := *r
+		rejected[i] = &rc
+	}
+	return rejected
+}
+
 func (sw *Switch) FilterConnByAddr(addr net.Addr) error {
 	if sw.filterConnByAddr != nil {
 		return sw.filterConnByAddr(addr)
@@ -360,9 +492,9 @@ func (sw *Switch) SetDealExchangeDataFunc(f DealExchangeDataFunc) {
 	sw.dealExchangeData = f
 }
 
-func (sw *Switch) DealExchangeData(data *ExchangeData) error {
+func (sw *Switch) DealExchangeData(peerNodeInfo *NodeInfo, data *ExchangeData) error {
 	if sw.dealExchangeData != nil {
-		return sw.dealExchangeData(data)
+		return sw.dealExchangeData(peerNodeInfo, data)
 	}
 	return nil
 }
@@ -428,6 +560,133 @@ func (sw *Switch) dialSeed(addr *NetAddress) {
 	log.Info("Connected to seed", zap.Stringer("peer", peer))
 }
 
+// DialPersistentPeers dials addrs and keeps them connected, a persistent
+// peer that can't be reached or drops for an error is redialed with
+// exponential backoff for as long as the switch runs.
+func (sw *Switch) DialPersistentPeers(addrs []string) {
+	sw.persistentMtx.Lock()
+	for _, addr := range addrs {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			sw.persistent[addr] = true
+		}
+	}
+	sw.persistentMtx.Unlock()
+
+	for _, addr := range addrs {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			go sw.redialPersistentPeer(addr)
+		}
+	}
+}
+
+// redialPersistentPeer dials addr until it is connected, at most one loop
+// runs per address
+func (sw *Switch) redialPersistentPeer(addr string) {
+	sw.persistentMtx.Lock()
+	if sw.redialing[addr] {
+		sw.persistentMtx.Unlock()
+		return
+	}
+	sw.redialing[addr] = true
+	sw.persistentMtx.Unlock()
+	defer func() {
+		sw.persistentMtx.Lock()
+		delete(sw.redialing, addr)
+		sw.persistentMtx.Unlock()
+	}()
+
+	backoff := persistentRedialBaseBackoff
+	for sw.IsRunning() {
+		if sw.persistentConnected(addr) {
+			return
+		}
+		// resolve on every try in case of host resolve err(k8s)
+		netAddr, err := NewNetAddressString(addr)
+		if err == nil {
+			var peer *Peer
+			peer, err = sw.DialPeerWithAddress(netAddr)
+			if err == nil {
+				sw.persistentMtx.Lock()
+				sw.persistentKeys[peer.Key] = addr
+				sw.persistentMtx.Unlock()
+				log.Info("Connected to persistent peer", zap.Stringer("peer", peer))
+				return
+			}
+			if err == ErrSwitchDuplicatePeer {
+				return
+			}
+		}
+		log.Warn("Failed dialing persistent peer", zap.String("address", addr),
+			zap.Duration("retry", backoff), zap.String("error", err.Error()))
+
+		// jitter so the peers a node lost at once aren't redialed in lockstep
+		wait := backoff + time.Duration(rand.Int63n(int64(backoff)/2+1))
+		select {
+		case <-time.After(wait):
+		case <-sw.Quit:
+			return
+		}
+		if backoff *= 2; backoff > persistentRedialMaxBackoff {
+			backoff = persistentRedialMaxBackoff
+		}
+	}
+}
+
+// persistentAddr returns the persistent address of peer, empty if peer is
+// not a persistent peer
+func (sw *Switch) persistentAddr(peer *Peer) string {
+	sw.persistentMtx.Lock()
+	defer sw.persistentMtx.Unlock()
+	if addr, ok := sw.persistentKeys[peer.Key]; ok {
+		return addr
+	}
+	if sw.persistent[peer.ListenAddr] {
+		return peer.ListenAddr
+	}
+	if peer.outbound {
+		if addr := peer.mconn.RemoteAddress.String(); sw.persistent[addr] {
+			return addr
+		}
+	}
+	return ""
+}
+
+// persistentConnected tells if a peer dialed or recognized at the
+// persistent address addr is connected
+func (sw *Switch) persistentConnected(addr string) bool {
+	for _, peer := range sw.peers.List() {
+		if sw.persistentAddr(peer) == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPersistent tells if peer is kept connected by DialPersistentPeers
+func (sw *Switch) IsPersistent(peer *Peer) bool {
+	return sw.persistentAddr(peer) != ""
+}
+
+// IsUnconditional tells if the peer of key is let in beyond max_num_peers
+func (sw *Switch) IsUnconditional(key string) bool {
+	return hasPeerID(sw.config.GetString(configKeyUnconditionalPeers), key)
+}
+
+// IsPrivate tells if the address of the peer of key is kept out of pex
+func (sw *Switch) IsPrivate(key string) bool {
+	return hasPeerID(sw.config.GetString(configKeyPrivatePeerIDs), key)
+}
+
+// hasPeerID tells if the comma separated ids list the peer key
+func hasPeerID(ids, key string) bool {
+	for _, id := range strings.Split(ids, ",") {
+		if id = strings.TrimSpace(id); id != "" && strings.EqualFold(id, key) {
+			return true
+		}
+	}
+	return false
+}
+
 func (sw *Switch) DialPeerWithAddress(addr *NetAddress) (*Peer, error) {
 	log.Debug("Dialing address", zap.Stringer("address", addr))
 	sw.dialing.Set(addr.IP.String(), addr)
@@ -496,6 +755,16 @@ func (sw *Switch) StopPeerForError(peer *Peer, reason interface{}) {
 	sw.peers.Remove(peer)
 	peer.Stop()
 	sw.removePeerFromReactors(peer, reason)
+
+	if addr := sw.persistentAddr(peer); addr != "" && sw.IsRunning() {
+		go sw.redialPersistentPeer(addr)
+	}
+}
+
+// onPeerFlood is called from the recv routine of peer, stopping the peer
+// there would block it
+func (sw *Switch) onPeerFlood(peer *Peer) {
+	go sw.PenalizePeer(peer, PenaltyFlood, fmt.Sprintf("more than %d msgs per second", sw.config.GetInt(configKeyPeerFloodRate)))
 }
 
 // Disconnect from a peer gracefully.
@@ -526,9 +795,10 @@ func (sw *Switch) listenerRoutine(l Listener) {
 			break
 		}
 
-		// ignore connection if we already have enough
+		// ignore connection if we already have enough, unless it may come
+		// from an unconditional peer which is only known after the handshake
 		maxPeers := sw.config.GetInt(configKeyMaxNumPeers)
-		if maxPeers <= sw.peers.Size() {
+		if maxPeers <= sw.peers.Size() && sw.config.GetString(configKeyUnconditionalPeers) == "" {
 			log.Info("Ignoring inbound connection: already have enough inbound peers", zap.Stringer("address", inConn.RemoteAddr()), zap.Int("numPeers", sw.peers.Size()), zap.Int("max", maxPeers))
 			continue
 		}
@@ -0,0 +1,242 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package p2p
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func newTestSwitch(t *testing.T, i int, configure func(*viper.Viper), initSwitch func(int, *Switch) *Switch) *Switch {
+	cfg := viper.New()
+	if configure != nil {
+		configure(cfg)
+	}
+	if initSwitch == nil {
+		initSwitch = func(_ int, sw *Switch) *Switch { return sw }
+	}
+	sw := makeSwitch(cfg, i, "testing", "1.2.3", initSwitch)
+	if _, err := sw.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return sw
+}
+
+// connectPipe connects from to to, the peer is inbound on to
+func connectPipe(from, to *Switch) (err error) {
+	c1, c2 := net.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		_, err := from.AddPeerWithConnection(c1, true)
+		done <- err
+	}()
+	_, err = to.AddPeerWithConnection(c2, false)
+	<-done
+	return err
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func TestSwitchUnconditionalPeers(t *testing.T) {
+	full := newTestSwitch(t, 0, func(cfg *viper.Viper) { cfg.Set(configKeyMaxNumPeers, 0) }, nil)
+	defer full.Stop()
+	other := newTestSwitch(t, 1, nil, nil)
+	defer other.Stop()
+
+	if err := connectPipe(other, full); err != ErrSwitchMaxPeers {
+		t.Fatalf("expected %v, got %v", ErrSwitchMaxPeers, err)
+	}
+
+	full.config.Set(configKeyUnconditionalPeers, "AB, "+other.NodeInfo().PubKey.KeyString())
+	if err := connectPipe(other, full); err != nil {
+		t.Fatal(err)
+	}
+	if full.Peers().Size() != 1 || !full.IsUnconditional(other.NodeInfo().PubKey.KeyString()) {
+		t.Fatal("expected the unconditional peer to be let in")
+	}
+}
+
+func TestPEXPrivatePeers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		books    = make([]*AddrBook, 3)
+		reactors = make([]*PEXReactor, 3)
+	)
+	withPEX := func(i int, sw *Switch) *Switch {
+		books[i] = NewAddrBook(filepath.Join(dir, sw.config.GetString("name")), false)
+		reactors[i] = NewPEXReactor(books[i])
+		sw.AddReactor("PEX", reactors[i])
+		return sw
+	}
+	switches := make([]*Switch, 3)
+	for i := range switches {
+		name := string(rune('a' + i))
+		switches[i] = newTestSwitch(t, i, func(cfg *viper.Viper) { cfg.Set("name", name) }, withPEX)
+		defer switches[i].Stop()
+	}
+	sentry, validator, public := switches[0], switches[1], switches[2]
+	validator.NodeInfo().ListenAddr = "10.0.0.1:46656"
+	public.NodeInfo().ListenAddr = "10.0.0.2:46656"
+	sentry.config.Set(configKeyPrivatePeerIDs, validator.NodeInfo().PubKey.KeyString())
+
+	for _, sw := range []*Switch{validator, public} {
+		if err := connectPipe(sw, sentry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !sentry.IsPrivate(validator.NodeInfo().PubKey.KeyString()) || sentry.IsPrivate(public.NodeInfo().PubKey.KeyString()) {
+		t.Fatal("expected only the validator to be private")
+	}
+	waitFor(t, "the public peer in the book", func() bool { return books[0].Size() == 1 })
+
+	// the private address learned from another peer is still kept out
+	privateAddr, _ := NewNetAddressString(validator.NodeInfo().ListenAddr)
+	books[0].AddAddress(privateAddr, privateAddr)
+	if books[0].Size() != 2 {
+		t.Fatalf("expected 2 addresses in the book, got %d", books[0].Size())
+	}
+	reactors[2].RequestPEX(public.Peers().List()[0])
+	waitFor(t, "the addresses from the sentry", func() bool { return books[2].Size() > 0 })
+	time.Sleep(200 * time.Millisecond)
+	for _, addr := range books[2].GetSelection() {
+		if addr.String() == validator.NodeInfo().ListenAddr {
+			t.Fatal("expected the private peer not to be gossiped")
+		}
+	}
+}
+
+func TestSwitchPersistentPeers(t *testing.T) {
+	server := newTestSwitch(t, 0, nil, nil)
+	defer server.Stop()
+	l, err := NewDefaultListener("tcp", "127.0.0.1:0", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.AddListener(l)
+	go server.listenerRoutine(l)
+	addr := l.(*DefaultListener).listener.Addr().String()
+
+	client := newTestSwitch(t, 1, nil, nil)
+	defer client.Stop()
+	client.DialPersistentPeers([]string{addr})
+	waitFor(t, "the persistent peer", func() bool { return client.Peers().Size() == 1 })
+
+	peer := client.Peers().List()[0]
+	if !client.IsPersistent(peer) || !peer.IsOutbound() {
+		t.Fatal("expected an outbound persistent peer")
+	}
+	if !client.persistentConnected(addr) {
+		t.Fatal("expected the persistent address to be connected")
+	}
+
+	client.StopPeerForError(peer, "test")
+	waitFor(t, "the persistent peer to be redialed", func() bool {
+		peers := client.Peers().List()
+		return len(peers) == 1 && peers[0] != peer
+	})
+
+	// graceful stops are not redialed
+	client.StopPeerGracefully(client.Peers().List()[0])
+	time.Sleep(2 * persistentRedialBaseBackoff)
+	if client.Peers().Size() != 0 {
+		t.Fatal("expected a gracefully stopped peer to stay disconnected")
+	}
+}
+
+func TestSwitchPenalizePeer(t *testing.T) {
+	sw := newTestSwitch(t, 0, nil, nil)
+	defer sw.Stop()
+	bad := newTestSwitch(t, 1, nil, nil)
+	defer bad.Stop()
+	if err := connectPipe(bad, sw); err != nil {
+		t.Fatal(err)
+	}
+	peer := sw.Peers().List()[0]
+
+	if sw.PenalizePeer(peer, PenaltyBadBlock, "bad block") {
+		t.Fatal("expected the peer not to be banned yet")
+	}
+	if score := sw.PeerScore(peer.Key); score != initialPeerScore-PenaltyBadBlock {
+		t.Fatalf("expected score %d, got %d", initialPeerScore-PenaltyBadBlock, score)
+	}
+	if !sw.PenalizePeer(peer, PenaltyBadBlock, "bad block") {
+		t.Fatal("expected the peer to be banned")
+	}
+	if sw.Peers().Size() != 0 {
+		t.Fatal("expected the banned peer to be stopped")
+	}
+	bans := sw.Bans()
+	if len(bans) != 1 || bans[0].PubKey != peer.Key || bans[0].Reason != "bad block" {
+		t.Fatalf("unexpected bans %+v", bans)
+	}
+
+	if err := connectPipe(bad, sw); err != bans[0] {
+		t.Fatalf("expected the banned peer refused, got %v", err)
+	}
+	if cleared := sw.ClearBans(strings.ToLower(peer.Key)); len(cleared) != 1 {
+		t.Fatalf("expected the ban cleared, got %v", cleared)
+	}
+	if err := connectPipe(bad, sw); err != nil {
+		t.Fatal(err)
+	}
+	if score := sw.PeerScore(peer.Key); score != initialPeerScore {
+		t.Fatalf("expected the peer to start afresh, got score %d", score)
+	}
+}
+
+func TestPeerScoreRecovers(t *testing.T) {
+	now := time.Now()
+	s := &peerScore{value: 40, updated: now.Add(-90 * time.Second)}
+	s.recover(now)
+	if s.value != 40+peerScoreRecoveryPerMinute || !s.updated.Equal(now.Add(-30*time.Second)) {
+		t.Fatalf("unexpected score %+v", s)
+	}
+	s.recover(now.Add(24 * time.Hour))
+	if s.value != initialPeerScore {
+		t.Fatalf("expected the score capped at %d, got %d", initialPeerScore, s.value)
+	}
+}
+
+func TestPeerCountRecv(t *testing.T) {
+	p := &Peer{}
+	for i := 1; i <= 5; i++ {
+		if flood := p.countRecv(3); flood != (i == 4) {
+			t.Fatalf("msg %d: expected flood %v", i, i == 4)
+		}
+	}
+	p.recvSince = p.recvSince.Add(-time.Second)
+	if p.countRecv(3) || p.recvCount != 1 {
+		t.Fatal("expected a new second to start over")
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"net"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dappledger/AnnChain/gemmill/go-crypto"
 )
@@ -36,37 +37,100 @@ type NodeInfo struct {
 	Other       []string      `json:"other"`   // other application specific data
 }
 
+// RejectedPeer is a peer whose handshake was refused, Count times in a row
+type RejectedPeer struct {
+	Addr    string    `json:"addr"`
+	PubKey  string    `json:"pub_key"`
+	Moniker string    `json:"moniker"`
+	Network string    `json:"network"`
+	Version string    `json:"version"`
+	Reason  string    `json:"reason"`
+	Count   int       `json:"count"`
+	Time    time.Time `json:"time"` // of the last refusal
+}
+
 type ExchangeData struct {
 	GenesisJSON []byte `json:"genesis_str"`
 }
 
-// CONTRACT: two nodes are compatible if the major/minor versions match and network match
-func (info *NodeInfo) CompatibleWith(other *NodeInfo) error {
-	iMajor, _, _, iErr := splitVersion(info.Version)
-	oMajor, _, _, oErr := splitVersion(other.Version)
+// CompatibleWith checks that other is on the same network and runs the same
+// major and minor versions of the node and of the reactor protocols.
+// With rollingUpgrade a peer only differing in minor versions, or not
+// advertising a protocol yet, is let in and the differences are returned
+// for the caller to log.
+func (info *NodeInfo) CompatibleWith(other *NodeInfo, protocols []string, rollingUpgrade bool) ([]string, error) {
+	// nodes must be on the same network
+	if (len(info.Network) != 0 && len(other.Network) != 0) &&
+		info.Network != other.Network {
+		return nil, fmt.Errorf("Peer is on a different network. Got %v, expected %v", other.Network, info.Network)
+	}
 
-	// if our own version number is not formatted right, we messed up
-	if iErr != nil {
-		return iErr
+	var mismatches []string
+	check := func(what, mine, theirs string) error {
+		iMajor, iMinor, _, iErr := splitVersion(mine)
+		// if our own version number is not formatted right, we messed up
+		if iErr != nil {
+			return iErr
+		}
+		if theirs == "" && rollingUpgrade {
+			mismatches = append(mismatches, fmt.Sprintf("%v: none, expected %v", what, mine))
+			return nil
+		}
+		// version number must be formatted correctly ("x.x.x")
+		oMajor, oMinor, _, oErr := splitVersion(theirs)
+		if oErr != nil {
+			return fmt.Errorf("Peer %v: %v", what, oErr)
+		}
+		// major version must match
+		if iMajor != oMajor {
+			return fmt.Errorf("Peer is on a different major %v version. Got %v, expected %v", what, oMajor, iMajor)
+		}
+		if iMinor != oMinor {
+			if !rollingUpgrade {
+				return fmt.Errorf("Peer is on a different minor %v version. Got %v, expected %v", what, oMinor, iMinor)
+			}
+			mismatches = append(mismatches, fmt.Sprintf("%v: %v, expected %v", what, theirs, mine))
+		}
+		return nil
 	}
 
-	// version number must be formatted correctly ("x.x.x")
-	if oErr != nil {
-		return oErr
+	if err := check("node", info.Version, other.Version); err != nil {
+		return nil, err
+	}
+	for _, protocol := range protocols {
+		if err := check(protocol, info.ProtocolVersion(protocol), other.ProtocolVersion(protocol)); err != nil {
+			return nil, err
+		}
 	}
+	return mismatches, nil
+}
 
-	// major version must match
-	if iMajor != oMajor {
-		return fmt.Errorf("Peer is on a different major version. Got %v, expected %v", oMajor, iMajor)
+// ProtocolVersion returns the version of the reactor protocol the node
+// advertises in Other, empty if it doesn't
+func (info *NodeInfo) ProtocolVersion(reactor string) string {
+	prefix := protocolKey(reactor) + "="
+	for _, o := range info.Other {
+		if strings.HasPrefix(o, prefix) {
+			return o[len(prefix):]
+		}
 	}
+	return ""
+}
 
-	// nodes must be on the same network
-	if (len(info.Network) != 0 && len(other.Network) != 0) &&
-		info.Network != other.Network {
-		return fmt.Errorf("Peer is on a different network. Got %v, expected %v", other.Network, info.Network)
+// SetProtocolVersion advertises the version of the reactor protocol in Other
+func (info *NodeInfo) SetProtocolVersion(reactor, version string) {
+	prefix := protocolKey(reactor) + "="
+	for i, o := range info.Other {
+		if strings.HasPrefix(o, prefix) {
+			info.Other[i] = prefix + version
+			return
+		}
 	}
+	info.Other = append(info.Other, prefix+version)
+}
 
-	return nil
+func protocolKey(reactor string) string {
+	return strings.ToLower(reactor) + "_version"
 }
 
 func (info *NodeInfo) ListenHost() string {
@@ -0,0 +1,93 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func testNodeInfo(network, version, consensus string) *NodeInfo {
+	info := &NodeInfo{Network: network, Version: version}
+	if consensus != "" {
+		info.SetProtocolVersion("CONSENSUS", consensus)
+	}
+	return info
+}
+
+func TestNodeInfoCompatibleWith(t *testing.T) {
+	ours := testNodeInfo("chain", "1.2.0", "0.2.2")
+	protocols := []string{"CONSENSUS"}
+	cases := []struct {
+		peer       *NodeInfo
+		strict     bool // compatible without rolling upgrade
+		rolling    bool // compatible with rolling upgrade
+		mismatches int
+	}{
+		{testNodeInfo("chain", "1.2.5", "0.2.0"), true, true, 0},
+		{testNodeInfo("", "1.2.0", "0.2.2"), true, true, 0},
+		{testNodeInfo("other", "1.2.0", "0.2.2"), false, false, 0},
+		{testNodeInfo("chain", "2.2.0", "0.2.2"), false, false, 0},
+		{testNodeInfo("chain", "1.3.0", "0.2.2"), false, true, 1},
+		{testNodeInfo("chain", "1.3.0", "0.3.2"), false, true, 2},
+		{testNodeInfo("chain", "1.2.0", "1.2.2"), false, false, 0},
+		{testNodeInfo("chain", "1.2.0", ""), false, true, 1},
+	}
+	for i, c := range cases {
+		if _, err := ours.CompatibleWith(c.peer, protocols, false); (err == nil) != c.strict {
+			t.Errorf("case %d: expected compatible %v, got %v", i, c.strict, err)
+		}
+		mismatches, err := ours.CompatibleWith(c.peer, protocols, true)
+		if (err == nil) != c.rolling {
+			t.Errorf("case %d: expected compatible %v in a rolling upgrade, got %v", i, c.rolling, err)
+		}
+		if len(mismatches) != c.mismatches {
+			t.Errorf("case %d: expected %d mismatches, got %v", i, c.mismatches, mismatches)
+		}
+	}
+
+	ours.SetProtocolVersion("CONSENSUS", "0.3.0")
+	if v := ours.ProtocolVersion("CONSENSUS"); v != "0.3.0" || len(ours.Other) != 1 {
+		t.Fatalf("expected the protocol version replaced, got %v", ours.Other)
+	}
+}
+
+func TestSwitchRejectedPeers(t *testing.T) {
+	sw := NewSwitch(viper.New())
+	sw.reject("1.2.3.4:1000", nil, errors.New("timeout"))
+	sw.reject("1.2.3.4:1001", nil, errors.New("timeout"))
+	sw.reject("5.6.7.8:1000", testNodeInfo("other", "1.2.0", ""), errors.New("different network"))
+
+	rejected := sw.RejectedPeers()
+	if len(rejected) != 2 {
+		t.Fatalf("expected 2 rejected peers, got %d", len(rejected))
+	}
+	if rejected[0].Addr != "1.2.3.4:1001" || rejected[0].Count != 2 {
+		t.Fatalf("expected the host rejected twice, got %+v", rejected[0])
+	}
+	if rejected[1].Network != "other" || rejected[1].Reason != "different network" {
+		t.Fatalf("unexpected rejected peer %+v", rejected[1])
+	}
+
+	for i := 0; i < maxRejectedPeers+10; i++ {
+		sw.reject(fmt.Sprintf("10.0.0.%d:1", i), nil, errors.New("bad"))
+	}
+	if n := len(sw.RejectedPeers()); n != maxRejectedPeers {
+		t.Fatalf("expected %d rejected peers, got %d", maxRejectedPeers, n)
+	}
+}
@@ -15,3 +15,10 @@
 package p2p
 
 const Version = "0.3.5" // minor fixes
+
+// VersionedReactor is a reactor whose messages are versioned major.minor.revision.
+// The switch advertises the version in NodeInfo.Other and refuses peers
+// running another major or minor version of it.
+type VersionedReactor interface {
+	ProtocolVersion() string
+}
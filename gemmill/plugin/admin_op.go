@@ -19,10 +19,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/dappledger/AnnChain/gemmill/go-crypto"
+	dbm "github.com/dappledger/AnnChain/gemmill/modules/go-db"
 	"github.com/dappledger/AnnChain/gemmill/modules/go-log"
 	"github.com/dappledger/AnnChain/gemmill/p2p"
 	"github.com/dappledger/AnnChain/gemmill/refuse_list"
@@ -34,33 +36,86 @@ type AdminApp interface {
 	From() []byte
 }
 
+// AdminTxHasher is implemented by the apps knowing the hash of the tx an admin
+// op comes in, so that refuse list entries can point to it
+type AdminTxHasher interface {
+	TxHash() []byte
+}
+
+// PermissionApp is implemented by the apps keeping a permission table, the
+// permission ops change it in the state of the tx they come in
+type PermissionApp interface {
+	SetRestricted(permission string, restricted bool) error
+	SetPermission(addr []byte, permission string, granted bool) error
+}
+
+// FreezeApp is implemented by the apps which can freeze accounts, the freeze
+// ops change them in the state of the tx they come in
+type FreezeApp interface {
+	SetFrozen(addr []byte, frozen bool, reason string) error
+}
+
+// SimulatedApp is implemented by the apps running admin ops in calls and
+// replays too, the simulated ops change the state of app only
+type SimulatedApp interface {
+	Simulated() bool
+}
+
+// ReplayedApp is implemented by the apps replaying committed txs, e.g. to
+// trace them. Their admin ops were signed by the validators of their block,
+// which may not be validators anymore.
+type ReplayedApp interface {
+	Replayed() bool
+}
+
+// RefuseChange is a refuse list update staged until EndBlock, which gives it
+// the height
+type RefuseChange struct {
+	PubKey    crypto.PubKey
+	Reason    string
+	TxHash    []byte
+	RefuseFor int64 // seconds, 0 for ever
+}
+
 type AdminOp struct {
 	ChangedValidators []*agtypes.ValidatorAttr
 	DisconnectedPeers []*p2p.Peer
-	AddRefuseKeys     []crypto.PubKey
-	DeleteRefuseKeys  []crypto.PubKey
+	AddRefuseKeys     []*RefuseChange
+	DeleteRefuseKeys  []*RefuseChange
+	ConsensusParams   *agtypes.ConsensusParams
 	validators        **agtypes.ValidatorSet
+	consensusParams   **agtypes.ConsensusParams
 	sw                *p2p.Switch
 	privkey           crypto.PrivKey
 	refuselist        *refuse_list.RefuseList
 	eventSwitch       agtypes.EventSwitch
+	txHash            []byte // of the admin op being processed
+
+	db        dbm.DB                            // of the proposals
+	height    int64                             // of the block being executed
+	proposals map[string]*agtypes.AdminProposal // the pending ones and the ones closed in the block, by id
+	changed   map[string]bool                   // ids of the proposals to save at EndBlock
 }
 
 func (s *AdminOp) Init(p *InitParams) {
 	s.ChangedValidators = make([]*agtypes.ValidatorAttr, 0)
 	s.DisconnectedPeers = make([]*p2p.Peer, 0)
-	s.AddRefuseKeys = make([]crypto.PubKey, 0)
-	s.DeleteRefuseKeys = make([]crypto.PubKey, 0)
+	s.AddRefuseKeys = make([]*RefuseChange, 0)
+	s.DeleteRefuseKeys = make([]*RefuseChange, 0)
 
 	s.sw = p.Switch
 	s.validators = p.Validators // get initial validatorset from switch, then no more updates from it
+	s.consensusParams = p.ConsensusParams
 	s.privkey = p.PrivKey
 	s.refuselist = p.RefuseList
+	s.db = p.StateDB
+	s.loadProposals()
 }
 
 func (s *AdminOp) Reload(p *ReloadParams) {
 	s.sw = p.Switch
 	s.validators = p.Validators // get initial validatorset from switch, then no more updates from it
+	s.consensusParams = p.ConsensusParams
 	s.privkey = p.PrivKey
 	s.refuselist = p.RefuseList
 	//s.stateDB = p.StateDB
@@ -95,6 +150,7 @@ func (s *AdminOp) DeliverTx(tx []byte, i int) (bool, error) {
 			return false, nil
 		}
 	}
+	s.txHash = agtypes.Tx(tx).Hash()
 	return false, s.ProcessAdminOP(cmd, nil)
 }
 
@@ -105,18 +161,49 @@ func (s *AdminOp) ExecTX(app AdminApp, tx []byte) (error) {
 		log.Error(fmt.Sprintf("AdminOPCmd Unmarshal :%s\ndata=%x", err.Error(), data))
 		return err
 	}
-	if !s.CheckMajor23(cmd) {
+	// proposals and votes gather the validators' approval on chain
+	if cmd.CmdType != agtypes.AdminOpPropose && cmd.CmdType != agtypes.AdminOpProposalVote && !isReplayed(app) && !s.CheckMajor23(cmd) {
 		log.Error("need more than 2/3 total voting power")
 		return fmt.Errorf("need more than 2/3 total voting power")
 	}
+	if sapp, ok := app.(SimulatedApp); ok && sapp.Simulated() {
+		return s.simulateAdminOP(cmd, app)
+	}
+	s.txHash = nil
+	if h, ok := app.(AdminTxHasher); ok {
+		s.txHash = h.TxHash()
+	}
+	if len(s.txHash) == 0 {
+		s.txHash = agtypes.Tx(tx).Hash()
+	}
 	return s.ProcessAdminOP(cmd, app)
 }
 
+// isReplayed reports whether app replays a simulated committed tx, which
+// passed the signature check when it was executed
+func isReplayed(app AdminApp) bool {
+	sapp, ok := app.(SimulatedApp)
+	rapp, rok := app.(ReplayedApp)
+	return ok && sapp.Simulated() && rok && rapp.Replayed()
+}
+
+// simulateAdminOP runs the part of an op changing the state of app, the
+// validators, consensus params and proposals are left as they are
+func (s *AdminOp) simulateAdminOP(cmd *agtypes.AdminOPCmd, app AdminApp) error {
+	switch cmd.CmdType {
+	case agtypes.AdminOpChangePermissions:
+		return s.processPermissions(cmd, app)
+	case agtypes.AdminOpFreezeAccounts:
+		return s.processFreeze(cmd, app)
+	}
+	return nil
+}
+
 func (s *AdminOp) ExecBlock(p *ExecBlockParams) (*ExecBlockReturns, error) {
 	// Run ExTxs of block
 	for i, tx := range p.Block.Data.ExTxs {
 		if _, err := s.DeliverTx(tx, i); err != nil {
-			return nil, fmt.Errorf("[Plugin AdminOp ExecBlock]:%s", zap.Error(err))
+			return nil, fmt.Errorf("[Plugin AdminOp ExecBlock]:%v", err)
 		}
 	}
 
@@ -124,11 +211,17 @@ func (s *AdminOp) ExecBlock(p *ExecBlockParams) (*ExecBlockReturns, error) {
 }
 
 func (s *AdminOp) BeginBlock(p *BeginBlockParams) (*BeginBlockReturns, error) {
+	if p.Block != nil {
+		s.height = p.Block.Height
+		s.restageProposals()
+	}
 	return nil, nil
 }
 
 func (s *AdminOp) EndBlock(p *EndBlockParams) (*EndBlockReturns, error) {
 	defer s.Reset()
+	// proposals passing stage their op like the ones signed off chain
+	s.closeProposals()
 	changedValidators := make([]*agtypes.ValidatorAttr, 0, len(s.ChangedValidators)+len(p.ChangedValidators))
 	copy(changedValidators, p.ChangedValidators)
 	for _, v := range s.ChangedValidators {
@@ -147,6 +240,9 @@ func (s *AdminOp) EndBlock(p *EndBlockParams) (*EndBlockReturns, error) {
 	if err != nil {
 		return &EndBlockReturns{NextValidatorSet: p.NextValidatorSet}, err
 	}
+	if s.ConsensusParams != nil && p.NextConsensusParams != nil {
+		*p.NextConsensusParams = *s.ConsensusParams
+	}
 
 	// s.validators is a ** pointing to *(state.validators)
 	// update validatorset in out plugin & switch
@@ -158,17 +254,35 @@ func (s *AdminOp) EndBlock(p *EndBlockParams) (*EndBlockReturns, error) {
 		s.sw.StopPeerGracefully(peer)
 	}
 
-	if len(s.AddRefuseKeys) > 0 {
-		for _, k := range s.AddRefuseKeys {
-			s.refuselist.AddRefuseKey(k.Bytes())
-		}
+	if s.refuselist != nil {
+		s.updateRefuseList(p.Block)
 	}
-	if len(s.DeleteRefuseKeys) > 0 {
-		for _, k := range s.DeleteRefuseKeys {
-			s.refuselist.DeleteRefuseKey(k.Bytes())
+	return &EndBlockReturns{NextValidatorSet: p.NextValidatorSet}, nil
+}
+
+// updateRefuseList applies the staged refuse list changes as of block, entries
+// expire RefuseFor after the block time
+func (s *AdminOp) updateRefuseList(block *agtypes.Block) {
+	var (
+		height  int64
+		addedAt = time.Now()
+	)
+	if block != nil {
+		height = block.Height
+		addedAt = block.Time
+	}
+	for _, c := range s.AddRefuseKeys {
+		e := &refuse_list.Entry{Reason: c.Reason, Height: height, TxHash: fmt.Sprintf("%X", c.TxHash), AddedAt: addedAt}
+		if c.RefuseFor > 0 {
+			expiry := addedAt.Add(time.Duration(c.RefuseFor) * time.Second)
+			e.Expiry = &expiry
 		}
+		s.refuselist.AddEntry(c.PubKey.Bytes(), e)
 	}
-	return &EndBlockReturns{NextValidatorSet: p.NextValidatorSet}, nil
+	for _, c := range s.DeleteRefuseKeys {
+		s.refuselist.DeleteEntry(c.PubKey.Bytes(), &refuse_list.Entry{Reason: c.Reason, Height: height, TxHash: fmt.Sprintf("%X", c.TxHash)})
+	}
+	s.refuselist.Expire(addedAt)
 }
 
 func (s *AdminOp) Reset() {
@@ -176,26 +290,36 @@ func (s *AdminOp) Reset() {
 	s.DisconnectedPeers = s.DisconnectedPeers[:0]
 	s.AddRefuseKeys = s.AddRefuseKeys[:0]
 	s.DeleteRefuseKeys = s.DeleteRefuseKeys[:0]
+	s.ConsensusParams = nil
 }
 
 // if power <=0, node is a peer, else if power > 0, node is a validator
 // addnode: change node to peer
 // update: change power value so that node to be validator
 // remove: remove node from validators
+// app is nil for the ops of passed proposals, their account was checked
+// when they were proposed.
 func (s *AdminOp) ProcessAdminOP(cmd *agtypes.AdminOPCmd, app AdminApp) error {
-	if cmd.CmdType != agtypes.AdminOpChangeValidator {
+	switch cmd.CmdType {
+	case agtypes.AdminOpChangeConsensusParams:
+		return s.processConsensusParams(cmd, app)
+	case agtypes.AdminOpPropose:
+		return s.processProposal(cmd, app)
+	case agtypes.AdminOpProposalVote:
+		return s.processProposalVote(cmd, app)
+	case agtypes.AdminOpChangePermissions:
+		return s.processPermissions(cmd, app)
+	case agtypes.AdminOpFreezeAccounts:
+		return s.processFreeze(cmd, app)
+	case agtypes.AdminOpChangeValidator:
+	default:
 		return errors.New("unsupported admin operation")
 	}
 	vAttr, err := s.ParseValidator(cmd)
 	if err != nil {
 		return fmt.Errorf("parse validator err:%v", err)
 	}
-	if !bytes.Equal(app.From(), vAttr.Addr) {
-		return fmt.Errorf("verify nonce err")
-	}
-	nonce := app.GetNonce()
-	if vAttr.Nonce+1 != nonce {
-		err = fmt.Errorf("admin nonce error:need(%d) gived(%d)", nonce, vAttr.Nonce)
+	if err = checkAdminAccount(app, vAttr.Addr, vAttr.Nonce); err != nil {
 		return err
 	}
 
@@ -214,7 +338,7 @@ func (s *AdminOp) ProcessAdminOP(cmd *agtypes.AdminOPCmd, app AdminApp) error {
 			return nil
 		}
 		s.ChangedValidators = append(s.ChangedValidators, vAttr)
-		s.DeleteRefuseKeys = append(s.DeleteRefuseKeys, msgPubKey)
+		s.DeleteRefuseKeys = append(s.DeleteRefuseKeys, s.refuseChange(msgPubKey, vAttr))
 		return nil
 	case agtypes.ValidatorCmdUpdateNode:
 		_, val := (*s.validators).GetByAddress(msgPubKey.Address())
@@ -227,7 +351,7 @@ func (s *AdminOp) ProcessAdminOP(cmd *agtypes.AdminOPCmd, app AdminApp) error {
 			return nil
 		}
 		s.ChangedValidators = append(s.ChangedValidators, vAttr)
-		s.DeleteRefuseKeys = append(s.DeleteRefuseKeys, msgPubKey)
+		s.DeleteRefuseKeys = append(s.DeleteRefuseKeys, s.refuseChange(msgPubKey, vAttr))
 		return nil
 	case agtypes.ValidatorCmdRemoveNode:
 		if !(*s.validators).HasAddress(msgPubKey.Address()) {
@@ -235,15 +359,14 @@ func (s *AdminOp) ProcessAdminOP(cmd *agtypes.AdminOPCmd, app AdminApp) error {
 		}
 		s.ChangedValidators = append(s.ChangedValidators, vAttr)
 		//disconnect;
-		sw := *(s.sw)
-		peers := sw.Peers().List()
+		peers := s.sw.Peers().List()
 		for _, peer := range peers {
 			if peer.NodeInfo.PubKey == msgPubKey {
 				s.DisconnectedPeers = append(s.DisconnectedPeers, peer)
 				break
 			}
 		}
-		s.AddRefuseKeys = append(s.AddRefuseKeys, msgPubKey)
+		s.AddRefuseKeys = append(s.AddRefuseKeys, s.refuseChange(msgPubKey, vAttr))
 		return nil
 	default:
 		return errors.New("unsupported admin operation:" + string(vAttr.Cmd))
@@ -252,6 +375,125 @@ func (s *AdminOp) ProcessAdminOP(cmd *agtypes.AdminOPCmd, app AdminApp) error {
 	return nil
 }
 
+func (s *AdminOp) refuseChange(pubKey crypto.PubKey, vAttr *agtypes.ValidatorAttr) *RefuseChange {
+	reason := vAttr.Reason
+	if reason == "" {
+		reason = "admin " + string(vAttr.Cmd)
+	}
+	return &RefuseChange{PubKey: pubKey, Reason: reason, TxHash: s.txHash, RefuseFor: vAttr.RefuseFor}
+}
+
+// processConsensusParams stages new consensus params, EndBlock hands them to
+// the state so they apply from the next height on
+func (s *AdminOp) processConsensusParams(cmd *agtypes.AdminOPCmd, app AdminApp) error {
+	attr := &agtypes.ConsensusParamsAttr{}
+	if _, err := cmd.ExtractMsg(attr); err != nil {
+		return fmt.Errorf("parse consensus params err:%v", err)
+	}
+	if err := checkAdminAccount(app, attr.Addr, attr.Nonce); err != nil {
+		return err
+	}
+	if attr.Params == nil {
+		return errors.New("change consensus params nil")
+	}
+	var prev *agtypes.ConsensusParams
+	if s.consensusParams != nil {
+		prev = *s.consensusParams
+	}
+	if err := attr.Params.ValidateChange(prev, s.height); err != nil {
+		return fmt.Errorf("invalid consensus params:%v", err)
+	}
+	s.ConsensusParams = attr.Params
+	return nil
+}
+
+// processPermissions changes the permission table of app right away, so the
+// next txs of the block already follow it
+func (s *AdminOp) processPermissions(cmd *agtypes.AdminOPCmd, app AdminApp) error {
+	if app == nil {
+		return errors.New("permission changes come in txs")
+	}
+	attr := &agtypes.PermissionsAttr{}
+	if _, err := cmd.ExtractMsg(attr); err != nil {
+		return fmt.Errorf("parse permissions err:%v", err)
+	}
+	if err := checkAdminAccount(app, attr.Addr, attr.Nonce); err != nil {
+		return err
+	}
+	papp, ok := app.(PermissionApp)
+	if !ok {
+		return errors.New("the app keeps no permission table")
+	}
+	for _, p := range attr.Restrict {
+		if err := papp.SetRestricted(p, true); err != nil {
+			return err
+		}
+	}
+	for _, p := range attr.Unrestrict {
+		if err := papp.SetRestricted(p, false); err != nil {
+			return err
+		}
+	}
+	for _, addr := range attr.Accounts {
+		for _, p := range attr.Grant {
+			if err := papp.SetPermission(addr, p, true); err != nil {
+				return err
+			}
+		}
+		for _, p := range attr.Revoke {
+			if err := papp.SetPermission(addr, p, false); err != nil {
+				return err
+			}
+		}
+	}
+	log.Info("AdminOp change permissions", zap.Strings("restrict", attr.Restrict), zap.Strings("unrestrict", attr.Unrestrict),
+		zap.Strings("grant", attr.Grant), zap.Strings("revoke", attr.Revoke), zap.Int("accounts", len(attr.Accounts)))
+	return nil
+}
+
+// processFreeze freezes or unfreezes the accounts of app right away
+func (s *AdminOp) processFreeze(cmd *agtypes.AdminOPCmd, app AdminApp) error {
+	if app == nil {
+		return errors.New("freezes come in txs")
+	}
+	attr := &agtypes.FreezeAttr{}
+	if _, err := cmd.ExtractMsg(attr); err != nil {
+		return fmt.Errorf("parse freeze err:%v", err)
+	}
+	if err := checkAdminAccount(app, attr.Addr, attr.Nonce); err != nil {
+		return err
+	}
+	fapp, ok := app.(FreezeApp)
+	if !ok {
+		return errors.New("the app can't freeze accounts")
+	}
+	if len(attr.Accounts) == 0 {
+		return errors.New("freeze without accounts")
+	}
+	for _, addr := range attr.Accounts {
+		if err := fapp.SetFrozen(addr, attr.Freeze, attr.Reason); err != nil {
+			return err
+		}
+	}
+	log.Info("AdminOp freeze accounts", zap.Bool("freeze", attr.Freeze), zap.Int("accounts", len(attr.Accounts)), zap.String("reason", attr.Reason))
+	return nil
+}
+
+// checkAdminAccount checks that the op of addr and nonce comes in a tx of
+// app, a nil app skips the check
+func checkAdminAccount(app AdminApp, addr []byte, nonce uint64) error {
+	if app == nil {
+		return nil
+	}
+	if !bytes.Equal(app.From(), addr) {
+		return fmt.Errorf("verify nonce err")
+	}
+	if need := app.GetNonce(); nonce+1 != need {
+		return fmt.Errorf("admin nonce error:need(%d) gived(%d)", need, nonce)
+	}
+	return nil
+}
+
 func (s *AdminOp) CheckMajor23(cmd *agtypes.AdminOPCmd) bool {
 	msg := cmd.Msg
 	var major23 int64
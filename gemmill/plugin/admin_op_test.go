@@ -0,0 +1,180 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dappledger/AnnChain/gemmill/go-crypto"
+	"github.com/dappledger/AnnChain/gemmill/types"
+)
+
+type testPermissionApp struct {
+	testAdminApp
+	restricted map[string]bool
+	granted    map[string][]string
+}
+
+func (app *testPermissionApp) SetRestricted(permission string, restricted bool) error {
+	app.restricted[permission] = restricted
+	return nil
+}
+
+func (app *testPermissionApp) SetPermission(addr []byte, permission string, granted bool) error {
+	if granted {
+		app.granted[string(addr)] = append(app.granted[string(addr)], permission)
+	}
+	return nil
+}
+
+func TestAdminPermissions(t *testing.T) {
+	privs := []crypto.PrivKey{crypto.GenNodePrivKey(), crypto.GenNodePrivKey(), crypto.GenNodePrivKey()}
+	vals := make([]*types.Validator, len(privs))
+	for i := range privs {
+		vals[i] = types.NewValidator(privs[i].PubKey(), 1, false)
+	}
+	vset := types.NewValidatorSet(vals)
+	s := &AdminOp{}
+	s.Init(&InitParams{Validators: &vset})
+
+	attr := &types.PermissionsAttr{Restrict: []string{"deploy"}, Grant: []string{"deploy"}, Accounts: [][]byte{[]byte("deployer")}, Addr: []byte("admin")}
+	app := &testPermissionApp{
+		testAdminApp: testAdminApp{from: []byte("admin"), nonce: 1},
+		restricted:   make(map[string]bool),
+		granted:      make(map[string][]string),
+	}
+	tx := func(signers ...crypto.PrivKey) []byte {
+		data, _ := json.Marshal(signAdminCmd(t, types.AdminOpChangePermissions, attr, signers...))
+		return types.TagAdminOPTx(data)
+	}
+
+	if err := s.ExecTX(app, tx(privs[0], privs[1])); err == nil {
+		t.Fatal("expected permission changes to need more than 2/3 of the validators")
+	}
+	if err := s.ExecTX(&app.testAdminApp, tx(privs...)); err == nil {
+		t.Fatal("expected an app without permission table to fail")
+	}
+	if err := s.ExecTX(app, tx(privs...)); err != nil {
+		t.Fatal(err)
+	}
+	if !app.restricted["deploy"] || len(app.granted["deployer"]) != 1 {
+		t.Fatalf("unexpected permissions %v %v", app.restricted, app.granted)
+	}
+}
+
+type testFreezeApp struct {
+	testAdminApp
+	frozen map[string]string
+}
+
+func (app *testFreezeApp) SetFrozen(addr []byte, frozen bool, reason string) error {
+	if frozen {
+		app.frozen[string(addr)] = reason
+	} else {
+		delete(app.frozen, string(addr))
+	}
+	return nil
+}
+
+func TestAdminFreeze(t *testing.T) {
+	priv := crypto.GenNodePrivKey()
+	vset := types.NewValidatorSet([]*types.Validator{types.NewValidator(priv.PubKey(), 1, false)})
+	s := &AdminOp{}
+	s.Init(&InitParams{Validators: &vset})
+
+	app := &testFreezeApp{testAdminApp: testAdminApp{from: []byte("admin"), nonce: 1}, frozen: make(map[string]string)}
+	freeze := func(accounts [][]byte, frozen bool) error {
+		attr := &types.FreezeAttr{Accounts: accounts, Freeze: frozen, Reason: "court order", Addr: []byte("admin")}
+		data, _ := json.Marshal(signAdminCmd(t, types.AdminOpFreezeAccounts, attr, priv))
+		return s.ExecTX(app, types.TagAdminOPTx(data))
+	}
+	if err := freeze(nil, true); err == nil {
+		t.Fatal("expected a freeze without accounts to fail")
+	}
+	if err := freeze([][]byte{[]byte("a"), []byte("b")}, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := freeze([][]byte{[]byte("a")}, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(app.frozen) != 1 || app.frozen["b"] != "court order" {
+		t.Fatalf("expected b frozen, got %v", app.frozen)
+	}
+}
+
+type testSimulatedApp struct {
+	testFreezeApp
+}
+
+func (app *testSimulatedApp) Simulated() bool { return true }
+
+func TestAdminSimulated(t *testing.T) {
+	priv := crypto.GenNodePrivKey()
+	vset := types.NewValidatorSet([]*types.Validator{types.NewValidator(priv.PubKey(), 1, false)})
+	s := &AdminOp{}
+	s.Init(&InitParams{Validators: &vset})
+
+	app := &testSimulatedApp{testFreezeApp{testAdminApp: testAdminApp{from: []byte("admin"), nonce: 1, txHash: []byte("tx")}, frozen: make(map[string]string)}}
+	exec := func(cmdType string, attr interface{}) error {
+		data, _ := json.Marshal(signAdminCmd(t, cmdType, attr, priv))
+		return s.ExecTX(app, types.TagAdminOPTx(data))
+	}
+
+	// the state changes still apply to the app
+	if err := exec(types.AdminOpFreezeAccounts, &types.FreezeAttr{Accounts: [][]byte{[]byte("a")}, Freeze: true, Addr: []byte("admin")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := app.frozen["a"]; !ok {
+		t.Fatal("expected a frozen in the simulated state")
+	}
+	// nothing is staged for EndBlock
+	params := &types.ConsensusParams{BlockSize: 1000, BlockPartSize: 100, TimeoutPropose: 1, TimeoutPrevote: 1, TimeoutPrecommit: 1}
+	if err := exec(types.AdminOpChangeConsensusParams, &types.ConsensusParamsAttr{Params: params, Addr: []byte("admin")}); err != nil {
+		t.Fatal(err)
+	}
+	if s.ConsensusParams != nil || len(s.ChangedValidators) != 0 || s.txHash != nil {
+		t.Fatal("expected a simulated op to stage nothing")
+	}
+}
+
+type testReplayedApp struct {
+	testSimulatedApp
+}
+
+func (app *testReplayedApp) Replayed() bool { return true }
+
+func TestAdminReplayed(t *testing.T) {
+	// the op was signed by the validator of its block, replaced since
+	signer := crypto.GenNodePrivKey()
+	vset := types.NewValidatorSet([]*types.Validator{types.NewValidator(crypto.GenNodePrivKey().PubKey(), 1, false)})
+	s := &AdminOp{}
+	s.Init(&InitParams{Validators: &vset})
+
+	freeze := &types.FreezeAttr{Accounts: [][]byte{[]byte("a")}, Freeze: true, Addr: []byte("admin")}
+	data, _ := json.Marshal(signAdminCmd(t, types.AdminOpFreezeAccounts, freeze, signer))
+
+	simulated := &testSimulatedApp{testFreezeApp{testAdminApp: testAdminApp{from: []byte("admin"), nonce: 1}, frozen: make(map[string]string)}}
+	if err := s.ExecTX(simulated, types.TagAdminOPTx(data)); err == nil {
+		t.Fatal("expected a call signed by no validator to fail")
+	}
+	replayed := &testReplayedApp{testSimulatedApp{testFreezeApp{testAdminApp: testAdminApp{from: []byte("admin"), nonce: 1}, frozen: make(map[string]string)}}}
+	if err := s.ExecTX(replayed, types.TagAdminOPTx(data)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := replayed.frozen["a"]; !ok {
+		t.Fatal("expected a frozen in the replayed state")
+	}
+}
@@ -0,0 +1,327 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"go.uber.org/zap"
+
+	"github.com/dappledger/AnnChain/gemmill/go-crypto"
+	"github.com/dappledger/AnnChain/gemmill/modules/go-log"
+	agtypes "github.com/dappledger/AnnChain/gemmill/types"
+)
+
+var (
+	proposalKeyPrefix       = []byte("proposal:")
+	proposalClosedKeyPrefix = []byte("proposal-closed:")
+)
+
+func proposalKey(id string) []byte {
+	return append(append([]byte(nil), proposalKeyPrefix...), id...)
+}
+
+// proposalClosedKey keeps the ids of the proposals closed at height
+func proposalClosedKey(height int64) []byte {
+	return append(append([]byte(nil), proposalClosedKeyPrefix...), fmt.Sprintf("%d", height)...)
+}
+
+// loadProposals reads the pending proposals back, the closed ones stay in
+// the db for Proposal and Proposals
+func (s *AdminOp) loadProposals() {
+	s.proposals = make(map[string]*agtypes.AdminProposal)
+	s.changed = make(map[string]bool)
+	if s.db == nil {
+		return
+	}
+	for _, p := range s.Proposals("") {
+		if p.Status == agtypes.AdminProposalPending {
+			s.proposals[p.ID] = p
+		}
+	}
+}
+
+// restageProposals puts the proposals closed at the current height back to
+// pending. They are saved at EndBlock before the block is committed, so a
+// crash can leave them closed while the height is replayed on restart, and
+// an approved op must be staged again.
+func (s *AdminOp) restageProposals() {
+	if s.db == nil {
+		return
+	}
+	data := s.db.Get(proposalClosedKey(s.height))
+	if len(data) == 0 {
+		return
+	}
+	var ids []string
+	if err := json.Unmarshal(data, &ids); err != nil {
+		log.Error("AdminOp decode closed proposals", zap.Int64("height", s.height), zap.Error(err))
+		return
+	}
+	for _, id := range ids {
+		p := s.Proposal(id)
+		if p == nil || p.ClosedAt != s.height {
+			continue
+		}
+		p.Status, p.ClosedAt, p.Error = agtypes.AdminProposalPending, 0, ""
+		s.proposals[id] = p
+		log.Info("AdminOp proposal restaged", zap.String("id", id), zap.Int64("height", s.height))
+	}
+}
+
+// Proposal returns the proposal of id as of the last block, nil if there is
+// none. Like Proposals it only reads the db, so rpcs can call it.
+func (s *AdminOp) Proposal(id string) *agtypes.AdminProposal {
+	if s.db == nil {
+		return nil
+	}
+	data := s.db.Get(proposalKey(id))
+	if len(data) == 0 {
+		return nil
+	}
+	p := &agtypes.AdminProposal{}
+	if err := json.Unmarshal(data, p); err != nil {
+		log.Error("AdminOp decode proposal", zap.String("id", id), zap.Error(err))
+		return nil
+	}
+	return p
+}
+
+// Proposals returns the proposals of status as of the last block, all of
+// them if it is empty, by height
+func (s *AdminOp) Proposals(status string) []*agtypes.AdminProposal {
+	var proposals []*agtypes.AdminProposal
+	if s.db == nil {
+		return proposals
+	}
+	iter := s.db.Iterator()
+	for iter.Next() {
+		if len(iter.Key()) <= len(proposalKeyPrefix) || string(iter.Key()[:len(proposalKeyPrefix)]) != string(proposalKeyPrefix) {
+			continue
+		}
+		p := &agtypes.AdminProposal{}
+		if err := json.Unmarshal(iter.Value(), p); err != nil {
+			continue
+		}
+		if status == "" || p.Status == status {
+			proposals = append(proposals, p)
+		}
+	}
+	sort.Slice(proposals, func(i, j int) bool {
+		if proposals[i].Height != proposals[j].Height {
+			return proposals[i].Height < proposals[j].Height
+		}
+		return proposals[i].ID < proposals[j].ID
+	})
+	return proposals
+}
+
+// processProposal opens a proposal for the op of cmd, the validators which
+// signed cmd approve it
+func (s *AdminOp) processProposal(cmd *agtypes.AdminOPCmd, app AdminApp) error {
+	if app == nil {
+		return errors.New("proposals come in txs")
+	}
+	if s.db == nil {
+		return errors.New("proposals need the db of adminOp")
+	}
+	attr := &agtypes.AdminProposalAttr{}
+	if _, err := cmd.ExtractMsg(attr); err != nil {
+		return fmt.Errorf("parse proposal err:%v", err)
+	}
+	if err := checkAdminAccount(app, attr.Addr, attr.Nonce); err != nil {
+		return err
+	}
+	if attr.Op == nil {
+		return errors.New("proposal without op")
+	}
+	if err := s.checkProposedOp(attr.Op); err != nil {
+		return fmt.Errorf("invalid proposal op:%v", err)
+	}
+	id := fmt.Sprintf("%X", s.txHash)
+	if _, ok := s.proposals[id]; ok || s.Proposal(id) != nil {
+		// ids are tx hashes, the block is replayed
+		log.Info("AdminOp proposal exists", zap.String("id", id))
+		return nil
+	}
+	period := attr.VotingPeriod
+	if period <= 0 {
+		period = agtypes.DefaultAdminProposalVotingPeriod
+	}
+	op := *attr.Op
+	op.SInfos = nil
+	p := &agtypes.AdminProposal{
+		ID:          id,
+		Op:          &op,
+		Description: attr.Description,
+		Proposer:    app.From(),
+		Height:      s.height,
+		Deadline:    s.height + period,
+		Status:      agtypes.AdminProposalPending,
+	}
+	if s.voteProposal(p, cmd, true) == 0 {
+		return errors.New("proposal signed by no validator")
+	}
+	s.proposals[id] = p
+	s.changed[id] = true
+	log.Info("AdminOp proposal", zap.String("id", id), zap.String("op", op.CmdType), zap.Int64("deadline", p.Deadline))
+	return nil
+}
+
+// checkProposedOp checks what can be of op before it is voted, the rest is
+// checked when it is executed. Passed proposals are executed at EndBlock,
+// out of any tx, so the ops changing the app state can't be proposed.
+func (s *AdminOp) checkProposedOp(op *agtypes.AdminOPCmd) error {
+	switch op.CmdType {
+	case agtypes.AdminOpChangeValidator:
+		vAttr, err := s.ParseValidator(op)
+		if err != nil {
+			return err
+		}
+		if vAttr.Cmd == agtypes.ValidatorCmdAddPeer {
+			nodePub := crypto.SetNodePubkey(vAttr.PubKey)
+			if !nodePub.VerifyBytes(op.Msg, crypto.SetNodeSignature(op.SelfSign)) {
+				return errors.New("self verify failed")
+			}
+		}
+		return nil
+	case agtypes.AdminOpChangeConsensusParams:
+		attr := &agtypes.ConsensusParamsAttr{}
+		if _, err := op.ExtractMsg(attr); err != nil {
+			return err
+		}
+		if attr.Params == nil {
+			return errors.New("change consensus params nil")
+		}
+		return attr.Params.ValidateBasic()
+	case agtypes.AdminOpChangePermissions, agtypes.AdminOpFreezeAccounts:
+		return errors.New(op.CmdType + " changes the app state in its own tx, it needs +2/3 signatures and can't be proposed")
+	default:
+		return errors.New("unsupported admin operation:" + op.CmdType)
+	}
+}
+
+// processProposalVote records the votes of the validators which signed cmd
+func (s *AdminOp) processProposalVote(cmd *agtypes.AdminOPCmd, app AdminApp) error {
+	if app == nil {
+		return errors.New("proposal votes come in txs")
+	}
+	attr := &agtypes.AdminProposalVoteAttr{}
+	if _, err := cmd.ExtractMsg(attr); err != nil {
+		return fmt.Errorf("parse proposal vote err:%v", err)
+	}
+	if err := checkAdminAccount(app, attr.Addr, attr.Nonce); err != nil {
+		return err
+	}
+	p, ok := s.proposals[attr.ProposalID]
+	if !ok {
+		closed := s.Proposal(attr.ProposalID)
+		if closed == nil {
+			return fmt.Errorf("no proposal %s", attr.ProposalID)
+		}
+		if closed.ClosedAt >= s.height {
+			// the block is replayed
+			return nil
+		}
+		return fmt.Errorf("proposal %s is %s", closed.ID, closed.Status)
+	}
+	if p.Status != agtypes.AdminProposalPending {
+		return fmt.Errorf("proposal %s is %s", p.ID, p.Status)
+	}
+	if s.height > p.Deadline {
+		return fmt.Errorf("proposal %s expired at %d", p.ID, p.Deadline)
+	}
+	if s.voteProposal(p, cmd, attr.Approve) == 0 {
+		return errors.New("proposal vote signed by no validator")
+	}
+	s.changed[p.ID] = true
+	return nil
+}
+
+// voteProposal records the vote of the validators signing cmd and returns
+// how many there are
+func (s *AdminOp) voteProposal(p *agtypes.AdminProposal, cmd *agtypes.AdminOPCmd, approve bool) int {
+	var n int
+	for _, sig := range cmd.SInfos {
+		pubKey := crypto.SetNodePubkey(sig.PubKey)
+		if !s.isValidatorPubKey(pubKey) || !pubKey.VerifyBytes(cmd.Msg, crypto.SetNodeSignature(sig.Signature)) {
+			log.Warn(fmt.Sprintf("node(%s) is not validator or signed wrong", pubKey.KeyString()))
+			continue
+		}
+		p.Vote(pubKey.Address(), approve, s.height)
+		n++
+	}
+	return n
+}
+
+// closeProposals executes the pending proposals approved by more than 2/3
+// of the voting power, rejects the ones which can't be anymore and expires
+// the ones past their deadline, then saves the changed ones.
+func (s *AdminOp) closeProposals() {
+	if s.db == nil {
+		return
+	}
+	ids := make([]string, 0, len(s.proposals))
+	for id := range s.proposals {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	total := (*s.validators).TotalVotingPower()
+	var closed []string
+	for _, id := range ids {
+		p := s.proposals[id]
+		approve, reject := p.Tally(*s.validators)
+		switch {
+		case approve > total*2/3:
+			p.Status = agtypes.AdminProposalExecuted
+			// the refuse list points to the proposal for the op
+			s.txHash, _ = hex.DecodeString(p.ID)
+			if err := s.ProcessAdminOP(p.Op, nil); err != nil {
+				p.Status = agtypes.AdminProposalFailed
+				p.Error = err.Error()
+			}
+		case reject >= total-total*2/3:
+			p.Status = agtypes.AdminProposalRejected
+		case s.height >= p.Deadline:
+			p.Status = agtypes.AdminProposalExpired
+		default:
+			continue
+		}
+		p.ClosedAt = s.height
+		s.changed[id] = true
+		closed = append(closed, id)
+		log.Info("AdminOp proposal closed", zap.String("id", id), zap.String("status", p.Status), zap.Int64("approve", approve), zap.Int64("reject", reject))
+	}
+
+	batch := s.db.NewBatch()
+	for id := range s.changed {
+		p := s.proposals[id]
+		data, _ := json.Marshal(p)
+		batch.Set(proposalKey(id), data)
+		if p.Status != agtypes.AdminProposalPending {
+			delete(s.proposals, id)
+		}
+	}
+	if len(closed) > 0 {
+		data, _ := json.Marshal(closed)
+		batch.Set(proposalClosedKey(s.height), data)
+	}
+	batch.Write()
+	s.changed = make(map[string]bool)
+}
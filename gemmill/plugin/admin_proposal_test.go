@@ -0,0 +1,184 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/dappledger/AnnChain/gemmill/go-crypto"
+	dbm "github.com/dappledger/AnnChain/gemmill/modules/go-db"
+	"github.com/dappledger/AnnChain/gemmill/types"
+)
+
+type testAdminApp struct {
+	from   []byte
+	nonce  uint64
+	txHash []byte
+}
+
+func (app *testAdminApp) GetNonce() uint64 { return app.nonce }
+func (app *testAdminApp) From() []byte     { return app.from }
+func (app *testAdminApp) TxHash() []byte   { return app.txHash }
+
+func signAdminCmd(t *testing.T, cmdType string, attr interface{}, privs ...crypto.PrivKey) *types.AdminOPCmd {
+	msg, err := json.Marshal(attr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := &types.AdminOPCmd{CmdType: cmdType, Msg: msg}
+	for _, priv := range privs {
+		cmd.SInfos = append(cmd.SInfos, types.SigInfo{
+			PubKey:    crypto.GetNodePubkeyBytes(priv.PubKey()),
+			Signature: crypto.GetNodeSigBytes(priv.Sign(msg)),
+		})
+	}
+	return cmd
+}
+
+func TestAdminProposals(t *testing.T) {
+	privs := make([]crypto.PrivKey, 4)
+	vals := make([]*types.Validator, len(privs))
+	for i := range privs {
+		privs[i] = crypto.GenNodePrivKey()
+		vals[i] = types.NewValidator(privs[i].PubKey(), 1, false)
+	}
+	vset := types.NewValidatorSet(vals)
+	dir, err := ioutil.TempDir("", "admin_op")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// the proposals are listed with an iterator, which MemDB lacks
+	db := dbm.NewDB("admin_proposals", dbm.LevelDBBackendStr, dir)
+	defer db.Close()
+	s := &AdminOp{}
+	s.Init(&InitParams{StateDB: db, Validators: &vset})
+
+	account := []byte("admin")
+	var nonce uint64
+	exec := func(height int64, cmd *types.AdminOPCmd, txHash string) error {
+		s.BeginBlock(&BeginBlockParams{Block: &types.Block{Header: &types.Header{Height: height}}})
+		data, _ := json.Marshal(cmd)
+		nonce++
+		return s.ExecTX(&testAdminApp{from: account, nonce: nonce, txHash: []byte(txHash)}, types.TagAdminOPTx(data))
+	}
+	propose := func(height int64, period int64, txHash string, signers ...crypto.PrivKey) string {
+		params := &types.ConsensusParams{BlockSize: 1000, BlockPartSize: 100, TimeoutPropose: 1, TimeoutPrevote: 1, TimeoutPrecommit: 1}
+		op := signAdminCmd(t, types.AdminOpChangeConsensusParams, &types.ConsensusParamsAttr{Params: params})
+		attr := &types.AdminProposalAttr{Op: op, VotingPeriod: period, Addr: account, Nonce: nonce}
+		if err := exec(height, signAdminCmd(t, types.AdminOpPropose, attr, signers...), txHash); err != nil {
+			t.Fatal(err)
+		}
+		return fmt.Sprintf("%X", txHash)
+	}
+	vote := func(height int64, id string, approve bool, signers ...crypto.PrivKey) error {
+		attr := &types.AdminProposalVoteAttr{ProposalID: id, Approve: approve, Addr: account, Nonce: nonce}
+		return exec(height, signAdminCmd(t, types.AdminOpProposalVote, attr, signers...), "vote")
+	}
+
+	// one approval of four at height 1, two more at height 2 pass it
+	passed := propose(1, 0, "passed", privs[0])
+	s.closeProposals()
+	if p := s.Proposal(passed); p == nil || p.Status != types.AdminProposalPending || p.Deadline != 1+types.DefaultAdminProposalVotingPeriod {
+		t.Fatalf("expected a pending proposal, got %+v", p)
+	}
+	if err := vote(2, passed, true, privs[1], privs[2]); err != nil {
+		t.Fatal(err)
+	}
+	s.closeProposals()
+	if p := s.Proposal(passed); p.Status != types.AdminProposalExecuted || p.ClosedAt != 2 || len(p.Votes) != 3 {
+		t.Fatalf("expected the proposal executed at 2, got %+v", p)
+	}
+	if s.ConsensusParams == nil || s.ConsensusParams.BlockSize != 1000 {
+		t.Fatalf("expected the consensus params staged, got %+v", s.ConsensusParams)
+	}
+	if err := vote(3, passed, false, privs[3]); err == nil {
+		t.Fatal("expected a vote on a closed proposal to fail")
+	}
+
+	// two rejections of four leave no way to more than 2/3
+	rejected := propose(3, 0, "rejected", privs[2])
+	if err := vote(3, rejected, false, privs[0], privs[1]); err != nil {
+		t.Fatal(err)
+	}
+	s.closeProposals()
+	if p := s.Proposal(rejected); p.Status != types.AdminProposalRejected {
+		t.Fatalf("expected the proposal rejected, got %+v", p)
+	}
+
+	// validators out of the set don't propose nor vote
+	op := signAdminCmd(t, types.AdminOpChangeConsensusParams, &types.ConsensusParamsAttr{Params: &types.ConsensusParams{BlockSize: 1000, BlockPartSize: 100, TimeoutPropose: 1, TimeoutPrevote: 1, TimeoutPrecommit: 1}})
+	attr := &types.AdminProposalAttr{Op: op, Addr: account, Nonce: nonce}
+	if err := exec(4, signAdminCmd(t, types.AdminOpPropose, attr, crypto.GenNodePrivKey()), "unsigned"); err == nil || !strings.Contains(err.Error(), "no validator") {
+		t.Fatalf("expected a proposal of no validator to fail, got %v", err)
+	}
+	expired := propose(4, 2, "expired", privs[0])
+
+	// permission and freeze ops change the app state in their tx
+	freeze := signAdminCmd(t, types.AdminOpFreezeAccounts, &types.FreezeAttr{Accounts: [][]byte{account}, Freeze: true})
+	attr = &types.AdminProposalAttr{Op: freeze, Addr: account, Nonce: nonce}
+	if err := exec(4, signAdminCmd(t, types.AdminOpPropose, attr, privs[0]), "freeze"); err == nil || !strings.Contains(err.Error(), "can't be proposed") {
+		t.Fatalf("expected a freeze proposal to fail, got %v", err)
+	}
+	if err := vote(4, expired, true, crypto.GenNodePrivKey()); err == nil {
+		t.Fatal("expected a vote of no validator to fail")
+	}
+	s.closeProposals()
+	s.BeginBlock(&BeginBlockParams{Block: &types.Block{Header: &types.Header{Height: 6}}})
+	s.closeProposals()
+	if p := s.Proposal(expired); p.Status != types.AdminProposalExpired || p.ClosedAt != 6 {
+		t.Fatalf("expected the proposal expired at 6, got %+v", p)
+	}
+
+	pending := propose(7, 0, "pending", privs[3])
+	s.closeProposals()
+	reloaded := &AdminOp{}
+	reloaded.Init(&InitParams{StateDB: db, Validators: &vset})
+	if len(reloaded.proposals) != 1 || reloaded.proposals[pending] == nil {
+		t.Fatalf("expected the pending proposal loaded, got %v", reloaded.proposals)
+	}
+	if all := s.Proposals(""); len(all) != 4 || all[0].ID != passed || all[3].ID != pending {
+		t.Fatalf("expected the 4 proposals by height, got %d", len(all))
+	}
+	if got := s.Proposals(types.AdminProposalRejected); len(got) != 1 || got[0].ID != rejected {
+		t.Fatalf("expected the rejected proposal, got %+v", got)
+	}
+
+	// a crash before height 2 was committed replays it, the passed proposal
+	// is saved as executed already but its op has to be staged again
+	replayed := &AdminOp{}
+	replayed.Init(&InitParams{StateDB: db, Validators: &vset})
+	replayed.BeginBlock(&BeginBlockParams{Block: &types.Block{Header: &types.Header{Height: 2}}})
+	if replayed.proposals[passed] == nil {
+		t.Fatal("expected the proposal closed at 2 restaged")
+	}
+	replayed.closeProposals()
+	if replayed.ConsensusParams == nil || replayed.ConsensusParams.BlockSize != 1000 {
+		t.Fatalf("expected the consensus params staged on replay, got %+v", replayed.ConsensusParams)
+	}
+	if p := s.Proposal(passed); p.Status != types.AdminProposalExecuted || p.ClosedAt != 2 {
+		t.Fatalf("expected the proposal executed at 2 again, got %+v", p)
+	}
+	replayed.Reset()
+	replayed.BeginBlock(&BeginBlockParams{Block: &types.Block{Header: &types.Header{Height: 8}}})
+	if len(replayed.proposals) != 1 || replayed.proposals[pending] == nil {
+		t.Fatalf("expected only the pending proposal at 8, got %v", replayed.proposals)
+	}
+}
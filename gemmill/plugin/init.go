@@ -23,6 +23,8 @@ import (
 	"github.com/dappledger/AnnChain/gemmill/p2p"
 	"github.com/dappledger/AnnChain/gemmill/refuse_list"
 	"github.com/dappledger/AnnChain/gemmill/types"
+
+	"github.com/spf13/viper"
 )
 
 const (
@@ -43,6 +45,8 @@ type (
 		PrivKey    crypto.PrivKey
 		RefuseList *refuse_list.RefuseList
 		Validators **types.ValidatorSet
+		// ConsensusParams in effect for the block being executed
+		ConsensusParams **types.ConsensusParams
 	}
 
 	ReloadParams struct {
@@ -52,6 +56,8 @@ type (
 		PrivKey    crypto.PrivKey
 		RefuseList *refuse_list.RefuseList
 		Validators **types.ValidatorSet
+		// ConsensusParams in effect for the block being executed
+		ConsensusParams **types.ConsensusParams
 	}
 
 	BeginBlockParams struct {
@@ -76,6 +82,8 @@ type (
 		Block             *types.Block
 		ChangedValidators []*types.ValidatorAttr
 		NextValidatorSet  *types.ValidatorSet
+		// NextConsensusParams is overwritten by plugins changing consensus params
+		NextConsensusParams *types.ConsensusParams
 	}
 
 	EndBlockReturns struct {
@@ -119,4 +127,22 @@ func init() {
 		struct{ IPlugin }{},
 		wire.ConcreteType{&AdminOp{}, pluginTypeAdminOP},
 	)
+
+	Register("adminOp", &Factory{
+		New: func(*viper.Viper) IPlugin { return &AdminOp{} },
+		DB:  &DBSpec{Dir: "admin_op", Name: "admin_proposals"},
+	})
+	Register("querycache", &Factory{
+		New: func(*viper.Viper) IPlugin { return &QueryCachePlugin{} },
+		DB:  &DBSpec{Dir: "query_cache", Name: "tx_execution_result"},
+	})
+	Register("uptime", &Factory{
+		New: func(conf *viper.Viper) IPlugin {
+			return &UptimePlugin{
+				Window:    conf.GetInt64("uptime_window"),
+				Threshold: conf.GetInt64("uptime_threshold"),
+			}
+		},
+		DB: &DBSpec{Dir: "uptime", Name: "validator_uptime"},
+	})
 }
@@ -51,12 +51,26 @@ func (qc *QueryCachePlugin) EndBlock(p *EndBlockParams) (*EndBlockReturns, error
 }
 
 func (qc *QueryCachePlugin) ExecBlock(p *ExecBlockParams) (*ExecBlockReturns, error) {
+	invalids := make(map[string]string, len(p.InvalidTxs))
+	for _, itx := range p.InvalidTxs {
+		errStr := ""
+		if itx.Error != nil {
+			errStr = itx.Error.Error()
+		}
+		invalids[string(itx.Bytes)] = errStr
+	}
+
 	batch := qc.db.NewBatch()
 	for i, tx := range p.Block.Data.Txs {
 		e := types.TxExecutionResult{
 			Height:    uint64(p.Block.Height),
 			BlockHash: p.Block.Hash(),
 			Index:     uint64(i),
+			Status:    types.TxStatusValid,
+		}
+		if errStr, ok := invalids[string(tx)]; ok {
+			e.Status = types.TxStatusInvalid
+			e.Error = errStr
 		}
 		data, err := e.ToBytes()
 		if err != nil {
@@ -70,9 +84,7 @@ func (qc *QueryCachePlugin) ExecBlock(p *ExecBlockParams) (*ExecBlockReturns, er
 
 func (qc *QueryCachePlugin) Reset() {}
 
-func (qc *QueryCachePlugin) Stop() {
-	qc.db.Close()
-}
+func (qc *QueryCachePlugin) Stop() {}
 
 func (qc *QueryCachePlugin) ExecutionResult(txHash []byte) (*types.TxExecutionResult, error) {
 	item := qc.db.Get(txHash)
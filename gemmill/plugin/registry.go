@@ -0,0 +1,218 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	dbm "github.com/dappledger/AnnChain/gemmill/modules/go-db"
+
+	"github.com/spf13/viper"
+)
+
+type (
+	// Factory declares a core plugin to Register
+	Factory struct {
+		// New makes the plugin out of the node config
+		New func(conf *viper.Viper) IPlugin
+		// Requires are the plugins initialized before this one and stopped
+		// after it, they must be listed in the genesis too
+		Requires []string
+		// DB asks for a db of the plugin's own instead of the state db
+		DB *DBSpec
+	}
+
+	// DBSpec names the db of a plugin, opened as Name in db_dir/Dir
+	DBSpec struct {
+		Dir  string
+		Name string
+	}
+
+	// HealthChecker is implemented by plugins reporting their health to
+	// the plugins rpc, a plugin without it is healthy once initialized
+	HealthChecker interface {
+		Health() error
+	}
+
+	// Loaded is a plugin made by its factory, in the order Load returns
+	Loaded struct {
+		Name     string
+		Plugin   IPlugin
+		Requires []string
+		DB       dbm.DB
+		Running  bool
+	}
+)
+
+var (
+	registryMtx sync.Mutex
+	registry    = make(map[string]*Factory)
+)
+
+// Register makes a core plugin loadable by name from GenesisDoc.Plugins, it
+// panics if the name is taken or the factory makes nothing.
+func Register(name string, factory *Factory) {
+	registryMtx.Lock()
+	defer registryMtx.Unlock()
+	if factory == nil || factory.New == nil {
+		panic("plugin: Register of a nil factory for " + name)
+	}
+	if _, dup := registry[name]; dup {
+		panic("plugin: Register called twice for " + name)
+	}
+	registry[name] = factory
+}
+
+// Registered returns the names of the registered plugins, sorted
+func Registered() []string {
+	registryMtx.Lock()
+	defer registryMtx.Unlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsRegistered tells if a plugin is registered as name
+func IsRegistered(name string) bool {
+	return factoryOf(name) != nil
+}
+
+func factoryOf(name string) *Factory {
+	registryMtx.Lock()
+	defer registryMtx.Unlock()
+	return registry[name]
+}
+
+// Load makes the plugins of names, ordered so that every plugin comes after
+// the ones it requires. Empty and repeated names are skipped.
+func Load(names []string, conf *viper.Viper) ([]*Loaded, error) {
+	factories := make(map[string]*Factory, len(names))
+	listed := make([]string, 0, len(names))
+	for _, name := range names {
+		if name == "" || factories[name] != nil {
+			continue
+		}
+		f := factoryOf(name)
+		if f == nil {
+			return nil, fmt.Errorf("plugin: unknown plugin %s", name)
+		}
+		factories[name] = f
+		listed = append(listed, name)
+	}
+
+	var (
+		loaded   = make([]*Loaded, 0, len(listed))
+		visiting = make(map[string]bool)
+		done     = make(map[string]bool)
+		visit    func(name string) error
+	)
+	visit = func(name string) error {
+		if done[name] {
+			return nil
+		}
+		if visiting[name] {
+			return fmt.Errorf("plugin: %s requires itself", name)
+		}
+		visiting[name] = true
+		f := factories[name]
+		for _, req := range f.Requires {
+			if factories[req] == nil {
+				return fmt.Errorf("plugin: %s requires %s", name, req)
+			}
+			if err := visit(req); err != nil {
+				return err
+			}
+		}
+		done[name] = true
+		loaded = append(loaded, &Loaded{Name: name, Plugin: f.New(conf), Requires: f.Requires})
+		return nil
+	}
+	for _, name := range listed {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return loaded, nil
+}
+
+// Start initializes the loaded plugins in order, the ones asking for a db
+// get it from openDB in their params. A plugin whose db can't be opened, or
+// requiring such a plugin, is left stopped and the first error returned.
+func Start(loaded []*Loaded, params *InitParams, openDB func(*DBSpec) (dbm.DB, error)) error {
+	var firstErr error
+	running := make(map[string]bool, len(loaded))
+	for _, l := range loaded {
+		if l.Running {
+			running[l.Name] = true
+			continue
+		}
+		var err error
+		for _, req := range l.Requires {
+			if !running[req] {
+				err = fmt.Errorf("plugin: %s requires %s which is stopped", l.Name, req)
+				break
+			}
+		}
+		p := *params
+		if spec := factoryOf(l.Name).DB; err == nil && spec != nil {
+			if l.DB, err = openDB(spec); err != nil {
+				err = fmt.Errorf("plugin: %s db: %v", l.Name, err)
+			}
+			p.StateDB = l.DB
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		l.Plugin.Init(&p)
+		l.Running = true
+		running[l.Name] = true
+	}
+	return firstErr
+}
+
+// Stop stops the running plugins in the reverse order of Start and closes
+// the dbs opened for them
+func Stop(loaded []*Loaded) {
+	for i := len(loaded) - 1; i >= 0; i-- {
+		l := loaded[i]
+		if l.Running {
+			l.Plugin.Stop()
+			l.Running = false
+		}
+		if l.DB != nil {
+			l.DB.Close()
+			l.DB = nil
+		}
+	}
+}
+
+// Health returns nil for a running plugin that is healthy
+func (l *Loaded) Health() error {
+	if !l.Running {
+		return fmt.Errorf("not running")
+	}
+	if hc, ok := l.Plugin.(HealthChecker); ok {
+		return hc.Health()
+	}
+	return nil
+}
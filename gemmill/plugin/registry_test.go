@@ -0,0 +1,135 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	dbm "github.com/dappledger/AnnChain/gemmill/modules/go-db"
+
+	"github.com/spf13/viper"
+)
+
+type testPlugin struct {
+	QueryCachePlugin
+	name   string
+	events *[]string
+	db     dbm.DB
+}
+
+func (tp *testPlugin) Init(p *InitParams) {
+	tp.db = p.StateDB
+	*tp.events = append(*tp.events, "init "+tp.name)
+}
+
+func (tp *testPlugin) Stop() {
+	*tp.events = append(*tp.events, "stop "+tp.name)
+}
+
+func (tp *testPlugin) Health() error {
+	if tp.db == nil {
+		return errors.New("no db")
+	}
+	return nil
+}
+
+func registerTestPlugin(events *[]string, name string, db *DBSpec, requires ...string) {
+	Register(name, &Factory{
+		New:      func(*viper.Viper) IPlugin { return &testPlugin{name: name, events: events} },
+		Requires: requires,
+		DB:       db,
+	})
+}
+
+func TestLoadInDependencyOrder(t *testing.T) {
+	var events []string
+	registerTestPlugin(&events, "test-app", nil, "test-index", "test-store")
+	registerTestPlugin(&events, "test-index", &DBSpec{Dir: "index", Name: "index"}, "test-store")
+	registerTestPlugin(&events, "test-store", nil)
+	registerTestPlugin(&events, "test-loop-a", nil, "test-loop-b")
+	registerTestPlugin(&events, "test-loop-b", nil, "test-loop-a")
+
+	if !IsRegistered("uptime") || !IsRegistered("test-app") || IsRegistered("test-none") {
+		t.Fatal("unexpected registry")
+	}
+	if _, err := Load([]string{"test-app", "test-index"}, viper.New()); err == nil {
+		t.Fatal("expected a missing requirement to fail")
+	}
+	if _, err := Load([]string{"test-loop-a", "test-loop-b"}, viper.New()); err == nil {
+		t.Fatal("expected a requirement loop to fail")
+	}
+	if _, err := Load([]string{"test-none"}, viper.New()); err == nil {
+		t.Fatal("expected an unknown plugin to fail")
+	}
+
+	loaded, err := Load([]string{"test-app", "", "test-index", "test-store", "test-app"}, viper.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := dbm.NewMemDB()
+	var opened []*DBSpec
+	openDB := func(spec *DBSpec) (dbm.DB, error) {
+		opened = append(opened, spec)
+		return dbm.NewMemDB(), nil
+	}
+	if err := Start(loaded, &InitParams{StateDB: state}, openDB); err != nil {
+		t.Fatal(err)
+	}
+	if len(opened) != 1 || opened[0].Name != "index" {
+		t.Fatalf("expected only the index db opened, got %v", opened)
+	}
+	if loaded[1].Plugin.(*testPlugin).db == state || loaded[0].Plugin.(*testPlugin).db != state {
+		t.Fatal("expected the index plugin to get a db of its own")
+	}
+	for _, l := range loaded {
+		if err := l.Health(); err != nil {
+			t.Fatalf("%s: %v", l.Name, err)
+		}
+	}
+
+	Stop(loaded)
+	expected := []string{"init test-store", "init test-index", "init test-app", "stop test-app", "stop test-index", "stop test-store"}
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("expected %v, got %v", expected, events)
+	}
+	if err := loaded[0].Health(); err == nil {
+		t.Fatal("expected a stopped plugin to be unhealthy")
+	}
+}
+
+func TestStartSkipsPluginsWithoutDB(t *testing.T) {
+	var events []string
+	registerTestPlugin(&events, "test-broken", &DBSpec{Dir: "broken", Name: "broken"})
+	registerTestPlugin(&events, "test-dependent", nil, "test-broken")
+
+	loaded, err := Load([]string{"test-dependent", "test-broken"}, viper.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Start(loaded, &InitParams{}, func(*DBSpec) (dbm.DB, error) { return nil, errors.New("disk full") })
+	if err == nil {
+		t.Fatal("expected the db error")
+	}
+	for _, l := range loaded {
+		if l.Running {
+			t.Fatalf("expected %s to be left stopped", l.Name)
+		}
+	}
+	if len(events) != 0 {
+		t.Fatalf("expected no plugin initialized, got %v", events)
+	}
+}
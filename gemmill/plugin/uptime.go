@@ -0,0 +1,405 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+
+	"github.com/dappledger/AnnChain/gemmill/go-wire"
+	dbm "github.com/dappledger/AnnChain/gemmill/modules/go-db"
+	"github.com/dappledger/AnnChain/gemmill/types"
+)
+
+const (
+	uptimeHeightKey  = "uptime-height"
+	uptimeWindowKey  = "uptime-window"
+	uptimeCurrentKey = "uptime-current"
+	uptimeValsPrefix = "uptime-vals-"
+	uptimeInfoPrefix = "uptime-info-"
+	uptimeSignPrefix = "uptime-sign-"
+	uptimeSigned     = byte(1)
+	uptimeMissed     = byte(0)
+)
+
+// UptimePlugin records which validators signed the precommits of every
+// committed height, as found in the LastCommit of the next block. Only the
+// marks of the last Window heights are kept, the signing infos count them as
+// the window slides.
+type UptimePlugin struct {
+	// Window is the number of heights the uptime and the threshold apply to,
+	// 0 keeps no marks
+	Window int64
+	// Threshold is the uptime in percent over Window below which
+	// EventStringValidatorUptimeLow fires, 0 disables the event
+	Threshold int64
+
+	db          dbm.DB
+	validators  **types.ValidatorSet
+	eventSwitch types.EventSwitch
+}
+
+func (up *UptimePlugin) Init(p *InitParams) {
+	up.db = p.StateDB
+	up.validators = p.Validators
+	if data := up.db.Get([]byte(uptimeWindowKey)); len(data) != 8 || int64(binary.BigEndian.Uint64(data)) != up.Window {
+		up.rewindow()
+	}
+}
+
+func (up *UptimePlugin) Reload(p *ReloadParams) {
+	up.validators = p.Validators
+}
+
+func (up *UptimePlugin) CheckTx(tx []byte) (bool, error) {
+	return true, nil
+}
+
+func (up *UptimePlugin) DeliverTx(tx []byte, i int) (bool, error) {
+	return true, nil
+}
+
+func (up *UptimePlugin) BeginBlock(p *BeginBlockParams) (*BeginBlockReturns, error) {
+	return nil, nil
+}
+
+// ExecBlock accounts the precommits of the height before p.Block
+func (up *UptimePlugin) ExecBlock(p *ExecBlockParams) (*ExecBlockReturns, error) {
+	block := p.Block
+	if block.Height <= 1 || block.LastCommit == nil {
+		return nil, nil
+	}
+	height := block.Height - 1
+	if height <= up.LastHeight() {
+		// replaying blocks already accounted
+		return nil, nil
+	}
+
+	precommits := block.LastCommit.Precommits
+	lows := make([]types.EventDataValidatorUptimeLow, 0)
+	batch := up.db.NewBatch()
+	for i, addr := range up.signers(height, precommits) {
+		if addr == nil {
+			continue
+		}
+		if low := up.account(batch, addr, height, precommits[i] != nil); low != nil {
+			lows = append(lows, *low)
+		}
+	}
+	batch.Set([]byte(uptimeHeightKey), heightBytes(height))
+	batch.Delete(valsKey(height))
+	batch.Write()
+
+	for _, low := range lows {
+		types.FireEventValidatorUptimeLow(up.eventSwitch, low)
+	}
+	return nil, nil
+}
+
+// EndBlock keeps the validators of the next height, their precommits only
+// show up one block later
+func (up *UptimePlugin) EndBlock(p *EndBlockParams) (*EndBlockReturns, error) {
+	if p.NextValidatorSet == nil {
+		return nil, nil
+	}
+	addrs := make([][]byte, 0, p.NextValidatorSet.Size())
+	for _, v := range p.NextValidatorSet.Validators {
+		addrs = append(addrs, v.Address)
+	}
+	data := wire.BinaryBytes(addrs)
+	batch := up.db.NewBatch()
+	batch.Set(valsKey(p.Block.Height+1), data)
+	batch.Set([]byte(uptimeCurrentKey), data)
+	batch.Write()
+	return nil, nil
+}
+
+func (up *UptimePlugin) Reset() {}
+
+func (up *UptimePlugin) Stop() {}
+
+func (up *UptimePlugin) SetEventSwitch(sw types.EventSwitch) {
+	up.eventSwitch = sw
+}
+
+// LastHeight returns the last height whose precommits are accounted
+func (up *UptimePlugin) LastHeight() int64 {
+	data := up.db.Get([]byte(uptimeHeightKey))
+	if len(data) != 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(data))
+}
+
+// Uptime returns the precommits of the current validators over the last
+// Window heights
+func (up *UptimePlugin) Uptime() (int64, []*types.ValidatorUptime, error) {
+	if up.Window <= 0 {
+		return 0, nil, errors.New("uptime_window is 0, no uptime is kept")
+	}
+	last := up.LastHeight()
+	from := last - up.Window + 1
+	if from < 1 {
+		from = 1
+	}
+
+	addrs := up.currentValidators()
+	uptimes := make([]*types.ValidatorUptime, 0, len(addrs))
+	for _, addr := range addrs {
+		u := &types.ValidatorUptime{
+			Address:    addr,
+			FromHeight: from,
+			ToHeight:   last,
+		}
+		if info := up.signingInfo(addr); info != nil {
+			// a validator left out of the last heights still counts the
+			// marks that slid out of the window since
+			up.slide(nil, info, from-1)
+			u.Signed, u.Missed = info.WindowSigned, info.WindowMissed
+		}
+		u.UptimePermille = types.UptimePermille(u.Signed, u.Missed)
+		uptimes = append(uptimes, u)
+	}
+	return last, uptimes, nil
+}
+
+// SigningInfos returns the signing info of address, or of all the current
+// validators when address is empty
+func (up *UptimePlugin) SigningInfos(address []byte) (int64, []*types.ValidatorSigningInfo, error) {
+	last := up.LastHeight()
+	if len(address) > 0 {
+		info := up.signingInfo(address)
+		if info == nil {
+			return 0, nil, errors.Errorf("no signing info for %X", address)
+		}
+		return last, []*types.ValidatorSigningInfo{info}, nil
+	}
+
+	addrs := up.currentValidators()
+	infos := make([]*types.ValidatorSigningInfo, 0, len(addrs))
+	for _, addr := range addrs {
+		if info := up.signingInfo(addr); info != nil {
+			infos = append(infos, info)
+		}
+	}
+	return last, infos, nil
+}
+
+// signers maps the precommits of height to the addresses expected to sign them
+func (up *UptimePlugin) signers(height int64, precommits []*types.Vote) [][]byte {
+	if data := up.db.Get(valsKey(height)); len(data) > 0 {
+		var addrs [][]byte
+		if err := wire.ReadBinaryBytes(data, &addrs); err == nil && len(addrs) == len(precommits) {
+			return addrs
+		}
+	}
+
+	// heights before the plugin got enabled have no record, take the current
+	// validators when they line up with the votes, or else only the signers
+	addrs := make([][]byte, len(precommits))
+	var vals *types.ValidatorSet
+	if up.validators != nil {
+		vals = *up.validators
+	}
+	fits := vals != nil && vals.Size() == len(precommits)
+	for i, v := range precommits {
+		if v == nil {
+			continue
+		}
+		addrs[i] = v.ValidatorAddress
+		if fits && !bytes.Equal(vals.Validators[i].Address, v.ValidatorAddress) {
+			fits = false
+		}
+	}
+	if fits {
+		for i := range addrs {
+			addrs[i] = vals.Validators[i].Address
+		}
+	}
+	return addrs
+}
+
+// account records one precommit of addr and returns the event to fire when
+// the validator just fell below the threshold
+func (up *UptimePlugin) account(batch dbm.Batch, addr []byte, height int64, signed bool) *types.EventDataValidatorUptimeLow {
+	info := up.signingInfo(addr)
+	if info == nil {
+		info = &types.ValidatorSigningInfo{
+			Address:     addr,
+			StartHeight: height,
+		}
+	}
+	if height <= info.LastHeight {
+		return nil
+	}
+
+	if up.Window > 0 {
+		up.slide(batch, info, height-up.Window)
+	}
+	mark := uptimeSigned
+	if signed {
+		info.SignedBlocks++
+		info.MissedInRow = 0
+	} else {
+		mark = uptimeMissed
+		info.MissedBlocks++
+		info.MissedInRow++
+		info.LastMissedHeight = height
+	}
+	info.LastHeight = height
+
+	var low *types.EventDataValidatorUptimeLow
+	if up.Window > 0 {
+		if signed {
+			info.WindowSigned++
+		} else {
+			info.WindowMissed++
+		}
+		batch.Set(signKey(addr, height), []byte{mark})
+	}
+	if up.Threshold > 0 && up.Window > 0 && height-info.StartHeight+1 >= up.Window {
+		permille := types.UptimePermille(info.WindowSigned, info.WindowMissed)
+		below := permille < up.Threshold*10
+		if below && !info.BelowThreshold {
+			low = &types.EventDataValidatorUptimeLow{
+				Height:         height,
+				Address:        addr,
+				Window:         up.Window,
+				Missed:         info.WindowMissed,
+				UptimePermille: permille,
+				Threshold:      up.Threshold,
+			}
+		}
+		info.BelowThreshold = below
+	}
+
+	batch.Set(infoKey(addr), wire.BinaryBytes(*info))
+	return low
+}
+
+// slide takes the marks of info up to height to out of its window counters,
+// and out of the db when batch is not nil
+func (up *UptimePlugin) slide(batch dbm.Batch, info *types.ValidatorSigningInfo, to int64) {
+	from := info.LastHeight - up.Window + 1
+	if from < info.StartHeight {
+		from = info.StartHeight
+	}
+	for h := from; h <= to; h++ {
+		key := signKey(info.Address, h)
+		data := up.db.Get(key)
+		if len(data) == 0 {
+			continue
+		}
+		if data[0] == uptimeSigned {
+			info.WindowSigned--
+		} else {
+			info.WindowMissed--
+		}
+		if batch != nil {
+			batch.Delete(key)
+		}
+	}
+}
+
+// rewindow recounts the window counters of every signing info from the marks
+// kept when Window changed, dropping the marks out of the new window. Marks
+// already dropped by a smaller window are not counted back.
+func (up *UptimePlugin) rewindow() {
+	var (
+		from   = up.LastHeight() - up.Window + 1
+		counts = make(map[string][2]int64)
+		infos  []*types.ValidatorSigningInfo
+		batch  = up.db.NewBatch()
+		iter   = up.db.Iterator()
+	)
+	for iter != nil && iter.Next() {
+		key := iter.Key()
+		switch {
+		case bytes.HasPrefix(key, []byte(uptimeInfoPrefix)):
+			info := &types.ValidatorSigningInfo{}
+			if err := wire.ReadBinaryBytes(iter.Value(), info); err == nil {
+				infos = append(infos, info)
+			}
+		case bytes.HasPrefix(key, []byte(uptimeSignPrefix)) && len(key) > len(uptimeSignPrefix)+8:
+			if up.Window <= 0 || int64(binary.BigEndian.Uint64(key[len(key)-8:])) < from {
+				batch.Delete(append([]byte(nil), key...))
+				continue
+			}
+			addr := string(key[len(uptimeSignPrefix) : len(key)-8])
+			c := counts[addr]
+			if len(iter.Value()) > 0 && iter.Value()[0] == uptimeSigned {
+				c[0]++
+			} else {
+				c[1]++
+			}
+			counts[addr] = c
+		}
+	}
+	for _, info := range infos {
+		c := counts[string(info.Address)]
+		info.WindowSigned, info.WindowMissed = c[0], c[1]
+		batch.Set(infoKey(info.Address), wire.BinaryBytes(*info))
+	}
+	batch.Set([]byte(uptimeWindowKey), heightBytes(up.Window))
+	batch.Write()
+}
+
+func (up *UptimePlugin) signingInfo(addr []byte) *types.ValidatorSigningInfo {
+	data := up.db.Get(infoKey(addr))
+	if len(data) == 0 {
+		return nil
+	}
+	info := &types.ValidatorSigningInfo{}
+	if err := wire.ReadBinaryBytes(data, info); err != nil {
+		return nil
+	}
+	return info
+}
+
+func (up *UptimePlugin) currentValidators() [][]byte {
+	var addrs [][]byte
+	if data := up.db.Get([]byte(uptimeCurrentKey)); len(data) > 0 {
+		if err := wire.ReadBinaryBytes(data, &addrs); err == nil {
+			return addrs
+		}
+	}
+	if up.validators != nil && *up.validators != nil {
+		for _, v := range (*up.validators).Validators {
+			addrs = append(addrs, v.Address)
+		}
+	}
+	return addrs
+}
+
+func heightBytes(height int64) []byte {
+	bz := make([]byte, 8)
+	binary.BigEndian.PutUint64(bz, uint64(height))
+	return bz
+}
+
+func valsKey(height int64) []byte {
+	return append([]byte(uptimeValsPrefix), heightBytes(height)...)
+}
+
+func infoKey(addr []byte) []byte {
+	return append([]byte(uptimeInfoPrefix), addr...)
+}
+
+func signKey(addr []byte, height int64) []byte {
+	key := append([]byte(uptimeSignPrefix), addr...)
+	return append(key, heightBytes(height)...)
+}
@@ -0,0 +1,127 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/dappledger/AnnChain/gemmill/go-crypto"
+	dbm "github.com/dappledger/AnnChain/gemmill/modules/go-db"
+	"github.com/dappledger/AnnChain/gemmill/types"
+)
+
+func TestUptimeWindowAndEvent(t *testing.T) {
+	vset := types.NewValidatorSet([]*types.Validator{
+		types.NewValidator(crypto.GenPrivKeyEd25519().PubKey(), 1, false),
+		types.NewValidator(crypto.GenPrivKeyEd25519().PubKey(), 1, false),
+		types.NewValidator(crypto.GenPrivKeyEd25519().PubKey(), 1, false),
+	})
+	dir, err := ioutil.TempDir("", "uptime")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db := dbm.NewDB("validator_uptime", dbm.GoLevelDBBackendStr, dir)
+	defer db.Close()
+	up := &UptimePlugin{Window: 4, Threshold: 75}
+	up.Init(&InitParams{StateDB: db, Validators: &vset})
+	evsw := types.NewEventSwitch()
+	up.SetEventSwitch(evsw)
+	var lows []types.EventDataValidatorUptimeLow
+	types.AddListenerForEvent(evsw, "test", types.EventStringValidatorUptimeLow(), func(data types.TMEventData) {
+		lows = append(lows, data.(types.EventDataValidatorUptimeLow))
+	})
+
+	// the last validator misses the precommits of heights 3 and 4
+	offline := vset.Validators[2].Address
+	for height := int64(1); height <= 8; height++ {
+		block := &types.Block{Header: &types.Header{Height: height}}
+		if height > 1 {
+			precommits := make([]*types.Vote, vset.Size())
+			for i, v := range vset.Validators {
+				if i == 2 && (height-1 == 3 || height-1 == 4) {
+					continue
+				}
+				precommits[i] = &types.Vote{ValidatorAddress: v.Address, ValidatorIndex: i}
+			}
+			block.LastCommit = &types.Commit{Precommits: precommits}
+		}
+		if _, err := up.ExecBlock(&ExecBlockParams{Block: block}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := up.EndBlock(&EndBlockParams{Block: block, NextValidatorSet: vset}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if up.LastHeight() != 7 {
+		t.Fatalf("expected heights up to 7 accounted, got %d", up.LastHeight())
+	}
+	if len(lows) != 1 || !bytes.Equal(lows[0].Address, offline) || lows[0].Height != 4 || lows[0].UptimePermille != 500 {
+		t.Fatalf("expected one uptime event of the offline validator at height 4, got %+v", lows)
+	}
+
+	_, infos, err := up.SigningInfos(offline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := infos[0]
+	if info.SignedBlocks != 5 || info.MissedBlocks != 2 || info.MissedInRow != 0 ||
+		info.LastMissedHeight != 4 || info.WindowSigned != 3 || info.WindowMissed != 1 || info.BelowThreshold {
+		t.Fatalf("unexpected signing info %+v", info)
+	}
+
+	// only the marks of the window are kept
+	for height := int64(1); height <= 7; height++ {
+		if kept := len(db.Get(signKey(offline, height))) > 0; kept != (height >= 4) {
+			t.Fatalf("mark of height %d kept %v", height, kept)
+		}
+	}
+
+	_, uptimes, err := up.Uptime()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range uptimes {
+		expect := int64(1000)
+		if bytes.Equal(u.Address, offline) {
+			expect = 750
+		}
+		if u.FromHeight != 4 || u.ToHeight != 7 || u.UptimePermille != expect {
+			t.Fatalf("unexpected uptime %+v", u)
+		}
+	}
+
+	// replaying a block must not account its commit twice
+	if _, err := up.ExecBlock(&ExecBlockParams{Block: &types.Block{Header: &types.Header{Height: 8}, LastCommit: &types.Commit{Precommits: make([]*types.Vote, 3)}}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, infos, _ = up.SigningInfos(offline); infos[0].MissedBlocks != 2 {
+		t.Fatalf("replayed commit got accounted, %+v", infos[0])
+	}
+
+	// a smaller window recounts the kept marks on restart
+	up = &UptimePlugin{Window: 3}
+	up.Init(&InitParams{StateDB: db, Validators: &vset})
+	if _, infos, _ = up.SigningInfos(offline); infos[0].WindowSigned != 3 || infos[0].WindowMissed != 0 {
+		t.Fatalf("unexpected signing info after the window changed %+v", infos[0])
+	}
+	if len(db.Get(signKey(offline, 4))) > 0 {
+		t.Fatal("expected the mark out of the new window dropped")
+	}
+}
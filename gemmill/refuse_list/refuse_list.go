@@ -15,60 +15,219 @@
 package refuse_list
 
 import (
+	"encoding/binary"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	dbm "github.com/dappledger/AnnChain/gemmill/modules/go-db"
 	log "github.com/dappledger/AnnChain/gemmill/modules/go-log"
 	"go.uber.org/zap"
 )
 
+const (
+	ActionAdd    = "add"
+	ActionDelete = "delete"
+	ActionExpire = "expire"
+
+	// ReasonLegacy is given to the keys stored before entries had a reason
+	ReasonLegacy = "legacy"
+)
+
 type RefuseList struct {
-	db dbm.DB
+	mtx     sync.Mutex
+	db      dbm.DB
+	history dbm.DB
+	lastSeq uint64
+}
+
+// Entry is a refused node key, with where and why it was added
+type Entry struct {
+	PubKey  string     `json:"pubkey"`
+	Reason  string     `json:"reason"`
+	Height  int64      `json:"height,omitempty"`
+	TxHash  string     `json:"tx_hash,omitempty"`
+	AddedAt time.Time  `json:"added_at"`
+	Expiry  *time.Time `json:"expiry,omitempty"`
+}
+
+// Expired tells if e has an expiry not after now
+func (e *Entry) Expired(now time.Time) bool {
+	return e.Expiry != nil && !e.Expiry.After(now)
+}
+
+// Record is one change of the list in the history. For a delete, Reason,
+// Height and TxHash are the ones of the removal.
+type Record struct {
+	Seq    uint64     `json:"seq"`
+	Action string     `json:"action"`
+	PubKey string     `json:"pubkey"`
+	Reason string     `json:"reason"`
+	Height int64      `json:"height,omitempty"`
+	TxHash string     `json:"tx_hash,omitempty"`
+	Expiry *time.Time `json:"expiry,omitempty"`
+	Time   time.Time  `json:"time"`
+}
+
+// Filter selects entries and records, zero fields match everything
+type Filter struct {
+	PubKey     string // hex prefix, case insensitive
+	Reason     string // substring
+	FromHeight int64
+	ToHeight   int64
+}
+
+func (f *Filter) match(pubKey, reason string, height int64) bool {
+	if f == nil {
+		return true
+	}
+	if f.PubKey != "" && !strings.HasPrefix(pubKey, strings.ToUpper(f.PubKey)) {
+		return false
+	}
+	if f.Reason != "" && !strings.Contains(reason, f.Reason) {
+		return false
+	}
+	if f.FromHeight > 0 && height < f.FromHeight {
+		return false
+	}
+	if f.ToHeight > 0 && height > f.ToHeight {
+		return false
+	}
+	return true
 }
 
 var (
 	dbName        = "refuse_list"
-	keyExistValue = []byte{'Y'}
+	historyDBName = "refuse_list_history"
+	lastSeqKey    = []byte("last_seq")
 )
 
 func NewRefuseList(dbBackend, dbDir string) *RefuseList {
 	refuseListDB := dbm.NewDB(dbName, dbBackend, dbDir)
-	return &RefuseList{refuseListDB}
+	historyDB := dbm.NewDB(historyDBName, dbBackend, dbDir)
+	rl := &RefuseList{db: refuseListDB, history: historyDB}
+	if seq := historyDB.Get(lastSeqKey); len(seq) == 8 {
+		rl.lastSeq = binary.BigEndian.Uint64(seq)
+	}
+	return rl
 }
 
 func (rl *RefuseList) Stop() {
 	rl.db.Close()
+	rl.history.Close()
 }
 
 func (rl *RefuseList) QueryRefuseKey(pubKey []byte) (keyExist bool) {
+	return rl.Get(pubKey) != nil
+}
+
+// Get returns the entry of pubKey, nil if it is not refused
+func (rl *RefuseList) Get(pubKey []byte) *Entry {
+	rl.mtx.Lock()
+	defer rl.mtx.Unlock()
+	return rl.get(pubKey)
+}
+
+func (rl *RefuseList) get(pubKey []byte) *Entry {
 	ret := rl.db.Get(pubKey[:])
-	if len(ret) == 1 {
-		keyExist = true
+	if len(ret) == 0 {
+		return nil
 	}
-	return
+	return decodeEntry(pubKey, ret)
+}
+
+func decodeEntry(pubKey, value []byte) *Entry {
+	e := &Entry{}
+	if len(value) == 1 || json.Unmarshal(value, e) != nil {
+		e = &Entry{Reason: ReasonLegacy}
+	}
+	e.PubKey = strings.ToUpper(hex.EncodeToString(pubKey))
+	return e
 }
 
 func (rl *RefuseList) ListAllKey() (keyList []string) {
+	for _, e := range rl.List(nil) {
+		keyList = append(keyList, e.PubKey)
+	}
+	return
+}
+
+// List returns the entries matching f, sorted by pubkey
+func (rl *RefuseList) List(f *Filter) []*Entry {
+	rl.mtx.Lock()
+	defer rl.mtx.Unlock()
+	var entries []*Entry
 	iter := rl.db.Iterator()
 	for iter.Next() {
-		key := iter.Key()
-		str := hex.EncodeToString(key)
-		keyList = append(keyList, strings.ToUpper(str))
+		e := decodeEntry(iter.Key(), iter.Value())
+		if f.match(e.PubKey, e.Reason, e.Height) {
+			entries = append(entries, e)
+		}
 	}
-	return
+	return entries
+}
+
+// Expire removes the entries expired by now and returns how many there were.
+// Entries only expire here, now is the time of the block being committed so
+// that every node refuses the same keys at the same height.
+func (rl *RefuseList) Expire(now time.Time) int {
+	rl.mtx.Lock()
+	defer rl.mtx.Unlock()
+	var expired [][]byte
+	iter := rl.db.Iterator()
+	for iter.Next() {
+		key := append([]byte(nil), iter.Key()...)
+		if decodeEntry(key, iter.Value()).Expired(now) {
+			expired = append(expired, key)
+		}
+	}
+	for _, key := range expired {
+		rl.expire(key, rl.get(key), now)
+	}
+	return len(expired)
+}
+
+func (rl *RefuseList) expire(pubKey []byte, e *Entry, now time.Time) {
+	rl.db.DeleteSync(pubKey)
+	rl.record(&Record{Action: ActionExpire, PubKey: e.PubKey, Reason: e.Reason, Height: e.Height, TxHash: e.TxHash, Expiry: e.Expiry, Time: now})
+	log.Info("[refuse list],expire key", zap.String("pubkey", e.PubKey))
 }
 
 func (rl *RefuseList) AddRefuseKey(pubKey []byte) {
-	rl.db.SetSync(pubKey[:], keyExistValue)
-	log.Info("[refuse list],add key", zap.String("pubkey", fmt.Sprintf("%x", pubKey)))
+	rl.AddEntry(pubKey, &Entry{})
+}
+
+// AddEntry refuses pubKey for e.Reason, replacing its previous entry. AddedAt
+// defaults to now.
+func (rl *RefuseList) AddEntry(pubKey []byte, e *Entry) {
+	rl.mtx.Lock()
+	defer rl.mtx.Unlock()
+	e.PubKey = strings.ToUpper(hex.EncodeToString(pubKey))
+	if e.AddedAt.IsZero() {
+		e.AddedAt = time.Now()
+	}
+	value, _ := json.Marshal(e)
+	rl.db.SetSync(pubKey[:], value)
+	rl.record(&Record{Action: ActionAdd, PubKey: e.PubKey, Reason: e.Reason, Height: e.Height, TxHash: e.TxHash, Expiry: e.Expiry})
+	log.Info("[refuse list],add key", zap.String("pubkey", fmt.Sprintf("%x", pubKey)), zap.String("reason", e.Reason))
 }
 
 func (rl *RefuseList) DeleteRefuseKey(pubKey []byte) (err error) {
-	if rl.QueryRefuseKey(pubKey) {
+	return rl.DeleteEntry(pubKey, &Entry{})
+}
+
+// DeleteEntry removes pubKey from the list, by gives the reason and source of
+// the removal recorded in the history
+func (rl *RefuseList) DeleteEntry(pubKey []byte, by *Entry) (err error) {
+	rl.mtx.Lock()
+	defer rl.mtx.Unlock()
+	if rl.get(pubKey) != nil {
 		rl.db.DeleteSync(pubKey[:])
+		rl.record(&Record{Action: ActionDelete, PubKey: strings.ToUpper(hex.EncodeToString(pubKey)), Reason: by.Reason, Height: by.Height, TxHash: by.TxHash})
 		log.Info("[refuse list],del key", zap.String("pubkey", fmt.Sprintf("%x", pubKey)))
 	} else {
 		err = errors.New("pubKey not exist")
@@ -76,3 +235,44 @@ func (rl *RefuseList) DeleteRefuseKey(pubKey []byte) (err error) {
 	}
 	return
 }
+
+// History returns the records matching f in the order they were made, the
+// last limit ones if limit > 0
+func (rl *RefuseList) History(f *Filter, limit int) []*Record {
+	rl.mtx.Lock()
+	defer rl.mtx.Unlock()
+	var records []*Record
+	iter := rl.history.Iterator()
+	for iter.Next() {
+		if len(iter.Key()) != 8 {
+			continue
+		}
+		r := &Record{}
+		if err := json.Unmarshal(iter.Value(), r); err != nil {
+			continue
+		}
+		if f.match(r.PubKey, r.Reason, r.Height) {
+			records = append(records, r)
+		}
+	}
+	if limit > 0 && len(records) > limit {
+		records = records[len(records)-limit:]
+	}
+	return records
+}
+
+// record appends r to the history, the caller holds mtx
+func (rl *RefuseList) record(r *Record) {
+	rl.lastSeq++
+	r.Seq = rl.lastSeq
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, r.Seq)
+	value, _ := json.Marshal(r)
+	batch := rl.history.NewBatch()
+	batch.Set(key, value)
+	batch.Set(lastSeqKey, key)
+	batch.Write()
+}
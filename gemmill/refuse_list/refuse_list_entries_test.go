@@ -0,0 +1,84 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package refuse_list
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dappledger/AnnChain/gemmill/modules/go-db"
+)
+
+func TestRefuseListEntries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "refuse_list")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	refuseList := NewRefuseList(db.LevelDBBackendStr, dir)
+
+	var (
+		removed = []byte{0x01, 0x02}
+		legacy  = []byte{0x03, 0x04}
+		expired = []byte{0x05, 0x06}
+		past    = time.Now().Add(-time.Minute)
+	)
+	refuseList.AddEntry(removed, &Entry{Reason: "admin remove_node", Height: 10, TxHash: "AB"})
+	refuseList.db.SetSync(legacy, []byte{'Y'})
+	refuseList.AddEntry(expired, &Entry{Reason: "admin remove_node", Height: 20, Expiry: &past})
+
+	e := refuseList.Get(removed)
+	assert.NotNil(t, e)
+	assert.Equal(t, "0102", e.PubKey)
+	assert.Equal(t, int64(10), e.Height)
+	assert.Equal(t, "AB", e.TxHash)
+	assert.Equal(t, ReasonLegacy, refuseList.Get(legacy).Reason)
+
+	// entries stay refused until a block after their expiry
+	assert.Equal(t, true, refuseList.QueryRefuseKey(expired))
+	assert.Equal(t, 0, refuseList.Expire(past.Add(-time.Second)))
+	assert.Equal(t, 1, refuseList.Expire(past))
+	assert.Equal(t, false, refuseList.QueryRefuseKey(expired))
+
+	assert.Equal(t, []string{"0102", "0304"}, refuseList.ListAllKey())
+	assert.Equal(t, 1, len(refuseList.List(&Filter{Reason: "remove"})))
+	assert.Equal(t, 1, len(refuseList.List(&Filter{PubKey: "03"})))
+	assert.Equal(t, 0, len(refuseList.List(&Filter{FromHeight: 11})))
+
+	assert.Nil(t, refuseList.DeleteEntry(removed, &Entry{Reason: "admin add_peer", Height: 30}))
+	assert.NotNil(t, refuseList.DeleteRefuseKey(removed))
+	refuseList.Stop()
+
+	// the history survives a restart and goes on from its last record
+	refuseList = NewRefuseList(db.LevelDBBackendStr, dir)
+	defer refuseList.Stop()
+	refuseList.AddRefuseKey(removed)
+	history := refuseList.History(nil, 0)
+	actions := make([]string, 0, len(history))
+	for i, r := range history {
+		assert.Equal(t, uint64(i+1), r.Seq)
+		actions = append(actions, r.Action)
+	}
+	assert.Equal(t, []string{ActionAdd, ActionAdd, ActionExpire, ActionDelete, ActionAdd}, actions)
+	assert.Equal(t, "admin add_peer", history[3].Reason)
+	assert.True(t, history[2].Time.Equal(past))
+
+	last := refuseList.History(&Filter{PubKey: "0102"}, 2)
+	assert.Equal(t, 2, len(last))
+	assert.Equal(t, ActionDelete, last[0].Action)
+	assert.Equal(t, 2, len(refuseList.History(&Filter{FromHeight: 15, ToHeight: 25}, 0)))
+}
@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package refuse_list
+package refuse_list_test
 
 import (
 	"os"
@@ -21,14 +21,16 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/dappledger/AnnChain/gemmill/modules/go-db"
+	. "github.com/dappledger/AnnChain/gemmill/refuse_list"
 	"github.com/dappledger/AnnChain/gemmill/types"
 )
 
 func TestRefuseList(t *testing.T) {
 	refuseList := NewRefuseList(db.LevelDBBackendStr, "./")
 	defer func() {
+		refuseList.Stop()
 		os.RemoveAll("refuse_list.db")
-		refuseList.db.Close()
+		os.RemoveAll("refuse_list_history.db")
 	}()
 	var keyStr = "6FEBD39916627AA0CD7CFDA4A94586F3BA958078621E6E466488A423272B9700"
 
@@ -16,6 +16,7 @@ package rpcclient
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -84,10 +85,15 @@ func NewClientJSONRPC(remote string) *ClientJSONRPC {
 }
 
 func (c *ClientJSONRPC) Call(method string, params []interface{}, result interface{}) (interface{}, error) {
-	return c.call(method, params, result)
+	return c.call(context.Background(), method, params, result)
+}
+
+// CallContext is Call bounded by ctx, the request is aborted once ctx is done.
+func (c *ClientJSONRPC) CallContext(ctx context.Context, method string, params []interface{}, result interface{}) (interface{}, error) {
+	return c.call(ctx, method, params, result)
 }
 
-func (c *ClientJSONRPC) call(method string, params []interface{}, result interface{}) (interface{}, error) {
+func (c *ClientJSONRPC) call(ctx context.Context, method string, params []interface{}, result interface{}) (interface{}, error) {
 	// Make request and get responseBytes
 	request := rpctypes.RPCRequest{
 		JSONRPC: "2.0",
@@ -102,6 +108,7 @@ func (c *ClientJSONRPC) call(method string, params []interface{}, result interfa
 	if err != nil {
 		return nil, err
 	}
+	req = req.WithContext(ctx)
 	req.Close = true
 	req.Header.Set("contentType", "text/json")
 	httpResponse, err := c.client.Do(req)
@@ -47,6 +47,10 @@ type (
 		Got      *State
 		Expected *State
 	}
+
+	ErrNoValSetForHeight struct {
+		Height int64
+	}
 )
 
 func (e ErrUnknownBlock) Error() string {
@@ -67,3 +71,7 @@ func (e ErrLastStateMismatch) Error() string {
 func (e ErrStateMismatch) Error() string {
 	return gcmn.Fmt("State after replay does not match saved state. Got ----\n%v\nExpected ----\n%v\n", e.Got, e.Expected)
 }
+
+func (e ErrNoValSetForHeight) Error() string {
+	return gcmn.Fmt("Could not find validator set for height #%d", e.Height)
+}
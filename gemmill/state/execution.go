@@ -49,6 +49,7 @@ func (s *State) ExecBlock(eventSwitch types.EventSwitch, block *types.Block, blo
 	valSet := s.Validators.Copy()
 	nextValSet := valSet.Copy()
 	changedValidators := make([]*types.ValidatorAttr, 0)
+	nextParams := &types.ConsensusParams{}
 
 	err := s.blockExecutable.BeginBlock(block, eventSwitch, &blockPartsHeader)
 	if err != nil {
@@ -59,10 +60,27 @@ func (s *State) ExecBlock(eventSwitch types.EventSwitch, block *types.Block, blo
 		return err
 	}
 
-	err = s.blockExecutable.EndBlock(block, eventSwitch, &blockPartsHeader, changedValidators, nextValSet)
+	err = s.blockExecutable.EndBlock(block, eventSwitch, &blockPartsHeader, changedValidators, nextValSet, nextParams)
 	if err != nil {
 		return err
 	}
+	// the round the last height got committed at is only known now, account
+	// its proposer slots with the stats and params consensus chose them with
+	stats := s.ProposerStats
+	if block.Height > 1 {
+		s.ProposerStats = s.ProposerStats.Update(s.LastProposerStats, s.LastValidators, block.Height-1, block.LastCommit.Round(), s.LastConsensusParams).Prune(nextValSet)
+	}
+	// consensus chose the proposers of this block with these
+	s.LastProposerStats, s.LastConsensusParams = stats, s.ConsensusParams
+
+	// plugins fill nextParams only when the block changed them
+	if nextParams.ValidateBasic() == nil {
+		if err := nextParams.ValidateChange(s.ConsensusParams, block.Height); err != nil {
+			log.Warn("consensus params not changed", zap.Int64("height", block.Height), zap.Error(err))
+		} else {
+			s.ConsensusParams = nextParams
+		}
+	}
 	// plugins modify changedValidators inplace
 	// All good!
 	// Update validator accums and set state variables
@@ -170,11 +188,33 @@ func (s *State) validateBlock(block *types.Block) error {
 		if err != nil {
 			return err
 		}
+		if s.ConsensusParams.BFTTime(block.Height) {
+			if err := s.validateBlockTime(block); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
+// validateBlockTime requires +2/3 of the LastCommit to be timestamped and the
+// block time to be the voting power weighted median of those timestamps,
+// after the last block time.
+func (s *State) validateBlockTime(block *types.Block) error {
+	commit := block.LastCommit
+	if power := commit.TimestampedPower(s.LastValidators); power <= s.LastValidators.TotalVotingPower()*2/3 {
+		return fmt.Errorf("Invalid LastCommit. Timestamped voting power %v is not +2/3 of %v", power, s.LastValidators.TotalVotingPower())
+	}
+	if median := commit.MedianTime(s.LastValidators); !block.Time.Equal(median) {
+		return fmt.Errorf("Wrong Block.Header.Time. Expected median vote time %v, got %v", median, block.Time)
+	}
+	if !block.Time.After(s.LastBlockTime) {
+		return fmt.Errorf("Wrong Block.Header.Time. Expected after %v, got %v", s.LastBlockTime, block.Time)
+	}
+	return nil
+}
+
 // ApplyBlock executes the block, then commits and updates the mempool atomically
 func (s *State) ApplyBlock(eventSwitch types.EventSwitch, block *types.Block, partsHeader types.PartSetHeader, mempool types.IMempool, round int64) error {
 	// Run the block on the State:
@@ -0,0 +1,163 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state
+
+import (
+	"bytes"
+	"testing"
+
+	dbm "github.com/dappledger/AnnChain/gemmill/modules/go-db"
+	"github.com/dappledger/AnnChain/gemmill/modules/go-events"
+	"github.com/dappledger/AnnChain/gemmill/types"
+)
+
+type testBlockExecutable struct{}
+
+func (testBlockExecutable) BeginBlock(*types.Block, events.Fireable, *types.PartSetHeader) error {
+	return nil
+}
+
+func (testBlockExecutable) ExecBlock(*types.Block, events.Fireable, *types.ExecuteResult) error {
+	return nil
+}
+
+func (testBlockExecutable) EndBlock(*types.Block, events.Fireable, *types.PartSetHeader, []*types.ValidatorAttr, *types.ValidatorSet, *types.ConsensusParams) error {
+	return nil
+}
+
+// testChain commits blocks to a state the way consensus does
+type testChain struct {
+	t     *testing.T
+	s     *State
+	evsw  types.EventSwitch
+	privs []*types.PrivValidator
+	last  *types.Commit
+}
+
+func newTestChain(t *testing.T, params *types.ConsensusParams) *testChain {
+	valSet, privs := types.RandValidatorSet(4, 10)
+	genDoc := &types.GenesisDoc{ChainID: "test-chain", ConsensusParams: params}
+	for _, val := range valSet.Validators {
+		genDoc.Validators = append(genDoc.Validators, types.GenesisValidator{PubKey: val.PubKey, Amount: val.VotingPower})
+	}
+	s := MakeGenesisState(dbm.NewMemDB(), genDoc)
+	s.SetBlockExecutable(testBlockExecutable{})
+
+	evsw := types.NewEventSwitch()
+	if _, err := evsw.Start(); err != nil {
+		t.Fatal(err)
+	}
+	types.AddListenerForEvent(evsw, "test", types.EventStringHookExecute(), func(data types.TMEventData) {
+		data.(types.EventDataHookExecute).ResCh <- types.ExecuteResult{}
+	})
+	return &testChain{t: t, s: s, evsw: evsw, privs: privs, last: &types.Commit{}}
+}
+
+// proposer is who consensus, see ConsensusState.proposer, picks at round
+// of the next height
+func (c *testChain) proposer(round int64) *types.Validator {
+	return c.s.ProposerStats.Proposer(c.validators(round), c.s.LastBlockHeight+1, c.s.ConsensusParams)
+}
+
+// validators of the next height at round, entered one round after another
+func (c *testChain) validators(round int64) *types.ValidatorSet {
+	vals := c.s.Validators.Copy()
+	for r := int64(0); r < round; r++ {
+		vals.IncrementAccum(1)
+	}
+	return vals
+}
+
+// commit makes the next block at round, proposed by the proposer of round,
+// and executes it
+func (c *testChain) commit(round int64) {
+	height := c.s.LastBlockHeight + 1
+	block, parts := types.MakeBlock(height, c.s.ChainID, nil, nil, c.last, c.s.LastBlockTime, c.proposer(round).Address,
+		c.s.LastBlockID, c.s.Validators.Hash(), c.s.AppHash, c.s.ReceiptsHash, 1024)
+	blockID := types.BlockID{Hash: block.Hash(), PartsHeader: parts.Header()}
+	voteSet := types.NewVoteSet(c.s.ChainID, height, round, types.VoteTypePrecommit, c.s.Validators)
+	for _, priv := range c.privs {
+		idx, _ := c.s.Validators.GetByAddress(priv.Address)
+		vote := &types.Vote{ValidatorAddress: priv.Address, ValidatorIndex: idx, Height: height, Round: round, Type: types.VoteTypePrecommit, BlockID: blockID}
+		vote.Signature = priv.Sign(types.SignBytes(c.s.ChainID, vote))
+		if _, err := voteSet.AddVote(vote); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+	if err := c.s.ExecBlock(c.evsw, block, parts.Header(), round); err != nil {
+		c.t.Fatalf("height %d: %v", height, err)
+	}
+	c.last = voteSet.MakeCommit()
+}
+
+func TestExecBlockProposerStats(t *testing.T) {
+	// a proposer missing at a height is only skipped from the height after
+	// next, when its miss is accounted
+	for _, rounds := range [][]int64{
+		{2, 0, 0},
+		{1, 2, 0},
+		{0, 3, 0, 0},
+		{1, 1, 0, 2, 1, 0, 0, 1, 1, 0, 0, 3, 0},
+	} {
+		checkProposerStats(t, rounds)
+	}
+}
+
+// checkProposerStats commits blocks at rounds and checks the proposer stats
+// against the proposers consensus picked
+func checkProposerStats(t *testing.T, rounds []int64) {
+	params := &types.ConsensusParams{BlockSize: 1000, BlockPartSize: 100, TimeoutPropose: 1, TimeoutPrevote: 1, TimeoutPrecommit: 1,
+		ProposerMaxMissed: 1, ProposerSkipBlocks: 3}
+	c := newTestChain(t, params)
+	defer c.evsw.Stop()
+
+	expected := make(map[string]*types.ProposerStat)
+	stat := func(address []byte) *types.ProposerStat {
+		if expected[string(address)] == nil {
+			expected[string(address)] = &types.ProposerStat{Address: address}
+		}
+		return expected[string(address)]
+	}
+	for _, commitRound := range rounds {
+		height := c.s.LastBlockHeight + 1
+		for round := int64(0); round <= commitRound; round++ {
+			proposer := c.proposer(round)
+			if natural := c.validators(round).Proposer(); !bytes.Equal(natural.Address, proposer.Address) {
+				stat(natural.Address).Skipped++
+			}
+			st := stat(proposer.Address)
+			if round == commitRound {
+				st.Missed = 0
+			} else {
+				st.Missed++
+				st.TotalMissed++
+				st.LastMissedHeight = height
+			}
+		}
+		c.commit(commitRound)
+	}
+	// the last block only accounted the slots of the one before
+	c.commit(0)
+
+	if len(c.s.ProposerStats) != len(expected) {
+		t.Fatalf("expected %d stats, got %d", len(expected), len(c.s.ProposerStats))
+	}
+	for _, st := range expected {
+		got := c.s.ProposerStats.Get(st.Address)
+		if got == nil || got.Missed != st.Missed || got.TotalMissed != st.TotalMissed || got.Skipped != st.Skipped || got.LastMissedHeight != st.LastMissedHeight {
+			t.Fatalf("expected %+v, got %+v", st, got)
+		}
+	}
+}
@@ -39,7 +39,7 @@ var (
 type IBlockExecutable interface {
 	BeginBlock(*types.Block, events.Fireable, *types.PartSetHeader) error
 	ExecBlock(*types.Block, events.Fireable, *types.ExecuteResult) error
-	EndBlock(*types.Block, events.Fireable, *types.PartSetHeader, []*types.ValidatorAttr, *types.ValidatorSet) error
+	EndBlock(*types.Block, events.Fireable, *types.PartSetHeader, []*types.ValidatorAttr, *types.ValidatorSet, *types.ConsensusParams) error
 }
 
 // NOTE: not goroutine-safe.
@@ -67,6 +67,18 @@ type State struct {
 	AppHash []byte
 	// ReceiptsHash is updated only after eval the txs
 	ReceiptsHash []byte
+
+	// ConsensusParams are set from genesis or an AdminOpChangeConsensusParams
+	// and take effect at the height after the block changing them.
+	// Nil means every node goes by its own config.
+	ConsensusParams *types.ConsensusParams `json:"consensus_params,omitempty" wire:"optional"`
+	// ProposerStats are updated from each block's LastCommit round
+	ProposerStats types.ProposerStats `json:"proposer_stats,omitempty" wire:"optional"`
+	// LastProposerStats and LastConsensusParams are the ones consensus chose
+	// the proposers of LastBlockHeight with, its slots are only accounted in
+	// the next block
+	LastProposerStats   types.ProposerStats    `json:"last_proposer_stats,omitempty" wire:"optional"`
+	LastConsensusParams *types.ConsensusParams `json:"last_consensus_params,omitempty" wire:"optional"`
 }
 
 func (s *State) CheckPubkeyPtr() {
@@ -99,6 +111,11 @@ func loadState(db dbm.DB, key []byte) *State {
 	}
 	// TODO: ensure that buf is completely read.
 
+	// the binary GenesisDoc lacks JSON only fields, prefer the stored one
+	if genDoc := LoadGenesisDoc(db); genDoc != nil {
+		s.GenesisDoc = genDoc
+	}
+
 	return s
 }
 
@@ -107,16 +124,39 @@ func (s *State) Copy() *State {
 		db:              s.db,
 		blockExecutable: s.blockExecutable,
 
-		GenesisDoc:         s.GenesisDoc,
-		ChainID:            s.ChainID,
-		LastBlockHeight:    s.LastBlockHeight,
-		LastBlockID:        s.LastBlockID,
-		LastBlockTime:      s.LastBlockTime,
-		Validators:         s.Validators.Copy(),
-		LastValidators:     s.LastValidators.Copy(),
-		AppHash:            s.AppHash,
-		ReceiptsHash:       s.ReceiptsHash,
-		LastNonEmptyHeight: s.LastNonEmptyHeight,
+		GenesisDoc:          s.GenesisDoc,
+		ChainID:             s.ChainID,
+		LastBlockHeight:     s.LastBlockHeight,
+		LastBlockID:         s.LastBlockID,
+		LastBlockTime:       s.LastBlockTime,
+		Validators:          s.Validators.Copy(),
+		LastValidators:      s.LastValidators.Copy(),
+		AppHash:             s.AppHash,
+		ReceiptsHash:        s.ReceiptsHash,
+		LastNonEmptyHeight:  s.LastNonEmptyHeight,
+		ConsensusParams:     s.ConsensusParams.Copy(),
+		ProposerStats:       s.ProposerStats.Copy(),
+		LastProposerStats:   s.LastProposerStats.Copy(),
+		LastConsensusParams: s.LastConsensusParams.Copy(),
+	}
+}
+
+// GetConsensusParams returns the consensus params in effect for the next
+// block, falling back to the node's config if none were set on chain.
+func (s *State) GetConsensusParams(config *viper.Viper) *types.ConsensusParams {
+	if s.ConsensusParams != nil {
+		return s.ConsensusParams
+	}
+	return &types.ConsensusParams{
+		BlockSize:             int64(config.GetInt("block_size")),
+		BlockPartSize:         int64(config.GetInt("block_part_size")),
+		TimeoutPropose:        int64(config.GetInt("timeout_propose")),
+		TimeoutProposeDelta:   int64(config.GetInt("timeout_propose_delta")),
+		TimeoutPrevote:        int64(config.GetInt("timeout_prevote")),
+		TimeoutPrevoteDelta:   int64(config.GetInt("timeout_prevote_delta")),
+		TimeoutPrecommit:      int64(config.GetInt("timeout_precommit")),
+		TimeoutPrecommitDelta: int64(config.GetInt("timeout_precommit_delta")),
+		TimeoutCommit:         int64(config.GetInt("timeout_commit")),
 	}
 }
 
@@ -131,6 +171,7 @@ func StateDB(config *viper.Viper) dbm.DB {
 func (s *State) Save() {
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
+	saveValidators(s.db, s.LastBlockHeight+1, s.Validators, s.LastValidators)
 	s.db.SetSync(stateKey, s.Bytes())
 }
 
@@ -170,6 +211,10 @@ func (s *State) LoadIntermediate() {
 		gcmn.PanicSanity(gcmn.Fmt("State mismatch for ReceiptsHash. Got %X, Expected %X", s2.ReceiptsHash, s.ReceiptsHash))
 	}
 
+	s.ConsensusParams = s2.ConsensusParams
+	s.ProposerStats = s2.ProposerStats
+	s.LastProposerStats = s2.LastProposerStats
+	s.LastConsensusParams = s2.LastConsensusParams
 	s.setBlockAndValidators(s2.LastBlockHeight, s2.LastNonEmptyHeight, s2.LastBlockID, s2.LastBlockTime, s2.Validators.Copy(), s2.LastValidators.Copy())
 }
 
@@ -275,6 +320,14 @@ func MakeGenesisState(db dbm.DB, genDoc *types.GenesisDoc) *State {
 	validatorSet := types.NewValidatorSet(validators)
 	lastValidatorSet := types.NewValidatorSet(nil)
 
+	if genDoc.ConsensusParams != nil {
+		if err := genDoc.ConsensusParams.ValidateBasic(); err != nil {
+			gcmn.Exit(gcmn.Fmt("The genesis file has invalid consensus_params: %v", err))
+		}
+	}
+	SaveGenesisDoc(db, genDoc)
+	saveValidators(db, 1, validatorSet, lastValidatorSet)
+
 	// TODO: genDoc doesn't need to provide receiptsHash
 	return &State{
 		db:                 db,
@@ -287,5 +340,25 @@ func MakeGenesisState(db dbm.DB, genDoc *types.GenesisDoc) *State {
 		LastValidators:     lastValidatorSet,
 		AppHash:            genDoc.AppHash,
 		LastNonEmptyHeight: 0,
+		ConsensusParams:    genDoc.ConsensusParams.Copy(),
+	}
+}
+
+// SaveGenesisDoc stores genDoc under GenDocKey
+func SaveGenesisDoc(db dbm.DB, genDoc *types.GenesisDoc) {
+	db.SetSync(types.GenDocKey, genDoc.JSONBytes())
+}
+
+// LoadGenesisDoc returns the genesis stored under GenDocKey, nil if there is
+// none
+func LoadGenesisDoc(db dbm.DB) *types.GenesisDoc {
+	buf := db.Get(types.GenDocKey)
+	if len(buf) == 0 {
+		return nil
+	}
+	genDoc, err := types.GenesisDocFromJSONRet(buf)
+	if err != nil {
+		return nil
 	}
+	return genDoc
 }
@@ -0,0 +1,197 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/dappledger/AnnChain/gemmill/go-crypto"
+	dbm "github.com/dappledger/AnnChain/gemmill/modules/go-db"
+	"github.com/dappledger/AnnChain/gemmill/types"
+)
+
+func testGenesisDoc(params *types.ConsensusParams) *types.GenesisDoc {
+	privKey := crypto.GenPrivKeyEd25519()
+	return &types.GenesisDoc{
+		ChainID: "test-chain",
+		Validators: []types.GenesisValidator{
+			{PubKey: privKey.PubKey(), Amount: 10, Name: "val0"},
+		},
+		ConsensusParams: params,
+	}
+}
+
+func TestConsensusParamsPersisted(t *testing.T) {
+	params := &types.ConsensusParams{
+		BlockSize:        100,
+		BlockPartSize:    1024,
+		TimeoutPropose:   3000,
+		TimeoutPrevote:   1000,
+		TimeoutPrecommit: 1000,
+		TimeoutCommit:    500,
+	}
+	db := dbm.NewMemDB()
+	s := MakeGenesisState(db, testGenesisDoc(params))
+	s.Save()
+
+	// without params the state encodes exactly as before they existed
+	legacy := s.Copy()
+	legacy.ConsensusParams = nil
+	if !bytes.HasPrefix(s.Bytes(), legacy.Bytes()) {
+		t.Fatal("consensus params changed the encoding of the other state fields")
+	}
+
+	loaded := LoadState(db)
+	if loaded.ConsensusParams == nil || *loaded.ConsensusParams != *params {
+		t.Fatalf("expected consensus params %v, got %v", params, loaded.ConsensusParams)
+	}
+	if loaded.GenesisDoc.ConsensusParams == nil || *loaded.GenesisDoc.ConsensusParams != *params {
+		t.Fatalf("expected genesis consensus params %v, got %v", params, loaded.GenesisDoc.ConsensusParams)
+	}
+
+	legacyDB := dbm.NewMemDB()
+	legacyDB.Set(stateKey, legacy.Bytes())
+	loaded = LoadState(legacyDB)
+	if loaded.ConsensusParams != nil {
+		t.Fatalf("expected no consensus params, got %v", loaded.ConsensusParams)
+	}
+	conf := viper.New()
+	conf.Set("block_size", 5000)
+	if got := loaded.GetConsensusParams(conf).BlockSize; got != 5000 {
+		t.Fatalf("expected block_size from config, got %d", got)
+	}
+}
+
+func TestProposerStatsPersistedWithoutParams(t *testing.T) {
+	db := dbm.NewMemDB()
+	s := MakeGenesisState(db, testGenesisDoc(nil))
+	addr := s.Validators.Validators[0].Address
+	s.ProposerStats = types.ProposerStats{{Address: addr, Missed: 2, TotalMissed: 3, LastMissedHeight: 7}}
+	s.Save()
+
+	loaded := LoadState(db)
+	if loaded.ConsensusParams != nil {
+		t.Fatalf("expected no consensus params, got %v", loaded.ConsensusParams)
+	}
+	if st := loaded.ProposerStats.Get(addr); st == nil || st.Missed != 2 || st.TotalMissed != 3 || st.LastMissedHeight != 7 {
+		t.Fatalf("expected proposer stat %v, got %v", s.ProposerStats[0], st)
+	}
+}
+
+func TestLoadValidatorsAtHeight(t *testing.T) {
+	db := dbm.NewMemDB()
+	s := MakeGenesisState(db, testGenesisDoc(nil))
+	sets := map[int64]*types.ValidatorSet{1: s.Validators.Copy()}
+	for h := int64(1); h <= 5; h++ {
+		next := s.Validators.Copy()
+		if h == 3 {
+			val := next.Validators[0].Copy()
+			val.VotingPower = 20
+			next.Update(val)
+		}
+		next.IncrementAccum(1)
+		s.LastBlockHeight = h
+		s.LastValidators, s.Validators = s.Validators, next
+		s.Save()
+		sets[h+1] = next.Copy()
+	}
+
+	for h, want := range sets {
+		got, err := LoadValidators(db, h)
+		if err != nil {
+			t.Fatalf("height %d: %v", h, err)
+		}
+		if !bytes.Equal(got.Hash(), want.Hash()) {
+			t.Fatalf("height %d: expected validators %v, got %v", h, want, got)
+		}
+	}
+	if info := loadValidatorsInfo(db, 3); info.ValidatorSet != nil || info.LastHeightChanged != 1 {
+		t.Fatalf("expected height 3 to point at 1, got %+v", info)
+	}
+	if info := loadValidatorsInfo(db, 4); info.ValidatorSet == nil {
+		t.Fatal("expected the changed set stored at 4")
+	}
+	if _, err := LoadValidators(db, 7); err == nil {
+		t.Fatal("expected no validators past the last saved height")
+	}
+}
+
+func TestValidateBlockTime(t *testing.T) {
+	vals := make([]*types.Validator, 4)
+	for i := range vals {
+		vals[i] = types.NewValidator(crypto.GenPrivKeyEd25519().PubKey(), 10, false)
+	}
+	base := time.Unix(1500000000, 0)
+	s := &State{LastValidators: types.NewValidatorSet(vals), LastBlockTime: base.Add(-time.Second)}
+	times := []time.Time{base, base.Add(time.Second), base.Add(2 * time.Second)}
+	block := &types.Block{
+		Header:     &types.Header{Height: 3, Time: base.Add(time.Minute)},
+		LastCommit: &types.Commit{Precommits: []*types.Vote{{}, {}, {}, {}}, Timestamps: []*time.Time{&times[0], &times[1], nil, nil}},
+	}
+
+	// a validator leaving its timestamp out can't fall back to the proposer's time
+	if err := s.validateBlockTime(block); err == nil {
+		t.Fatal("block after a commit without +2/3 timestamped accepted")
+	}
+	block.Time = times[0]
+	if err := s.validateBlockTime(block); err == nil {
+		t.Fatal("block at the median of less than +2/3 accepted")
+	}
+
+	block.LastCommit.Timestamps[2] = &times[2]
+	block.Time = base.Add(time.Minute)
+	if err := s.validateBlockTime(block); err == nil {
+		t.Fatal("block time off the vote median accepted")
+	}
+	block.Time = times[1]
+	if err := s.validateBlockTime(block); err != nil {
+		t.Fatalf("block at the vote median rejected: %v", err)
+	}
+	s.LastBlockTime = times[1]
+	if err := s.validateBlockTime(block); err == nil {
+		t.Fatal("block at the last block time accepted")
+	}
+}
+
+func TestConsensusParamsBFTTimeHeight(t *testing.T) {
+	params := &types.ConsensusParams{BlockSize: 1000, BlockPartSize: 100, TimeoutPropose: 1, TimeoutPrevote: 1, TimeoutPrecommit: 1,
+		BFTTimeHeight: 10}
+	if params.BFTTime(9) || !params.BFTTime(10) {
+		t.Fatal("bft time not active from bft_time_height")
+	}
+	if (*types.ConsensusParams)(nil).BFTTime(10) {
+		t.Fatal("bft time active without params")
+	}
+
+	// votes of the height before activation must be timestamped already
+	if err := params.ValidateChange(nil, 8); err != nil {
+		t.Fatalf("activation two heights ahead rejected: %v", err)
+	}
+	if err := params.ValidateChange(nil, 9); err == nil {
+		t.Fatal("activation at the next height accepted")
+	}
+	if err := params.ValidateChange(params.Copy(), 20); err != nil {
+		t.Fatalf("unchanged active bft_time_height rejected: %v", err)
+	}
+	off := params.Copy()
+	off.BFTTimeHeight = 0
+	if err := off.ValidateChange(params, 20); err != nil {
+		t.Fatalf("turning bft time off rejected: %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/dappledger/AnnChain/gemmill/go-wire"
+	dbm "github.com/dappledger/AnnChain/gemmill/modules/go-db"
+	"github.com/dappledger/AnnChain/gemmill/types"
+)
+
+// valSetCheckpointInterval bounds how many accum increments LoadValidators
+// replays on top of the last stored set
+const valSetCheckpointInterval = 1000
+
+// ValidatorsInfo is stored for every height, the set itself only when it
+// changed at that height or on a checkpoint.
+type ValidatorsInfo struct {
+	ValidatorSet      *types.ValidatorSet
+	LastHeightChanged int64
+}
+
+func calcValidatorsKey(height int64) []byte {
+	return []byte(fmt.Sprintf("validatorsKey:%v", height))
+}
+
+// saveValidators stores vals as the set signing the block at height,
+// prev being the set of the height before
+func saveValidators(db dbm.DB, height int64, vals, prev *types.ValidatorSet) {
+	info := ValidatorsInfo{LastHeightChanged: height}
+	if height > 1 && height%valSetCheckpointInterval != 0 && sameValidators(vals, prev) {
+		if last := loadValidatorsInfo(db, height-1); last != nil {
+			info.LastHeightChanged = last.LastHeightChanged
+		}
+	}
+	if info.LastHeightChanged == height {
+		info.ValidatorSet = vals
+	}
+	db.Set(calcValidatorsKey(height), wire.BinaryBytes(info))
+}
+
+func loadValidatorsInfo(db dbm.DB, height int64) *ValidatorsInfo {
+	buf := db.Get(calcValidatorsKey(height))
+	if len(buf) == 0 {
+		return nil
+	}
+	info := new(ValidatorsInfo)
+	if err := wire.ReadBinaryBytes(buf, info); err != nil {
+		return nil
+	}
+	return info
+}
+
+// LoadValidators returns the validator set that signed the block at height,
+// accums included, so its hash matches the ValidatorsHash of that header.
+func LoadValidators(db dbm.DB, height int64) (*types.ValidatorSet, error) {
+	info := loadValidatorsInfo(db, height)
+	if info == nil {
+		return nil, ErrNoValSetForHeight{height}
+	}
+	if info.ValidatorSet == nil {
+		base := loadValidatorsInfo(db, info.LastHeightChanged)
+		if base == nil || base.ValidatorSet == nil {
+			return nil, ErrNoValSetForHeight{height}
+		}
+		info.ValidatorSet = base.ValidatorSet
+		info.ValidatorSet.IncrementAccum(height - info.LastHeightChanged)
+	}
+	return info.ValidatorSet, nil
+}
+
+// sameValidators compares addresses, keys and powers, accums left out
+func sameValidators(a, b *types.ValidatorSet) bool {
+	if a == nil || b == nil || a.Size() != b.Size() {
+		return false
+	}
+	for i, va := range a.Validators {
+		vb := b.Validators[i]
+		if !bytes.Equal(va.Address, vb.Address) || !bytes.Equal(va.PubKey.Bytes(), vb.PubKey.Bytes()) ||
+			va.VotingPower != vb.VotingPower || va.IsCA != vb.IsCA {
+			return false
+		}
+	}
+	return true
+}
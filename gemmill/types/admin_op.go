@@ -52,7 +52,12 @@ func (cmd *AdminOPCmd) ExtractMsg(o interface{}) (interface{}, error) {
 type CmdType string
 
 const (
-	AdminOpChangeValidator = "changeValidator"
+	AdminOpChangeValidator       = "changeValidator"
+	AdminOpChangeConsensusParams = "changeConsensusParams"
+	AdminOpPropose               = "propose"
+	AdminOpProposalVote          = "proposalVote"
+	AdminOpChangePermissions     = "changePermissions"
+	AdminOpFreezeAccounts        = "freezeAccounts"
 )
 
 var (
@@ -72,3 +77,109 @@ type AdminVoteResult struct {
 	PubKey    []byte
 	Signature []byte
 }
+
+// PermissionsAttr is the msg of an AdminOpChangePermissions cmd, which changes
+// the permission table the app keeps in its state. The permission names are
+// the app's, e.g. "deploy" and "transfer" for the evm.
+type PermissionsAttr struct {
+	Restrict   []string `json:"restrict,omitempty"`   // permissions the accounts need from now on
+	Unrestrict []string `json:"unrestrict,omitempty"` // permissions the accounts don't need anymore
+	Grant      []string `json:"grant,omitempty"`      // permissions given to Accounts
+	Revoke     []string `json:"revoke,omitempty"`     // permissions taken from Accounts
+	Accounts   [][]byte `json:"accounts,omitempty"`
+	Addr       []byte   `json:"addr"`
+	Nonce      uint64   `json:"nonce"`
+}
+
+// FreezeAttr is the msg of an AdminOpFreezeAccounts cmd, which freezes the
+// accounts of the app or unfreezes them
+type FreezeAttr struct {
+	Accounts [][]byte `json:"accounts"`
+	Freeze   bool     `json:"freeze"`
+	Reason   string   `json:"reason,omitempty"` // e.g. the court order
+	Addr     []byte   `json:"addr"`
+	Nonce    uint64   `json:"nonce"`
+}
+
+// DefaultAdminProposalVotingPeriod is the number of blocks a proposal is open
+// for votes when its AdminProposalAttr gives none
+const DefaultAdminProposalVotingPeriod = 1000
+
+const (
+	AdminProposalPending  = "pending"
+	AdminProposalExecuted = "executed"
+	AdminProposalFailed   = "failed" // passed but its op returned an error
+	AdminProposalRejected = "rejected"
+	AdminProposalExpired  = "expired"
+)
+
+// AdminProposalAttr is the msg of an AdminOpPropose cmd. It puts Op to the
+// vote of the validators instead of collecting all its signatures off chain,
+// the validators signing the propose cmd approve it right away.
+type AdminProposalAttr struct {
+	Op           *AdminOPCmd `json:"op"` // its SInfos are ignored
+	VotingPeriod int64       `json:"voting_period,omitempty"`
+	Description  string      `json:"description,omitempty"`
+	Addr         []byte      `json:"addr"`
+	Nonce        uint64      `json:"nonce"`
+}
+
+// AdminProposalVoteAttr is the msg of an AdminOpProposalVote cmd, every
+// validator signing it votes for or against the proposal
+type AdminProposalVoteAttr struct {
+	ProposalID string `json:"proposal_id"`
+	Approve    bool   `json:"approve"`
+	Addr       []byte `json:"addr"`
+	Nonce      uint64 `json:"nonce"`
+}
+
+// AdminProposalVote is the last vote of a validator on a proposal
+type AdminProposalVote struct {
+	Address []byte `json:"address"`
+	Approve bool   `json:"approve"`
+	Height  int64  `json:"height"`
+}
+
+// AdminProposal is an admin op put to the vote of the validators on chain. It
+// is executed once validators with more than 2/3 of the voting power approve
+// it, rejected once that can't happen anymore and expires after Deadline.
+type AdminProposal struct {
+	ID          string               `json:"id"` // hash of the tx proposing it
+	Op          *AdminOPCmd          `json:"op"`
+	Description string               `json:"description,omitempty"`
+	Proposer    []byte               `json:"proposer"` // account of the propose tx
+	Height      int64                `json:"height"`
+	Deadline    int64                `json:"deadline"` // last height votes are counted at
+	Status      string               `json:"status"`
+	Votes       []*AdminProposalVote `json:"votes"`
+	ClosedAt    int64                `json:"closed_at,omitempty"`
+	Error       string               `json:"error,omitempty"`
+}
+
+// Vote records the vote of the validator at address, replacing its previous one
+func (p *AdminProposal) Vote(address []byte, approve bool, height int64) {
+	for _, v := range p.Votes {
+		if bytes.Equal(v.Address, address) {
+			v.Approve, v.Height = approve, height
+			return
+		}
+	}
+	p.Votes = append(p.Votes, &AdminProposalVote{Address: address, Approve: approve, Height: height})
+}
+
+// Tally sums the power of the validators in vals for and against p, the
+// votes of the ones not in vals anymore don't count
+func (p *AdminProposal) Tally(vals *ValidatorSet) (approve, reject int64) {
+	for _, v := range p.Votes {
+		_, val := vals.GetByAddress(v.Address)
+		if val == nil {
+			continue
+		}
+		if v.Approve {
+			approve += val.VotingPower
+		} else {
+			reject += val.VotingPower
+		}
+	}
+	return
+}
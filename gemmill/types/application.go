@@ -40,9 +40,16 @@ type Application interface {
 	SetCore(Core)
 }
 
+// GenesisApplication is an Application configured by the genesis, InitGenesis
+// is called once the genesis is known and before any block is executed
+type GenesisApplication interface {
+	InitGenesis(*GenesisDoc) error
+}
+
 type Core interface {
 	Query(byte, []byte) (interface{}, error)
 	GetBlockMeta(height int64) (*BlockMeta, error)
+	GetBlock(height int64) (*Block, *BlockMeta, error)
 }
 
 // type AppMaker func(config.Config) Application
@@ -19,6 +19,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 	"time"
 
@@ -36,13 +37,14 @@ type Block struct {
 }
 
 // TODO: version
-func MakeBlock(height int64, chainID string, txs []Tx, extxs []Tx, commit *Commit, proposer []byte,
+// blockTime is the proposer's clock, or the median time of commit from bft_time_height on.
+func MakeBlock(height int64, chainID string, txs []Tx, extxs []Tx, commit *Commit, blockTime time.Time, proposer []byte,
 	prevBlockID BlockID, valHash, appHash, receiptsHash []byte, partSize int) (*Block, *PartSet) {
 	block := &Block{
 		Header: &Header{
 			ChainID:         chainID,
 			Height:          height,
-			Time:            time.Now(),
+			Time:            blockTime,
 			NumTxs:          int64(len(txs) + len(extxs)),
 			LastBlockID:     prevBlockID,
 			ValidatorsHash:  valHash,
@@ -72,13 +74,11 @@ func (b *Block) ValidateBasic(chainID string, lastBlockHeight int64, lastBlockID
 	if b.Height != lastBlockHeight+1 {
 		return errors.New(gcmn.Fmt("Wrong Block.Header.Height. Expected %v, got %v", lastBlockHeight+1, b.Height))
 	}
-	/*	TODO: Determine bounds for Time
-		See blockchain/reactor "stopSyncingDurationMinutes"
-
-		if !b.Time.After(lastBlockTime) {
-			return errors.New("Invalid Block.Header.Time")
-		}
-	*/
+	// Time is only bounded once votes carry signed timestamps, from
+	// bft_time_height on State.ValidateBlock requires the median of them.
+	if b.Height != 1 && len(b.LastCommit.Timestamps) > 0 && !b.Time.After(lastBlockTime) {
+		return errors.New(gcmn.Fmt("Wrong Block.Header.Time. Expected after %v, got %v", lastBlockTime, b.Time))
+	}
 	if b.NumTxs != int64(len(b.Data.Txs)+len(b.Data.ExTxs)) {
 		return errors.New(gcmn.Fmt("Wrong Block.Header.NumTxs. Expected %v, got %v", len(b.Data.Txs)+len(b.Data.ExTxs), b.NumTxs))
 	}
@@ -245,6 +245,11 @@ type Commit struct {
 	// active ValidatorSet.
 	BlockID    BlockID `json:"blockID"`
 	Precommits []*Vote `json:"precommits"`
+	// Timestamps[i] is the signed vote time of Precommits[i], nil for votes
+	// signed without one and empty until bft_time is activated.
+	// Optional so older commits keep their encoding and hash, it must stay
+	// the last field.
+	Timestamps []*time.Time `json:"timestamps,omitempty" wire:"optional"`
 
 	// Volatile
 	firstPrecommit *Vote
@@ -304,7 +309,61 @@ func (commit *Commit) BitArray() *gcmn.BitArray {
 }
 
 func (commit *Commit) GetByIndex(index int) *Vote {
-	return commit.Precommits[index]
+	vote := commit.Precommits[index]
+	if vote != nil && index < len(commit.Timestamps) && commit.Timestamps[index] != nil {
+		vote.Timestamp = *commit.Timestamps[index]
+	}
+	return vote
+}
+
+type weightedTime struct {
+	time  time.Time
+	power int64
+}
+
+// timestamps returns the signed timestamps of the precommits for the
+// committed block with the voting power behind each, and their total power.
+// valSet must be the set the commit was signed by.
+func (commit *Commit) timestamps(valSet *ValidatorSet) ([]weightedTime, int64) {
+	times := make([]weightedTime, 0, len(commit.Timestamps))
+	total := int64(0)
+	for i, precommit := range commit.Precommits {
+		if precommit == nil || i >= len(commit.Timestamps) || commit.Timestamps[i] == nil ||
+			!commit.BlockID.Equals(precommit.BlockID) {
+			continue
+		}
+		_, val := valSet.GetByIndex(i)
+		if val == nil {
+			continue
+		}
+		times = append(times, weightedTime{*commit.Timestamps[i], val.VotingPower})
+		total += val.VotingPower
+	}
+	return times, total
+}
+
+// TimestampedPower returns the voting power of the precommits for the
+// committed block that carry a signed timestamp.
+func (commit *Commit) TimestampedPower(valSet *ValidatorSet) int64 {
+	_, total := commit.timestamps(valSet)
+	return total
+}
+
+// MedianTime returns the voting power weighted median of the timestamps of
+// the precommits for the committed block. valSet must be the set the commit
+// was signed by.
+func (commit *Commit) MedianTime(valSet *ValidatorSet) time.Time {
+	times, total := commit.timestamps(valSet)
+	sort.Slice(times, func(i, j int) bool { return times[i].time.Before(times[j].time) })
+
+	median := total / 2
+	for _, t := range times {
+		if median <= t.power {
+			return t.time
+		}
+		median -= t.power
+	}
+	return time.Time{}
 }
 
 func (commit *Commit) IsCommit() bool {
@@ -321,6 +380,10 @@ func (commit *Commit) ValidateBasic() error {
 	if len(commit.Precommits) == 0 {
 		return errors.New("No precommits in commit")
 	}
+	if len(commit.Timestamps) != 0 && len(commit.Timestamps) != len(commit.Precommits) {
+		return fmt.Errorf("Invalid commit timestamps. Expected %v, got %v",
+			len(commit.Precommits), len(commit.Timestamps))
+	}
 	height, round := commit.Height(), commit.Round()
 
 	// validate the precommits
@@ -355,6 +418,9 @@ func (commit *Commit) Hash() []byte {
 			bs[i] = precommit
 		}
 		commit.hash = merkle.SimpleHashFromBinaries(bs)
+		if len(commit.Timestamps) > 0 {
+			commit.hash = merkle.SimpleHashFromTwoHashes(commit.hash, merkle.SimpleHashFromBinary(commit.Timestamps))
+		}
 	}
 	return commit.hash
 }
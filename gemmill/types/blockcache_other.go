@@ -16,6 +16,7 @@ package types
 
 import (
 	"math/rand"
+	"time"
 
 	"github.com/dappledger/AnnChain/gemmill/go-crypto"
 )
@@ -63,6 +64,7 @@ func GenBlockForTest(height int64, ntxs, nexTxs int) *Block {
 		txs,
 		extxs,
 		GenCommitForTest(height, chainID),
+		time.Now(),
 		nil,
 		GenBlockID(),
 		crypto.CRandBytes(20),
@@ -14,6 +14,8 @@
 
 package types
 
+import "time"
+
 // canonical json is go-wire's json for structs with fields in alphabetical order
 
 type CanonicalJSONBlockID struct {
@@ -35,10 +37,11 @@ type CanonicalJSONProposal struct {
 }
 
 type CanonicalJSONVote struct {
-	BlockID CanonicalJSONBlockID `json:"block_id"`
-	Height  int64                  `json:"height"`
-	Round   int64                  `json:"round"`
-	Type    byte                 `json:"type"`
+	BlockID   CanonicalJSONBlockID `json:"block_id"`
+	Height    int64                `json:"height"`
+	Round     int64                `json:"round"`
+	Type      byte                 `json:"type"`
+	Timestamp time.Time            `json:"timestamp,omitempty"`
 }
 
 //------------------------------------
@@ -87,5 +90,6 @@ func CanonicalVote(vote *Vote) CanonicalJSONVote {
 		vote.Height,
 		vote.Round,
 		vote.Type,
+		vote.Timestamp,
 	}
 }
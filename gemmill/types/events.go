@@ -47,6 +47,8 @@ func EventStringVote() string             { return "Vote" }
 
 func EventStringSwitchToConsensus() string { return "SwitchToConsensus" }
 
+func EventStringValidatorUptimeLow() string { return "ValidatorUptimeLow" }
+
 func EventStringHookPrevote() string   { return "Hook Prevote" }
 func EventStringHookNewRound() string  { return "Hook NewRound" }
 func EventStringHookPropose() string   { return "Hook Propose" }
@@ -79,6 +81,8 @@ const (
 	EventDataTypeHookPrecommit = byte(0x24)
 	EventDataTypeHookCommit    = byte(0x25)
 	EventDataTypeHookExecute   = byte(0x26)
+
+	EventDataTypeValidatorUptimeLow = byte(0x31)
 )
 
 var _ = wire.RegisterInterface(
@@ -98,6 +102,8 @@ var _ = wire.RegisterInterface(
 	wire.ConcreteType{EventDataHookPrecommit{}, EventDataTypeHookPrecommit},
 	wire.ConcreteType{EventDataHookCommit{}, EventDataTypeHookCommit},
 	wire.ConcreteType{EventDataHookExecute{}, EventDataTypeHookExecute},
+
+	wire.ConcreteType{EventDataValidatorUptimeLow{}, EventDataTypeValidatorUptimeLow},
 )
 
 // Most event messages are basic types (a block, a transaction)
@@ -172,6 +178,16 @@ type EventDataHookExecute struct {
 	ResCh  chan ExecuteResult
 }
 
+// fired once when a validator's uptime over the window drops below the threshold
+type EventDataValidatorUptimeLow struct {
+	Height         int64  `json:"height"`
+	Address        []byte `json:"address"`
+	Window         int64  `json:"window"`
+	Missed         int64  `json:"missed"`
+	UptimePermille int64  `json:"uptime_permille"`
+	Threshold      int64  `json:"threshold"` // percent
+}
+
 func NewEventDataHookNewRound(height, round int64) EventDataHookNewRound {
 	return EventDataHookNewRound{
 		Height: height,
@@ -212,6 +228,8 @@ func (_ EventDataHookPrecommit) AssertIsTMEventData() {}
 func (_ EventDataHookCommit) AssertIsTMEventData()    {}
 func (_ EventDataHookExecute) AssertIsTMEventData()   {}
 
+func (_ EventDataValidatorUptimeLow) AssertIsTMEventData() {}
+
 //----------------------------------------
 // Wrappers for type safety
 
@@ -272,6 +290,10 @@ func FireEventTx(fireable events.Fireable, tx EventDataTx) {
 	fireEvent(fireable, EventStringTx(tx.Tx), tx)
 }
 
+func FireEventValidatorUptimeLow(fireable events.Fireable, data EventDataValidatorUptimeLow) {
+	fireEvent(fireable, EventStringValidatorUptimeLow(), data)
+}
+
 //--- EventDataRoundState events
 
 func FireEventNewRoundStep(fireable events.Fireable, rs EventDataRoundState) {
@@ -19,6 +19,7 @@ import (
 	"time"
 
 	"github.com/dappledger/AnnChain/gemmill/go-crypto"
+	"github.com/dappledger/AnnChain/gemmill/go-hash"
 	"github.com/dappledger/AnnChain/gemmill/go-wire"
 	gcmn "github.com/dappledger/AnnChain/gemmill/modules/go-common"
 )
@@ -45,6 +46,21 @@ type GenesisDoc struct {
 	Validators  []GenesisValidator `json:"validators"`
 	AppHash     []byte             `json:"app_hash"`
 	Plugins     string             `json:"plugins"`
+
+	// ConsensusParams is JSON only, State keeps the binary copy
+	ConsensusParams *ConsensusParams `json:"consensus_params,omitempty" binary:"-"`
+	// Precompiles is JSON only as well, the application installs them
+	Precompiles []*GenesisPrecompile `json:"precompiles,omitempty" binary:"-"`
+}
+
+// GenesisPrecompile installs a native contract kind of the application at
+// Address from ActivationHeight on, the nil gas fields keep its defaults
+type GenesisPrecompile struct {
+	Kind             string  `json:"kind"`
+	Address          string  `json:"address"`
+	ActivationHeight int64   `json:"activation_height,omitempty"`
+	BaseGas          *uint64 `json:"base_gas,omitempty"`
+	WordGas          *uint64 `json:"word_gas,omitempty"`
 }
 
 // Utility method for saving GenensisDoc as JSON file.
@@ -57,6 +73,12 @@ func (genDoc *GenesisDoc) JSONBytes() []byte {
 	return wire.JSONBytesPretty(genDoc)
 }
 
+// Hash is the hash of the JSON nodes exchange, operators pass it as the
+// genesis_hash of the nodes joining without a genesis file
+func (genDoc *GenesisDoc) Hash() []byte {
+	return hash.Keccak256Func(genDoc.JSONBytes())
+}
+
 //------------------------------------------------------------
 // Make genesis state from file
 
@@ -0,0 +1,113 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ConsensusParams are the consensus settings all validators must agree on.
+// Once set on chain they override block_size, block_part_size and the
+// timeout_* values of every node's config. Timeouts are in milliseconds.
+type ConsensusParams struct {
+	BlockSize             int64 `json:"block_size"`
+	BlockPartSize         int64 `json:"block_part_size"`
+	TimeoutPropose        int64 `json:"timeout_propose"`
+	TimeoutProposeDelta   int64 `json:"timeout_propose_delta"`
+	TimeoutPrevote        int64 `json:"timeout_prevote"`
+	TimeoutPrevoteDelta   int64 `json:"timeout_prevote_delta"`
+	TimeoutPrecommit      int64 `json:"timeout_precommit"`
+	TimeoutPrecommitDelta int64 `json:"timeout_precommit_delta"`
+	TimeoutCommit         int64 `json:"timeout_commit"`
+
+	// A validator that missed ProposerMaxMissed proposer slots in a row is
+	// skipped as proposer for the next ProposerSkipBlocks heights. 0 disables.
+	ProposerMaxMissed  int64 `json:"proposer_max_missed"`
+	ProposerSkipBlocks int64 `json:"proposer_skip_blocks"`
+
+	// From BFTTimeHeight on, the block time is the voting power weighted
+	// median of the timestamps the last +2/3 signed into their precommits.
+	// Votes are timestamped from the height before. 0 disables.
+	BFTTimeHeight int64 `json:"bft_time_height"`
+}
+
+func (params *ConsensusParams) ValidateBasic() error {
+	if params.BlockSize <= 0 {
+		return errors.New("block_size must be positive")
+	}
+	if params.BlockPartSize <= 0 || params.BlockPartSize > MaxBlockSize {
+		return errors.New("block_part_size out of range")
+	}
+	if params.TimeoutPropose <= 0 || params.TimeoutPrevote <= 0 || params.TimeoutPrecommit <= 0 {
+		return errors.New("timeout_propose, timeout_prevote and timeout_precommit must be positive")
+	}
+	if params.TimeoutProposeDelta < 0 || params.TimeoutPrevoteDelta < 0 || params.TimeoutPrecommitDelta < 0 || params.TimeoutCommit < 0 {
+		return errors.New("timeout deltas and timeout_commit cannot be negative")
+	}
+	if params.ProposerMaxMissed < 0 || params.ProposerSkipBlocks < 0 {
+		return errors.New("proposer_max_missed and proposer_skip_blocks cannot be negative")
+	}
+	if params.BFTTimeHeight < 0 {
+		return errors.New("bft_time_height cannot be negative")
+	}
+	return nil
+}
+
+// ValidateChange checks the params can replace prev after the block at
+// height. A new bft_time_height must leave the next height to timestamp the
+// votes the first median is taken from.
+func (params *ConsensusParams) ValidateChange(prev *ConsensusParams, height int64) error {
+	if err := params.ValidateBasic(); err != nil {
+		return err
+	}
+	if params.BFTTimeHeight > 0 && params.BFTTimeHeight != prev.bftTimeHeight() && params.BFTTimeHeight < height+2 {
+		return fmt.Errorf("bft_time_height must be at least %v", height+2)
+	}
+	return nil
+}
+
+func (params *ConsensusParams) bftTimeHeight() int64 {
+	if params == nil {
+		return 0
+	}
+	return params.BFTTimeHeight
+}
+
+// BFTTime reports whether the time of the block at height is the median
+// time of its LastCommit.
+func (params *ConsensusParams) BFTTime(height int64) bool {
+	return params != nil && params.BFTTimeHeight > 0 && height >= params.BFTTimeHeight
+}
+
+// SkipsProposers reports whether validators missing proposer slots get skipped
+func (params *ConsensusParams) SkipsProposers() bool {
+	return params != nil && params.ProposerMaxMissed > 0 && params.ProposerSkipBlocks > 0
+}
+
+func (params *ConsensusParams) Copy() *ConsensusParams {
+	if params == nil {
+		return nil
+	}
+	cp := *params
+	return &cp
+}
+
+// ConsensusParamsAttr is the msg of an AdminOpChangeConsensusParams cmd
+type ConsensusParamsAttr struct {
+	Params *ConsensusParams `json:"params"`
+	Addr   []byte           `json:"addr"`
+	Nonce  uint64           `json:"nonce"`
+}
@@ -240,6 +240,7 @@ func (privVal *PrivValidator) GetPrivateKey() crypto.PrivKey {
 func (privVal *PrivValidator) SignVote(chainID string, vote *Vote) error {
 	privVal.mtx.Lock()
 	defer privVal.mtx.Unlock()
+	privVal.reuseLastTimestamp(chainID, vote)
 	signature, err := privVal.signBytesHRS(vote.Height, vote.Round, voteToStep(vote), SignBytes(chainID, vote))
 	if err != nil {
 		return errors.New(gcmn.Fmt("Error signing vote: %v", err))
@@ -248,6 +249,25 @@ func (privVal *PrivValidator) SignVote(chainID string, vote *Vote) error {
 	return nil
 }
 
+// reuseLastTimestamp gives vote the timestamp of the last signed vote when
+// the two only differ by it, so re-signing the same vote (e.g. after a
+// restart) returns the last signature instead of failing as a conflict.
+func (privVal *PrivValidator) reuseLastTimestamp(chainID string, vote *Vote) {
+	if vote.Timestamp.IsZero() || privVal.LastSignBytes == nil ||
+		privVal.LastHeight != vote.Height || privVal.LastRound != vote.Round || privVal.LastStep != voteToStep(vote) {
+		return
+	}
+	var last CanonicalJSONOnceVote
+	if err := wire.ReadJSONBytes(privVal.LastSignBytes, &last); err != nil || last.Vote.Timestamp.IsZero() {
+		return
+	}
+	cp := *vote
+	cp.Timestamp = last.Vote.Timestamp
+	if bytes.Equal(SignBytes(chainID, &cp), privVal.LastSignBytes) {
+		vote.Timestamp = cp.Timestamp
+	}
+}
+
 func (privVal *PrivValidator) SignProposal(chainID string, proposal *Proposal) error {
 	privVal.mtx.Lock()
 	defer privVal.mtx.Unlock()
@@ -0,0 +1,125 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"bytes"
+	"sort"
+)
+
+// ProposerStat tracks how a validator performs in its proposer slots.
+type ProposerStat struct {
+	Address          []byte `json:"address"`
+	Missed           int64  `json:"missed"` // proposer slots missed in a row
+	TotalMissed      int64  `json:"total_missed"`
+	Skipped          int64  `json:"skipped"` // proposer slots handed to others
+	LastMissedHeight int64  `json:"last_missed_height"`
+}
+
+// ProposerStats is kept in state, sorted by address. It is only updated from
+// committed blocks so every node computes the same proposers.
+type ProposerStats []*ProposerStat
+
+func (ps ProposerStats) Copy() ProposerStats {
+	if ps == nil {
+		return nil
+	}
+	cp := make(ProposerStats, len(ps))
+	for i, st := range ps {
+		stCopy := *st
+		cp[i] = &stCopy
+	}
+	return cp
+}
+
+func (ps ProposerStats) Get(address []byte) *ProposerStat {
+	idx := ps.search(address)
+	if idx != len(ps) && bytes.Equal(ps[idx].Address, address) {
+		return ps[idx]
+	}
+	return nil
+}
+
+func (ps ProposerStats) search(address []byte) int {
+	return sort.Search(len(ps), func(i int) bool {
+		return bytes.Compare(address, ps[i].Address) <= 0
+	})
+}
+
+// getOrAdd returns the stat of address, inserting an empty one if missing
+func (ps *ProposerStats) getOrAdd(address []byte) *ProposerStat {
+	idx := ps.search(address)
+	if idx != len(*ps) && bytes.Equal((*ps)[idx].Address, address) {
+		return (*ps)[idx]
+	}
+	st := &ProposerStat{Address: address}
+	*ps = append(*ps, nil)
+	copy((*ps)[idx+1:], (*ps)[idx:])
+	(*ps)[idx] = st
+	return st
+}
+
+// IsSkipped reports whether address gets no proposer slots at height
+func (ps ProposerStats) IsSkipped(address []byte, height int64, params *ConsensusParams) bool {
+	if !params.SkipsProposers() {
+		return false
+	}
+	st := ps.Get(address)
+	return st != nil && st.Missed >= params.ProposerMaxMissed && height <= st.LastMissedHeight+params.ProposerSkipBlocks
+}
+
+// Proposer returns who proposes for valSet, already incremented to the round, at height
+func (ps ProposerStats) Proposer(valSet *ValidatorSet, height int64, params *ConsensusParams) *Validator {
+	return valSet.ProposerSkipping(func(val *Validator) bool {
+		return ps.IsSkipped(val.Address, height, params)
+	})
+}
+
+// Update accounts the proposer slots of height, committed at commitRound.
+// decided and params are the stats and consensus params the proposers of
+// height were chosen with, valSet is the validator set of height at round 0.
+// Proposers of the rounds before commitRound missed their slot.
+func (ps ProposerStats) Update(decided ProposerStats, valSet *ValidatorSet, height, commitRound int64, params *ConsensusParams) ProposerStats {
+	stats := ps.Copy()
+	vals := valSet.Copy()
+	for round := int64(0); round <= commitRound; round++ {
+		if round > 0 {
+			vals.IncrementAccum(1)
+		}
+		if natural := vals.Proposer(); decided.IsSkipped(natural.Address, height, params) {
+			stats.getOrAdd(natural.Address).Skipped++
+		}
+		st := stats.getOrAdd(decided.Proposer(vals, height, params).Address)
+		if round == commitRound {
+			st.Missed = 0
+		} else {
+			st.Missed++
+			st.TotalMissed++
+			st.LastMissedHeight = height
+		}
+	}
+	return stats
+}
+
+// Prune drops the stats of validators no longer in valSet
+func (ps ProposerStats) Prune(valSet *ValidatorSet) ProposerStats {
+	stats := ps[:0:0]
+	for _, st := range ps {
+		if valSet.HasAddress(st.Address) {
+			stats = append(stats, st)
+		}
+	}
+	return stats
+}
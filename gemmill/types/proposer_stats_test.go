@@ -0,0 +1,73 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestProposerStatsSkipMissing(t *testing.T) {
+	vset := NewValidatorSet([]*Validator{
+		NewValidator(randPubKey(), 1, false),
+		NewValidator(randPubKey(), 1, false),
+		NewValidator(randPubKey(), 1, false),
+	})
+	params := &ConsensusParams{ProposerMaxMissed: 2, ProposerSkipBlocks: 5}
+
+	var stats ProposerStats
+	offline := vset.Proposer().Address
+	// the offline validator misses round 0 of two heights, round 1 commits
+	for height := int64(1); height <= 2; height++ {
+		if proposer := stats.Proposer(vset, height, params); !bytes.Equal(proposer.Address, offline) {
+			t.Fatalf("height %d: expected %X to propose before it is skipped", height, offline)
+		}
+		stats = stats.Update(stats, vset, height, 1, params)
+	}
+	st := stats.Get(offline)
+	if st == nil || st.Missed != 2 || st.TotalMissed != 2 || st.LastMissedHeight != 2 {
+		t.Fatalf("unexpected stat of missing validator %+v", st)
+	}
+
+	for height := int64(3); height <= 2+params.ProposerSkipBlocks; height++ {
+		if !stats.IsSkipped(offline, height, params) {
+			t.Fatalf("expected %X to be skipped at height %d", offline, height)
+		}
+		if proposer := stats.Proposer(vset, height, params); bytes.Equal(proposer.Address, offline) {
+			t.Fatalf("height %d: skipped validator was chosen as proposer", height)
+		}
+	}
+	if stats.IsSkipped(offline, 3+params.ProposerSkipBlocks, params) {
+		t.Fatal("expected skipping to end")
+	}
+	if stats.IsSkipped(offline, 3, nil) {
+		t.Fatal("expected no skipping without consensus params")
+	}
+
+	// committing at round 0 while skipped hands the slot to another validator
+	stats = stats.Update(stats, vset, 3, 0, params)
+	if st := stats.Get(offline); st.Skipped != 1 || st.Missed != 2 {
+		t.Fatalf("unexpected stat of skipped validator %+v", st)
+	}
+	substitute := stats.Proposer(vset, 3, params).Address
+	if st := stats.Get(substitute); st == nil || st.Missed != 0 {
+		t.Fatalf("unexpected stat of substitute proposer %+v", st)
+	}
+
+	pruned := stats.Prune(NewValidatorSet([]*Validator{vset.Validators[0]}))
+	if len(pruned) > 1 || (len(pruned) == 1 && !bytes.Equal(pruned[0].Address, vset.Validators[0].Address)) {
+		t.Fatalf("unexpected pruned stats %v", pruned)
+	}
+}
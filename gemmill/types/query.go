@@ -24,10 +24,25 @@ const (
 	QueryTx          = 0x02
 )
 
+const (
+	TxStatusValid   uint64 = 0
+	TxStatusInvalid uint64 = 1
+)
+
 type TxExecutionResult struct {
 	Height    uint64 `json:"height"`
 	BlockHash []byte `json:"blockhash"`
 	Index     uint64 `json:"index"`
+	Status    uint64 `json:"status"`
+	Error     string `json:"error"`
+}
+
+// legacyTxExecutionResult is the layout written before status was recorded,
+// every tx stored that way is treated as valid
+type legacyTxExecutionResult struct {
+	Height    uint64
+	BlockHash []byte
+	Index     uint64
 }
 
 func (i *TxExecutionResult) ToBytes() ([]byte, error) {
@@ -35,5 +50,25 @@ func (i *TxExecutionResult) ToBytes() ([]byte, error) {
 }
 
 func (i *TxExecutionResult) FromBytes(data []byte) error {
-	return rlp.DecodeBytes(data, i)
+	if err := rlp.DecodeBytes(data, i); err == nil {
+		return nil
+	}
+	legacy := &legacyTxExecutionResult{}
+	if err := rlp.DecodeBytes(data, legacy); err != nil {
+		return err
+	}
+	*i = TxExecutionResult{
+		Height:    legacy.Height,
+		BlockHash: legacy.BlockHash,
+		Index:     legacy.Index,
+		Status:    TxStatusValid,
+	}
+	return nil
+}
+
+func TxStatusString(status uint64) string {
+	if status == TxStatusInvalid {
+		return "invalid"
+	}
+	return "valid"
 }
@@ -0,0 +1,58 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dappledger/AnnChain/eth/rlp"
+)
+
+func TestTxExecutionResultRoundTrip(t *testing.T) {
+	e := &TxExecutionResult{
+		Height:    10,
+		BlockHash: []byte("blockhash"),
+		Index:     3,
+		Status:    TxStatusInvalid,
+		Error:     "nonce too low",
+	}
+	data, err := e.ToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &TxExecutionResult{}
+	if err := got.FromBytes(data); err != nil {
+		t.Fatal(err)
+	}
+	if got.Height != e.Height || got.Index != e.Index || !bytes.Equal(got.BlockHash, e.BlockHash) ||
+		got.Status != e.Status || got.Error != e.Error {
+		t.Fatalf("expected %v, got %v", e, got)
+	}
+}
+
+func TestTxExecutionResultLegacy(t *testing.T) {
+	data, err := rlp.EncodeToBytes(&legacyTxExecutionResult{Height: 7, BlockHash: []byte("old"), Index: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &TxExecutionResult{}
+	if err := got.FromBytes(data); err != nil {
+		t.Fatal(err)
+	}
+	if got.Height != 7 || got.Index != 1 || got.Status != TxStatusValid || got.Error != "" {
+		t.Fatalf("unexpected legacy decode result %v", got)
+	}
+}
@@ -20,12 +20,43 @@ import (
 	"github.com/dappledger/AnnChain/gemmill/go-crypto"
 	"github.com/dappledger/AnnChain/gemmill/go-wire"
 	"github.com/dappledger/AnnChain/gemmill/p2p"
+	"github.com/dappledger/AnnChain/gemmill/refuse_list"
 	"github.com/dappledger/AnnChain/gemmill/rpc/types"
 )
 
 type ResultBlockchainInfo struct {
-	LastHeight int64        `json:"last_height"`
-	BlockMetas []*BlockMeta `json:"block_metas"`
+	LastHeight   int64        `json:"last_height"`
+	PrunedHeight int64        `json:"pruned_height,omitempty"` // blocks at or below only have their metas
+	BlockMetas   []*BlockMeta `json:"block_metas"`
+}
+
+type ResultSyncInfo struct {
+	Syncing         bool        `json:"syncing"` // fast syncing, not yet switched to consensus
+	StartHeight     int64       `json:"start_height"`
+	Height          int64       `json:"height"` // last block stored
+	MaxPeerHeight   int64       `json:"max_peer_height"`
+	BlocksPerSecond int64       `json:"blocks_per_second"`
+	NumPending      int32       `json:"num_pending"`
+	NumRequesters   int         `json:"num_requesters"`
+	NumBadBlocks    int64       `json:"num_bad_blocks"`
+	Peers           []*SyncPeer `json:"peers"`
+	BannedPeers     []string    `json:"banned_peers"`
+}
+
+type SyncPeer struct {
+	ID         string `json:"id"`
+	Height     int64  `json:"height"`
+	NumPending int32  `json:"num_pending"`
+	Score      int64  `json:"score"` // blocks served and verified
+}
+
+// ResultCommit is a header with the precommits committing it, canonical
+// when they are the LastCommit of the next block, otherwise they are the
+// ones the node saw for its latest block
+type ResultCommit struct {
+	Header    *Header `json:"header"`
+	Commit    *Commit `json:"commit"`
+	Canonical bool    `json:"canonical"`
 }
 
 type ResultGenesis struct {
@@ -39,6 +70,7 @@ type ResultHealthInfo struct {
 type ResultBlock struct {
 	BlockMeta *BlockMeta `json:"block_meta"`
 	Block     *Block     `json:"block"`
+	Pruned    bool       `json:"pruned,omitempty"`
 }
 
 type ResultLastHeight struct {
@@ -59,9 +91,10 @@ type ResultStatus struct {
 }
 
 type ResultNetInfo struct {
-	Listening bool     `json:"listening"`
-	Listeners []string `json:"listeners"`
-	Peers     []*Peer  `json:"peers"`
+	Listening bool                `json:"listening"`
+	Listeners []string            `json:"listeners"`
+	Peers     []*Peer             `json:"peers"`
+	Rejected  []*p2p.RejectedPeer `json:"rejected"` // latest refused handshakes
 }
 
 type ResultDialSeeds struct {
@@ -70,6 +103,10 @@ type ResultDialSeeds struct {
 type Peer struct {
 	p2p.NodeInfo     `json:"node_info"`
 	IsOutbound       bool                 `json:"is_outbound"`
+	IsPersistent     bool                 `json:"is_persistent"`
+	IsUnconditional  bool                 `json:"is_unconditional"`
+	IsPrivate        bool                 `json:"is_private"`
+	Score            int                  `json:"score"`
 	ConnectionStatus p2p.ConnectionStatus `json:"connection_status"`
 }
 
@@ -79,6 +116,11 @@ type ResultValidator struct {
 	VotingPower int64  `json:"voting_power"`
 	Accum       int64  `json:"accum"`
 	IsCA        bool   `json:"is_ca"`
+
+	MissedProposals      int64 `json:"missed_proposals"` // in a row
+	TotalMissedProposals int64 `json:"total_missed_proposals"`
+	SkippedProposals     int64 `json:"skipped_proposals"`
+	ProposerSkipped      bool  `json:"proposer_skipped"` // skipped at the next height
 }
 
 func MakeResultValidators(vs []*Validator) []*ResultValidator {
@@ -95,9 +137,36 @@ func MakeResultValidators(vs []*Validator) []*ResultValidator {
 	return rets
 }
 
+// SetProposerStats fills in the proposer stats of the validators for height
+func SetProposerStats(rvs []*ResultValidator, stats ProposerStats, height int64, params *ConsensusParams) {
+	for _, rv := range rvs {
+		if st := stats.Get(rv.Address); st != nil {
+			rv.MissedProposals = st.Missed
+			rv.TotalMissedProposals = st.TotalMissed
+			rv.SkippedProposals = st.Skipped
+			rv.ProposerSkipped = stats.IsSkipped(rv.Address, height, params)
+		}
+	}
+}
+
 type ResultValidators struct {
 	BlockHeight int64              `json:"block_height"`
 	Validators  []*ResultValidator `json:"validators"`
+	// ValidatorSet is the set that signed the block at BlockHeight, accums
+	// included so it hashes to the header's ValidatorsHash. Only set when
+	// a height is asked for.
+	ValidatorSet *ValidatorSet `json:"validator_set,omitempty"`
+}
+
+type ResultValidatorUptime struct {
+	BlockHeight int64              `json:"block_height"` // last height accounted
+	Window      int64              `json:"window"`
+	Validators  []*ValidatorUptime `json:"validators"`
+}
+
+type ResultValidatorSigningInfo struct {
+	BlockHeight int64                   `json:"block_height"` // last height accounted
+	Infos       []*ValidatorSigningInfo `json:"infos"`
 }
 
 type ResultDumpConsensusState struct {
@@ -118,6 +187,14 @@ type ResultRequestAdminOP struct {
 	Log  string   `json:"log"`
 }
 
+type ResultAdminProposals struct {
+	Proposals []*AdminProposal `json:"proposals"`
+}
+
+type ResultAdminProposal struct {
+	Proposal *AdminProposal `json:"proposal"`
+}
+
 type ResultBroadcastTxCommit struct {
 	Code   CodeType `json:"code"`
 	Data   []byte   `json:"data"`
@@ -131,6 +208,8 @@ type ResultTransaction struct {
 	TransactionIndex uint64 `json:"transaction_index"`
 	RawTransaction   []byte `json:"raw_transaction"`
 	Timestamp        uint64 `json:"timestamp"`
+	Status           uint64 `json:"status"`
+	Error            string `json:"error"`
 }
 
 type ResultUnconfirmedTxs struct {
@@ -157,10 +236,40 @@ type ResultQuery struct {
 	Result Result `json:"result"`
 }
 
+// PluginInfo is a core plugin loaded from the genesis
+type PluginInfo struct {
+	Name     string   `json:"name"`
+	Requires []string `json:"requires,omitempty"`
+	Running  bool     `json:"running"`
+	Healthy  bool     `json:"healthy"`
+	Error    string   `json:"error,omitempty"`
+}
+
+type ResultPlugins struct {
+	Plugins    []*PluginInfo `json:"plugins"`
+	Registered []string      `json:"registered"` // all the plugins a genesis may name
+}
+
+type ResultBans struct {
+	Bans []*p2p.Ban `json:"bans"`
+}
+
+type ResultClearBans struct {
+	Cleared []*p2p.Ban `json:"cleared"`
+}
+
 type ResultRefuseList struct {
 	Result []string `json:"result"`
 }
 
+type ResultRefuseEntries struct {
+	Entries []*refuse_list.Entry `json:"entries"`
+}
+
+type ResultRefuseHistory struct {
+	Records []*refuse_list.Record `json:"records"`
+}
+
 type ResultUnsafeFlushMempool struct{}
 
 type ResultUnsafeSetConfig struct{}
@@ -206,19 +315,28 @@ const (
 	ResultTypeBlock          = byte(0x03)
 	ResultTypeLastHeight     = byte(0x04)
 	ResultTypeHealthInfo     = byte(0x05)
+	ResultTypeSyncInfo       = byte(0x06)
+	ResultTypeCommit         = byte(0x07)
+	ResultTypePlugins        = byte(0x08)
 
 	// 0x2 bytes are for the network
 	ResultTypeStatus    = byte(0x20)
 	ResultTypeNetInfo   = byte(0x21)
 	ResultTypeDialSeeds = byte(0x22)
 	ResultTypeShards    = byte(0x23)
+	ResultTypeBans      = byte(0x24)
+	ResultTypeClearBans = byte(0x25)
 
 	// 0x1  bytes are for refuseList
-	ResultTypeRefuseList = byte(0x10)
+	ResultTypeRefuseList    = byte(0x10)
+	ResultTypeRefuseEntries = byte(0x11)
+	ResultTypeRefuseHistory = byte(0x12)
 
 	// 0x4 bytes are for the consensus
 	ResultTypeValidators         = byte(0x40)
 	ResultTypeDumpConsensusState = byte(0x41)
+	ResultTypeValidatorUptime    = byte(0x42)
+	ResultTypeSigningInfo        = byte(0x43)
 
 	// 0x6 bytes are for txs / the application
 	ResultTypeBroadcastTx       = byte(0x60)
@@ -227,6 +345,8 @@ const (
 	ResultTypeRequestAdminOP    = byte(0x63)
 	ResultTypeNumArchivedBlocks = byte(0x64)
 	ResultTypeNumLimitTx        = byte(0x65)
+	ResultTypeAdminProposals    = byte(0x66)
+	ResultTypeAdminProposal     = byte(0x67)
 
 	// 0x7 bytes are for querying the application
 	ResultTypeQuery = byte(0x70)
@@ -260,16 +380,25 @@ var _ = wire.RegisterInterface(
 	wire.ConcreteType{&ResultBlockchainInfo{}, ResultTypeBlockchainInfo},
 	wire.ConcreteType{&ResultBlock{}, ResultTypeBlock},
 	wire.ConcreteType{&ResultHealthInfo{}, ResultTypeHealthInfo},
+	wire.ConcreteType{&ResultSyncInfo{}, ResultTypeSyncInfo},
+	wire.ConcreteType{&ResultCommit{}, ResultTypeCommit},
+	wire.ConcreteType{&ResultPlugins{}, ResultTypePlugins},
 	wire.ConcreteType{&ResultLastHeight{}, ResultTypeLastHeight},
 	wire.ConcreteType{&ResultStatus{}, ResultTypeStatus},
 	wire.ConcreteType{&ResultShards{}, ResultTypeShards},
 	wire.ConcreteType{&ResultNetInfo{}, ResultTypeNetInfo},
 	wire.ConcreteType{&ResultDialSeeds{}, ResultTypeDialSeeds},
+	wire.ConcreteType{&ResultBans{}, ResultTypeBans},
+	wire.ConcreteType{&ResultClearBans{}, ResultTypeClearBans},
 	wire.ConcreteType{&ResultValidators{}, ResultTypeValidators},
 	wire.ConcreteType{&ResultDumpConsensusState{}, ResultTypeDumpConsensusState},
+	wire.ConcreteType{&ResultValidatorUptime{}, ResultTypeValidatorUptime},
+	wire.ConcreteType{&ResultValidatorSigningInfo{}, ResultTypeSigningInfo},
 	wire.ConcreteType{&ResultBroadcastTx{}, ResultTypeBroadcastTx},
 	wire.ConcreteType{&ResultBroadcastTxCommit{}, ResultTypeBroadcastTxCommit},
 	wire.ConcreteType{&ResultRequestAdminOP{}, ResultTypeRequestAdminOP},
+	wire.ConcreteType{&ResultAdminProposals{}, ResultTypeAdminProposals},
+	wire.ConcreteType{&ResultAdminProposal{}, ResultTypeAdminProposal},
 	wire.ConcreteType{&ResultUnconfirmedTxs{}, ResultTypeUnconfirmedTxs},
 	wire.ConcreteType{&ResultNumArchivedBlocks{}, ResultTypeNumArchivedBlocks},
 	wire.ConcreteType{&ResultNumLimitTx{}, ResultTypeNumLimitTx},
@@ -285,5 +414,7 @@ var _ = wire.RegisterInterface(
 	wire.ConcreteType{&ResultInfo{}, ResultTypeInfo},
 	wire.ConcreteType{&ResultSurveillance{}, ResultTypeSurveillance},
 	wire.ConcreteType{&ResultRefuseList{}, ResultTypeRefuseList},
+	wire.ConcreteType{&ResultRefuseEntries{}, ResultTypeRefuseEntries},
+	wire.ConcreteType{&ResultRefuseHistory{}, ResultTypeRefuseHistory},
 	wire.ConcreteType{&ResultCoreVersion{}, ResultTypeCoreVersion},
 )
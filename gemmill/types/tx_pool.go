@@ -31,6 +31,20 @@ type TxPool interface {
 	RegisterFilter(filter IFilter)
 }
 
+// TxsNotifier is implemented by tx pools that can signal consensus when
+// new txs become available, instead of it blocking on TxsFrontWait.
+// The channel should be buffered and fed without blocking.
+type TxsNotifier interface {
+	TxsAvailable() <-chan struct{}
+}
+
+// ExecutableCounter is implemented by tx pools keeping txs that can't go
+// into the next block yet, e.g. waiting for a nonce gap to close. Consensus
+// waits for executable txs only, Size still counts all of them.
+type ExecutableCounter interface {
+	ExecutableSize() int
+}
+
 // A transaction that successfully ran
 type TxInPool struct {
 	Counter int64 // a simple incrementing counter
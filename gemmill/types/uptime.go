@@ -0,0 +1,49 @@
+// Copyright 2017 ZhongAn Information Technology Services Co.,Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// ValidatorSigningInfo summarizes the precommits of a validator since the
+// uptime plugin first saw it in a validator set.
+type ValidatorSigningInfo struct {
+	Address          []byte `json:"address"`
+	StartHeight      int64  `json:"start_height"`
+	LastHeight       int64  `json:"last_height"` // last height accounted
+	SignedBlocks     int64  `json:"signed_blocks"`
+	MissedBlocks     int64  `json:"missed_blocks"`
+	MissedInRow      int64  `json:"missed_in_row"`
+	LastMissedHeight int64  `json:"last_missed_height"`
+	WindowSigned     int64  `json:"window_signed"` // signed within the configured uptime_window
+	WindowMissed     int64  `json:"window_missed"` // missed within the configured uptime_window
+	BelowThreshold   bool   `json:"below_threshold"`
+}
+
+// ValidatorUptime is the signing record of a validator over a window of heights.
+type ValidatorUptime struct {
+	Address        []byte `json:"address"`
+	FromHeight     int64  `json:"from_height"`
+	ToHeight       int64  `json:"to_height"`
+	Signed         int64  `json:"signed"`
+	Missed         int64  `json:"missed"`
+	UptimePermille int64  `json:"uptime_permille"`
+}
+
+// UptimePermille returns signed out of signed+missed in per mille, a validator
+// with nothing accounted yet is taken as fully up.
+func UptimePermille(signed, missed int64) int64 {
+	if signed+missed == 0 {
+		return 1000
+	}
+	return signed * 1000 / (signed + missed)
+}
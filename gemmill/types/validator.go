@@ -26,7 +26,7 @@ import (
 
 // Volatile state for each Validator
 // TODO: make non-volatile identity
-// 	- Remove Accum - it can be computed, and now valset becomes identifying
+//   - Remove Accum - it can be computed, and now valset becomes identifying
 type Validator struct {
 	Address     []byte        `json:"address"`
 	PubKey      crypto.PubKey `json:"pub_key"`
@@ -49,6 +49,10 @@ type ValidatorAttr struct {
 	Cmd    ValidatorCmd `json:"cmd"`
 	Addr   []byte       `json:"addr"`
 	Nonce  uint64       `json:"nonce"`
+	// Reason and RefuseFor (seconds, 0 for ever) go to the refuse list entry
+	// of a removed node
+	Reason    string `json:"reason,omitempty"`
+	RefuseFor int64  `json:"refuseFor,omitempty"`
 }
 
 func (m *ValidatorAttr) Reset() { *m = ValidatorAttr{} }
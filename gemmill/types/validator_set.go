@@ -140,6 +140,26 @@ func (valSet *ValidatorSet) Proposer() (proposer *Validator) {
 	return valSet.proposer.Copy()
 }
 
+// ProposerSkipping returns the proposer unless skip rejects it, in which case
+// the validator with the next highest accum that skip accepts proposes.
+// If skip rejects all validators the proposer is returned anyway.
+func (valSet *ValidatorSet) ProposerSkipping(skip func(*Validator) bool) *Validator {
+	proposer := valSet.Proposer()
+	if proposer == nil || !skip(proposer) {
+		return proposer
+	}
+	var next *Validator
+	for _, val := range valSet.Validators {
+		if !skip(val) {
+			next = next.CompareAccum(val)
+		}
+	}
+	if next == nil {
+		return proposer
+	}
+	return next.Copy()
+}
+
 func (valSet *ValidatorSet) Hash() []byte {
 	if len(valSet.Validators) == 0 {
 		return nil
@@ -231,8 +251,13 @@ func (valSet *ValidatorSet) VerifyCommit(chainID string, blockID BlockID, height
 	talliedVotingPower := int64(0)
 	round := commit.Round()
 
-	for idx, precommit := range commit.Precommits {
+	if len(commit.Timestamps) != 0 && len(commit.Timestamps) != len(commit.Precommits) {
+		return fmt.Errorf("Invalid commit -- wrong timestamps size: %v vs %v", len(commit.Timestamps), len(commit.Precommits))
+	}
+
+	for idx := range commit.Precommits {
 		// may be nil if validator skipped.
+		precommit := commit.GetByIndex(idx)
 		if precommit == nil {
 			continue
 		}
@@ -266,6 +291,41 @@ func (valSet *ValidatorSet) VerifyCommit(chainID string, blockID BlockID, height
 	}
 }
 
+// VerifyCommitTrusting checks that validators of valSet holding more than 1/3
+// of its voting power signed commit for blockID. The commit may come from a
+// later set, so precommits are matched by address and those of validators
+// not in valSet are skipped.
+func (valSet *ValidatorSet) VerifyCommitTrusting(chainID string, blockID BlockID, height int64, commit *Commit) error {
+	if height != commit.Height() {
+		return fmt.Errorf("Invalid commit -- wrong height: %v vs %v", height, commit.Height())
+	}
+
+	talliedVotingPower := int64(0)
+	counted := make(map[int]bool)
+	for idx := range commit.Precommits {
+		precommit := commit.GetByIndex(idx)
+		if precommit == nil || precommit.Height != height || precommit.Type != VoteTypePrecommit ||
+			!blockID.Equals(precommit.BlockID) {
+			continue
+		}
+		i, val := valSet.GetByAddress(precommit.ValidatorAddress)
+		if val == nil || counted[i] {
+			continue
+		}
+		if !val.PubKey.VerifyBytes(SignBytes(chainID, precommit), precommit.Signature) {
+			return fmt.Errorf("Invalid commit -- invalid signature: %v", precommit)
+		}
+		counted[i] = true
+		talliedVotingPower += val.VotingPower
+	}
+
+	if talliedVotingPower > valSet.TotalVotingPower()/3 {
+		return nil
+	}
+	return fmt.Errorf("Invalid commit -- insufficient trusted voting power: got %v, needed %v",
+		talliedVotingPower, valSet.TotalVotingPower()/3+1)
+}
+
 func (valSet *ValidatorSet) String() string {
 	return valSet.StringIndented("")
 }
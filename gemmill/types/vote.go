@@ -18,6 +18,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/dappledger/AnnChain/gemmill/go-crypto"
 	"github.com/dappledger/AnnChain/gemmill/go-wire"
@@ -68,6 +69,10 @@ type Vote struct {
 	Type             byte             `json:"type"`
 	BlockID          BlockID          `json:"block_id"` // zero if vote is nil.
 	Signature        crypto.Signature `json:"signature"`
+
+	// Timestamp is signed but not part of the vote encoding, it travels in
+	// VoteMessage and Commit.Timestamps. Zero before bft_time_height.
+	Timestamp time.Time `json:"-"`
 }
 
 func (vote *Vote) WriteSignBytes(chainID string, w io.Writer, n *int, err *error) {
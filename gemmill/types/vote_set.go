@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	gcmn "github.com/dappledger/AnnChain/gemmill/modules/go-common"
 )
@@ -483,10 +484,23 @@ func (voteSet *VoteSet) MakeCommit() *Commit {
 	// For every validator, get the precommit
 	votesCopy := make([]*Vote, len(voteSet.votes))
 	copy(votesCopy, voteSet.votes)
-	return &Commit{
+	commit := &Commit{
 		BlockID:    *voteSet.maj23,
 		Precommits: votesCopy,
 	}
+	// only carry timestamps when votes were signed with them, so commits
+	// without bft_time keep their old encoding
+	for i, vote := range votesCopy {
+		if vote == nil || vote.Timestamp.IsZero() {
+			continue
+		}
+		if commit.Timestamps == nil {
+			commit.Timestamps = make([]*time.Time, len(votesCopy))
+		}
+		timestamp := vote.Timestamp
+		commit.Timestamps[i] = &timestamp
+	}
+	return commit
 }
 
 //--------------------------------------------------------------------------------
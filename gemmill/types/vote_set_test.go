@@ -17,8 +17,10 @@ package types
 import (
 	"bytes"
 	"testing"
+	"time"
 
 	"github.com/dappledger/AnnChain/gemmill/go-crypto"
+	"github.com/dappledger/AnnChain/gemmill/go-wire"
 	. "github.com/dappledger/AnnChain/gemmill/modules/go-common"
 )
 
@@ -482,3 +484,122 @@ func TestMakeCommit(t *testing.T) {
 	}
 
 }
+
+func TestCommitTimestamps(t *testing.T) {
+	height, round := 2, 0
+	voteSet, valSet, privValidators := randVoteSet(height, round, VoteTypePrecommit, 5, 1)
+	blockID := BlockID{crypto.CRandBytes(32), PartSetHeader{123, crypto.CRandBytes(32)}}
+	voteProto := &Vote{
+		ValidatorIndex: -1,
+		Height:         int64(height),
+		Round:          int64(round),
+		Type:           VoteTypePrecommit,
+		BlockID:        blockID,
+	}
+
+	// the last validator signs without a timestamp
+	base := time.Unix(1500000000, 0)
+	offsets := []time.Duration{3 * time.Second, time.Second, 4 * time.Second, 2 * time.Second}
+	for i := 0; i < 5; i++ {
+		vote := withValidator(voteProto, privValidators[i].Address, i)
+		if i < len(offsets) {
+			vote.Timestamp = base.Add(offsets[i])
+		}
+		if _, err := signAddVote(privValidators[i], vote, voteSet); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	commit := voteSet.MakeCommit()
+	if len(commit.Timestamps) != 5 || commit.Timestamps[4] != nil {
+		t.Fatalf("unexpected timestamps %v", commit.Timestamps)
+	}
+	if power := commit.TimestampedPower(valSet); power != 4 {
+		t.Errorf("timestamped power %v, expected 4", power)
+	}
+	if median := commit.MedianTime(valSet); !median.Equal(base.Add(2 * time.Second)) {
+		t.Errorf("median time %v, expected %v", median, base.Add(2*time.Second))
+	}
+
+	decoded := new(Commit)
+	if err := wire.ReadBinaryBytes(wire.BinaryBytes(*commit), decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded.Hash(), commit.Hash()) {
+		t.Error("commit hash changed after decoding")
+	}
+	if err := valSet.VerifyCommit("test_chain_id", blockID, int64(height), decoded); err != nil {
+		t.Errorf("decoded commit does not verify: %v", err)
+	}
+	*decoded.Timestamps[0] = decoded.Timestamps[0].Add(time.Second)
+	if err := valSet.VerifyCommit("test_chain_id", blockID, int64(height), decoded); err == nil {
+		t.Error("commit with a forged timestamp verifies")
+	}
+}
+
+func TestCommitWithoutTimestampsKeepsEncoding(t *testing.T) {
+	type legacyCommit struct {
+		BlockID    BlockID
+		Precommits []*Vote
+	}
+	height, round := 2, 0
+	voteSet, valSet, privValidators := randVoteSet(height, round, VoteTypePrecommit, 4, 1)
+	voteProto := &Vote{
+		ValidatorIndex: -1,
+		Height:         int64(height),
+		Round:          int64(round),
+		Type:           VoteTypePrecommit,
+		BlockID:        BlockID{crypto.CRandBytes(32), PartSetHeader{123, crypto.CRandBytes(32)}},
+	}
+	for i := 0; i < 3; i++ {
+		signAddVote(privValidators[i], withValidator(voteProto, privValidators[i].Address, i), voteSet)
+	}
+	commit := voteSet.MakeCommit()
+	if commit.Timestamps != nil {
+		t.Fatal("commit without vote timestamps should carry none")
+	}
+	if power := commit.TimestampedPower(valSet); power != 0 {
+		t.Errorf("commit without vote timestamps has timestamped power %v", power)
+	}
+
+	legacy := wire.BinaryBytes(legacyCommit{commit.BlockID, commit.Precommits})
+	if !bytes.Equal(wire.BinaryBytes(*commit), legacy) {
+		t.Fatal("commit encoding changed")
+	}
+	decoded := new(Commit)
+	if err := wire.ReadBinaryBytes(legacy, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded.Hash(), commit.Hash()) {
+		t.Error("legacy commit hash changed")
+	}
+}
+
+func TestSignVoteReusesTimestamp(t *testing.T) {
+	_, privVal := RandValidator(false, 1)
+	vote := &Vote{
+		ValidatorAddress: privVal.Address,
+		Height:           3,
+		Type:             VoteTypePrecommit,
+		Timestamp:        time.Unix(1500000000, 0),
+	}
+	if err := privVal.SignVote("test_chain_id", vote); err != nil {
+		t.Fatal(err)
+	}
+
+	again := vote.Copy()
+	again.Signature = nil
+	again.Timestamp = vote.Timestamp.Add(time.Second)
+	if err := privVal.SignVote("test_chain_id", again); err != nil {
+		t.Fatal(err)
+	}
+	if !again.Timestamp.Equal(vote.Timestamp) || !again.Signature.Equals(vote.Signature) {
+		t.Error("re-signed vote should reuse the last timestamp and signature")
+	}
+
+	conflicting := again.Copy()
+	conflicting.BlockID = BlockID{Hash: crypto.CRandBytes(32)}
+	if err := privVal.SignVote("test_chain_id", conflicting); err == nil {
+		t.Error("conflicting vote was signed")
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright © 2017 ZhongAn Technology
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sdk
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/dappledger/AnnChain/eth"
+	"github.com/dappledger/AnnChain/eth/accounts/abi/bind"
+	"github.com/dappledger/AnnChain/eth/common"
+	etypes "github.com/dappledger/AnnChain/eth/core/types"
+	"github.com/dappledger/AnnChain/eth/rlp"
+)
+
+var (
+	_ bind.ContractBackend       = (*Client)(nil)
+	_ bind.PendingContractCaller = (*Client)(nil)
+	_ bind.DeployBackend         = (*Client)(nil)
+)
+
+// CodeAt implements bind.ContractCaller, blockNumber has to be nil.
+func (c *Client) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	if blockNumber != nil {
+		return nil, ErrPastHeight
+	}
+	return c.GetCode(ctx, contract)
+}
+
+// CallContract implements bind.ContractCaller, blockNumber has to be nil.
+func (c *Client) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if blockNumber != nil {
+		return nil, ErrPastHeight
+	}
+	return c.Call(ctx, call)
+}
+
+// PendingCodeAt implements bind.ContractTransactor. There is no pending
+// state on AnnChain, committed blocks are final, so it is the latest state.
+func (c *Client) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	return c.GetCode(ctx, account)
+}
+
+// PendingCallContract implements bind.PendingContractCaller against the latest state.
+func (c *Client) PendingCallContract(ctx context.Context, call ethereum.CallMsg) ([]byte, error) {
+	return c.Call(ctx, call)
+}
+
+// PendingNonceAt implements bind.ContractTransactor. Txs still in the
+// mempool are not counted, senders issuing several txs per block have to
+// track their nonces themselves.
+func (c *Client) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return c.GetNonce(ctx, account)
+}
+
+// SuggestGasPrice implements bind.ContractTransactor, gas is free on AnnChain.
+func (c *Client) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return new(big.Int), nil
+}
+
+// SendTransaction implements bind.ContractTransactor.
+func (c *Client) SendTransaction(ctx context.Context, tx *etypes.Transaction) error {
+	raw, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return err
+	}
+	_, err = c.SendRawTx(ctx, raw)
+	return err
+}
+
+// TransactionReceipt implements bind.DeployBackend.
+func (c *Client) TransactionReceipt(ctx context.Context, txHash common.Hash) (*etypes.Receipt, error) {
+	return c.GetReceipt(ctx, txHash)
+}
+
+// FilterLogs implements bind.ContractFilterer by scanning the receipts of
+// every block in range, nil FromBlock means the first block and nil ToBlock the latest.
+func (c *Client) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]etypes.Log, error) {
+	if q.BlockHash != nil {
+		return nil, ErrBlockHash
+	}
+	last, err := c.LastHeight(ctx)
+	if err != nil {
+		return nil, err
+	}
+	from, to := int64(1), last
+	if q.FromBlock != nil && q.FromBlock.Int64() > from {
+		from = q.FromBlock.Int64()
+	}
+	if q.ToBlock != nil && q.ToBlock.Int64() < to {
+		to = q.ToBlock.Int64()
+	}
+	var logs []etypes.Log
+	for height := from; height <= to; height++ {
+		blockLogs, err := c.blockLogs(ctx, height, q)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, blockLogs...)
+	}
+	return logs, nil
+}
+
+// blockLogs returns the logs of the block at height that match q.
+func (c *Client) blockLogs(ctx context.Context, height int64, q ethereum.FilterQuery) ([]etypes.Log, error) {
+	res, err := c.GetBlock(ctx, height)
+	if err != nil {
+		return nil, err
+	}
+	if res.Pruned {
+		return nil, ErrBlockPruned
+	}
+	if res.Block == nil || res.Block.Data == nil {
+		return nil, nil
+	}
+	var logs []etypes.Log
+	for _, tx := range res.Block.Data.Txs {
+		receipt, err := c.GetReceipt(ctx, common.BytesToHash(tx.Hash()))
+		if err == ethereum.NotFound {
+			// invalid txs are not executed and leave no receipt
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, log := range receipt.Logs {
+			if matchLog(log, q) {
+				logs = append(logs, *log)
+			}
+		}
+	}
+	return logs, nil
+}
+
+// matchLog follows the eth_getLogs rules: any of the addresses, and per
+// topic position any of the given topics, an empty position matches all.
+func matchLog(log *etypes.Log, q ethereum.FilterQuery) bool {
+	if len(q.Addresses) > 0 {
+		found := false
+		for _, addr := range q.Addresses {
+			if addr == log.Address {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(q.Topics) > len(log.Topics) {
+		return false
+	}
+	for i, alternatives := range q.Topics {
+		if len(alternatives) == 0 {
+			continue
+		}
+		found := false
+		for _, topic := range alternatives {
+			if topic == log.Topics[i] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,359 @@
+// Copyright © 2017 ZhongAn Technology
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sdk is a typed Go client of an AnnChain node's rpc.
+//
+// Client wraps the json-rpc routes and the app query types so services don't
+// have to assemble params and query prefixes by hand, and it implements
+// bind.ContractBackend so that abigen bindings work against AnnChain directly:
+//
+//	client := sdk.NewClient("tcp://127.0.0.1:46657")
+//	token, err := NewToken(tokenAddr, client) // generated by abigen
+//	balance, err := token.BalanceOf(nil, owner)
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/dappledger/AnnChain/chain/types"
+	"github.com/dappledger/AnnChain/eth"
+	"github.com/dappledger/AnnChain/eth/common"
+	etypes "github.com/dappledger/AnnChain/eth/core/types"
+	"github.com/dappledger/AnnChain/eth/core/vm"
+	"github.com/dappledger/AnnChain/eth/rlp"
+	rpcclient "github.com/dappledger/AnnChain/gemmill/rpc/client"
+	gtypes "github.com/dappledger/AnnChain/gemmill/types"
+)
+
+const (
+	defaultRetries      = 3
+	defaultRetryBackoff = 200 * time.Millisecond
+	defaultPollInterval = time.Second
+
+	// defaultCallGas is used by calls without a gas limit, the node caps
+	// execution at its own EVM gas limit anyway
+	defaultCallGas = uint64(100000000)
+)
+
+var (
+	// ErrPastHeight is returned for calls at a given height, the node only
+	// simulates unsigned calls against the latest state.
+	ErrPastHeight = errors.New("sdk: calls at a past height are not supported")
+	// ErrBlockHash is returned for log filters by block hash.
+	ErrBlockHash = errors.New("sdk: filtering logs by block hash is not supported")
+	// ErrBlockPruned is returned when the node only kept the meta of a block.
+	ErrBlockPruned = errors.New("sdk: block was pruned by the node")
+)
+
+// Client talks to one node over json-rpc. It is safe for concurrent use.
+type Client struct {
+	rpc *rpcclient.ClientJSONRPC
+
+	retries      int
+	retryBackoff time.Duration
+	pollInterval time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithRetry sets how many times a read is retried on transport errors
+// and the delay before the first retry, which doubles afterwards.
+// Transactions are never resent.
+func WithRetry(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.retryBackoff = backoff
+	}
+}
+
+// WithPollInterval sets how often subscriptions poll the node for new blocks.
+func WithPollInterval(interval time.Duration) Option {
+	return func(c *Client) {
+		c.pollInterval = interval
+	}
+}
+
+// NewClient returns a client of the node listening on addr, e.g. "tcp://127.0.0.1:46657".
+func NewClient(addr string, opts ...Option) *Client {
+	c := &Client{
+		rpc:          rpcclient.NewClientJSONRPC(addr),
+		retries:      defaultRetries,
+		retryBackoff: defaultRetryBackoff,
+		pollInterval: defaultPollInterval,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// call invokes an idempotent rpc method, retrying on transport errors.
+func (c *Client) call(ctx context.Context, method string, params []interface{}, result interface{}) error {
+	backoff := c.retryBackoff
+	for attempt := 0; ; attempt++ {
+		_, err := c.rpc.CallContext(ctx, method, params, result)
+		if err == nil || attempt >= c.retries || !isTransportError(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func isTransportError(err error) bool {
+	switch err.(type) {
+	case *url.Error, net.Error:
+		return true
+	}
+	return false
+}
+
+// Query runs an app query of the given type, see chain/types QueryType_*.
+// A result with a non-OK code is returned as an error carrying its log.
+func (c *Client) Query(ctx context.Context, typ types.QueryType, load []byte) ([]byte, error) {
+	res := new(gtypes.ResultQuery)
+	query := append([]byte{typ}, load...)
+	if err := c.call(ctx, "query", []interface{}{query}, res); err != nil {
+		return nil, err
+	}
+	if res.Result.IsErr() {
+		return res.Result.Data, errors.New(res.Result.Log)
+	}
+	return res.Result.Data, nil
+}
+
+// Status returns the node's status.
+func (c *Client) Status(ctx context.Context) (*gtypes.ResultStatus, error) {
+	res := new(gtypes.ResultStatus)
+	if err := c.call(ctx, "status", nil, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// LastHeight returns the height of the latest committed block.
+func (c *Client) LastHeight(ctx context.Context) (int64, error) {
+	res := new(gtypes.ResultLastHeight)
+	if err := c.call(ctx, "last_height", nil, res); err != nil {
+		return 0, err
+	}
+	return res.LastHeight, nil
+}
+
+// GetBlock returns the block at height together with its meta.
+func (c *Client) GetBlock(ctx context.Context, height int64) (*gtypes.ResultBlock, error) {
+	res := new(gtypes.ResultBlock)
+	if err := c.call(ctx, "block", []interface{}{height}, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Commit returns the header of the block at height, 0 for the latest one,
+// with the precommits committing it.
+func (c *Client) Commit(ctx context.Context, height int64) (*gtypes.ResultCommit, error) {
+	res := new(gtypes.ResultCommit)
+	if err := c.call(ctx, "commit", []interface{}{height}, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Validators returns the validators that signed the block at height, 0 for
+// the ones signing the next block.
+func (c *Client) Validators(ctx context.Context, height int64) (*gtypes.ResultValidators, error) {
+	res := new(gtypes.ResultValidators)
+	if err := c.call(ctx, "validators", []interface{}{height}, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetNonce returns the nonce of addr in the latest state.
+func (c *Client) GetNonce(ctx context.Context, addr common.Address) (uint64, error) {
+	data, err := c.Query(ctx, types.QueryType_Nonce, addr.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	var nonce uint64
+	if err := rlp.DecodeBytes(data, &nonce); err != nil {
+		return 0, err
+	}
+	return nonce, nil
+}
+
+// GetCode returns the contract code at addr in the latest state.
+func (c *Client) GetCode(ctx context.Context, addr common.Address) ([]byte, error) {
+	return c.Query(ctx, types.QueryType_Code, addr.Bytes())
+}
+
+// GetPermissions returns the permission table in the latest state, only with
+// the permissions of addr if it is not nil.
+func (c *Client) GetPermissions(ctx context.Context, addr *common.Address) (*vm.Permissions, error) {
+	var load []byte
+	if addr != nil {
+		load = addr.Bytes()
+	}
+	data, err := c.Query(ctx, types.QueryType_Permissions, load)
+	if err != nil {
+		return nil, err
+	}
+	permissions := new(vm.Permissions)
+	if err := json.Unmarshal(data, permissions); err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+// GetFrozenAccounts returns the frozen accounts in the latest state with the
+// page of the events which froze or unfroze them selected by args, pass the
+// Next of the result as Start for the next page.
+func (c *Client) GetFrozenAccounts(ctx context.Context, args *types.FrozenArgs) (*types.FrozenAccounts, error) {
+	load, err := rlp.EncodeToBytes(args)
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.Query(ctx, types.QueryType_Frozen, load)
+	if err != nil {
+		return nil, err
+	}
+	frozen := new(types.FrozenAccounts)
+	if err := json.Unmarshal(data, frozen); err != nil {
+		return nil, err
+	}
+	return frozen, nil
+}
+
+// GetProof returns the proof of addr and its storage slots keys in the state
+// after the block at height, see the lite package for checking it.
+func (c *Client) GetProof(ctx context.Context, addr common.Address, keys []common.Hash, height uint64) (*types.AccountProof, error) {
+	load, err := rlp.EncodeToBytes(&types.ProofArgs{Address: addr, Keys: keys, Height: height})
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.Query(ctx, types.QueryType_Proof, load)
+	if err != nil {
+		return nil, err
+	}
+	proof := new(types.AccountProof)
+	if err := rlp.DecodeBytes(data, proof); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+// GetReceipt returns the receipt of a committed tx, or ethereum.NotFound.
+func (c *Client) GetReceipt(ctx context.Context, hash common.Hash) (*etypes.Receipt, error) {
+	data, err := c.Query(ctx, types.QueryType_Receipt, hash.Bytes())
+	if err != nil && (isTransportError(err) || ctx.Err() != nil) {
+		return nil, err
+	}
+	if err != nil || len(data) == 0 {
+		return nil, ethereum.NotFound
+	}
+	receipt := new(etypes.ReceiptForStorage)
+	if err := rlp.DecodeBytes(data, receipt); err != nil {
+		return nil, err
+	}
+	return (*etypes.Receipt)(receipt), nil
+}
+
+// GetTransaction returns a committed tx with its block, status and receipt.
+func (c *Client) GetTransaction(ctx context.Context, hash common.Hash) (*types.TxDetail, error) {
+	res := new(types.TxDetail)
+	if err := c.call(ctx, "transaction", []interface{}{hash.Bytes()}, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// SendRawTx hands a signed, rlp encoded tx to the node's mempool and returns its hash.
+func (c *Client) SendRawTx(ctx context.Context, raw []byte) (common.Hash, error) {
+	res := new(gtypes.ResultBroadcastTx)
+	if _, err := c.rpc.CallContext(ctx, "broadcast_tx_async", []interface{}{raw}, res); err != nil {
+		return common.Hash{}, err
+	}
+	return common.HexToHash(res.TxHash), nil
+}
+
+// SendRawTxCommit is SendRawTx but returns only after the tx has been committed.
+func (c *Client) SendRawTxCommit(ctx context.Context, raw []byte) (common.Hash, error) {
+	res := new(gtypes.ResultBroadcastTxCommit)
+	if _, err := c.rpc.CallContext(ctx, "broadcast_tx_commit", []interface{}{raw}, res); err != nil {
+		return common.Hash{}, err
+	}
+	return common.HexToHash(res.TxHash), nil
+}
+
+// Call executes msg against the latest state without committing it and returns its output,
+// a reverted call fails with the revert reason.
+func (c *Client) Call(ctx context.Context, msg ethereum.CallMsg) ([]byte, error) {
+	load, err := callArgs(msg)
+	if err != nil {
+		return nil, err
+	}
+	return c.Query(ctx, types.QueryType_Call, load)
+}
+
+// EstimateGas returns the lowest gas limit msg executes successfully with.
+func (c *Client) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	load, err := callArgs(msg)
+	if err != nil {
+		return 0, err
+	}
+	data, err := c.Query(ctx, types.QueryType_EstimateGas, load)
+	if err != nil {
+		return 0, err
+	}
+	var gas uint64
+	if err := rlp.DecodeBytes(data, &gas); err != nil {
+		return 0, err
+	}
+	return gas, nil
+}
+
+func callArgs(msg ethereum.CallMsg) ([]byte, error) {
+	gas := msg.Gas
+	if gas == 0 {
+		gas = defaultCallGas
+	}
+	value, gasPrice := msg.Value, msg.GasPrice
+	if value == nil {
+		value = common.Big0
+	}
+	if gasPrice == nil {
+		gasPrice = common.Big0
+	}
+	var tx *etypes.Transaction
+	if msg.To == nil {
+		tx = etypes.NewContractCreation(0, value, gas, gasPrice, msg.Data)
+	} else {
+		tx = etypes.NewTransaction(0, *msg.To, value, gas, gasPrice, msg.Data)
+	}
+	raw, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return nil, err
+	}
+	from := msg.From
+	return rlp.EncodeToBytes(&types.CallArgs{Tx: raw, From: &from})
+}
@@ -0,0 +1,186 @@
+// Copyright © 2017 ZhongAn Technology
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sdk
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dappledger/AnnChain/chain/types"
+	"github.com/dappledger/AnnChain/eth"
+	"github.com/dappledger/AnnChain/eth/common"
+	etypes "github.com/dappledger/AnnChain/eth/core/types"
+	"github.com/dappledger/AnnChain/eth/rlp"
+	rpcserver "github.com/dappledger/AnnChain/gemmill/rpc/server"
+	gtypes "github.com/dappledger/AnnChain/gemmill/types"
+)
+
+var (
+	testFrom     = common.HexToAddress("0x01")
+	testContract = common.HexToAddress("0x02")
+)
+
+// testQuery plays the app's side of the query route
+func testQuery(query []byte) (*gtypes.ResultQuery, error) {
+	load := query[1:]
+	switch query[0] {
+	case types.QueryType_Nonce:
+		data, _ := rlp.EncodeToBytes(uint64(7))
+		return &gtypes.ResultQuery{Result: gtypes.NewResultOK(data, "")}, nil
+	case types.QueryType_Call:
+		args := new(types.CallArgs)
+		if err := rlp.DecodeBytes(load, args); err != nil {
+			return nil, err
+		}
+		tx := new(etypes.Transaction)
+		if err := rlp.DecodeBytes(args.Tx, tx); err != nil {
+			return nil, err
+		}
+		if args.From == nil || *args.From != testFrom || *tx.To() != testContract {
+			return &gtypes.ResultQuery{Result: gtypes.NewError(gtypes.CodeType_BaseInvalidInput, "bad call")}, nil
+		}
+		if len(tx.Data()) == 0 {
+			return &gtypes.ResultQuery{Result: gtypes.NewResult(gtypes.CodeType_InvalidTx, nil, "execution reverted: empty input")}, nil
+		}
+		return &gtypes.ResultQuery{Result: gtypes.NewResultOK(tx.Data(), "")}, nil
+	case types.QueryType_Receipt:
+		return &gtypes.ResultQuery{Result: gtypes.NewError(gtypes.CodeType_InternalError, "fail to get receipt")}, nil
+	}
+	return &gtypes.ResultQuery{Result: gtypes.NewError(gtypes.CodeType_BaseInvalidInput, "unimplemented query")}, nil
+}
+
+func newTestServer(t *testing.T, failFirst int32) (*httptest.Server, *int32) {
+	mux := http.NewServeMux()
+	rpcserver.RegisterRPCFuncs(mux, map[string]*rpcserver.RPCFunc{
+		"query": rpcserver.NewRPCFunc(testQuery, "query"),
+	})
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= failFirst {
+			// drop the connection to simulate a flaky network
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn.Close()
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	return server, &requests
+}
+
+func testClient(server *httptest.Server, opts ...Option) *Client {
+	return NewClient("tcp://"+strings.TrimPrefix(server.URL, "http://"), opts...)
+}
+
+func TestClientQueries(t *testing.T) {
+	server, _ := newTestServer(t, 0)
+	defer server.Close()
+	client := testClient(server)
+	ctx := context.Background()
+
+	nonce, err := client.GetNonce(ctx, testFrom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nonce != 7 {
+		t.Fatalf("expected nonce 7, got %d", nonce)
+	}
+
+	to := testContract
+	out, err := client.CallContract(ctx, ethereum.CallMsg{From: testFrom, To: &to, Data: []byte{0xca, 0xfe}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if common.Bytes2Hex(out) != "cafe" {
+		t.Fatalf("unexpected call output %x", out)
+	}
+	if _, err := client.CallContract(ctx, ethereum.CallMsg{From: testFrom, To: &to}, nil); err == nil || err.Error() != "execution reverted: empty input" {
+		t.Fatalf("expected revert reason, got %v", err)
+	}
+	if _, err := client.CallContract(ctx, ethereum.CallMsg{From: testFrom, To: &to}, big.NewInt(1)); err != ErrPastHeight {
+		t.Fatalf("expected ErrPastHeight, got %v", err)
+	}
+	if _, err := client.TransactionReceipt(ctx, common.Hash{}); err != ethereum.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+}
+
+func TestClientRetry(t *testing.T) {
+	server, requests := newTestServer(t, 2)
+	defer server.Close()
+
+	client := testClient(server, WithRetry(2, time.Millisecond))
+	if _, err := client.GetNonce(context.Background(), testFrom); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(requests); n != 3 {
+		t.Fatalf("expected 3 requests, got %d", n)
+	}
+
+	atomic.StoreInt32(requests, -1)
+	client = testClient(server, WithRetry(0, time.Millisecond))
+	if _, err := client.GetNonce(context.Background(), testFrom); !isTransportError(err) {
+		t.Fatalf("expected a transport error without retries, got %v", err)
+	}
+}
+
+func TestClientCanceled(t *testing.T) {
+	server, _ := newTestServer(t, 1)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client := testClient(server, WithRetry(5, time.Hour))
+	if _, err := client.GetNonce(ctx, testFrom); err == nil {
+		t.Fatal("expected the canceled context to abort the call")
+	}
+}
+
+func TestMatchLog(t *testing.T) {
+	topicA, topicB := common.HexToHash("0xa"), common.HexToHash("0xb")
+	log := &etypes.Log{Address: testContract, Topics: []common.Hash{topicA, topicB}}
+
+	cases := []struct {
+		q     ethereum.FilterQuery
+		match bool
+	}{
+		{ethereum.FilterQuery{}, true},
+		{ethereum.FilterQuery{Addresses: []common.Address{testFrom, testContract}}, true},
+		{ethereum.FilterQuery{Addresses: []common.Address{testFrom}}, false},
+		{ethereum.FilterQuery{Topics: [][]common.Hash{{topicA}}}, true},
+		{ethereum.FilterQuery{Topics: [][]common.Hash{nil, {topicA, topicB}}}, true},
+		{ethereum.FilterQuery{Topics: [][]common.Hash{{topicB}}}, false},
+		{ethereum.FilterQuery{Topics: [][]common.Hash{nil, nil, nil}}, false},
+	}
+	for i, c := range cases {
+		if matchLog(log, c.q) != c.match {
+			t.Errorf("case %d: expected match %v", i, c.match)
+		}
+	}
+}
+
+func TestIsTransportError(t *testing.T) {
+	if isTransportError(errors.New("Response error: boom")) {
+		t.Fatal("rpc errors must not be retried")
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright © 2017 ZhongAn Technology
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lite verifies headers and state of an AnnChain chain against a
+// trusted header, without running a node or trusting the one it talks to.
+//
+// Starting from a header and the validator set that signed it, obtained out
+// of band, a Client fetches later headers with their commits and validator
+// sets and accepts one once validators holding more than 1/3 of the power of
+// a verified set signed it, bisecting when the set changed too much in
+// between. Account and storage proofs are then checked against the AppHash,
+// the state root, of a verified header:
+//
+//	lc, err := lite.NewClient(chainID, header, vals, sdk.NewClient("tcp://127.0.0.1:46657"))
+//	account, err := lc.VerifyAccount(ctx, addr, []common.Hash{slot}, 0)
+//
+// The trusted header should be recent, validators that left the set long ago
+// could otherwise sign a fork nobody punishes them for.
+package lite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/dappledger/AnnChain/chain/types"
+	"github.com/dappledger/AnnChain/eth/common"
+	gtypes "github.com/dappledger/AnnChain/gemmill/types"
+)
+
+// maxVerifiedHeaders caps the verified headers kept to answer again
+const maxVerifiedHeaders = 1000
+
+var (
+	// ErrValidatorsHash is returned when a validator set doesn't hash to the
+	// ValidatorsHash of the header it is said to sign.
+	ErrValidatorsHash = errors.New("lite: validator set doesn't match the header")
+	// ErrChainID is returned for headers of another chain.
+	ErrChainID = errors.New("lite: header of another chain")
+)
+
+// Provider serves what the light client verifies, *sdk.Client is one.
+// Nothing it returns is trusted.
+type Provider interface {
+	Commit(ctx context.Context, height int64) (*gtypes.ResultCommit, error)
+	Validators(ctx context.Context, height int64) (*gtypes.ResultValidators, error)
+	GetProof(ctx context.Context, addr common.Address, keys []common.Hash, height uint64) (*types.AccountProof, error)
+}
+
+// SignedHeader is a header with the commit and the validator set signing it.
+type SignedHeader struct {
+	Header     *gtypes.Header
+	Commit     *gtypes.Commit
+	Validators *gtypes.ValidatorSet
+}
+
+// Client verifies headers from its provider. It is safe for concurrent use,
+// verifications run one at a time.
+type Client struct {
+	chainID  string
+	provider Provider
+
+	mtx      sync.Mutex
+	latest   *SignedHeader // the highest verified header
+	verified map[int64]*gtypes.Header
+}
+
+// NewClient returns a client trusting header and vals, the set that signed it.
+func NewClient(chainID string, header *gtypes.Header, vals *gtypes.ValidatorSet, provider Provider) (*Client, error) {
+	if header.ChainID != chainID {
+		return nil, ErrChainID
+	}
+	if !bytes.Equal(vals.Hash(), header.ValidatorsHash) {
+		return nil, ErrValidatorsHash
+	}
+	return &Client{
+		chainID:  chainID,
+		provider: provider,
+		latest:   &SignedHeader{Header: header, Validators: vals},
+		verified: map[int64]*gtypes.Header{header.Height: header},
+	}, nil
+}
+
+// TrustedHeight returns the height of the highest verified header.
+func (c *Client) TrustedHeight() int64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.latest.Header.Height
+}
+
+// VerifyHeader returns the header at height, 0 for the latest one, once it is
+// verified. Headers above the highest verified one are checked by their
+// commits, lower ones by the hash chain down from a verified header.
+func (c *Client) VerifyHeader(ctx context.Context, height int64) (*gtypes.Header, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if height == 0 {
+		res, err := c.provider.Commit(ctx, 0)
+		if err != nil {
+			return nil, err
+		}
+		if res.Header == nil {
+			return nil, errors.New("lite: no latest header")
+		}
+		height = res.Header.Height
+	}
+	if header, ok := c.verified[height]; ok {
+		return header, nil
+	}
+	if height < c.latest.Header.Height {
+		return c.verifyBackwards(ctx, height)
+	}
+
+	target, err := c.fetch(ctx, height)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.verifySkipping(ctx, c.latest, target); err != nil {
+		return nil, err
+	}
+	c.remember(target.Header)
+	c.latest = target
+	return target.Header, nil
+}
+
+// fetch gets the header at height and checks that the validator set it names
+// committed it, which says nothing yet about whether that set is the real one
+func (c *Client) fetch(ctx context.Context, height int64) (*SignedHeader, error) {
+	res, err := c.provider.Commit(ctx, height)
+	if err != nil {
+		return nil, err
+	}
+	if res.Header == nil || res.Commit == nil || res.Header.Height != height {
+		return nil, fmt.Errorf("lite: no commit for height %d", height)
+	}
+	if res.Header.ChainID != c.chainID {
+		return nil, ErrChainID
+	}
+	vres, err := c.provider.Validators(ctx, height)
+	if err != nil {
+		return nil, err
+	}
+	if vres.ValidatorSet == nil || !bytes.Equal(vres.ValidatorSet.Hash(), res.Header.ValidatorsHash) {
+		return nil, ErrValidatorsHash
+	}
+	sh := &SignedHeader{Header: res.Header, Commit: res.Commit, Validators: vres.ValidatorSet}
+	blockID, err := sh.blockID()
+	if err != nil {
+		return nil, err
+	}
+	if err := sh.Validators.VerifyCommit(c.chainID, blockID, height, sh.Commit); err != nil {
+		return nil, fmt.Errorf("lite: header %d: %v", height, err)
+	}
+	return sh, nil
+}
+
+func (sh *SignedHeader) blockID() (gtypes.BlockID, error) {
+	first := sh.Commit.FirstPrecommit()
+	if first == nil {
+		return gtypes.BlockID{}, fmt.Errorf("lite: empty commit for height %d", sh.Header.Height)
+	}
+	return gtypes.BlockID{Hash: sh.Header.Hash(), PartsHeader: first.BlockID.PartsHeader}, nil
+}
+
+// verifySkipping accepts target when validators of trusted holding more than
+// 1/3 of its power signed it, else it verifies the height halfway first
+func (c *Client) verifySkipping(ctx context.Context, trusted, target *SignedHeader) error {
+	height := target.Header.Height
+	adjacent := height == trusted.Header.Height+1
+	if adjacent && !bytes.Equal(target.Header.LastBlockID.Hash, trusted.Header.Hash()) {
+		return fmt.Errorf("lite: header %d doesn't follow the trusted header", height)
+	}
+	blockID, err := target.blockID()
+	if err != nil {
+		return err
+	}
+	err = trusted.Validators.VerifyCommitTrusting(c.chainID, blockID, height, target.Commit)
+	if err == nil {
+		return nil
+	}
+	if adjacent {
+		return fmt.Errorf("lite: validators changed too much at height %d: %v", height, err)
+	}
+
+	pivot, err := c.fetch(ctx, (trusted.Header.Height+height)/2)
+	if err != nil {
+		return err
+	}
+	if err := c.verifySkipping(ctx, trusted, pivot); err != nil {
+		return err
+	}
+	c.remember(pivot.Header)
+	return c.verifySkipping(ctx, pivot, target)
+}
+
+// verifyBackwards follows LastBlockID down from the closest verified header
+// above height
+func (c *Client) verifyBackwards(ctx context.Context, height int64) (*gtypes.Header, error) {
+	next := c.latest.Header
+	for h, header := range c.verified {
+		if h > height && h < next.Height {
+			next = header
+		}
+	}
+	for h := next.Height - 1; h >= height; h-- {
+		res, err := c.provider.Commit(ctx, h)
+		if err != nil {
+			return nil, err
+		}
+		if res.Header == nil || res.Header.Height != h || !bytes.Equal(res.Header.Hash(), next.LastBlockID.Hash) {
+			return nil, fmt.Errorf("lite: header %d doesn't match the verified header %d", h, next.Height)
+		}
+		next = res.Header
+	}
+	c.remember(next)
+	return next, nil
+}
+
+func (c *Client) remember(header *gtypes.Header) {
+	if len(c.verified) >= maxVerifiedHeaders {
+		for h := range c.verified {
+			if h != c.latest.Header.Height {
+				delete(c.verified, h)
+				break
+			}
+		}
+	}
+	c.verified[header.Height] = header
+}
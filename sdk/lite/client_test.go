@@ -0,0 +1,218 @@
+// Copyright © 2017 ZhongAn Technology
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lite
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/dappledger/AnnChain/chain/types"
+	"github.com/dappledger/AnnChain/eth/common"
+	estate "github.com/dappledger/AnnChain/eth/core/state"
+	"github.com/dappledger/AnnChain/eth/ethdb"
+	gtypes "github.com/dappledger/AnnChain/gemmill/types"
+)
+
+const testChainID = "test-chain"
+
+type testProvider struct {
+	commits    map[int64]*gtypes.ResultCommit
+	validators map[int64]*gtypes.ValidatorSet
+	state      *estate.StateDB
+}
+
+func (p *testProvider) Commit(ctx context.Context, height int64) (*gtypes.ResultCommit, error) {
+	if height == 0 {
+		height = int64(len(p.commits))
+	}
+	if res, ok := p.commits[height]; ok {
+		return res, nil
+	}
+	return nil, fmt.Errorf("no commit for %d", height)
+}
+
+func (p *testProvider) Validators(ctx context.Context, height int64) (*gtypes.ResultValidators, error) {
+	return &gtypes.ResultValidators{BlockHeight: height, ValidatorSet: p.validators[height]}, nil
+}
+
+func (p *testProvider) GetProof(ctx context.Context, addr common.Address, keys []common.Hash, height uint64) (*types.AccountProof, error) {
+	proof := &types.AccountProof{Address: addr, Height: height}
+	proof.AccountProof, _ = p.state.GetProof(addr)
+	for _, key := range keys {
+		sp := types.StorageProof{Key: key}
+		sp.Proof, _ = p.state.GetStorageProof(addr, key)
+		proof.StorageProof = append(proof.StorageProof, sp)
+	}
+	return proof, nil
+}
+
+// makeChain commits n headers, the ones from height on are signed by the
+// validators of sets[i] where height is the i-th of changes
+func makeChain(t *testing.T, n int64, changes []int64, sets [][]int, appHash []byte) *testProvider {
+	vals := make([]*gtypes.Validator, 8)
+	privs := make(map[string]*gtypes.PrivValidator)
+	for i := range vals {
+		val, priv := gtypes.RandValidator(false, 1)
+		vals[i] = val
+		privs[string(val.Address)] = priv
+	}
+
+	p := &testProvider{commits: make(map[int64]*gtypes.ResultCommit), validators: make(map[int64]*gtypes.ValidatorSet)}
+	var (
+		valSet      *gtypes.ValidatorSet
+		lastBlockID gtypes.BlockID
+	)
+	for h := int64(1); h <= n; h++ {
+		for i, c := range changes {
+			if c == h {
+				members := make([]*gtypes.Validator, 0, len(sets[i]))
+				for _, idx := range sets[i] {
+					members = append(members, vals[idx])
+				}
+				valSet = gtypes.NewValidatorSet(members)
+			}
+		}
+		header := &gtypes.Header{
+			ChainID:        testChainID,
+			Height:         h,
+			Time:           time.Unix(h, 0),
+			LastBlockID:    lastBlockID,
+			ValidatorsHash: valSet.Hash(),
+			AppHash:        appHash,
+		}
+		lastBlockID = gtypes.BlockID{Hash: header.Hash(), PartsHeader: gtypes.PartSetHeader{Total: 1, Hash: []byte("parts")}}
+
+		voteSet := gtypes.NewVoteSet(testChainID, h, 0, gtypes.VoteTypePrecommit, valSet)
+		for i, val := range valSet.Validators {
+			vote := &gtypes.Vote{
+				ValidatorAddress: val.Address,
+				ValidatorIndex:   i,
+				Height:           h,
+				Type:             gtypes.VoteTypePrecommit,
+				BlockID:          lastBlockID,
+			}
+			vote.Signature = privs[string(val.Address)].GetPrivKey().Sign(gtypes.SignBytes(testChainID, vote))
+			if _, err := voteSet.AddVote(vote); err != nil {
+				t.Fatal(err)
+			}
+		}
+		p.commits[h] = &gtypes.ResultCommit{Header: header, Commit: voteSet.MakeCommit(), Canonical: h < n}
+		p.validators[h] = valSet
+	}
+	return p
+}
+
+func TestVerifyHeaderSkipping(t *testing.T) {
+	// half of the set is replaced at 5 and the rest at 9, so 12 can't be
+	// verified from 1 directly
+	p := makeChain(t, 12, []int64{1, 5, 9}, [][]int{{0, 1, 2, 3}, {2, 3, 4, 5}, {4, 5, 6, 7}}, nil)
+	lc, err := NewClient(testChainID, p.commits[1].Header, p.validators[1], p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header, err := lc.VerifyHeader(context.Background(), 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Height != 12 || lc.TrustedHeight() != 12 {
+		t.Fatalf("expected to trust height 12, got header %d and trusted %d", header.Height, lc.TrustedHeight())
+	}
+	if _, ok := lc.verified[6]; !ok {
+		t.Fatal("expected the pivot at 6 to be verified")
+	}
+
+	// lower headers follow the hash chain
+	header, err = lc.VerifyHeader(context.Background(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(header.Hash()) != string(p.commits[3].Header.Hash()) {
+		t.Fatal("got another header at 3")
+	}
+}
+
+func TestVerifyHeaderRejectsForks(t *testing.T) {
+	p := makeChain(t, 4, []int64{1}, [][]int{{0, 1, 2, 3}}, nil)
+	// the same heights signed by validators the client doesn't know
+	fork := makeChain(t, 4, []int64{1}, [][]int{{0, 1, 2, 3}}, nil)
+
+	lc, err := NewClient(testChainID, p.commits[1].Header, p.validators[1], fork)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lc.VerifyHeader(context.Background(), 4); err == nil {
+		t.Fatal("expected a header of unknown validators to be rejected")
+	}
+
+	fork.validators[4] = p.validators[4]
+	if _, err := lc.VerifyHeader(context.Background(), 4); err != ErrValidatorsHash {
+		t.Fatalf("expected %v, got %v", ErrValidatorsHash, err)
+	}
+	if _, err := NewClient(testChainID, p.commits[1].Header, fork.validators[1], p); err != ErrValidatorsHash {
+		t.Fatalf("expected %v, got %v", ErrValidatorsHash, err)
+	}
+}
+
+func TestVerifyAccount(t *testing.T) {
+	var (
+		addr    = common.HexToAddress("0x0a")
+		missing = common.HexToAddress("0x0b")
+		slot    = common.HexToHash("0x01")
+		empty   = common.HexToHash("0x02")
+	)
+	statedb, _ := estate.New(common.Hash{}, estate.NewDatabase(ethdb.NewMemDatabase()))
+	statedb.SetBalance(addr, big.NewInt(100))
+	statedb.SetNonce(addr, 3)
+	statedb.SetState(addr, slot, common.HexToHash("0x2a"))
+	root, err := statedb.Commit(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := makeChain(t, 3, []int64{1}, [][]int{{0, 1, 2}}, root.Bytes())
+	p.state = statedb
+	lc, err := NewClient(testChainID, p.commits[1].Header, p.validators[1], p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account, err := lc.VerifyAccount(context.Background(), addr, []common.Hash{slot, empty}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !account.Exists || account.Height != 2 || account.Nonce != 3 || account.Balance.Int64() != 100 {
+		t.Fatalf("unexpected account %+v", account)
+	}
+	if account.Storage[slot] != common.HexToHash("0x2a") || account.Storage[empty] != (common.Hash{}) {
+		t.Fatalf("unexpected storage %v", account.Storage)
+	}
+
+	account, err = lc.VerifyAccount(context.Background(), missing, []common.Hash{slot}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Exists || account.Balance.Sign() != 0 || account.Storage[slot] != (common.Hash{}) {
+		t.Fatalf("expected a missing account, got %+v", account)
+	}
+
+	// a proof against another root fails
+	proof, _ := p.GetProof(context.Background(), addr, nil, 1)
+	if _, err := VerifyAccountProof(common.HexToHash("0x01"), proof, nil); err == nil {
+		t.Fatal("expected the proof to fail against another root")
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright © 2017 ZhongAn Technology
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/dappledger/AnnChain/chain/types"
+	"github.com/dappledger/AnnChain/eth/common"
+	estate "github.com/dappledger/AnnChain/eth/core/state"
+	etypes "github.com/dappledger/AnnChain/eth/core/types"
+	"github.com/dappledger/AnnChain/eth/crypto"
+	"github.com/dappledger/AnnChain/eth/ethdb"
+	"github.com/dappledger/AnnChain/eth/rlp"
+	"github.com/dappledger/AnnChain/eth/trie"
+)
+
+// Account is the state of an account after the block at Height, a missing
+// account and missing slots come out zero.
+type Account struct {
+	Address     common.Address
+	Height      uint64
+	Exists      bool
+	Nonce       uint64
+	Balance     *big.Int
+	CodeHash    []byte
+	StorageRoot common.Hash
+	Storage     map[common.Hash]common.Hash
+}
+
+// VerifyAccount returns addr and its storage slots keys in the state after
+// the block at height, checked against the AppHash of the verified header at
+// height+1. Height 0 means the latest state a header carries the root of.
+func (c *Client) VerifyAccount(ctx context.Context, addr common.Address, keys []common.Hash, height uint64) (*Account, error) {
+	var anchor int64
+	if height > 0 {
+		anchor = int64(height) + 1
+	}
+	header, err := c.VerifyHeader(ctx, anchor)
+	if err != nil {
+		return nil, err
+	}
+	if height == 0 {
+		if header.Height < 2 {
+			return nil, errors.New("lite: no state to prove yet")
+		}
+		height = uint64(header.Height - 1)
+	}
+
+	proof, err := c.provider.GetProof(ctx, addr, keys, height)
+	if err != nil {
+		return nil, err
+	}
+	if proof.Address != addr || proof.Height != height {
+		return nil, fmt.Errorf("lite: got the proof of %x at %d", proof.Address, proof.Height)
+	}
+	root := etypes.EmptyRootHash
+	if len(header.AppHash) > 0 {
+		root = common.BytesToHash(header.AppHash)
+	}
+	return VerifyAccountProof(root, proof, keys)
+}
+
+// VerifyAccountProof checks proof against the state root and returns the
+// account with the values of its storage slots keys.
+func VerifyAccountProof(root common.Hash, proof *types.AccountProof, keys []common.Hash) (*Account, error) {
+	value, err := verifyProof(root, proof.Address.Bytes(), proof.AccountProof)
+	if err != nil {
+		return nil, fmt.Errorf("lite: account %x: %v", proof.Address, err)
+	}
+	account := &Account{
+		Address:     proof.Address,
+		Height:      proof.Height,
+		Balance:     new(big.Int),
+		StorageRoot: etypes.EmptyRootHash,
+		Storage:     make(map[common.Hash]common.Hash, len(keys)),
+	}
+	if value != nil {
+		var data estate.Account
+		if err := rlp.DecodeBytes(value, &data); err != nil {
+			return nil, fmt.Errorf("lite: account %x: %v", proof.Address, err)
+		}
+		account.Exists = true
+		account.Nonce = data.Nonce
+		account.Balance = data.Balance
+		account.CodeHash = data.CodeHash
+		account.StorageRoot = data.Root
+	}
+
+	if len(proof.StorageProof) != len(keys) {
+		return nil, fmt.Errorf("lite: got %d storage proofs for %d keys", len(proof.StorageProof), len(keys))
+	}
+	for i, key := range keys {
+		sp := proof.StorageProof[i]
+		if sp.Key != key {
+			return nil, fmt.Errorf("lite: got the storage proof of %x for %x", sp.Key, key)
+		}
+		var slot common.Hash
+		if account.StorageRoot != etypes.EmptyRootHash {
+			value, err := verifyProof(account.StorageRoot, key.Bytes(), sp.Proof)
+			if err != nil {
+				return nil, fmt.Errorf("lite: storage %x: %v", key, err)
+			}
+			if value != nil {
+				_, content, _, err := rlp.Split(value)
+				if err != nil {
+					return nil, fmt.Errorf("lite: storage %x: %v", key, err)
+				}
+				slot = common.BytesToHash(content)
+			}
+		}
+		account.Storage[key] = slot
+	}
+	return account, nil
+}
+
+// verifyProof returns the value at the secure trie key, nil when proven missing
+func verifyProof(root common.Hash, key []byte, nodes [][]byte) ([]byte, error) {
+	db := ethdb.NewMemDatabase()
+	for _, node := range nodes {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	value, _, err := trie.VerifyProof(root, crypto.Keccak256(key), db)
+	return value, err
+}
@@ -0,0 +1,111 @@
+// Copyright © 2017 ZhongAn Technology
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sdk
+
+import (
+	"context"
+	"time"
+
+	"github.com/dappledger/AnnChain/eth"
+	etypes "github.com/dappledger/AnnChain/eth/core/types"
+	"github.com/dappledger/AnnChain/eth/event"
+	gtypes "github.com/dappledger/AnnChain/gemmill/types"
+)
+
+// SubscribeNewBlock delivers every block committed after the call on ch.
+// The node has no push channel, so new blocks are polled for.
+func (c *Client) SubscribeNewBlock(ctx context.Context, ch chan<- *gtypes.ResultBlock) (ethereum.Subscription, error) {
+	last, err := c.LastHeight(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.poll(last+1, func(ctx context.Context, quit <-chan struct{}, height int64) error {
+		block, err := c.GetBlock(ctx, height)
+		if err != nil {
+			return err
+		}
+		select {
+		case ch <- block:
+			return nil
+		case <-quit:
+			return nil
+		}
+	}), nil
+}
+
+// SubscribeFilterLogs implements bind.ContractFilterer, the logs of every
+// block committed after the call that match q are delivered on ch.
+func (c *Client) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- etypes.Log) (ethereum.Subscription, error) {
+	if q.BlockHash != nil {
+		return nil, ErrBlockHash
+	}
+	last, err := c.LastHeight(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.poll(last+1, func(ctx context.Context, quit <-chan struct{}, height int64) error {
+		logs, err := c.blockLogs(ctx, height, q)
+		if err != nil {
+			return err
+		}
+		for _, log := range logs {
+			select {
+			case ch <- log:
+			case <-quit:
+				return nil
+			}
+		}
+		return nil
+	}), nil
+}
+
+// poll hands every height from next on to handle as blocks get committed,
+// until the subscription is unsubscribed or a request fails.
+func (c *Client) poll(next int64, handle func(ctx context.Context, quit <-chan struct{}, height int64) error) ethereum.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			select {
+			case <-quit:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		ticker := time.NewTicker(c.pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-quit:
+				return nil
+			case <-ticker.C:
+			}
+			last, err := c.LastHeight(ctx)
+			if err != nil {
+				return err
+			}
+			for ; next <= last; next++ {
+				if err := handle(ctx, quit, next); err != nil {
+					return err
+				}
+				select {
+				case <-quit:
+					return nil
+				default:
+				}
+			}
+		}
+	})
+}